@@ -0,0 +1,122 @@
+package calendars
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/labstack/echo"
+)
+
+// Setting is an environment variable a backend reads its configuration from
+type Setting struct {
+	Name        string
+	Description string
+	Required    bool
+
+	// Secret settings are never printed
+	Secret bool
+}
+
+// Backend is a calendar provider that a calendar server can run
+type Backend struct {
+	// Name is what the backend is selected by, like exchange or gsuite
+	Name        string
+	Description string
+
+	// DefaultPort is the port the backend's server has historically run on
+	DefaultPort int
+
+	// ReadOnly is true for backends whose calendars can't be booked
+	ReadOnly bool
+
+	Settings []Setting
+	Create   CreateCalendarFunc
+
+	// Admin serves the backend's admin endpoints, if it has any. Servers mount it at /admin/backends/<name>.
+	Admin http.Handler
+}
+
+var (
+	backendsMu sync.RWMutex
+	backends   = make(map[string]Backend)
+)
+
+// Register makes a backend available by name. It is meant to be called from a provider's init function,
+// and panics if the backend is invalid or a backend with the same name is already registered.
+func Register(b Backend) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+
+	switch {
+	case len(b.Name) == 0:
+		panic("calendars: Register backend without a name")
+	case b.Create == nil:
+		panic(fmt.Sprintf("calendars: Register backend %q without a create func", b.Name))
+	}
+
+	if _, ok := backends[b.Name]; ok {
+		panic(fmt.Sprintf("calendars: Register called twice for backend %q", b.Name))
+	}
+
+	backends[b.Name] = b
+}
+
+// LookupBackend returns the backend registered with name
+func LookupBackend(name string) (Backend, bool) {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+
+	b, ok := backends[name]
+	return b, ok
+}
+
+// Backends returns every registered backend, sorted by name
+func Backends() []Backend {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+
+	list := make([]Backend, 0, len(backends))
+	for _, b := range backends {
+		list = append(list, b)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+
+	return list
+}
+
+// Missing returns the names of the backend's required settings that aren't set
+func (b Backend) Missing() []string {
+	var missing []string
+	for _, s := range b.Settings {
+		if s.Required && len(os.Getenv(s.Name)) == 0 {
+			missing = append(missing, s.Name)
+		}
+	}
+
+	return missing
+}
+
+// WithBackendAdmin serves the admin endpoints of the backends that have them, at /admin/backends/<name>.
+// Calendar servers don't authenticate requests, so they should only be reachable by the scheduler and admins.
+func WithBackendAdmin(backends ...Backend) ServerOption {
+	return func(o *serverOptions) {
+		o.admin = append(o.admin, backends...)
+	}
+}
+
+func addBackendAdminRoutes(e *echo.Echo, backends []Backend) {
+	for _, b := range backends {
+		if b.Admin == nil {
+			continue
+		}
+
+		prefix := "/admin/backends/" + b.Name
+		e.Match(backendAdminMethods, prefix+"/*", echo.WrapHandler(http.StripPrefix(prefix, b.Admin)))
+	}
+}
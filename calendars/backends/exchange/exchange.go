@@ -0,0 +1,57 @@
+//go:build providers
+
+// Package exchange registers the exchange calendar backend. Import it for its side effects,
+// into the calendar server or the scheduler (for inproc://exchange/<roomID> calendar urls).
+// It is only built with -tags providers.
+package exchange
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+
+	provider "github.com/byuoitav/exchange-calendar"
+	"github.com/byuoitav/scheduler/calendars"
+)
+
+func init() {
+	calendars.Register(calendars.Backend{
+		Name:        "exchange",
+		Description: "Exchange room resources, through Azure AD. Room IDs look like JET_1106@calendar.com",
+		DefaultPort: 11002,
+		Settings: []calendars.Setting{
+			{Name: "AZURE_AD_CLIENT_ID", Description: "azure ad application id", Required: true},
+			{Name: "AZURE_AD_CLIENT_SECRET", Description: "azure ad application secret", Required: true, Secret: true},
+			{Name: "AZURE_AD_TENNANT_ID", Description: "azure ad tenant id", Required: true},
+		},
+		Create: createExchange,
+	})
+}
+
+func createExchange(ctx context.Context, roomID string) (calendars.Calendar, error) {
+	// Separate roomID from resource
+	idSlice := strings.Split(roomID, "@")
+	cal := &provider.Calendar{
+		ClientId:     os.Getenv("AZURE_AD_CLIENT_ID"),
+		ClientSecret: os.Getenv("AZURE_AD_CLIENT_SECRET"),
+		TennantId:    os.Getenv("AZURE_AD_TENNANT_ID"),
+		RoomID:       idSlice[0],
+		RoomResource: roomID,
+	}
+
+	switch {
+	case len(cal.ClientId) == 0:
+		return nil, errors.New("AZURE_AD_CLIENT_ID not set")
+	case len(cal.ClientSecret) == 0:
+		return nil, errors.New("AZURE_AD_CLIENT_SECRET not set")
+	case len(cal.TennantId) == 0:
+		return nil, errors.New("AZURE_AD_TENNANT_ID not set")
+	case len(cal.RoomID) == 0:
+		return nil, errors.New("roomID must be set")
+	case len(cal.RoomResource) == 0:
+		return nil, errors.New("RoomResource must be set")
+	}
+
+	return cal, nil
+}
@@ -0,0 +1,53 @@
+//go:build providers
+
+// Package gsuite registers the gsuite calendar backend. Import it for its side effects.
+// It is only built with -tags providers.
+package gsuite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+
+	provider "github.com/byuoitav/gsuite-calendar"
+	"github.com/byuoitav/scheduler/calendars"
+)
+
+func init() {
+	calendars.Register(calendars.Backend{
+		Name:        "gsuite",
+		Description: "Google Workspace calendars, through a service account. Room IDs are url encoded calendar ids",
+		DefaultPort: 11001,
+		Settings: []calendars.Setting{
+			{Name: "G_SUITE_EMAIL", Description: "user the service account acts as", Required: true},
+			{Name: "G_SUITE_CREDENTIALS", Description: "path to the service account's credentials file", Required: true},
+		},
+		Create: createGSuite,
+	})
+}
+
+func createGSuite(ctx context.Context, roomID string) (calendars.Calendar, error) {
+	urlDecodedRoomID, err := url.QueryUnescape(roomID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid roomID %q: %w", roomID, err)
+	}
+
+	cal := &provider.Calendar{
+		UserEmail:       os.Getenv("G_SUITE_EMAIL"),
+		CredentialsPath: os.Getenv("G_SUITE_CREDENTIALS"),
+		RoomID:          urlDecodedRoomID,
+	}
+
+	switch {
+	case len(cal.UserEmail) == 0:
+		return nil, errors.New("G_SUITE_EMAIL not set")
+	case len(cal.CredentialsPath) == 0:
+		return nil, errors.New("G_SUITE_CREDENTIALS not set")
+	case len(cal.RoomID) == 0:
+		return nil, errors.New("roomID must be set")
+	}
+
+	return cal, nil
+}
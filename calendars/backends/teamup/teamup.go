@@ -0,0 +1,48 @@
+//go:build providers
+
+// Package teamup registers the teamup calendar backend. Import it for its side effects.
+// It is only built with -tags providers.
+package teamup
+
+import (
+	"context"
+	"errors"
+	"os"
+
+	"github.com/byuoitav/scheduler/calendars"
+	provider "github.com/byuoitav/teamup-calendar"
+)
+
+func init() {
+	calendars.Register(calendars.Backend{
+		Name:        "teamup",
+		Description: "Teamup calendars",
+		DefaultPort: 11003,
+		Settings: []calendars.Setting{
+			{Name: "TEAMUP_API_KEY", Description: "teamup api key", Required: true, Secret: true},
+			{Name: "TEAMUP_PASSWORD", Description: "password for the calendar, if it has one", Secret: true},
+			{Name: "TEAMUP_CALENDAR_ID", Description: "id of the teamup calendar", Required: true},
+		},
+		Create: createTeamup,
+	})
+}
+
+func createTeamup(ctx context.Context, roomID string) (calendars.Calendar, error) {
+	cal := &provider.Calendar{
+		APIKey:     os.Getenv("TEAMUP_API_KEY"),
+		Password:   os.Getenv("TEAMUP_PASSWORD"),
+		CalendarID: os.Getenv("TEAMUP_CALENDAR_ID"),
+		RoomID:     roomID,
+	}
+
+	switch {
+	case len(cal.APIKey) == 0:
+		return nil, errors.New("TEAMUP_API_KEY not set")
+	case len(cal.CalendarID) == 0:
+		return nil, errors.New("TEAMUP_CALENDAR_ID not set")
+	case len(cal.RoomID) == 0:
+		return nil, errors.New("roomID must be set")
+	}
+
+	return cal, nil
+}
@@ -2,6 +2,8 @@ package calendars
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
 	"sync"
 	"time"
@@ -13,6 +15,9 @@ type Event struct {
 	Title     string    `json:"title"`
 	StartTime time.Time `json:"startTime"`
 	EndTime   time.Time `json:"endTime"`
+
+	// Source is which of a room's calendars the event is from, when its schedule is merged from several
+	Source string `json:"source,omitempty"`
 }
 
 type Calendar interface {
@@ -22,37 +27,71 @@ type Calendar interface {
 
 type CreateCalendarFunc func(context.Context, string) (Calendar, error)
 
-func CreateCalendarServer(create CreateCalendarFunc) Server {
+// ServerOption changes how a calendar server is created
+type ServerOption func(*serverOptions)
+
+type serverOptions struct {
+	gateway      *Gateway
+	shadow       *Shadow
+	capabilities *Capabilities
+	admin        []Backend
+}
+
+// WithGateway runs the server in gateway mode, creating each room's calendar with the backend the gateway routes it to.
+// The routing table is served at /gateway/routes, and which backend a room is routed to at /gateway/routes/:roomID.
+func WithGateway(g *Gateway) ServerOption {
+	return func(o *serverOptions) {
+		o.gateway = g
+	}
+}
+
+// CreateCalendarServer serves the calendars created by create. In gateway mode, create may be nil.
+func CreateCalendarServer(create CreateCalendarFunc, opts ...ServerOption) Server {
+	var options serverOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
 	e := newEchoServer()
-	m := &sync.Map{}
 
-	createCal := func(ctx context.Context, roomID string) (Calendar, error) {
-		if cal, ok := m.Load(roomID); ok {
-			return cal.(Calendar), nil
-		}
+	// requests are checked against the api described at /openapi.json
+	api := newAPI(options)
+	e.Use(validateAPI(api))
+	e.GET("/openapi.json", echo.WrapHandler(api))
 
-		cal, err := create(ctx, roomID)
-		if err != nil {
-			return nil, err
-		}
+	if options.gateway != nil {
+		create = options.gateway.Create
+		addGatewayRoutes(e, options.gateway)
+	}
 
-		m.Store(roomID, cal)
-		return cal, nil
+	if options.shadow != nil {
+		addShadowRoutes(e, options.shadow)
 	}
 
+	addBackendAdminRoutes(e, options.admin)
+
+	rooms := &roomCalendars{create: create, shadow: options.shadow}
+	rooms.caps = capabilities{server: AllCapabilities, gateway: options.gateway}
+	switch {
+	case options.capabilities != nil:
+		rooms.caps.server = *options.capabilities
+	case options.gateway != nil:
+		rooms.caps.server = options.gateway.Capabilities()
+	}
+
+	addCapabilityRoutes(e, rooms.caps, rooms.calendar)
+
 	e.GET("/:roomID/events", func(c echo.Context) error {
 		roomID := c.Param("roomID")
 		if len(roomID) == 0 {
 			return c.String(http.StatusBadRequest, "must include roomID")
 		}
 
-		cal, err := createCal(c.Request().Context(), roomID)
-		if err != nil {
-			return c.String(http.StatusInternalServerError, err.Error())
-		}
-
-		events, err := cal.GetEvents(c.Request().Context())
-		if err != nil {
+		events, err := rooms.events(c.Request().Context(), roomID)
+		switch {
+		case errors.Is(err, ErrNoRoute):
+			return c.String(http.StatusNotFound, err.Error())
+		case err != nil:
 			return c.String(http.StatusInternalServerError, err.Error())
 		}
 
@@ -70,17 +109,84 @@ func CreateCalendarServer(create CreateCalendarFunc) Server {
 			return c.String(http.StatusBadRequest, err.Error())
 		}
 
-		cal, err := createCal(c.Request().Context(), roomID)
-		if err != nil {
-			return c.String(http.StatusInternalServerError, err.Error())
-		}
-
-		if err := cal.CreateEvent(c.Request().Context(), event); err != nil {
+		err := rooms.createEvent(c.Request().Context(), roomID, event)
+		switch {
+		case errors.Is(err, ErrNoRoute):
+			return c.String(http.StatusNotFound, err.Error())
+		case errors.Is(err, errNotBookable):
+			return c.String(http.StatusForbidden, ErrReadOnly.Error())
+		case err != nil:
 			return c.String(http.StatusInternalServerError, err.Error())
 		}
 
 		return c.String(http.StatusOK, "event successfully created")
 	})
 
-	return wrapEchoServer(e)
+	return wrapEchoServer(e, rooms)
+}
+
+// errNotBookable is returned when a room's capabilities say its calendar can't be booked
+var errNotBookable = fmt.Errorf("room can't be booked: %w", ErrReadOnly)
+
+// roomCalendars is the calendar of each room a server has been asked about. Its http and grpc apis share them.
+type roomCalendars struct {
+	create CreateCalendarFunc
+	caps   capabilities
+	shadow *Shadow
+
+	m sync.Map
+}
+
+// calendar returns a room's calendar, creating it the first time the room is asked about
+func (r *roomCalendars) calendar(ctx context.Context, roomID string) (Calendar, error) {
+	if cal, ok := r.m.Load(roomID); ok {
+		return cal.(Calendar), nil
+	}
+
+	cal, err := r.create(ctx, roomID)
+	if err != nil {
+		return nil, err
+	}
+
+	r.m.Store(roomID, cal)
+	return cal, nil
+}
+
+// events returns a room's events, comparing them against the shadow backend's if there is one
+func (r *roomCalendars) events(ctx context.Context, roomID string) ([]Event, error) {
+	cal, err := r.calendar(ctx, roomID)
+	if err != nil {
+		return nil, err
+	}
+
+	// the secondary calendar is read at the same time, but only compared once the primary answers
+	var secondary <-chan shadowResult
+	if r.shadow != nil {
+		secondary = r.shadow.fetch(roomID)
+	}
+
+	events, err := cal.GetEvents(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if secondary != nil {
+		go r.shadow.compare(roomID, events, secondary)
+	}
+
+	return events, nil
+}
+
+// createEvent books an event on a room's calendar, unless its capabilities say it can't be booked
+func (r *roomCalendars) createEvent(ctx context.Context, roomID string, event Event) error {
+	cal, err := r.calendar(ctx, roomID)
+	if err != nil {
+		return err
+	}
+
+	if !r.caps.room(roomID, cal).CreateEvents {
+		return errNotBookable
+	}
+
+	return cal.CreateEvent(ctx, event)
 }
@@ -0,0 +1,213 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: calendar.proto
+
+package calendarpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Calendar_ListEvents_FullMethodName  = "/scheduler.calendars.v1.Calendar/ListEvents"
+	Calendar_CreateEvent_FullMethodName = "/scheduler.calendars.v1.Calendar/CreateEvent"
+	Calendar_WatchEvents_FullMethodName = "/scheduler.calendars.v1.Calendar/WatchEvents"
+)
+
+// CalendarClient is the client API for Calendar service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Calendar serves room calendars, like calendars.Calendar. Rooms are created the same way,
+// and share the same calendars, as the calendar server's http api.
+type CalendarClient interface {
+	// ListEvents returns a room's events, or the ones in a window
+	ListEvents(ctx context.Context, in *ListEventsRequest, opts ...grpc.CallOption) (*ListEventsResponse, error)
+	// CreateEvent books an event on a room's calendar
+	CreateEvent(ctx context.Context, in *CreateEventRequest, opts ...grpc.CallOption) (*CreateEventResponse, error)
+	// WatchEvents sends a room's events, and sends them again each time they change
+	WatchEvents(ctx context.Context, in *WatchEventsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[EventsChanged], error)
+}
+
+type calendarClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewCalendarClient(cc grpc.ClientConnInterface) CalendarClient {
+	return &calendarClient{cc}
+}
+
+func (c *calendarClient) ListEvents(ctx context.Context, in *ListEventsRequest, opts ...grpc.CallOption) (*ListEventsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListEventsResponse)
+	err := c.cc.Invoke(ctx, Calendar_ListEvents_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *calendarClient) CreateEvent(ctx context.Context, in *CreateEventRequest, opts ...grpc.CallOption) (*CreateEventResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CreateEventResponse)
+	err := c.cc.Invoke(ctx, Calendar_CreateEvent_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *calendarClient) WatchEvents(ctx context.Context, in *WatchEventsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[EventsChanged], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Calendar_ServiceDesc.Streams[0], Calendar_WatchEvents_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchEventsRequest, EventsChanged]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Calendar_WatchEventsClient = grpc.ServerStreamingClient[EventsChanged]
+
+// CalendarServer is the server API for Calendar service.
+// All implementations must embed UnimplementedCalendarServer
+// for forward compatibility.
+//
+// Calendar serves room calendars, like calendars.Calendar. Rooms are created the same way,
+// and share the same calendars, as the calendar server's http api.
+type CalendarServer interface {
+	// ListEvents returns a room's events, or the ones in a window
+	ListEvents(context.Context, *ListEventsRequest) (*ListEventsResponse, error)
+	// CreateEvent books an event on a room's calendar
+	CreateEvent(context.Context, *CreateEventRequest) (*CreateEventResponse, error)
+	// WatchEvents sends a room's events, and sends them again each time they change
+	WatchEvents(*WatchEventsRequest, grpc.ServerStreamingServer[EventsChanged]) error
+	mustEmbedUnimplementedCalendarServer()
+}
+
+// UnimplementedCalendarServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedCalendarServer struct{}
+
+func (UnimplementedCalendarServer) ListEvents(context.Context, *ListEventsRequest) (*ListEventsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListEvents not implemented")
+}
+func (UnimplementedCalendarServer) CreateEvent(context.Context, *CreateEventRequest) (*CreateEventResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateEvent not implemented")
+}
+func (UnimplementedCalendarServer) WatchEvents(*WatchEventsRequest, grpc.ServerStreamingServer[EventsChanged]) error {
+	return status.Errorf(codes.Unimplemented, "method WatchEvents not implemented")
+}
+func (UnimplementedCalendarServer) mustEmbedUnimplementedCalendarServer() {}
+func (UnimplementedCalendarServer) testEmbeddedByValue()                  {}
+
+// UnsafeCalendarServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to CalendarServer will
+// result in compilation errors.
+type UnsafeCalendarServer interface {
+	mustEmbedUnimplementedCalendarServer()
+}
+
+func RegisterCalendarServer(s grpc.ServiceRegistrar, srv CalendarServer) {
+	// If the following call pancis, it indicates UnimplementedCalendarServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Calendar_ServiceDesc, srv)
+}
+
+func _Calendar_ListEvents_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListEventsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CalendarServer).ListEvents(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Calendar_ListEvents_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CalendarServer).ListEvents(ctx, req.(*ListEventsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Calendar_CreateEvent_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateEventRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CalendarServer).CreateEvent(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Calendar_CreateEvent_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CalendarServer).CreateEvent(ctx, req.(*CreateEventRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Calendar_WatchEvents_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchEventsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CalendarServer).WatchEvents(m, &grpc.GenericServerStream[WatchEventsRequest, EventsChanged]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Calendar_WatchEventsServer = grpc.ServerStreamingServer[EventsChanged]
+
+// Calendar_ServiceDesc is the grpc.ServiceDesc for Calendar service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Calendar_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "scheduler.calendars.v1.Calendar",
+	HandlerType: (*CalendarServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListEvents",
+			Handler:    _Calendar_ListEvents_Handler,
+		},
+		{
+			MethodName: "CreateEvent",
+			Handler:    _Calendar_CreateEvent_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchEvents",
+			Handler:       _Calendar_WatchEvents_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "calendar.proto",
+}
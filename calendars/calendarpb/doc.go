@@ -0,0 +1,5 @@
+// Package calendarpb is the gRPC api of calendar servers, generated from calendar.proto.
+// Calendar servers serve it with calendars.Server's ServeGRPC.
+package calendarpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative calendar.proto
@@ -0,0 +1,202 @@
+// Package calendartest checks that a calendars.Calendar behaves the way the scheduler and calendar servers expect.
+//
+// A backend's tests call Run with a func that returns an empty calendar:
+//
+//	func TestConformance(t *testing.T) {
+//		calendartest.Run(t, func(t *testing.T) calendars.Calendar {
+//			return newTestCalendar(t)
+//		})
+//	}
+//
+// Calendars whose capabilities say they can't be booked are only checked for the read-only behavior.
+package calendartest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/byuoitav/scheduler/calendars"
+)
+
+// NewCalendarFunc returns a calendar with no events on it. It is called once for each check.
+type NewCalendarFunc func(t *testing.T) calendars.Calendar
+
+// Run runs every conformance check against calendars made by newCal
+func Run(t *testing.T, newCal NewCalendarFunc) {
+	t.Run("EmptyCalendar", func(t *testing.T) { testEmpty(t, newCal(t)) })
+	t.Run("CancelledContext", func(t *testing.T) { testCancelled(t, newCal(t)) })
+
+	if !calendars.CalendarCapabilities(newCal(t)).CreateEvents {
+		t.Run("ReadOnly", func(t *testing.T) { testReadOnly(t, newCal(t)) })
+		return
+	}
+
+	t.Run("RoundTrip", func(t *testing.T) { testRoundTrip(t, newCal(t)) })
+	t.Run("Ordering", func(t *testing.T) { testOrdering(t, newCal(t)) })
+	t.Run("TimeZones", func(t *testing.T) { testTimeZones(t, newCal(t)) })
+	t.Run("InvalidEvents", func(t *testing.T) { testInvalid(t, newCal(t)) })
+}
+
+// tomorrow returns a time on the hour tomorrow, in UTC, so events don't land in the past or on a minute boundary a backend rounds
+func tomorrow(hour int) time.Time {
+	y, m, d := time.Now().UTC().AddDate(0, 0, 1).Date()
+	return time.Date(y, m, d, hour, 0, 0, 0, time.UTC)
+}
+
+func getEvents(t *testing.T, cal calendars.Calendar) []calendars.Event {
+	t.Helper()
+
+	events, err := cal.GetEvents(context.Background())
+	if err != nil {
+		t.Fatalf("GetEvents: %s", err)
+	}
+
+	return events
+}
+
+func createEvent(t *testing.T, cal calendars.Calendar, event calendars.Event) {
+	t.Helper()
+
+	if err := cal.CreateEvent(context.Background(), event); err != nil {
+		t.Fatalf("CreateEvent(%s): %s", describe(event), err)
+	}
+}
+
+// find returns the event that starts and ends at the same instants as want
+func find(events []calendars.Event, want calendars.Event) (calendars.Event, bool) {
+	for _, e := range events {
+		if e.StartTime.Equal(want.StartTime) && e.EndTime.Equal(want.EndTime) {
+			return e, true
+		}
+	}
+
+	return calendars.Event{}, false
+}
+
+func describe(e calendars.Event) string {
+	return fmt.Sprintf("%q %s - %s", e.Title, e.StartTime.Format(time.RFC3339), e.EndTime.Format(time.RFC3339))
+}
+
+// testEmpty checks that a calendar without events returns an empty list. It must not be nil, which is served as null instead of [].
+func testEmpty(t *testing.T, cal calendars.Calendar) {
+	events := getEvents(t, cal)
+
+	switch {
+	case events == nil:
+		t.Errorf("GetEvents returned nil, want an empty list")
+	case len(events) > 0:
+		t.Errorf("GetEvents returned %d events from an empty calendar, want none", len(events))
+	}
+}
+
+// testCancelled checks that calls with a cancelled context fail, and don't book anything
+func testCancelled(t *testing.T, cal calendars.Calendar) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := cal.GetEvents(ctx); err == nil {
+		t.Errorf("GetEvents with a cancelled context didn't return an error")
+	}
+
+	event := calendars.Event{Title: "Cancelled", StartTime: tomorrow(9), EndTime: tomorrow(10)}
+	if err := cal.CreateEvent(ctx, event); err == nil {
+		t.Errorf("CreateEvent with a cancelled context didn't return an error")
+	}
+
+	if _, ok := find(getEvents(t, cal), event); ok {
+		t.Errorf("CreateEvent with a cancelled context booked %s", describe(event))
+	}
+}
+
+// testReadOnly checks that calendars that can't be booked say so with calendars.ErrReadOnly
+func testReadOnly(t *testing.T, cal calendars.Calendar) {
+	event := calendars.Event{Title: "Read-only", StartTime: tomorrow(9), EndTime: tomorrow(10)}
+
+	err := cal.CreateEvent(context.Background(), event)
+	if !errors.Is(err, calendars.ErrReadOnly) {
+		t.Errorf("CreateEvent on a read-only calendar returned %v, want calendars.ErrReadOnly", err)
+	}
+}
+
+// testRoundTrip checks that a booked event comes back with the same title and times
+func testRoundTrip(t *testing.T, cal calendars.Calendar) {
+	event := calendars.Event{Title: "Round trip", StartTime: tomorrow(9), EndTime: tomorrow(10)}
+	createEvent(t, cal, event)
+
+	events := getEvents(t, cal)
+	if len(events) != 1 {
+		t.Fatalf("GetEvents returned %d events after booking one, want 1", len(events))
+	}
+
+	got, ok := find(events, event)
+	switch {
+	case !ok:
+		t.Errorf("GetEvents returned %s, want %s", describe(events[0]), describe(event))
+	case got.Title != event.Title:
+		t.Errorf("GetEvents returned title %q, want %q", got.Title, event.Title)
+	}
+}
+
+// testOrdering checks that events come back sorted by start time, no matter the order they were booked in
+func testOrdering(t *testing.T, cal calendars.Calendar) {
+	for _, hour := range []int{14, 9, 11} {
+		createEvent(t, cal, calendars.Event{Title: fmt.Sprintf("%d:00", hour), StartTime: tomorrow(hour), EndTime: tomorrow(hour + 1)})
+	}
+
+	events := getEvents(t, cal)
+	if len(events) != 3 {
+		t.Fatalf("GetEvents returned %d events after booking 3, want 3", len(events))
+	}
+
+	for i := 1; i < len(events); i++ {
+		if events[i].StartTime.Before(events[i-1].StartTime) {
+			t.Errorf("GetEvents returned %s before %s, want them sorted by start time", describe(events[i-1]), describe(events[i]))
+		}
+	}
+}
+
+// testTimeZones checks that events booked in different time zones keep the instants they were booked at
+func testTimeZones(t *testing.T, cal calendars.Calendar) {
+	var want []calendars.Event
+	for i, name := range []string{"America/Denver", "Asia/Kolkata", "UTC"} {
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			t.Skipf("time zone database isn't available: %s", err)
+		}
+
+		start := tomorrow(8 + 2*i).In(loc)
+		event := calendars.Event{Title: name, StartTime: start, EndTime: start.Add(90 * time.Minute)}
+
+		createEvent(t, cal, event)
+		want = append(want, event)
+	}
+
+	events := getEvents(t, cal)
+	for _, event := range want {
+		if _, ok := find(events, event); !ok {
+			t.Errorf("GetEvents didn't return %s (booked in %s)", describe(event), event.Title)
+		}
+	}
+}
+
+// testInvalid checks that events that end before they start are rejected with an error, and not booked
+func testInvalid(t *testing.T, cal calendars.Calendar) {
+	invalid := []calendars.Event{
+		{Title: "Backwards", StartTime: tomorrow(10), EndTime: tomorrow(9)},
+		{Title: "Empty", StartTime: tomorrow(10), EndTime: tomorrow(10)},
+		{Title: "No times"},
+	}
+
+	for _, event := range invalid {
+		if err := cal.CreateEvent(context.Background(), event); err == nil {
+			t.Errorf("CreateEvent(%s) didn't return an error", describe(event))
+		}
+	}
+
+	if events := getEvents(t, cal); len(events) > 0 {
+		t.Errorf("GetEvents returned %d events after only invalid bookings, want none", len(events))
+	}
+}
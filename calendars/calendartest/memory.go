@@ -0,0 +1,70 @@
+package calendartest
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/byuoitav/scheduler/calendars"
+)
+
+// Memory is a calendar that keeps its events in memory. It is the reference implementation the conformance suite is written against.
+type Memory struct {
+	mu     sync.Mutex
+	events []calendars.Event
+}
+
+// NewMemory returns an empty in-memory calendar
+func NewMemory(events ...calendars.Event) *Memory {
+	return &Memory{
+		events: append([]calendars.Event{}, events...),
+	}
+}
+
+// GetEvents returns the calendar's events, sorted by start time. An empty calendar returns an empty list, not nil.
+func (m *Memory) GetEvents(ctx context.Context) ([]calendars.Event, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	events := append([]calendars.Event{}, m.events...)
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].StartTime.Before(events[j].StartTime)
+	})
+
+	return events, nil
+}
+
+// CreateEvent adds event to the calendar
+func (m *Memory) CreateEvent(ctx context.Context, event calendars.Event) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := ValidateEvent(event); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	event.Source = ""
+	m.events = append(m.events, event)
+	return nil
+}
+
+// ValidateEvent returns an error if event can't be booked on any calendar
+func ValidateEvent(event calendars.Event) error {
+	switch {
+	case event.StartTime.IsZero() || event.EndTime.IsZero():
+		return errors.New("event must have a start and end time")
+	case !event.EndTime.After(event.StartTime):
+		return errors.New("event must end after it starts")
+	}
+
+	return nil
+}
@@ -0,0 +1,13 @@
+package calendartest
+
+import (
+	"testing"
+
+	"github.com/byuoitav/scheduler/calendars"
+)
+
+func TestMemory(t *testing.T) {
+	Run(t, func(t *testing.T) calendars.Calendar {
+		return NewMemory()
+	})
+}
@@ -0,0 +1,66 @@
+package calendartest
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/byuoitav/scheduler/calendars"
+)
+
+// Server is a calendar server for tests, serving a calendar for each room it's given
+type Server struct {
+	URL string
+
+	mu    sync.Mutex
+	rooms map[string]calendars.Calendar
+}
+
+// NewServer starts a calendar server, which is stopped when the test ends.
+// Rooms are added with Add, before their events are first read; other rooms are not found.
+func NewServer(t testing.TB, opts ...calendars.ServerOption) *Server {
+	t.Helper()
+
+	s := &Server{rooms: make(map[string]calendars.Calendar)}
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to start calendar server: %s", err)
+	}
+
+	srv := calendars.CreateCalendarServer(s.create, opts...)
+	go srv.Serve(lis)
+	t.Cleanup(func() { lis.Close() })
+
+	s.URL = "http://" + lis.Addr().String()
+	return s
+}
+
+// Add serves cal as roomID's calendar, and returns the url the room's events are at
+func (s *Server) Add(roomID string, cal calendars.Calendar) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.rooms[roomID] = cal
+	return s.EventsURL(roomID)
+}
+
+// EventsURL is where roomID's events are served, what a room config's calendarURL is set to
+func (s *Server) EventsURL(roomID string) string {
+	return fmt.Sprintf("%s/%s/events", s.URL, url.PathEscape(roomID))
+}
+
+func (s *Server) create(ctx context.Context, roomID string) (calendars.Calendar, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cal, ok := s.rooms[roomID]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", calendars.ErrNoRoute, roomID)
+	}
+
+	return cal, nil
+}
@@ -0,0 +1,97 @@
+package calendars
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo"
+)
+
+// Capabilities are what a calendar can do besides return its events
+type Capabilities struct {
+	// CreateEvents is true if events can be booked on the calendar
+	CreateEvents bool `json:"createEvents"`
+}
+
+// AllCapabilities are the capabilities of a calendar that doesn't say otherwise
+var AllCapabilities = Capabilities{
+	CreateEvents: true,
+}
+
+// Intersect returns the capabilities both c and other have
+func (c Capabilities) Intersect(other Capabilities) Capabilities {
+	return Capabilities{
+		CreateEvents: c.CreateEvents && other.CreateEvents,
+	}
+}
+
+// CapableCalendar is a calendar that can't do everything a Calendar can, like a read-only one
+type CapableCalendar interface {
+	Calendar
+	Capabilities() Capabilities
+}
+
+// CalendarCapabilities returns what cal can do
+func CalendarCapabilities(cal Calendar) Capabilities {
+	if c, ok := cal.(CapableCalendar); ok {
+		return c.Capabilities()
+	}
+
+	return AllCapabilities
+}
+
+// Capabilities returns what every calendar the backend creates can do
+func (b Backend) Capabilities() Capabilities {
+	caps := AllCapabilities
+	if b.ReadOnly {
+		caps.CreateEvents = false
+	}
+
+	return caps
+}
+
+// WithCapabilities sets what every room on the server can do, served at /capabilities.
+// It defaults to AllCapabilities, or in gateway mode, what all of the gateway's backends can do.
+func WithCapabilities(caps Capabilities) ServerOption {
+	return func(o *serverOptions) {
+		o.capabilities = &caps
+	}
+}
+
+// capabilities is what a server's rooms can do
+type capabilities struct {
+	server  Capabilities
+	gateway *Gateway
+}
+
+// room returns what roomID's calendar can do
+func (c capabilities) room(roomID string, cal Calendar) Capabilities {
+	caps := c.server
+	if c.gateway != nil {
+		if gcaps, err := c.gateway.RoomCapabilities(roomID); err == nil {
+			caps = gcaps
+		}
+	}
+
+	return caps.Intersect(CalendarCapabilities(cal))
+}
+
+func addCapabilityRoutes(e *echo.Echo, caps capabilities, createCal CreateCalendarFunc) {
+	e.GET("/capabilities", func(c echo.Context) error {
+		return c.JSON(http.StatusOK, caps.server)
+	})
+
+	e.GET("/:roomID/capabilities", func(c echo.Context) error {
+		roomID := c.Param("roomID")
+
+		cal, err := createCal(c.Request().Context(), roomID)
+		switch {
+		case errors.Is(err, ErrNoRoute):
+			return c.String(http.StatusNotFound, err.Error())
+		case err != nil:
+			return c.String(http.StatusInternalServerError, err.Error())
+		}
+
+		return c.JSON(http.StatusOK, caps.room(roomID, cal))
+	})
+}
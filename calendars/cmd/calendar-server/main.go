@@ -0,0 +1,247 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/byuoitav/scheduler/calendars"
+	"github.com/byuoitav/scheduler/log"
+	"github.com/spf13/pflag"
+
+	// backends the server can run. the provider backends are in providers.go
+	_ "github.com/byuoitav/scheduler/calendars/fake"
+)
+
+// defaultGatewayPort is the port a gateway runs on when one isn't given
+const defaultGatewayPort = 11000
+
+func main() {
+	// parse flags
+	var (
+		port         int
+		grpcPort     int
+		backendName  string
+		configPath   string
+		shadowName   string
+		listBackends bool
+		logLevel     string
+	)
+
+	pflag.IntVarP(&port, "port", "p", 0, "port to run the server on (default: the backend's port)")
+	pflag.IntVar(&grpcPort, "grpc-port", 0, "port to serve the gRPC api on (default: not served)")
+	pflag.StringVarP(&backendName, "backend", "b", os.Getenv("CALENDAR_BACKEND"), "calendar backend to serve")
+	pflag.StringVarP(&configPath, "config", "c", os.Getenv("CALENDAR_CONFIG"), "json config file with the backend, port, and backend settings")
+	pflag.StringVar(&shadowName, "shadow", "", "backend to compare served events against, without writing to it")
+	pflag.BoolVar(&listBackends, "list-backends", false, "list the available backends and their settings, then exit")
+	pflag.StringVar(&logLevel, "log-level", "info", "level to log at (debug also checks responses against /openapi.json)")
+	pflag.Parse()
+
+	if err := log.Config.Level.UnmarshalText([]byte(logLevel)); err != nil {
+		fmt.Printf("invalid --log-level: %s\n", err)
+		os.Exit(1)
+	}
+
+	var (
+		gateway *calendars.RoutingTable
+		shadow  calendars.ShadowConfig
+	)
+
+	if len(configPath) > 0 {
+		config, err := calendars.LoadServerConfig(configPath)
+		if err != nil {
+			fmt.Printf("%s\n", err)
+			os.Exit(1)
+		}
+
+		if err := config.ApplySettings(); err != nil {
+			fmt.Printf("%s\n", err)
+			os.Exit(1)
+		}
+
+		if len(backendName) == 0 {
+			backendName = config.Backend
+		}
+
+		if !pflag.CommandLine.Changed("port") && len(os.Getenv("CALENDAR_PORT")) == 0 {
+			port = config.Port
+		}
+
+		if !pflag.CommandLine.Changed("grpc-port") && len(os.Getenv("CALENDAR_GRPC_PORT")) == 0 {
+			grpcPort = config.GRPCPort
+		}
+
+		gateway = config.Gateway
+		if config.Shadow != nil {
+			shadow = *config.Shadow
+		}
+	}
+
+	if len(shadowName) > 0 {
+		shadow.Backend = shadowName
+	}
+
+	if listBackends {
+		printBackends(os.Stdout)
+		return
+	}
+
+	var (
+		name        string
+		defaultPort int
+		backends    []calendars.Backend
+		create      calendars.CreateCalendarFunc
+		opts        []calendars.ServerOption
+	)
+
+	switch {
+	case len(backendName) > 0:
+		backend, ok := calendars.LookupBackend(backendName)
+		if !ok {
+			fmt.Printf("unknown backend %q (must be one of %s)\n", backendName, strings.Join(backendNames(), ", "))
+			os.Exit(1)
+		}
+
+		name = backend.Name
+		defaultPort = backend.DefaultPort
+		backends = []calendars.Backend{backend}
+		create = backend.Create
+		opts = append(opts, calendars.WithCapabilities(backend.Capabilities()))
+	case gateway != nil:
+		g, err := calendars.NewGateway(*gateway)
+		if err != nil {
+			fmt.Printf("invalid gateway: %s\n", err)
+			os.Exit(1)
+		}
+
+		name = "gateway"
+		defaultPort = defaultGatewayPort
+		backends = g.Backends()
+		opts = append(opts, calendars.WithGateway(g))
+	default:
+		fmt.Printf("a backend must be chosen with --backend (one of %s), or a gateway set in the config file\n", strings.Join(backendNames(), ", "))
+		os.Exit(1)
+	}
+
+	if len(shadow.Backend) > 0 {
+		backend, ok := calendars.LookupBackend(shadow.Backend)
+		if !ok {
+			fmt.Printf("unknown shadow backend %q (must be one of %s)\n", shadow.Backend, strings.Join(backendNames(), ", "))
+			os.Exit(1)
+		}
+
+		backends = append(backends, backend)
+		opts = append(opts, calendars.WithShadow(calendars.NewShadow(backend.Create, shadow.Rooms)))
+		fmt.Printf("comparing events against %s\n", backend.Name)
+	}
+
+	opts = append(opts, calendars.WithBackendAdmin(backends...))
+
+	for _, backend := range backends {
+		if missing := backend.Missing(); len(missing) > 0 {
+			fmt.Printf("%s backend is missing required settings: %s\n", backend.Name, strings.Join(missing, ", "))
+			os.Exit(1)
+		}
+	}
+
+	if !pflag.CommandLine.Changed("port") {
+		if env := os.Getenv("CALENDAR_PORT"); len(env) > 0 {
+			var err error
+			if port, err = strconv.Atoi(env); err != nil {
+				fmt.Printf("invalid CALENDAR_PORT %q: %s\n", env, err)
+				os.Exit(1)
+			}
+		}
+	}
+
+	if !pflag.CommandLine.Changed("grpc-port") {
+		if env := os.Getenv("CALENDAR_GRPC_PORT"); len(env) > 0 {
+			var err error
+			if grpcPort, err = strconv.Atoi(env); err != nil {
+				fmt.Printf("invalid CALENDAR_GRPC_PORT %q: %s\n", env, err)
+				os.Exit(1)
+			}
+		}
+	}
+
+	if port == 0 {
+		port = defaultPort
+	}
+
+	// bind to given port
+	addr := fmt.Sprintf(":%d", port)
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		fmt.Printf("failed to start server: %s\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("serving %s calendars on %s\n", name, addr)
+
+	server := calendars.CreateCalendarServer(create, opts...)
+
+	// the gRPC api shares the http api's calendars, on its own port
+	if grpcPort != 0 {
+		grpcAddr := fmt.Sprintf(":%d", grpcPort)
+		grpcLis, err := net.Listen("tcp", grpcAddr)
+		if err != nil {
+			fmt.Printf("failed to start grpc server: %s\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("serving %s calendars over grpc on %s\n", name, grpcAddr)
+
+		go func() {
+			if err := server.ServeGRPC(grpcLis); err != nil {
+				fmt.Printf("error while serving grpc: %s\n", err)
+				os.Exit(1)
+			}
+		}()
+	}
+
+	if err = server.Serve(lis); err != nil {
+		fmt.Printf("error while listening: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func backendNames() []string {
+	var names []string
+	for _, b := range calendars.Backends() {
+		names = append(names, b.Name)
+	}
+
+	return names
+}
+
+// printBackends writes each backend, its default port, and the state of its settings
+func printBackends(f *os.File) {
+	w := tabwriter.NewWriter(f, 0, 4, 2, ' ', 0)
+	defer w.Flush()
+
+	for _, b := range calendars.Backends() {
+		fmt.Fprintf(w, "%s\t%d\t%s\n", b.Name, b.DefaultPort, b.Description)
+
+		for _, s := range b.Settings {
+			required := "optional"
+			if s.Required {
+				required = "required"
+			}
+
+			value, ok := os.LookupEnv(s.Name)
+			switch {
+			case !ok || len(value) == 0:
+				value = "not set"
+			case s.Secret:
+				value = "set"
+			default:
+				value = strconv.Quote(value)
+			}
+
+			fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", s.Name, required, value, s.Description)
+		}
+	}
+}
@@ -0,0 +1,10 @@
+//go:build providers
+
+package main
+
+import (
+	// provider backends the server can run
+	_ "github.com/byuoitav/scheduler/calendars/backends/exchange"
+	_ "github.com/byuoitav/scheduler/calendars/backends/gsuite"
+	_ "github.com/byuoitav/scheduler/calendars/backends/teamup"
+)
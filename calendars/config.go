@@ -0,0 +1,64 @@
+package calendars
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// ServerConfig is a calendar server's config file. Flags and environment variables take precedence over it.
+type ServerConfig struct {
+	Backend string `json:"backend"`
+	Port    int    `json:"port,omitempty"`
+
+	// GRPCPort is where the gRPC api is served. It isn't served when it's 0.
+	GRPCPort int `json:"grpcPort,omitempty"`
+
+	// Settings are used for any of the backend's settings that aren't set in the environment
+	Settings map[string]string `json:"settings,omitempty"`
+
+	// Gateway routes rooms to several backends. It's used when no backend is chosen.
+	Gateway *RoutingTable `json:"gateway,omitempty"`
+
+	// Shadow compares the events served against another backend's
+	Shadow *ShadowConfig `json:"shadow,omitempty"`
+}
+
+// ShadowConfig is the backend a calendar server compares its events against
+type ShadowConfig struct {
+	Backend string `json:"backend"`
+
+	// Rooms maps room IDs to their IDs on the shadow backend, for rooms whose IDs differ
+	Rooms map[string]string `json:"rooms,omitempty"`
+}
+
+// LoadServerConfig reads a calendar server config file
+func LoadServerConfig(path string) (ServerConfig, error) {
+	var config ServerConfig
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config, fmt.Errorf("unable to read config file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("unable to parse config file %q: %w", path, err)
+	}
+
+	return config, nil
+}
+
+// ApplySettings sets each of the config's settings that isn't already set in the environment
+func (c ServerConfig) ApplySettings() error {
+	for name, value := range c.Settings {
+		if _, ok := os.LookupEnv(name); ok {
+			continue
+		}
+
+		if err := os.Setenv(name, value); err != nil {
+			return fmt.Errorf("unable to apply setting %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
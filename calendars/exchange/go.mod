@@ -1,27 +0,0 @@
-module server.go
-
-go 1.18
-
-require (
-	github.com/byuoitav/exchange-calendar v0.0.7
-	github.com/byuoitav/scheduler v0.3.4
-	github.com/spf13/pflag v1.0.5
-)
-
-require (
-	github.com/byuoitav/common v0.0.0-20230217215806-8472d0ddbfb3 // indirect
-	github.com/byuoitav/scheduler/calendars/exchange v0.0.0-20241001215957-a36c5477b1f9 // indirect
-	github.com/fatih/color v1.17.0 // indirect
-	github.com/labstack/echo v3.3.10+incompatible // indirect
-	github.com/labstack/gommon v0.4.2 // indirect
-	github.com/mattn/go-colorable v0.1.13 // indirect
-	github.com/mattn/go-isatty v0.0.20 // indirect
-	github.com/valyala/bytebufferpool v1.0.0 // indirect
-	github.com/valyala/fasttemplate v1.2.2 // indirect
-	go.uber.org/multierr v1.11.0 // indirect
-	go.uber.org/zap v1.27.0 // indirect
-	golang.org/x/crypto v0.27.0 // indirect
-	golang.org/x/net v0.29.0 // indirect
-	golang.org/x/sys v0.25.0 // indirect
-	golang.org/x/text v0.18.0 // indirect
-)
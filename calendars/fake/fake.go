@@ -0,0 +1,290 @@
+// Package fake registers the fake calendar backend, for demos, load tests, and trying out panels without a real calendar.
+//
+// Rooms get a week of meetings generated from a seed, or the events in a yaml scenario file (see scenarios/demo.yaml).
+// Bookings are kept in memory until the process exits, and Handler injects faults, like errors and slow responses, on demand.
+// Import it for its side effects.
+package fake
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/byuoitav/scheduler/calendars"
+	"github.com/byuoitav/scheduler/clock"
+)
+
+// how many days around today are generated from a seed
+const (
+	generatedDaysBefore = 1
+	generatedDaysAfter  = 6
+)
+
+func init() {
+	calendars.Register(calendars.Backend{
+		Name:        "fake",
+		Description: "generated or scripted calendars kept in memory, for demos and testing",
+		DefaultPort: 11005,
+		Settings: []calendars.Setting{
+			{Name: "FAKE_CALENDAR_SCENARIO", Description: "yaml scenario file with each room's events and faults"},
+			{Name: "FAKE_CALENDAR_SEED", Description: "seed meetings are generated from, when there isn't a scenario (default 1)"},
+			{Name: "FAKE_CALENDAR_TIMEZONE", Description: "timezone meetings are generated in, when there isn't a scenario (default America/Denver)"},
+		},
+		Create: createFake,
+		Admin:  Handler(),
+	})
+}
+
+func createFake(ctx context.Context, roomID string) (calendars.Calendar, error) {
+	if len(roomID) == 0 {
+		return nil, errors.New("roomID must be set")
+	}
+
+	if _, err := state.load(); err != nil {
+		return nil, err
+	}
+
+	return &Calendar{RoomID: roomID}, nil
+}
+
+// Calendar is a room's fake calendar
+type Calendar struct {
+	RoomID string
+}
+
+// GetEvents returns the room's scenario events and bookings, sorted by start time
+func (c *Calendar) GetEvents(ctx context.Context) ([]calendars.Event, error) {
+	if err := state.faults(c.RoomID).inject(ctx); err != nil {
+		return nil, err
+	}
+
+	return state.events(c.RoomID)
+}
+
+// CreateEvent books event on the room's calendar
+func (c *Calendar) CreateEvent(ctx context.Context, event calendars.Event) error {
+	faults := state.faults(c.RoomID)
+	if faults.ReadOnly {
+		return calendars.ErrReadOnly
+	}
+
+	if err := faults.inject(ctx); err != nil {
+		return err
+	}
+
+	switch {
+	case event.StartTime.IsZero() || event.EndTime.IsZero():
+		return errors.New("event must have a start and end time")
+	case !event.EndTime.After(event.StartTime):
+		return errors.New("event must end after it starts")
+	}
+
+	return state.book(c.RoomID, event)
+}
+
+// Capabilities says whether the room can be booked right now
+func (c *Calendar) Capabilities() calendars.Capabilities {
+	if state.faults(c.RoomID).ReadOnly {
+		return calendars.Capabilities{}
+	}
+
+	return calendars.AllCapabilities
+}
+
+// SetScenario replaces the scenario every room's events come from, instead of the one from the environment.
+// Bookings and injected faults are kept.
+func SetScenario(s Scenario) error {
+	if err := s.validate(); err != nil {
+		return err
+	}
+
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	state.scenario = &s
+	return nil
+}
+
+// fakeState is what every fake calendar shares
+type fakeState struct {
+	mu       sync.Mutex
+	scenario *Scenario
+	injects  map[string]Faults
+	bookings map[string][]calendars.Event
+}
+
+var state = &fakeState{
+	injects:  make(map[string]Faults),
+	bookings: make(map[string][]calendars.Event),
+}
+
+// load returns the scenario, reading it from the environment the first time
+func (s *fakeState) load() (Scenario, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.scenario != nil {
+		return *s.scenario, nil
+	}
+
+	scenario, err := scenarioFromEnv()
+	if err != nil {
+		return scenario, err
+	}
+
+	s.scenario = &scenario
+	return scenario, nil
+}
+
+func scenarioFromEnv() (Scenario, error) {
+	if path := os.Getenv("FAKE_CALENDAR_SCENARIO"); len(path) > 0 {
+		return LoadScenario(path)
+	}
+
+	seed := int64(1)
+	if env := os.Getenv("FAKE_CALENDAR_SEED"); len(env) > 0 {
+		var err error
+		if seed, err = strconv.ParseInt(env, 10, 64); err != nil {
+			return Scenario{}, fmt.Errorf("invalid FAKE_CALENDAR_SEED %q: %w", env, err)
+		}
+	}
+
+	s := Scenario{Timezone: os.Getenv("FAKE_CALENDAR_TIMEZONE"), Default: Room{Seed: &seed}}
+	if err := s.validate(); err != nil {
+		return s, fmt.Errorf("invalid FAKE_CALENDAR_TIMEZONE: %w", err)
+	}
+
+	return s, nil
+}
+
+// faults returns the faults roomID has: injected into the room, then into every room, then from the scenario
+func (s *fakeState) faults(roomID string) Faults {
+	scenario, _ := s.load()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if f, ok := s.injects[roomID]; ok {
+		return f
+	}
+
+	if f, ok := s.injects[AllRooms]; ok {
+		return f
+	}
+
+	return scenario.room(roomID).Faults
+}
+
+// inject sets the faults injected into roomID, or stops injecting them if f is nil
+func (s *fakeState) inject(roomID string, f *Faults) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if f == nil {
+		delete(s.injects, roomID)
+		return
+	}
+
+	s.injects[roomID] = *f
+}
+
+func (s *fakeState) injected() map[string]Faults {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	injects := make(map[string]Faults, len(s.injects))
+	for room, f := range s.injects {
+		injects[room] = f
+	}
+
+	return injects
+}
+
+func (s *fakeState) clearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.injects = make(map[string]Faults)
+}
+
+func (s *fakeState) clearBookings() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.bookings = make(map[string][]calendars.Event)
+}
+
+// events returns roomID's generated, scenario, and booked events
+func (s *fakeState) events(roomID string) ([]calendars.Event, error) {
+	scenario, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+
+	loc, err := scenario.location()
+	if err != nil {
+		return nil, err
+	}
+
+	room := scenario.room(roomID)
+	now := clock.Now()
+	events := []calendars.Event{}
+
+	if room.Seed != nil {
+		today := startOfDay(now.In(loc))
+		for d := -generatedDaysBefore; d <= generatedDaysAfter; d++ {
+			events = append(events, generateDay(*room.Seed, roomID, today.AddDate(0, 0, d))...)
+		}
+	}
+
+	for _, spec := range room.Events {
+		start, end, err := spec.times(now, loc)
+		if err != nil {
+			return nil, err
+		}
+
+		events = append(events, calendars.Event{Title: spec.Title, StartTime: start, EndTime: end})
+	}
+
+	s.mu.Lock()
+	events = append(events, s.bookings[roomID]...)
+	s.mu.Unlock()
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].StartTime.Before(events[j].StartTime)
+	})
+
+	return events, nil
+}
+
+// book adds event to roomID's bookings, unless the room rejects overlapping events and it overlaps one
+func (s *fakeState) book(roomID string, event calendars.Event) error {
+	scenario, err := s.load()
+	if err != nil {
+		return err
+	}
+
+	if scenario.room(roomID).RejectOverlaps {
+		events, err := s.events(roomID)
+		if err != nil {
+			return err
+		}
+
+		for _, e := range events {
+			if event.StartTime.Before(e.EndTime) && e.StartTime.Before(event.EndTime) {
+				return fmt.Errorf("%w (%q %s - %s)", calendars.ErrOverlap, e.Title, e.StartTime.Format(time.Kitchen), e.EndTime.Format(time.Kitchen))
+			}
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.bookings[roomID] = append(s.bookings[roomID], event)
+	return nil
+}
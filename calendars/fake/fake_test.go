@@ -0,0 +1,148 @@
+package fake
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/byuoitav/scheduler/calendars"
+	"github.com/byuoitav/scheduler/calendars/calendartest"
+)
+
+// reset starts the test with scenario, and no bookings or injected faults
+func reset(t *testing.T, scenario Scenario) {
+	t.Helper()
+
+	if err := SetScenario(scenario); err != nil {
+		t.Fatalf("SetScenario: %s", err)
+	}
+
+	state.clearBookings()
+	state.clearFaults()
+}
+
+func TestConformance(t *testing.T) {
+	calendartest.Run(t, func(t *testing.T) calendars.Calendar {
+		reset(t, Scenario{})
+		return &Calendar{RoomID: "ITB-1010"}
+	})
+}
+
+func TestGenerate(t *testing.T) {
+	denver, _ := time.LoadLocation("America/Denver")
+	day := time.Date(2030, time.March, 10, 0, 0, 0, 0, denver) // clocks spring forward
+
+	first := generateDay(1, "ITB-1010", day)
+	if len(first) == 0 {
+		t.Fatalf("no meetings generated")
+	}
+
+	if again := generateDay(1, "ITB-1010", day); !reflect.DeepEqual(first, again) {
+		t.Errorf("the same seed generated different meetings")
+	}
+
+	if other := generateDay(2, "ITB-1010", day); reflect.DeepEqual(first, other) {
+		t.Errorf("different seeds generated the same meetings")
+	}
+
+	for _, e := range first {
+		if e.EndTime.Sub(e.StartTime) >= 24*time.Hour {
+			continue
+		}
+
+		if e.StartTime.Hour() < 8 || e.EndTime.After(time.Date(2030, time.March, 10, 18, 30, 0, 0, denver)) {
+			t.Errorf("%q is outside of working hours: %s - %s", e.Title, e.StartTime, e.EndTime)
+		}
+	}
+}
+
+func TestScenario(t *testing.T) {
+	s, err := LoadScenario("scenarios/demo.yaml")
+	if err != nil {
+		t.Fatalf("LoadScenario: %s", err)
+	}
+
+	reset(t, s)
+	ctx := context.Background()
+
+	events, err := (&Calendar{RoomID: "BACK-TO-BACK"}).GetEvents(ctx)
+	if err != nil {
+		t.Fatalf("GetEvents: %s", err)
+	}
+
+	for i := 1; i < len(events); i++ {
+		if !events[i].StartTime.Equal(events[i-1].EndTime) {
+			t.Errorf("%q doesn't start when %q ends", events[i].Title, events[i-1].Title)
+		}
+	}
+
+	overlapping := &Calendar{RoomID: "OVERLAPPING"}
+	events, _ = overlapping.GetEvents(ctx)
+
+	if err := overlapping.CreateEvent(ctx, calendars.Event{Title: "Walk-up", StartTime: events[0].StartTime, EndTime: events[0].EndTime}); !errors.Is(err, calendars.ErrOverlap) {
+		t.Errorf("got %v booking over an interview, expected ErrOverlap", err)
+	}
+
+	if _, err := (&Calendar{RoomID: "ERRORS"}).GetEvents(ctx); err == nil || err.Error() != "exchange is unavailable" {
+		t.Errorf("got %v from the ERRORS room", err)
+	}
+
+	if caps := calendars.CalendarCapabilities(&Calendar{RoomID: "READ-ONLY"}); caps.CreateEvents {
+		t.Errorf("READ-ONLY room can be booked")
+	}
+}
+
+func TestFaults(t *testing.T) {
+	reset(t, Scenario{})
+
+	srv := httptest.NewServer(Handler())
+	t.Cleanup(srv.Close)
+
+	put := func(room, body string) int {
+		req, _ := http.NewRequest(http.MethodPut, srv.URL+"/faults/"+room, strings.NewReader(body))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("unable to inject faults: %s", err)
+		}
+		resp.Body.Close()
+
+		return resp.StatusCode
+	}
+
+	cal := &Calendar{RoomID: "ITB-1010"}
+
+	if status := put(AllRooms, `{"latency": "1m"}`); status != http.StatusOK {
+		t.Fatalf("got %v injecting latency", status)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, err := cal.GetEvents(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v from a slow calendar, expected the deadline to be exceeded", err)
+	}
+
+	// faults injected into a room take the place of ones injected into every room
+	put("ITB-1010", `{"readOnly": true}`)
+	if err := cal.CreateEvent(context.Background(), calendars.Event{}); !errors.Is(err, calendars.ErrReadOnly) {
+		t.Errorf("got %v booking a read-only calendar", err)
+	}
+
+	if status := put("ITB-1010", `{"errorRate": 2}`); status != http.StatusBadRequest {
+		t.Errorf("got %v injecting an invalid error rate", status)
+	}
+
+	req, _ := http.NewRequest(http.MethodDelete, srv.URL+"/faults", nil)
+	if _, err := http.DefaultClient.Do(req); err != nil {
+		t.Fatalf("unable to clear faults: %s", err)
+	}
+
+	if _, err := cal.GetEvents(context.Background()); err != nil {
+		t.Errorf("GetEvents after clearing faults: %s", err)
+	}
+}
@@ -0,0 +1,124 @@
+package fake
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// AllRooms is the room faults are injected into to affect every room
+const AllRooms = "*"
+
+// Faults make a fake calendar misbehave like a real provider sometimes does
+type Faults struct {
+	// Error fails every request with this message
+	Error string `yaml:"error" json:"error,omitempty"`
+
+	// ErrorRate fails this fraction (0 to 1) of requests
+	ErrorRate float64 `yaml:"errorRate" json:"errorRate,omitempty"`
+
+	// Latency is how long every request takes, like 5s
+	Latency string `yaml:"latency" json:"latency,omitempty"`
+
+	// ReadOnly makes booking fail, and the calendar say it can't be booked
+	ReadOnly bool `yaml:"readOnly" json:"readOnly,omitempty"`
+}
+
+func (f Faults) validate() error {
+	if f.ErrorRate < 0 || f.ErrorRate > 1 {
+		return fmt.Errorf("errorRate must be between 0 and 1, not %v", f.ErrorRate)
+	}
+
+	if len(f.Latency) > 0 {
+		if _, err := time.ParseDuration(f.Latency); err != nil {
+			return fmt.Errorf("invalid latency: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// inject waits out the latency, then returns the error the request should fail with, if any
+func (f Faults) inject(ctx context.Context) error {
+	if len(f.Latency) > 0 {
+		d, _ := time.ParseDuration(f.Latency)
+
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	switch {
+	case len(f.Error) > 0:
+		return errors.New(f.Error)
+	case f.ErrorRate > 0 && rand.Float64() < f.ErrorRate:
+		return errors.New("injected fault")
+	}
+
+	return ctx.Err()
+}
+
+// Handler manages the faults injected into rooms on top of the scenario's:
+//
+//	GET    /faults        the injected faults, by room
+//	PUT    /faults/{room} inject faults into a room, or * for every room
+//	DELETE /faults/{room} stop injecting faults into a room
+//	DELETE /faults        stop injecting faults into every room
+//	DELETE /bookings      forget every booking
+//
+// Mount it where only admins can reach it.
+func Handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /faults", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, state.injected())
+	})
+
+	mux.HandleFunc("PUT /faults/{room}", func(w http.ResponseWriter, r *http.Request) {
+		var f Faults
+		if err := json.NewDecoder(r.Body).Decode(&f); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := f.validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		state.inject(r.PathValue("room"), &f)
+		writeJSON(w, http.StatusOK, state.injected())
+	})
+
+	mux.HandleFunc("DELETE /faults/{room}", func(w http.ResponseWriter, r *http.Request) {
+		state.inject(r.PathValue("room"), nil)
+		writeJSON(w, http.StatusOK, state.injected())
+	})
+
+	mux.HandleFunc("DELETE /faults", func(w http.ResponseWriter, r *http.Request) {
+		state.clearFaults()
+		writeJSON(w, http.StatusOK, state.injected())
+	})
+
+	mux.HandleFunc("DELETE /bookings", func(w http.ResponseWriter, r *http.Request) {
+		state.clearBookings()
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,216 @@
+package fake
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"os"
+	"time"
+
+	"github.com/byuoitav/scheduler/calendars"
+	"gopkg.in/yaml.v3"
+)
+
+// Scenario describes the fake calendars of every room. Rooms that aren't listed use Default.
+type Scenario struct {
+	// Timezone is where the scenario's times of day are. It defaults to America/Denver.
+	Timezone string `yaml:"timezone"`
+
+	Rooms   map[string]Room `yaml:"rooms"`
+	Default Room            `yaml:"default"`
+}
+
+// Room is one room's fake calendar
+type Room struct {
+	// Seed generates a week of meetings around today. Rooms without a seed only have their listed events and bookings.
+	Seed *int64 `yaml:"seed"`
+
+	Events []EventSpec `yaml:"events"`
+	Faults Faults      `yaml:"faults"`
+
+	// RejectOverlaps makes bookings that overlap another event fail, like a resource that declines conflicts
+	RejectOverlaps bool `yaml:"rejectOverlaps"`
+}
+
+// EventSpec is an event at a time of day, some days from today
+type EventSpec struct {
+	Title string `yaml:"title"`
+
+	// Day is how many days from today the event is on, like -1 for yesterday
+	Day int `yaml:"day"`
+
+	// Start and End are times of day, like 09:30. All day events leave them out.
+	Start  string `yaml:"start"`
+	End    string `yaml:"end"`
+	AllDay bool   `yaml:"allDay"`
+}
+
+// LoadScenario reads a yaml scenario file
+func LoadScenario(path string) (Scenario, error) {
+	var s Scenario
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return s, fmt.Errorf("unable to read scenario: %w", err)
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+
+	if err := dec.Decode(&s); err != nil {
+		return s, fmt.Errorf("unable to parse scenario %s: %w", path, err)
+	}
+
+	if err := s.validate(); err != nil {
+		return s, fmt.Errorf("invalid scenario %s: %w", path, err)
+	}
+
+	return s, nil
+}
+
+func (s Scenario) validate() error {
+	if _, err := s.location(); err != nil {
+		return err
+	}
+
+	check := func(name string, r Room) error {
+		for i, e := range r.Events {
+			if _, _, err := e.times(time.Now(), time.UTC); err != nil {
+				return fmt.Errorf("%s: events[%d]: %w", name, i, err)
+			}
+		}
+
+		if err := r.Faults.validate(); err != nil {
+			return fmt.Errorf("%s: faults: %w", name, err)
+		}
+
+		return nil
+	}
+
+	for name, r := range s.Rooms {
+		if err := check(name, r); err != nil {
+			return err
+		}
+	}
+
+	return check("default", s.Default)
+}
+
+func (s Scenario) location() (*time.Location, error) {
+	if len(s.Timezone) == 0 {
+		return time.LoadLocation("America/Denver")
+	}
+
+	return time.LoadLocation(s.Timezone)
+}
+
+// room returns roomID's fake calendar
+func (s Scenario) room(roomID string) Room {
+	if r, ok := s.Rooms[roomID]; ok {
+		return r
+	}
+
+	return s.Default
+}
+
+// times returns when the event starts and ends, if today is the day now is on in loc
+func (e EventSpec) times(now time.Time, loc *time.Location) (time.Time, time.Time, error) {
+	day := startOfDay(now.In(loc)).AddDate(0, 0, e.Day)
+	if e.AllDay {
+		return day, day.AddDate(0, 0, 1), nil
+	}
+
+	start, err := timeOfDay(day, e.Start)
+	if err != nil {
+		return start, start, fmt.Errorf("invalid start: %w", err)
+	}
+
+	end, err := timeOfDay(day, e.End)
+	if err != nil {
+		return start, end, fmt.Errorf("invalid end: %w", err)
+	}
+
+	if !end.After(start) {
+		// like a late night event that ends after midnight
+		end = end.AddDate(0, 0, 1)
+	}
+
+	return start, end, nil
+}
+
+func timeOfDay(day time.Time, hhmm string) (time.Time, error) {
+	t, err := time.Parse("15:04", hhmm)
+	if err != nil {
+		return t, err
+	}
+
+	return time.Date(day.Year(), day.Month(), day.Day(), t.Hour(), t.Minute(), 0, 0, day.Location()), nil
+}
+
+func startOfDay(t time.Time) time.Time {
+	return hour(t, 0)
+}
+
+// hour returns h o'clock on the day t is on, which isn't always h hours after midnight
+func hour(t time.Time, h int) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), h, 0, 0, 0, t.Location())
+}
+
+// titles are what generated meetings are called. Some are long, to see how the panel wraps them.
+var titles = []string{
+	"Standup",
+	"1:1",
+	"Sprint Planning",
+	"Design Review",
+	"Lunch & Learn",
+	"Interview",
+	"Budget Review",
+	"All Hands",
+	"Office Hours",
+	"Quarterly Business Review with the Office of Information Technology Leadership Team",
+	"Training: Getting the Most Out of the New Classroom Technology (Bring Your Laptop)",
+	"Retro",
+}
+
+// generateDay returns the meetings a seed puts in a room on day (midnight in the scenario's timezone).
+// The same seed, room, and day always make the same meetings.
+func generateDay(seed int64, roomID string, day time.Time) []calendars.Event {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s/%s", roomID, day.Format("2006-01-02"))
+	rng := rand.New(rand.NewSource(seed ^ int64(h.Sum64())))
+
+	var events []calendars.Event
+	add := func(start, end time.Time) {
+		events = append(events, calendars.Event{Title: titles[rng.Intn(len(titles))], StartTime: start, EndTime: end})
+	}
+
+	// some days have something going on all day
+	if rng.Intn(10) == 0 {
+		add(day, day.AddDate(0, 0, 1))
+	}
+
+	gaps := []int{0, 0, 0, 15, 30, 60, 90}
+	lengths := []int{15, 30, 30, 45, 60, 60, 90, 120}
+
+	t := hour(day, 8)
+	last := hour(day, 18)
+	for {
+		t = t.Add(time.Duration(gaps[rng.Intn(len(gaps))]) * time.Minute)
+		end := t.Add(time.Duration(lengths[rng.Intn(len(lengths))]) * time.Minute)
+		if end.After(last) {
+			break
+		}
+
+		add(t, end)
+
+		// and sometimes someone double books the room
+		if rng.Intn(12) == 0 {
+			add(t.Add(15*time.Minute), end.Add(30*time.Minute))
+		}
+
+		t = end
+	}
+
+	return events
+}
@@ -0,0 +1,195 @@
+package calendars
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"github.com/labstack/echo"
+)
+
+// ErrNoRoute is returned when a gateway doesn't have a backend for a room
+var ErrNoRoute = errors.New("no backend for room")
+
+// Route sends rooms to a backend
+type Route struct {
+	Backend string `json:"backend"`
+
+	// Rooms are room IDs sent to Backend. They take precedence over every route's Pattern.
+	Rooms []string `json:"rooms,omitempty"`
+
+	// Pattern is a regular expression matched against room IDs, in the order the routes are listed
+	Pattern string `json:"pattern,omitempty"`
+}
+
+// RoutingTable is how a gateway decides which backend serves each room
+type RoutingTable struct {
+	Routes []Route `json:"routes"`
+
+	// Default is the backend for rooms that don't match any route. Without it, those rooms aren't found.
+	Default string `json:"default,omitempty"`
+}
+
+// RouteMatch is which backend a room is sent to, and why
+type RouteMatch struct {
+	RoomID  string `json:"roomID"`
+	Backend string `json:"backend"`
+
+	// MatchedBy is "room", "pattern", or "default"
+	MatchedBy string `json:"matchedBy"`
+	Pattern   string `json:"pattern,omitempty"`
+}
+
+// Gateway serves rooms from several backends in one calendar server
+type Gateway struct {
+	table    RoutingTable
+	rooms    map[string]string
+	patterns []gatewayPattern
+	backends map[string]Backend
+}
+
+type gatewayPattern struct {
+	re      *regexp.Regexp
+	backend string
+}
+
+// NewGateway checks that every route's backend is registered and every pattern compiles
+func NewGateway(table RoutingTable) (*Gateway, error) {
+	g := &Gateway{
+		table:    table,
+		rooms:    make(map[string]string),
+		backends: make(map[string]Backend),
+	}
+
+	use := func(name string) error {
+		if _, ok := g.backends[name]; ok {
+			return nil
+		}
+
+		b, ok := LookupBackend(name)
+		if !ok {
+			return fmt.Errorf("unknown backend %q", name)
+		}
+
+		g.backends[name] = b
+		return nil
+	}
+
+	for i, route := range table.Routes {
+		if err := use(route.Backend); err != nil {
+			return nil, fmt.Errorf("routes[%d]: %w", i, err)
+		}
+
+		if len(route.Rooms) == 0 && len(route.Pattern) == 0 {
+			return nil, fmt.Errorf("routes[%d]: rooms or a pattern is required", i)
+		}
+
+		for _, room := range route.Rooms {
+			if prev, ok := g.rooms[room]; ok && prev != route.Backend {
+				return nil, fmt.Errorf("routes[%d]: room %q is already routed to %s", i, room, prev)
+			}
+
+			g.rooms[room] = route.Backend
+		}
+
+		if len(route.Pattern) > 0 {
+			re, err := regexp.Compile(route.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("routes[%d]: invalid pattern: %w", i, err)
+			}
+
+			g.patterns = append(g.patterns, gatewayPattern{re: re, backend: route.Backend})
+		}
+	}
+
+	if len(table.Default) > 0 {
+		if err := use(table.Default); err != nil {
+			return nil, fmt.Errorf("default: %w", err)
+		}
+	}
+
+	return g, nil
+}
+
+// Table returns the gateway's routing table
+func (g *Gateway) Table() RoutingTable {
+	return g.table
+}
+
+// Backends returns the backends the gateway routes to
+func (g *Gateway) Backends() []Backend {
+	var list []Backend
+	for _, b := range Backends() {
+		if _, ok := g.backends[b.Name]; ok {
+			list = append(list, b)
+		}
+	}
+
+	return list
+}
+
+// Resolve returns which backend serves roomID
+func (g *Gateway) Resolve(roomID string) (RouteMatch, error) {
+	if backend, ok := g.rooms[roomID]; ok {
+		return RouteMatch{RoomID: roomID, Backend: backend, MatchedBy: "room"}, nil
+	}
+
+	for _, p := range g.patterns {
+		if p.re.MatchString(roomID) {
+			return RouteMatch{RoomID: roomID, Backend: p.backend, MatchedBy: "pattern", Pattern: p.re.String()}, nil
+		}
+	}
+
+	if len(g.table.Default) > 0 {
+		return RouteMatch{RoomID: roomID, Backend: g.table.Default, MatchedBy: "default"}, nil
+	}
+
+	return RouteMatch{}, fmt.Errorf("%w %q", ErrNoRoute, roomID)
+}
+
+// Create creates roomID's calendar with the backend it's routed to
+func (g *Gateway) Create(ctx context.Context, roomID string) (Calendar, error) {
+	match, err := g.Resolve(roomID)
+	if err != nil {
+		return nil, err
+	}
+
+	return g.backends[match.Backend].Create(ctx, roomID)
+}
+
+// Capabilities returns what every room the gateway routes can do
+func (g *Gateway) Capabilities() Capabilities {
+	caps := AllCapabilities
+	for _, b := range g.backends {
+		caps = caps.Intersect(b.Capabilities())
+	}
+
+	return caps
+}
+
+// RoomCapabilities returns what the backend roomID is routed to can do
+func (g *Gateway) RoomCapabilities(roomID string) (Capabilities, error) {
+	match, err := g.Resolve(roomID)
+	if err != nil {
+		return Capabilities{}, err
+	}
+
+	return g.backends[match.Backend].Capabilities(), nil
+}
+
+func addGatewayRoutes(e *echo.Echo, g *Gateway) {
+	e.GET("/gateway/routes", func(c echo.Context) error {
+		return c.JSON(http.StatusOK, g.Table())
+	})
+
+	e.GET("/gateway/routes/:roomID", func(c echo.Context) error {
+		match, err := g.Resolve(c.Param("roomID"))
+		if err != nil {
+			return c.String(http.StatusNotFound, err.Error())
+		}
+
+		return c.JSON(http.StatusOK, match)
+	})
+}
@@ -0,0 +1,177 @@
+package calendars
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/byuoitav/scheduler/calendars/calendarpb"
+	"github.com/byuoitav/scheduler/clock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// how often WatchEvents checks a room's calendar for changes
+const defaultWatchInterval = time.Minute
+
+// minWatchInterval keeps watchers from polling providers too often
+var minWatchInterval = 5 * time.Second
+
+// grpcCalendars serves room calendars over the calendarpb api
+type grpcCalendars struct {
+	calendarpb.UnimplementedCalendarServer
+
+	rooms *roomCalendars
+}
+
+func newGRPCServer(rooms *roomCalendars) *grpc.Server {
+	s := grpc.NewServer()
+	calendarpb.RegisterCalendarServer(s, &grpcCalendars{rooms: rooms})
+	return s
+}
+
+func (g *grpcCalendars) ListEvents(ctx context.Context, req *calendarpb.ListEventsRequest) (*calendarpb.ListEventsResponse, error) {
+	if len(req.GetRoomId()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "must include room_id")
+	}
+
+	events, err := g.rooms.events(ctx, req.GetRoomId())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	return &calendarpb.ListEventsResponse{Events: eventsToProto(inWindow(events, req.GetWindow()))}, nil
+}
+
+func (g *grpcCalendars) CreateEvent(ctx context.Context, req *calendarpb.CreateEventRequest) (*calendarpb.CreateEventResponse, error) {
+	switch {
+	case len(req.GetRoomId()) == 0:
+		return nil, status.Error(codes.InvalidArgument, "must include room_id")
+	case req.GetEvent().GetStartTime() == nil || req.GetEvent().GetEndTime() == nil:
+		return nil, status.Error(codes.InvalidArgument, "event must include start_time and end_time")
+	}
+
+	if err := g.rooms.createEvent(ctx, req.GetRoomId(), eventFromProto(req.GetEvent())); err != nil {
+		return nil, grpcError(err)
+	}
+
+	return &calendarpb.CreateEventResponse{}, nil
+}
+
+// WatchEvents polls the room's calendar, since calendars can't say when they change, and sends its events whenever they're different
+func (g *grpcCalendars) WatchEvents(req *calendarpb.WatchEventsRequest, stream grpc.ServerStreamingServer[calendarpb.EventsChanged]) error {
+	if len(req.GetRoomId()) == 0 {
+		return status.Error(codes.InvalidArgument, "must include room_id")
+	}
+
+	interval := defaultWatchInterval
+	if req.GetInterval() != nil {
+		interval = max(req.GetInterval().AsDuration(), minWatchInterval)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	ctx := stream.Context()
+
+	var last []Event
+	for sent := false; ; sent = true {
+		events, err := g.rooms.events(ctx, req.GetRoomId())
+		if err != nil {
+			return grpcError(err)
+		}
+
+		events = inWindow(events, req.GetWindow())
+		if !sent || !sameEvents(last, events) {
+			changed := &calendarpb.EventsChanged{Events: eventsToProto(events), CheckedAt: timestamppb.New(clock.Now())}
+			if err := stream.Send(changed); err != nil {
+				return err
+			}
+
+			last = events
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// grpcError converts the errors calendars return to the codes their http statuses mean
+func grpcError(err error) error {
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return status.FromContextError(err).Err()
+	case errors.Is(err, ErrNoRoute):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, ErrReadOnly):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, ErrOverlap):
+		return status.Error(codes.AlreadyExists, err.Error())
+	}
+
+	return status.Error(codes.Internal, err.Error())
+}
+
+// inWindow returns the events that overlap window
+func inWindow(events []Event, window *calendarpb.Window) []Event {
+	if window == nil {
+		return events
+	}
+
+	filtered := []Event{}
+	for _, event := range events {
+		if window.GetStart() != nil && !event.EndTime.After(window.GetStart().AsTime()) {
+			continue
+		}
+
+		if window.GetEnd() != nil && !event.StartTime.Before(window.GetEnd().AsTime()) {
+			continue
+		}
+
+		filtered = append(filtered, event)
+	}
+
+	return filtered
+}
+
+func sameEvents(a, b []Event) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i].Title != b[i].Title || a[i].Source != b[i].Source || !a[i].StartTime.Equal(b[i].StartTime) || !a[i].EndTime.Equal(b[i].EndTime) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func eventsToProto(events []Event) []*calendarpb.Event {
+	pb := make([]*calendarpb.Event, 0, len(events))
+	for _, event := range events {
+		pb = append(pb, &calendarpb.Event{
+			Title:     event.Title,
+			StartTime: timestamppb.New(event.StartTime),
+			EndTime:   timestamppb.New(event.EndTime),
+			Source:    event.Source,
+		})
+	}
+
+	return pb
+}
+
+func eventFromProto(pb *calendarpb.Event) Event {
+	return Event{
+		Title:     pb.GetTitle(),
+		StartTime: pb.GetStartTime().AsTime(),
+		EndTime:   pb.GetEndTime().AsTime(),
+		Source:    pb.GetSource(),
+	}
+}
@@ -0,0 +1,112 @@
+package calendars
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/byuoitav/scheduler/calendars/calendarpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// bookableCalendar keeps the events booked on it
+type bookableCalendar struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (c *bookableCalendar) GetEvents(ctx context.Context) ([]Event, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]Event{}, c.events...), nil
+}
+
+func (c *bookableCalendar) CreateEvent(ctx context.Context, event Event) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.events = append(c.events, event)
+	return nil
+}
+
+func TestGRPC(t *testing.T) {
+	minWatchInterval = time.Millisecond
+
+	created := 0
+	srv := CreateCalendarServer(func(ctx context.Context, roomID string) (Calendar, error) {
+		created++
+		return &bookableCalendar{}, nil
+	})
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %s", err)
+	}
+
+	go srv.ServeGRPC(lis)
+	defer lis.Close()
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dial: %s", err)
+	}
+	defer conn.Close()
+
+	client := calendarpb.NewCalendarClient(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	watch, err := client.WatchEvents(ctx, &calendarpb.WatchEventsRequest{RoomId: "ITB-1010", Interval: durationpb.New(time.Millisecond)})
+	if err != nil {
+		t.Fatalf("WatchEvents: %s", err)
+	}
+
+	if changed, err := watch.Recv(); err != nil || len(changed.Events) != 0 {
+		t.Fatalf("got %v, %v watching an empty calendar", changed, err)
+	}
+
+	start := time.Date(2030, time.January, 1, 9, 0, 0, 0, time.UTC)
+	event := &calendarpb.Event{Title: "Standup", StartTime: timestamppb.New(start), EndTime: timestamppb.New(start.Add(time.Hour))}
+	if _, err := client.CreateEvent(ctx, &calendarpb.CreateEventRequest{RoomId: "ITB-1010", Event: event}); err != nil {
+		t.Fatalf("CreateEvent: %s", err)
+	}
+
+	// the http and grpc apis share each room's calendar
+	if _, err := srv.(*wrappedEchoServer).rooms.events(ctx, "ITB-1010"); err != nil || created != 1 {
+		t.Errorf("created %d calendars for one room", created)
+	}
+
+	if changed, err := watch.Recv(); err != nil || len(changed.Events) != 1 || changed.Events[0].Title != "Standup" {
+		t.Fatalf("got %v, %v after booking an event", changed, err)
+	}
+
+	list := func(window *calendarpb.Window) int {
+		resp, err := client.ListEvents(ctx, &calendarpb.ListEventsRequest{RoomId: "ITB-1010", Window: window})
+		if err != nil {
+			t.Fatalf("ListEvents: %s", err)
+		}
+
+		return len(resp.Events)
+	}
+
+	if n := list(&calendarpb.Window{Start: timestamppb.New(start.Add(30 * time.Minute))}); n != 1 {
+		t.Errorf("got %d events overlapping the window, want 1", n)
+	}
+
+	if n := list(&calendarpb.Window{Start: timestamppb.New(start.Add(time.Hour))}); n != 0 {
+		t.Errorf("got %d events after the event ended, want 0", n)
+	}
+
+	_, err = client.CreateEvent(ctx, &calendarpb.CreateEventRequest{RoomId: "ITB-1010", Event: &calendarpb.Event{Title: "Walk-up"}})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("got %v creating an event without times", err)
+	}
+}
@@ -1,9 +0,0 @@
-module github.com/byuoitav/scheduler/calendars/gsuite
-
-go 1.13
-
-require (
-	github.com/byuoitav/gsuite-calendar v0.0.5
-	github.com/byuoitav/scheduler v0.0.6
-	github.com/spf13/pflag v1.0.5
-)
@@ -0,0 +1,292 @@
+package calendars
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ICSContentType is the content type of iCalendar files
+const ICSContentType = "text/calendar"
+
+// ErrReadOnly is returned when creating an event on a calendar that can't be written to
+var ErrReadOnly = errors.New("calendar is read-only")
+
+// ErrOverlap is returned when an event is booked at the same time as another event, on a calendar that doesn't allow it
+var ErrOverlap = errors.New("event overlaps an existing event")
+
+var icsDuration = regexp.MustCompile(`^([+-])?P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// ParseICS reads the events in an iCalendar (.ics) file, like a nightly export of a room's calendar.
+// Times without a timezone are in loc. Cancelled events are skipped, and recurring events only
+// show up once, at their first occurrence, so exports should expand recurrences.
+func ParseICS(r io.Reader, loc *time.Location) ([]Event, error) {
+	lines, err := unfoldICS(r)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read ics: %w", err)
+	}
+
+	events := []Event{}
+
+	var (
+		inEvent   bool
+		event     Event
+		hasEnd    bool
+		allDay    bool
+		duration  time.Duration
+		cancelled bool
+
+		// components inside an event, like alarms, have properties of their own
+		nested int
+	)
+
+	for i, line := range lines {
+		name, params, value := splitICSLine(line)
+
+		switch {
+		case name == "BEGIN" && value == "VEVENT":
+			inEvent = true
+			event, hasEnd, allDay, duration, cancelled, nested = Event{}, false, false, 0, false, 0
+			continue
+		case name == "END" && value == "VEVENT":
+			inEvent = false
+
+			if cancelled {
+				continue
+			}
+
+			if event.StartTime.IsZero() {
+				return nil, fmt.Errorf("line %d: event %q has no DTSTART", i+1, event.Title)
+			}
+
+			if !hasEnd {
+				switch {
+				case duration != 0:
+					event.EndTime = event.StartTime.Add(duration)
+				case allDay:
+					event.EndTime = event.StartTime.AddDate(0, 0, 1)
+				default:
+					event.EndTime = event.StartTime
+				}
+			}
+
+			events = append(events, event)
+			continue
+		case !inEvent:
+			continue
+		case name == "BEGIN":
+			nested++
+			continue
+		case name == "END":
+			nested--
+			continue
+		case nested > 0:
+			continue
+		}
+
+		switch name {
+		case "SUMMARY":
+			event.Title = unescapeICS(value)
+		case "DTSTART":
+			event.StartTime, allDay, err = parseICSTime(value, params, loc)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid DTSTART: %w", i+1, err)
+			}
+		case "DTEND":
+			event.EndTime, _, err = parseICSTime(value, params, loc)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid DTEND: %w", i+1, err)
+			}
+
+			hasEnd = true
+		case "DURATION":
+			duration, err = parseICSDuration(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid DURATION: %w", i+1, err)
+			}
+		case "STATUS":
+			cancelled = strings.EqualFold(value, "CANCELLED")
+		}
+	}
+
+	return events, nil
+}
+
+// ICSCalendar is a read-only calendar of the events in an iCalendar file served over http(s)
+type ICSCalendar struct {
+	URL string
+
+	// Location is the timezone of times in the file that don't have one. It defaults to UTC.
+	Location *time.Location
+}
+
+func init() {
+	Register(Backend{
+		Name:        "ics",
+		Description: "read-only iCalendar (.ics) files. Room IDs are url encoded urls of the files",
+		DefaultPort: 11004,
+		ReadOnly:    true,
+		Create: func(ctx context.Context, roomID string) (Calendar, error) {
+			u, err := url.QueryUnescape(roomID)
+			if err != nil {
+				return nil, fmt.Errorf("invalid roomID %q: %w", roomID, err)
+			}
+
+			return &ICSCalendar{URL: u}, nil
+		},
+	})
+}
+
+// GetEvents downloads and parses the file
+func (c *ICSCalendar) GetEvents(ctx context.Context) ([]Event, error) {
+	loc := c.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to build ics request: %w", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to make ics request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("bad response from %s (%v): %s", c.URL, resp.StatusCode, b)
+	}
+
+	return ParseICS(resp.Body, loc)
+}
+
+// CreateEvent always returns ErrReadOnly
+func (c *ICSCalendar) CreateEvent(ctx context.Context, event Event) error {
+	return ErrReadOnly
+}
+
+// Capabilities says the calendar can't be booked
+func (c *ICSCalendar) Capabilities() Capabilities {
+	return Capabilities{}
+}
+
+// unfoldICS returns the file's logical lines; long lines are folded onto lines that start with a space or tab
+func unfoldICS(r io.Reader) ([]string, error) {
+	var lines []string
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+
+		if len(line) > 0 && (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+
+		if len(line) > 0 {
+			lines = append(lines, line)
+		}
+	}
+
+	return lines, scanner.Err()
+}
+
+// splitICSLine splits a line like DTSTART;TZID=America/Denver:20261019T090000 into its name, parameters, and value
+func splitICSLine(line string) (name string, params map[string]string, value string) {
+	// the value starts at the first colon that isn't in a quoted parameter
+	quoted := false
+	colon := -1
+	for i, r := range line {
+		if r == '"' {
+			quoted = !quoted
+		}
+
+		if r == ':' && !quoted {
+			colon = i
+			break
+		}
+	}
+
+	if colon == -1 {
+		return strings.ToUpper(line), nil, ""
+	}
+
+	parts := strings.Split(line[:colon], ";")
+	params = make(map[string]string, len(parts)-1)
+	for _, p := range parts[1:] {
+		if k, v, ok := strings.Cut(p, "="); ok {
+			params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+		}
+	}
+
+	return strings.ToUpper(parts[0]), params, line[colon+1:]
+}
+
+// parseICSTime parses a DATE or DATE-TIME value
+func parseICSTime(value string, params map[string]string, loc *time.Location) (time.Time, bool, error) {
+	if tzid, ok := params["TZID"]; ok {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			loc = l
+		}
+	}
+
+	if params["VALUE"] == "DATE" || len(value) == len("20060102") {
+		t, err := time.ParseInLocation("20060102", value, loc)
+		return t, true, err
+	}
+
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse("20060102T150405Z", value)
+		return t, false, err
+	}
+
+	t, err := time.ParseInLocation("20060102T150405", value, loc)
+	return t, false, err
+}
+
+// parseICSDuration parses a duration like PT1H30M or P1D
+func parseICSDuration(value string) (time.Duration, error) {
+	m := icsDuration.FindStringSubmatch(value)
+	if m == nil {
+		return 0, fmt.Errorf("%q is not a duration", value)
+	}
+
+	units := []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second}
+
+	var d time.Duration
+	for i, unit := range units {
+		if len(m[i+2]) == 0 {
+			continue
+		}
+
+		n, err := strconv.Atoi(m[i+2])
+		if err != nil {
+			return 0, err
+		}
+
+		d += time.Duration(n) * unit
+	}
+
+	if m[1] == "-" {
+		d = -d
+	}
+
+	return d, nil
+}
+
+func unescapeICS(s string) string {
+	return strings.NewReplacer(`\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";", `\\`, `\`).Replace(s)
+}
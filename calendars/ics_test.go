@@ -0,0 +1,22 @@
+package calendars_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/byuoitav/scheduler/calendars"
+	"github.com/byuoitav/scheduler/calendars/calendartest"
+)
+
+func TestICSCalendar(t *testing.T) {
+	calendartest.Run(t, func(t *testing.T) calendars.Calendar {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", calendars.ICSContentType)
+			w.Write([]byte("BEGIN:VCALENDAR\r\nVERSION:2.0\r\nEND:VCALENDAR\r\n"))
+		}))
+		t.Cleanup(srv.Close)
+
+		return &calendars.ICSCalendar{URL: srv.URL}
+	})
+}
@@ -0,0 +1,182 @@
+package calendars
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/byuoitav/scheduler/log"
+	"github.com/byuoitav/scheduler/openapi"
+	"github.com/labstack/echo"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// maxValidatedBody is the largest request or response body that is checked against the api
+const maxValidatedBody = 1 << 20
+
+// backendAdminMethods are the methods backends' admin endpoints are served with
+var backendAdminMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+// API describes the routes a calendar server created with opts serves, which it serves at /openapi.json
+func API(opts ...ServerOption) *openapi.Document {
+	var options serverOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return newAPI(options)
+}
+
+func newAPI(options serverOptions) *openapi.Document {
+	api := openapi.New("Calendar Server", "1.0.0", "A room calendar backend: each room's events, and booking them.")
+
+	var (
+		event  = api.Schema(Event{})
+		caps   = api.Schema(Capabilities{})
+		roomID = openapi.PathParam("roomID", "room the calendar is for, url encoded")
+	)
+
+	notFound := openapi.TextResponse("the server doesn't have a calendar for the room")
+
+	api.Add(http.MethodGet, "/{roomID}/events", openapi.Operation{
+		Summary:    "Get a room's events",
+		Tags:       []string{"events"},
+		Parameters: []openapi.Parameter{roomID},
+		Responses: map[string]*openapi.Response{
+			"200": openapi.JSONResponse("the room's events", openapi.Array(event)),
+			"404": notFound,
+		},
+	})
+	api.Add(http.MethodPost, "/{roomID}/events", openapi.Operation{
+		Summary:     "Book an event on a room's calendar",
+		Tags:        []string{"events"},
+		Parameters:  []openapi.Parameter{roomID},
+		RequestBody: openapi.JSONBody("the event", event.Requiring("startTime", "endTime")),
+		Responses: map[string]*openapi.Response{
+			"200": openapi.TextResponse("event successfully created"),
+			"403": openapi.TextResponse("the room's calendar can't be booked"),
+			"404": notFound,
+		},
+	})
+
+	api.Add(http.MethodGet, "/capabilities", openapi.Operation{
+		Summary:   "What every room on the server can do",
+		Tags:      []string{"capabilities"},
+		Responses: map[string]*openapi.Response{"200": openapi.JSONResponse("the capabilities", caps)},
+	})
+	api.Add(http.MethodGet, "/{roomID}/capabilities", openapi.Operation{
+		Summary:    "What a room's calendar can do",
+		Tags:       []string{"capabilities"},
+		Parameters: []openapi.Parameter{roomID},
+		Responses:  map[string]*openapi.Response{"200": openapi.JSONResponse("the capabilities", caps), "404": notFound},
+	})
+
+	if options.gateway != nil {
+		api.Add(http.MethodGet, "/gateway/routes", openapi.Operation{
+			Summary:   "Get the gateway's routing table",
+			Tags:      []string{"gateway"},
+			Responses: map[string]*openapi.Response{"200": openapi.JSONResponse("the routing table", api.Schema(RoutingTable{}))},
+		})
+		api.Add(http.MethodGet, "/gateway/routes/{roomID}", openapi.Operation{
+			Summary:    "Which backend a room is sent to, and why",
+			Tags:       []string{"gateway"},
+			Parameters: []openapi.Parameter{roomID},
+			Responses:  map[string]*openapi.Response{"200": openapi.JSONResponse("the route", api.Schema(RouteMatch{})), "404": openapi.TextResponse("no route matches the room")},
+		})
+	}
+
+	if options.shadow != nil {
+		api.Add(http.MethodGet, "/shadow/report", openapi.Operation{
+			Summary:   "How each room's events compare to the shadow backend's",
+			Tags:      []string{"shadow"},
+			Responses: map[string]*openapi.Response{"200": openapi.JSONResponse("each room's report", api.Schema([]ShadowReport{}))},
+		})
+		api.Add(http.MethodGet, "/shadow/report/{roomID}", openapi.Operation{
+			Summary:    "How a room's events compare to the shadow backend's",
+			Tags:       []string{"shadow"},
+			Parameters: []openapi.Parameter{roomID},
+			Responses:  map[string]*openapi.Response{"200": openapi.JSONResponse("the room's report", api.Schema(ShadowReport{})), "404": openapi.TextResponse("the room hasn't been compared yet")},
+		})
+	}
+
+	for _, b := range options.admin {
+		if b.Admin == nil {
+			continue
+		}
+
+		for _, method := range backendAdminMethods {
+			api.Add(method, "/admin/backends/"+b.Name+"/{path}", openapi.Operation{
+				Summary:   "The " + b.Name + " backend's admin endpoints. path can have slashes in it.",
+				Tags:      []string{"admin"},
+				Responses: map[string]*openapi.Response{"200": {Description: "whatever the backend returns", Content: map[string]openapi.MediaType{"*/*": {}}}},
+			})
+		}
+	}
+
+	api.Add(http.MethodGet, "/openapi.json", openapi.Operation{
+		Summary:   "Get this document",
+		Responses: map[string]*openapi.Response{"200": openapi.JSONResponse("the server's OpenAPI document", &openapi.Schema{Type: "object"})},
+	})
+
+	return api
+}
+
+// validateAPI rejects requests that don't match api with a 400. While debug logging is on,
+// it also logs responses that don't match, which means the api (or the document) has drifted.
+func validateAPI(api *openapi.Document) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			op, _, ok := api.Find(req.Method, req.URL.EscapedPath())
+			if !ok {
+				return next(c)
+			}
+
+			var body []byte
+			if op.BodyIsJSON(req.Header.Get(echo.HeaderContentType)) {
+				var err error
+				body, err = openapi.ReadBody(req, maxValidatedBody)
+				switch {
+				case errors.Is(err, openapi.ErrBodyTooLarge):
+					return c.String(http.StatusRequestEntityTooLarge, err.Error())
+				case err != nil:
+					return c.String(http.StatusBadRequest, err.Error())
+				}
+			}
+
+			if err := api.ValidateRequest(req, body); err != nil {
+				return c.String(http.StatusBadRequest, err.Error())
+			}
+
+			if !log.Config.Level.Enabled(zapcore.DebugLevel) {
+				return next(c)
+			}
+
+			w := &recordingWriter{ResponseWriter: c.Response().Writer}
+			c.Response().Writer = w
+
+			err := next(c)
+			if verr := api.ValidateResponse(req.Method, req.URL.EscapedPath(), c.Response().Status, c.Response().Header(), w.body.Bytes()); verr != nil {
+				log.P.Warn("Response doesn't match the api", zap.Error(verr))
+			}
+
+			return err
+		}
+	}
+}
+
+// recordingWriter keeps a copy of json responses, to check them once they're written
+type recordingWriter struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	if w.body.Len() < maxValidatedBody && strings.HasPrefix(w.Header().Get(echo.HeaderContentType), openapi.ContentJSON) {
+		w.body.Write(b)
+	}
+
+	return w.ResponseWriter.Write(b)
+}
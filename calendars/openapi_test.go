@@ -0,0 +1,93 @@
+package calendars
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testCalendar struct{}
+
+func (testCalendar) GetEvents(ctx context.Context) ([]Event, error) {
+	return []Event{{Title: "Standup", StartTime: time.Now(), EndTime: time.Now().Add(time.Hour)}}, nil
+}
+
+func (testCalendar) CreateEvent(ctx context.Context, event Event) error {
+	return nil
+}
+
+var echoParam = regexp.MustCompile(`:[^/]+|\*`)
+
+func TestAPI(t *testing.T) {
+	create := func(ctx context.Context, roomID string) (Calendar, error) {
+		return testCalendar{}, nil
+	}
+
+	gateway, err := NewGateway(RoutingTable{Default: "ics"})
+	if err != nil {
+		t.Fatalf("NewGateway: %s", err)
+	}
+
+	opts := []ServerOption{
+		WithGateway(gateway),
+		WithShadow(NewShadow(create, nil)),
+		WithBackendAdmin(Backend{Name: "test", Create: create, Admin: http.NotFoundHandler()}),
+	}
+
+	api := API(opts...)
+	srv := CreateCalendarServer(create, opts...).(*wrappedEchoServer)
+
+	// every route is described, and everything described is a route
+	routes := make(map[string]bool)
+	for _, route := range srv.Routes() {
+		routes[route.Method+" "+echoParam.ReplaceAllString(route.Path, "{}")] = true
+
+		if _, _, ok := api.Find(route.Method, echoParam.ReplaceAllString(route.Path, "x")); !ok {
+			t.Errorf("%s %s isn't in the openapi document", route.Method, route.Path)
+		}
+	}
+
+	for _, op := range api.Operations() {
+		path := regexp.MustCompile(`\{[^}/]+\}`).ReplaceAllString(op[1], "{}")
+		if !routes[op[0]+" "+path] {
+			t.Errorf("%s %s is in the openapi document, but isn't a route", op[0], op[1])
+		}
+	}
+
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do(http.MethodPost, "/ITB-1010/events", `{"title": "Walk-up", "startTime": "9am"}`); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "body.endTime: is required") {
+		t.Errorf("got %d %q booking an invalid event", w.Code, w.Body)
+	}
+
+	// bodies too large to check are turned away, even without a content length
+	req := httptest.NewRequest(http.MethodPost, "/ITB-1010/events", strings.NewReader(`{"title": "`+strings.Repeat("x", maxValidatedBody)+`"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.ContentLength = -1
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("got %d booking a chunked event that's too large, want 413", w.Code)
+	}
+
+	if w := do(http.MethodGet, "/openapi.json", ""); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"/gateway/routes/{roomID}"`) {
+		t.Errorf("got %d getting the openapi document", w.Code)
+	}
+
+	w = do(http.MethodGet, "/capabilities", "")
+	if err := api.ValidateResponse(http.MethodGet, "/capabilities", w.Code, w.Header(), w.Body.Bytes()); err != nil {
+		t.Errorf("%s", err)
+	}
+}
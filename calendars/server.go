@@ -8,10 +8,15 @@ import (
 
 type Server interface {
 	Serve(lis net.Listener) error
+
+	// ServeGRPC serves the calendarpb gRPC api on lis, with the same calendars as Serve
+	ServeGRPC(lis net.Listener) error
 }
 
 type wrappedEchoServer struct {
 	*echo.Echo
+
+	rooms *roomCalendars
 }
 
 func newEchoServer() *echo.Echo {
@@ -20,12 +25,17 @@ func newEchoServer() *echo.Echo {
 	return e
 }
 
-func wrapEchoServer(e *echo.Echo) Server {
+func wrapEchoServer(e *echo.Echo, rooms *roomCalendars) Server {
 	return &wrappedEchoServer{
-		Echo: e,
+		Echo:  e,
+		rooms: rooms,
 	}
 }
 
 func (e *wrappedEchoServer) Serve(lis net.Listener) error {
 	return e.Server.Serve(lis)
 }
+
+func (e *wrappedEchoServer) ServeGRPC(lis net.Listener) error {
+	return newGRPCServer(e.rooms).Serve(lis)
+}
@@ -0,0 +1,313 @@
+package calendars
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/byuoitav/scheduler/clock"
+	"github.com/byuoitav/scheduler/log"
+	"github.com/labstack/echo"
+	"go.uber.org/zap"
+)
+
+// Mismatch kinds
+const (
+	// MismatchMissing is an event the primary calendar has that the secondary doesn't
+	MismatchMissing = "missing"
+
+	// MismatchExtra is an event the secondary calendar has that the primary doesn't
+	MismatchExtra = "extra"
+
+	// MismatchTimeShifted is an event with the same title on both calendars, at different times
+	MismatchTimeShifted = "time-shifted"
+
+	// MismatchTitle is an event at the same time on both calendars, with different titles
+	MismatchTitle = "title"
+)
+
+// how long a secondary calendar has to answer before the comparison is given up on
+const shadowTimeout = 30 * time.Second
+
+// Mismatch is a difference between a primary and secondary calendar
+type Mismatch struct {
+	Kind      string `json:"kind"`
+	Primary   *Event `json:"primary,omitempty"`
+	Secondary *Event `json:"secondary,omitempty"`
+}
+
+// ShadowReport is how a room's secondary calendar has compared to its primary calendar
+type ShadowReport struct {
+	RoomID          string `json:"roomID"`
+	SecondaryRoomID string `json:"secondaryRoomID"`
+
+	// Comparisons is how many times the calendars have been compared, and Mismatched is how many of those found differences
+	Comparisons int `json:"comparisons"`
+	Mismatched  int `json:"mismatched"`
+
+	// Errors is how many times the secondary calendar couldn't be read
+	Errors    int    `json:"errors"`
+	LastError string `json:"lastError,omitempty"`
+
+	LastCompared time.Time `json:"lastCompared"`
+
+	// Mismatches are the differences found by the last comparison
+	Mismatches []Mismatch `json:"mismatches"`
+}
+
+// Shadow compares the events a calendar server serves against a secondary calendar, without ever writing to it
+type Shadow struct {
+	create CreateCalendarFunc
+	rooms  map[string]string
+	cals   sync.Map
+
+	mu      sync.Mutex
+	reports map[string]*ShadowReport
+}
+
+// NewShadow compares against the calendars created by create. rooms maps a room ID to its ID on the secondary calendar,
+// for rooms whose IDs differ between the two.
+func NewShadow(create CreateCalendarFunc, rooms map[string]string) *Shadow {
+	return &Shadow{
+		create:  create,
+		rooms:   rooms,
+		reports: make(map[string]*ShadowReport),
+	}
+}
+
+// WithShadow compares every read of a room's events against the shadow's secondary calendar.
+// Reports are served at /shadow/report and /shadow/report/:roomID.
+func WithShadow(s *Shadow) ServerOption {
+	return func(o *serverOptions) {
+		o.shadow = s
+	}
+}
+
+// Reports returns the report for every room that has been compared, sorted by room
+func (s *Shadow) Reports() []ShadowReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]ShadowReport, 0, len(s.reports))
+	for _, report := range s.reports {
+		list = append(list, *report)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].RoomID < list[j].RoomID
+	})
+
+	return list
+}
+
+// Report returns roomID's report, if it has been compared
+func (s *Shadow) Report(roomID string) (ShadowReport, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	report, ok := s.reports[roomID]
+	if !ok {
+		return ShadowReport{}, false
+	}
+
+	return *report, true
+}
+
+// secondaryRoom is roomID's ID on the secondary calendar
+func (s *Shadow) secondaryRoom(roomID string) string {
+	if id, ok := s.rooms[roomID]; ok {
+		return id
+	}
+
+	return roomID
+}
+
+type shadowResult struct {
+	events []Event
+	err    error
+}
+
+// fetch starts reading roomID's events from the secondary calendar. It isn't tied to the request,
+// so a slow secondary calendar never slows down the response.
+func (s *Shadow) fetch(roomID string) <-chan shadowResult {
+	ch := make(chan shadowResult, 1)
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), shadowTimeout)
+		defer cancel()
+
+		id := s.secondaryRoom(roomID)
+
+		var cal Calendar
+		if c, ok := s.cals.Load(id); ok {
+			cal = c.(Calendar)
+		} else {
+			var err error
+			if cal, err = s.create(ctx, id); err != nil {
+				ch <- shadowResult{err: err}
+				return
+			}
+
+			s.cals.Store(id, cal)
+		}
+
+		events, err := cal.GetEvents(ctx)
+		ch <- shadowResult{events: events, err: err}
+	}()
+
+	return ch
+}
+
+// compare records the differences between the primary calendar's events and the secondary calendar's, once it answers
+func (s *Shadow) compare(roomID string, primary []Event, secondary <-chan shadowResult) {
+	res := <-secondary
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	report, ok := s.reports[roomID]
+	if !ok {
+		report = &ShadowReport{RoomID: roomID, SecondaryRoomID: s.secondaryRoom(roomID), Mismatches: []Mismatch{}}
+		s.reports[roomID] = report
+	}
+
+	if res.err != nil {
+		report.Errors++
+		report.LastError = res.err.Error()
+		log.P.Warn("unable to read shadow calendar", zap.String("roomID", roomID), zap.String("secondaryRoomID", report.SecondaryRoomID), zap.Error(res.err))
+		return
+	}
+
+	mismatches := DiffEvents(primary, res.events)
+
+	report.Comparisons++
+	report.LastCompared = clock.Now()
+	report.Mismatches = mismatches
+
+	if len(mismatches) == 0 {
+		log.P.Debug("shadow calendar matches", zap.String("roomID", roomID), zap.Int("events", len(primary)))
+		return
+	}
+
+	report.Mismatched++
+
+	counts := make(map[string]int)
+	for _, m := range mismatches {
+		counts[m.Kind]++
+	}
+
+	log.P.Warn("shadow calendar doesn't match",
+		zap.String("roomID", roomID),
+		zap.String("secondaryRoomID", report.SecondaryRoomID),
+		zap.Int(MismatchMissing, counts[MismatchMissing]),
+		zap.Int(MismatchExtra, counts[MismatchExtra]),
+		zap.Int(MismatchTimeShifted, counts[MismatchTimeShifted]),
+		zap.Int(MismatchTitle, counts[MismatchTitle]),
+	)
+}
+
+// DiffEvents returns the differences between two calendars' events. Events are compared to the minute,
+// and titles after trimming and collapsing whitespace.
+func DiffEvents(primary, secondary []Event) []Mismatch {
+	ps := normalizeEvents(primary)
+	ss := normalizeEvents(secondary)
+
+	mismatches := []Mismatch{}
+
+	// pair off events with the first unmatched event in the secondary list that satisfies match
+	pair := func(match func(p, s Event) bool, kind string) {
+		for i := 0; i < len(ps); i++ {
+			for j := 0; j < len(ss); j++ {
+				if !match(ps[i].Event, ss[j].Event) {
+					continue
+				}
+
+				if len(kind) > 0 {
+					p, s := primary[ps[i].index], secondary[ss[j].index]
+					mismatches = append(mismatches, Mismatch{Kind: kind, Primary: &p, Secondary: &s})
+				}
+
+				ps = append(ps[:i], ps[i+1:]...)
+				ss = append(ss[:j], ss[j+1:]...)
+				i--
+				break
+			}
+		}
+	}
+
+	sameTime := func(p, s Event) bool {
+		return p.StartTime.Equal(s.StartTime) && p.EndTime.Equal(s.EndTime)
+	}
+
+	pair(func(p, s Event) bool { return sameTime(p, s) && p.Title == s.Title }, "")
+	pair(sameTime, MismatchTitle)
+	pair(func(p, s Event) bool { return p.Title == s.Title }, MismatchTimeShifted)
+
+	for _, p := range ps {
+		event := primary[p.index]
+		mismatches = append(mismatches, Mismatch{Kind: MismatchMissing, Primary: &event})
+	}
+
+	for _, s := range ss {
+		event := secondary[s.index]
+		mismatches = append(mismatches, Mismatch{Kind: MismatchExtra, Secondary: &event})
+	}
+
+	sort.SliceStable(mismatches, func(i, j int) bool {
+		return mismatchStart(mismatches[i]).Before(mismatchStart(mismatches[j]))
+	})
+
+	return mismatches
+}
+
+type normalizedEvent struct {
+	Event
+	index int
+}
+
+// normalizeEvents returns events in UTC truncated to the minute, with trimmed titles, sorted by start time
+func normalizeEvents(events []Event) []normalizedEvent {
+	list := make([]normalizedEvent, len(events))
+	for i, event := range events {
+		list[i] = normalizedEvent{
+			Event: Event{
+				Title:     strings.Join(strings.Fields(event.Title), " "),
+				StartTime: event.StartTime.UTC().Truncate(time.Minute),
+				EndTime:   event.EndTime.UTC().Truncate(time.Minute),
+			},
+			index: i,
+		}
+	}
+
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].StartTime.Before(list[j].StartTime)
+	})
+
+	return list
+}
+
+func mismatchStart(m Mismatch) time.Time {
+	if m.Primary != nil {
+		return m.Primary.StartTime
+	}
+
+	return m.Secondary.StartTime
+}
+
+func addShadowRoutes(e *echo.Echo, s *Shadow) {
+	e.GET("/shadow/report", func(c echo.Context) error {
+		return c.JSON(http.StatusOK, s.Reports())
+	})
+
+	e.GET("/shadow/report/:roomID", func(c echo.Context) error {
+		report, ok := s.Report(c.Param("roomID"))
+		if !ok {
+			return c.String(http.StatusNotFound, "room hasn't been compared yet")
+		}
+
+		return c.JSON(http.StatusOK, report)
+	})
+}
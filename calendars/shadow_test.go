@@ -0,0 +1,147 @@
+package calendars
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiffEvents(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2030, time.June, 3, hour, minute, 0, 0, time.UTC)
+	}
+
+	event := func(title string, start, end time.Time) Event {
+		return Event{Title: title, StartTime: start, EndTime: end}
+	}
+
+	standup := event("Standup", at(9, 0), at(9, 15))
+	review := event("Design Review", at(10, 0), at(11, 0))
+	lunch := event("Lunch", at(12, 0), at(13, 0))
+
+	tests := []struct {
+		name      string
+		primary   []Event
+		secondary []Event
+		want      []string
+	}{
+		{"same", []Event{standup, review}, []Event{review, standup}, nil},
+		{"empty", nil, []Event{}, nil},
+		{"missing", []Event{standup, review}, []Event{standup}, []string{"missing Design Review"}},
+		{"extra", []Event{standup}, []Event{standup, lunch}, []string{"extra Lunch"}},
+		{"time shifted", []Event{review}, []Event{event("Design Review", at(10, 30), at(11, 30))}, []string{"time-shifted Design Review/Design Review"}},
+		{"title", []Event{review}, []Event{event("Busy", at(10, 0), at(11, 0))}, []string{"title Design Review/Busy"}},
+		{
+			"compared to the minute, with whitespace collapsed",
+			[]Event{review},
+			[]Event{event("  Design \t Review ", at(10, 0).Add(30*time.Second), at(11, 0).Add(59*time.Second))},
+			nil,
+		},
+		{
+			"compared in utc",
+			[]Event{review},
+			[]Event{event("Design Review", at(10, 0).In(time.FixedZone("MDT", -6*60*60)), at(11, 0).In(time.FixedZone("MDT", -6*60*60)))},
+			nil,
+		},
+		{
+			"exact matches are paired first",
+			[]Event{standup, event("Standup", at(9, 30), at(9, 45))},
+			[]Event{event("Standup", at(9, 30), at(9, 45)), event("Sync", at(9, 0), at(9, 15))},
+			[]string{"title Standup/Sync"},
+		},
+		{
+			"sorted by start",
+			[]Event{lunch, review, standup},
+			[]Event{event("Design Review", at(10, 15), at(11, 0))},
+			[]string{"missing Standup", "time-shifted Design Review/Design Review", "missing Lunch"},
+		},
+	}
+
+	describe := func(m Mismatch) string {
+		switch {
+		case m.Primary != nil && m.Secondary != nil:
+			return fmt.Sprintf("%s %s/%s", m.Kind, m.Primary.Title, m.Secondary.Title)
+		case m.Primary != nil:
+			return fmt.Sprintf("%s %s", m.Kind, m.Primary.Title)
+		default:
+			return fmt.Sprintf("%s %s", m.Kind, m.Secondary.Title)
+		}
+	}
+
+	for _, tt := range tests {
+		mismatches := DiffEvents(tt.primary, tt.secondary)
+		if mismatches == nil {
+			t.Errorf("%s: got nil mismatches, want an empty list", tt.name)
+		}
+
+		var got []string
+		for _, m := range mismatches {
+			got = append(got, describe(m))
+		}
+
+		if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	// mismatches point at the original events, not the normalized ones
+	padded := event(" Design Review ", at(10, 0).Add(time.Second), at(11, 0))
+	mismatches := DiffEvents([]Event{padded}, nil)
+	if len(mismatches) != 1 || mismatches[0].Primary.Title != padded.Title || !mismatches[0].Primary.StartTime.Equal(padded.StartTime) {
+		t.Errorf("got %+v, want the original event", mismatches)
+	}
+}
+
+func TestShadowRoomMapping(t *testing.T) {
+	start := time.Date(2030, time.June, 3, 9, 0, 0, 0, time.UTC)
+	standup := Event{Title: "Standup", StartTime: start, EndTime: start.Add(15 * time.Minute)}
+
+	var created []string
+	shadow := NewShadow(func(ctx context.Context, roomID string) (Calendar, error) {
+		created = append(created, roomID)
+		if roomID == "broken" {
+			return nil, errors.New("no such calendar")
+		}
+
+		return &bookableCalendar{events: []Event{standup}}, nil
+	}, map[string]string{"JET-1106": "JET_1106@calendar.com", "ITB-1010": "broken"})
+
+	shadow.compare("JET-1106", []Event{standup}, shadow.fetch("JET-1106"))
+	shadow.compare("JET-1106", nil, shadow.fetch("JET-1106"))
+	shadow.compare("ITB-1011", []Event{standup}, shadow.fetch("ITB-1011"))
+	shadow.compare("ITB-1010", []Event{standup}, shadow.fetch("ITB-1010"))
+
+	// calendars are created once per secondary room, and rooms without a mapping keep their id
+	if strings.Join(created, ",") != "JET_1106@calendar.com,ITB-1011,broken" {
+		t.Errorf("got calendars created for %v", created)
+	}
+
+	jet, ok := shadow.Report("JET-1106")
+	if !ok || jet.SecondaryRoomID != "JET_1106@calendar.com" || jet.Comparisons != 2 || jet.Mismatched != 1 {
+		t.Fatalf("got report %+v", jet)
+	}
+
+	if len(jet.Mismatches) != 1 || jet.Mismatches[0].Kind != MismatchExtra {
+		t.Errorf("got mismatches %+v from the last comparison, want one extra", jet.Mismatches)
+	}
+
+	if itb, _ := shadow.Report("ITB-1011"); itb.SecondaryRoomID != "ITB-1011" || itb.Comparisons != 1 || itb.Mismatched != 0 {
+		t.Errorf("got report %+v for a room without a mapping", itb)
+	}
+
+	if broken, _ := shadow.Report("ITB-1010"); broken.Errors != 1 || broken.LastError != "no such calendar" || broken.Comparisons != 0 {
+		t.Errorf("got report %+v for a secondary calendar that can't be created", broken)
+	}
+
+	var rooms []string
+	for _, report := range shadow.Reports() {
+		rooms = append(rooms, report.RoomID)
+	}
+
+	if strings.Join(rooms, ",") != "ITB-1010,ITB-1011,JET-1106" {
+		t.Errorf("got reports for %v, want them sorted by room", rooms)
+	}
+}
@@ -0,0 +1,188 @@
+// Package clock is the time the scheduler shows and reasons about: what's happening in a room now, which day it is,
+// and when things happened. It's the real time unless it has been moved for testing or a demo.
+//
+// Timers, cache expiry, and retry backoff stay on the real time, so moving the clock can't stall or flood them.
+package clock
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrDisabled is returned when the clock is moved without time travel being enabled
+var ErrDisabled = errors.New("time travel is disabled (start the scheduler with --fake-time)")
+
+// Clock tells the time
+type Clock interface {
+	Now() time.Time
+}
+
+// Real is the system clock
+type Real struct{}
+
+// Now returns the current system time
+func (Real) Now() time.Time {
+	return time.Now()
+}
+
+// Fake is a clock that is some offset from the real time, or frozen at one time
+type Fake struct {
+	mu     sync.RWMutex
+	offset time.Duration
+	frozen *time.Time
+}
+
+// Now returns the fake time
+func (f *Fake) Now() time.Time {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	if f.frozen != nil {
+		return *f.frozen
+	}
+
+	return time.Now().Add(f.offset)
+}
+
+// Travel moves the clock to t, where it keeps running
+func (f *Fake) Travel(t time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.offset = time.Until(t)
+	f.frozen = nil
+}
+
+// Shift moves the clock by d, leaving it frozen if it was
+func (f *Fake) Shift(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.frozen != nil {
+		t := f.frozen.Add(d)
+		f.frozen = &t
+		return
+	}
+
+	f.offset += d
+}
+
+// Freeze stops the clock at t
+func (f *Fake) Freeze(t time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.offset = time.Until(t)
+	f.frozen = &t
+}
+
+// Resume starts a frozen clock again, from the time it was frozen at
+func (f *Fake) Resume() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.frozen != nil {
+		f.offset = time.Until(*f.frozen)
+		f.frozen = nil
+	}
+}
+
+// Reset puts the clock back to the real time
+func (f *Fake) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.offset = 0
+	f.frozen = nil
+}
+
+// State describes the clock
+func (f *Fake) State() State {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	state := State{Fake: true, Offset: f.offset.String(), Frozen: f.frozen != nil}
+	if f.frozen != nil {
+		state.Now = *f.frozen
+	} else {
+		state.Now = time.Now().Add(f.offset)
+	}
+
+	return state
+}
+
+// State is what time the clock says it is, and how that differs from the real time
+type State struct {
+	Now      time.Time `json:"now"`
+	RealTime time.Time `json:"realTime"`
+
+	// Fake is true when time travel is enabled, even if the clock hasn't been moved
+	Fake   bool   `json:"fake"`
+	Offset string `json:"offset"`
+	Frozen bool   `json:"frozen"`
+}
+
+var (
+	mu      sync.RWMutex
+	current Clock = Real{}
+)
+
+// Set replaces the clock the scheduler uses
+func Set(c Clock) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	current = c
+}
+
+// Get returns the clock the scheduler uses
+func Get() Clock {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	return current
+}
+
+// EnableTimeTravel replaces the clock with a fake one that can be moved, and returns it
+func EnableTimeTravel() *Fake {
+	f := &Fake{}
+	Set(f)
+	return f
+}
+
+// TimeTravel returns the fake clock, or ErrDisabled if the real clock is being used
+func TimeTravel() (*Fake, error) {
+	if f, ok := Get().(*Fake); ok {
+		return f, nil
+	}
+
+	return nil, ErrDisabled
+}
+
+// Now returns the scheduler's current time
+func Now() time.Time {
+	return Get().Now()
+}
+
+// Since returns the time elapsed since t, by the scheduler's clock
+func Since(t time.Time) time.Duration {
+	return Now().Sub(t)
+}
+
+// Until returns the duration until t, by the scheduler's clock
+func Until(t time.Time) time.Duration {
+	return t.Sub(Now())
+}
+
+// Status describes the scheduler's clock
+func Status() State {
+	if f, err := TimeTravel(); err == nil {
+		state := f.State()
+		state.RealTime = time.Now()
+		return state
+	}
+
+	now := time.Now()
+	return State{Now: now, RealTime: now, Offset: "0s"}
+}
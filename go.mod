@@ -6,10 +6,17 @@ require (
 	github.com/byuoitav/central-event-system v0.0.0-20200121172633-64fd9d467249
 	github.com/byuoitav/common v0.0.0-20191210190714-e9b411b3cc0d
 	github.com/byuoitav/device-monitoring v0.0.0-20200310211254-94d1f85b41c2
+	github.com/byuoitav/exchange-calendar v0.0.7
+	github.com/byuoitav/gsuite-calendar v0.0.5
+	github.com/byuoitav/teamup-calendar v0.0.5
 	github.com/gin-gonic/gin v1.10.1
 	github.com/labstack/echo v3.3.10+incompatible
 	github.com/spf13/pflag v1.0.5
 	go.uber.org/zap v1.13.0
+	golang.org/x/image v0.24.0
+	google.golang.org/grpc v1.73.0
+	google.golang.org/protobuf v1.36.6
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -30,7 +37,7 @@ require (
 	github.com/go-playground/universal-translator v0.18.1 // indirect
 	github.com/go-playground/validator/v10 v10.26.0 // indirect
 	github.com/goccy/go-json v0.10.5 // indirect
-	github.com/golang/protobuf v1.5.0 // indirect
+	github.com/golang/protobuf v1.5.4 // indirect
 	github.com/google/go-cmp v0.7.0 // indirect
 	github.com/google/gofuzz v1.0.0 // indirect
 	github.com/google/renameio v0.1.0 // indirect
@@ -76,11 +83,10 @@ require (
 	golang.org/x/text v0.26.0 // indirect
 	golang.org/x/tools v0.33.0 // indirect
 	golang.org/x/xerrors v0.0.0-20191204190536-9bdfabe68543 // indirect
-	google.golang.org/protobuf v1.36.6 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20250324211829-b45e905df463 // indirect
 	gopkg.in/check.v1 v1.0.0-20201130134442-10cb98267c6c // indirect
 	gopkg.in/errgo.v2 v2.1.0 // indirect
 	gopkg.in/yaml.v2 v2.2.2 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 	honnef.co/go/tools v0.0.1-2019.2.3 // indirect
 	nullprogram.com/x/optparse v1.0.0 // indirect
 	rsc.io/pdf v0.1.1 // indirect
@@ -0,0 +1,387 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/byuoitav/scheduler/log"
+	"github.com/byuoitav/scheduler/schedule"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// adminKey is set on the requests RequireAdmin lets through
+const adminKey = "admin"
+
+// RequireAdmin only lets requests through that have either a bearer token matching ADMIN_TOKEN,
+// or basic auth matching ADMIN_USERNAME/ADMIN_PASSWORD. Basic auth is only on when both of those are set.
+// If neither way is on, the admin api is disabled.
+func RequireAdmin(c *gin.Context) {
+	token := os.Getenv("ADMIN_TOKEN")
+	username, password := os.Getenv("ADMIN_USERNAME"), os.Getenv("ADMIN_PASSWORD")
+	basic := len(username) > 0 && len(password) > 0
+
+	if len(token) == 0 && !basic {
+		c.AbortWithStatusJSON(http.StatusForbidden, "admin api is disabled. set ADMIN_TOKEN or ADMIN_USERNAME/ADMIN_PASSWORD to enable it")
+		return
+	}
+
+	auth := c.GetHeader("Authorization")
+	if len(token) > 0 && strings.HasPrefix(auth, "Bearer ") {
+		if subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(auth, "Bearer ")), []byte(token)) == 1 {
+			c.Set(adminKey, true)
+			c.Next()
+			return
+		}
+	}
+
+	if basic {
+		if u, p, ok := c.Request.BasicAuth(); ok {
+			userOK := subtle.ConstantTimeCompare([]byte(u), []byte(username)) == 1
+			passOK := subtle.ConstantTimeCompare([]byte(p), []byte(password)) == 1
+			if userOK && passOK {
+				c.Set(adminKey, true)
+				c.Next()
+				return
+			}
+		}
+	}
+
+	log.P.Warn("Unauthorized admin request", zap.String("path", c.Request.URL.Path), zap.String("client_ip", c.ClientIP()))
+	c.Header("WWW-Authenticate", `Basic realm="scheduler admin"`)
+	c.AbortWithStatusJSON(http.StatusUnauthorized, "unauthorized")
+}
+
+// ListRoomConfigs returns every room config
+func ListRoomConfigs(c *gin.Context) {
+	configs, err := schedule.ListConfigs(c.Request.Context())
+	if err != nil {
+		writeAdminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, configs)
+}
+
+// GetCalendarHealth returns how each calendar url rooms read events from has been responding
+func GetCalendarHealth(c *gin.Context) {
+	c.JSON(http.StatusOK, schedule.CalendarHealth())
+}
+
+// GetRoomConfig returns a single room config, including its _rev
+func GetRoomConfig(c *gin.Context) {
+	config, err := schedule.GetConfig(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		writeAdminError(c, err)
+		return
+	}
+
+	c.Header("ETag", strconv.Quote(config.Rev))
+	c.JSON(http.StatusOK, config)
+}
+
+// CreateRoomConfig validates and saves a new room config
+func CreateRoomConfig(c *gin.Context) {
+	var config schedule.Config
+	if err := c.ShouldBindJSON(&config); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if len(config.Rev) > 0 {
+		c.String(http.StatusBadRequest, "new configs must not have a _rev")
+		return
+	}
+
+	saveRoomConfig(c, config, http.StatusCreated)
+}
+
+// UpdateRoomConfig validates and saves changes to a room config. The config's current revision must
+// be given in either the _rev field or an If-Match header.
+func UpdateRoomConfig(c *gin.Context) {
+	var config schedule.Config
+	if err := c.ShouldBindJSON(&config); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	id := c.Param("id")
+	switch {
+	case len(config.ID) == 0:
+		config.ID = id
+	case config.ID != id:
+		c.String(http.StatusBadRequest, fmt.Sprintf("_id %q does not match %q", config.ID, id))
+		return
+	}
+
+	if len(config.Rev) == 0 {
+		config.Rev = ifMatch(c)
+	}
+
+	if len(config.Rev) == 0 {
+		c.String(http.StatusPreconditionRequired, "_rev or If-Match is required to update a config")
+		return
+	}
+
+	saveRoomConfig(c, config, http.StatusOK)
+}
+
+func saveRoomConfig(c *gin.Context, config schedule.Config, status int) {
+	if err := schedule.ValidateConfig(c.Request.Context(), config, queryBool(c, "checkURLs", true)); err != nil {
+		writeAdminError(c, err)
+		return
+	}
+
+	config, err := schedule.PutConfig(c.Request.Context(), config)
+	if err != nil {
+		writeAdminError(c, err)
+		return
+	}
+
+	log.P.Info("Saved room config", zap.String("id", config.ID), zap.String("rev", config.Rev), zap.String("client_ip", c.ClientIP()))
+	c.Header("ETag", strconv.Quote(config.Rev))
+	c.JSON(status, config)
+}
+
+// DeleteRoomConfig deletes a room config. Its current revision must be given in either a rev query parameter or an If-Match header.
+func DeleteRoomConfig(c *gin.Context) {
+	id := c.Param("id")
+
+	rev := c.Query("rev")
+	if len(rev) == 0 {
+		rev = ifMatch(c)
+	}
+
+	if len(rev) == 0 {
+		c.String(http.StatusPreconditionRequired, "rev or If-Match is required to delete a config")
+		return
+	}
+
+	if err := schedule.DeleteConfig(c.Request.Context(), id, rev); err != nil {
+		writeAdminError(c, err)
+		return
+	}
+
+	log.P.Info("Deleted room config", zap.String("id", id), zap.String("rev", rev), zap.String("client_ip", c.ClientIP()))
+	c.Status(http.StatusNoContent)
+}
+
+// UploadRoomBackground saves an uploaded image as one of a room's backgrounds
+func UploadRoomBackground(c *gin.Context) {
+	uploadBackground(c, c.Param("id"))
+}
+
+// UploadBuildingBackground saves an uploaded image as one of a building's default backgrounds,
+// shown in the building's rooms that don't have their own
+func UploadBuildingBackground(c *gin.Context) {
+	uploadBackground(c, schedule.BuildingDoc(c.Param("id")))
+}
+
+// uploadBackground processes an uploaded png, jpeg, or webp image and attaches it to docID as the name query parameter (bg.png by default).
+// The image can be the request body or an "image" field in a multipart form. It's scaled and cropped to the
+// panel's resolution (or width/height query parameters), and darkened by the darken query parameter (0-1).
+func uploadBackground(c *gin.Context, id string) {
+	name := c.DefaultQuery("name", schedule.BackgroundAttachment)
+
+	opts := schedule.BackgroundOptions{
+		Width:  schedule.PanelWidth,
+		Height: schedule.PanelHeight,
+		Darken: schedule.DefaultDarken,
+	}
+
+	var err error
+	if v := c.Query("darken"); len(v) > 0 {
+		if opts.Darken, err = strconv.ParseFloat(v, 64); err != nil {
+			c.String(http.StatusBadRequest, fmt.Sprintf("invalid darken: %s", err))
+			return
+		}
+	}
+
+	if v := c.Query("width"); len(v) > 0 {
+		if opts.Width, err = strconv.Atoi(v); err != nil || opts.Width <= 0 || opts.Width > schedule.MaxBackgroundWidth {
+			c.String(http.StatusBadRequest, fmt.Sprintf("width must be a positive integer, up to %d", schedule.MaxBackgroundWidth))
+			return
+		}
+	}
+
+	if v := c.Query("height"); len(v) > 0 {
+		if opts.Height, err = strconv.Atoi(v); err != nil || opts.Height <= 0 || opts.Height > schedule.MaxBackgroundHeight {
+			c.String(http.StatusBadRequest, fmt.Sprintf("height must be a positive integer, up to %d", schedule.MaxBackgroundHeight))
+			return
+		}
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, schedule.MaxBackgroundSize+1<<20)
+
+	body := io.Reader(c.Request.Body)
+	if strings.HasPrefix(c.ContentType(), "multipart/form-data") {
+		file, _, err := c.Request.FormFile("image")
+		if err != nil {
+			c.String(http.StatusBadRequest, fmt.Sprintf("unable to read image field: %s", err))
+			return
+		}
+		defer file.Close()
+
+		body = file
+	}
+
+	img, err := schedule.ProcessBackground(body, opts)
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxErr):
+			c.String(http.StatusRequestEntityTooLarge, err.Error())
+		case errors.Is(err, schedule.ErrInvalidImage):
+			writeAdminError(c, err)
+		default:
+			c.String(http.StatusBadRequest, err.Error())
+		}
+		return
+	}
+
+	rev, err := schedule.PutBackgroundImage(c.Request.Context(), id, name, img)
+	if err != nil {
+		writeAdminError(c, err)
+		return
+	}
+
+	log.P.Info("Saved background", zap.String("id", id), zap.String("name", name), zap.String("rev", rev), zap.Int("size", len(img)), zap.String("client_ip", c.ClientIP()))
+	c.Header("ETag", strconv.Quote(rev))
+	c.JSON(http.StatusOK, gin.H{"id": id, "name": name, "rev": rev, "width": opts.Width, "height": opts.Height, "size": len(img)})
+}
+
+type importResult struct {
+	ID string `json:"id"`
+
+	// Result is created, updated, conflict, or failed
+	Result string   `json:"result"`
+	Rev    string   `json:"rev,omitempty"`
+	Errors []string `json:"errors,omitempty"`
+}
+
+// ImportRoomConfigs creates or updates many room configs at once, from either a csv (Content-Type: text/csv)
+// or a json array. Existing configs need their _rev, unless overwrite=true. With dryRun=true, configs are only validated.
+func ImportRoomConfigs(c *gin.Context) {
+	var configs []schedule.Config
+	var err error
+
+	if strings.HasPrefix(c.ContentType(), "text/csv") {
+		configs, err = schedule.ParseConfigsCSV(c.Request.Body)
+	} else {
+		err = json.NewDecoder(c.Request.Body).Decode(&configs)
+	}
+
+	if err != nil {
+		c.String(http.StatusBadRequest, fmt.Sprintf("unable to parse configs: %s", err))
+		return
+	}
+
+	existing, err := schedule.ListConfigs(c.Request.Context())
+	if err != nil {
+		writeAdminError(c, err)
+		return
+	}
+
+	revs := make(map[string]string, len(existing))
+	for _, config := range existing {
+		revs[config.ID] = config.Rev
+	}
+
+	dryRun := queryBool(c, "dryRun", false)
+	checkURLs := queryBool(c, "checkURLs", true)
+	overwrite := queryBool(c, "overwrite", false)
+
+	results := make([]importResult, 0, len(configs))
+	failed := 0
+
+	for _, config := range configs {
+		result := importResult{ID: config.ID, Result: "created"}
+
+		// existing configs are only changed at the revision the import says it expects, so a stale export
+		// can't undo edits made since. overwrite=true replaces whatever is there.
+		rev, exists := revs[config.ID]
+		if exists {
+			result.Result = "updated"
+			if len(config.Rev) == 0 && overwrite {
+				config.Rev = rev
+			}
+		}
+
+		var err error
+		if exists && len(config.Rev) == 0 {
+			err = fmt.Errorf("%w: %s already exists. include its _rev, or import with overwrite=true", schedule.ErrConflict, config.ID)
+		} else {
+			err = schedule.ValidateConfig(c.Request.Context(), config, checkURLs)
+		}
+
+		if err == nil && !dryRun {
+			config, err = schedule.PutConfig(c.Request.Context(), config)
+			result.Rev = config.Rev
+		}
+
+		if err != nil {
+			failed++
+			result.Result = "failed"
+			if errors.Is(err, schedule.ErrConflict) {
+				result.Result = "conflict"
+			}
+
+			var verr *schedule.ValidationError
+			if errors.As(err, &verr) {
+				result.Errors = verr.Problems
+			} else {
+				result.Errors = []string{err.Error()}
+			}
+		}
+
+		results = append(results, result)
+	}
+
+	log.P.Info("Imported room configs", zap.Int("count", len(configs)), zap.Int("failed", failed), zap.Bool("dryRun", dryRun), zap.String("client_ip", c.ClientIP()))
+	c.JSON(http.StatusOK, results)
+}
+
+func writeAdminError(c *gin.Context, err error) {
+	var verr *schedule.ValidationError
+
+	switch {
+	case errors.As(err, &verr):
+		c.JSON(http.StatusUnprocessableEntity, verr)
+	case errors.Is(err, schedule.ErrInvalidImage):
+		c.String(http.StatusUnprocessableEntity, err.Error())
+	case errors.Is(err, schedule.ErrNotFound):
+		c.String(http.StatusNotFound, err.Error())
+	case errors.Is(err, schedule.ErrConflict):
+		c.String(http.StatusConflict, err.Error())
+	default:
+		log.P.Error("Admin request failed", zap.Error(err), zap.String("path", c.Request.URL.Path), zap.String("client_ip", c.ClientIP()))
+		c.String(http.StatusInternalServerError, err.Error())
+	}
+}
+
+// ifMatch returns the revision in the If-Match header
+func ifMatch(c *gin.Context) string {
+	rev := c.GetHeader("If-Match")
+	if unquoted, err := strconv.Unquote(rev); err == nil {
+		return unquoted
+	}
+
+	return rev
+}
+
+// queryBool returns the boolean value of the query parameter key, or def if it isn't set or valid
+func queryBool(c *gin.Context, key string, def bool) bool {
+	b, err := strconv.ParseBool(c.Query(key))
+	if err != nil {
+		return def
+	}
+
+	return b
+}
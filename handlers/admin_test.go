@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/byuoitav/scheduler/schedule"
+	"github.com/byuoitav/scheduler/schedule/scheduletest"
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func TestRequireAdmin(t *testing.T) {
+	r := gin.New()
+	r.GET("/admin", RequireAdmin, func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	get := func(auth func(*http.Request)) int {
+		req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+		if auth != nil {
+			auth(req)
+		}
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	bearer := func(token string) func(*http.Request) {
+		return func(req *http.Request) { req.Header.Set("Authorization", "Bearer "+token) }
+	}
+
+	basic := func(username, password string) func(*http.Request) {
+		return func(req *http.Request) { req.SetBasicAuth(username, password) }
+	}
+
+	t.Setenv("ADMIN_TOKEN", "")
+	t.Setenv("ADMIN_USERNAME", "")
+	t.Setenv("ADMIN_PASSWORD", "")
+
+	if code := get(bearer("")); code != http.StatusForbidden {
+		t.Errorf("got %d with the admin api disabled, want 403", code)
+	}
+
+	// a username without a password doesn't turn on basic auth
+	t.Setenv("ADMIN_USERNAME", "admin")
+	if code := get(basic("admin", "")); code != http.StatusForbidden {
+		t.Errorf("got %d with just ADMIN_USERNAME set, want 403", code)
+	}
+
+	t.Setenv("ADMIN_TOKEN", "s3cret")
+	if code := get(basic("admin", "")); code != http.StatusUnauthorized {
+		t.Errorf("got %d with an empty password and no ADMIN_PASSWORD, want 401", code)
+	}
+
+	t.Setenv("ADMIN_TOKEN", "s3cret")
+	t.Setenv("ADMIN_USERNAME", "admin")
+	t.Setenv("ADMIN_PASSWORD", "hunter2")
+
+	tests := []struct {
+		name string
+		auth func(*http.Request)
+		want int
+	}{
+		{"token", bearer("s3cret"), http.StatusNoContent},
+		{"basic auth", basic("admin", "hunter2"), http.StatusNoContent},
+		{"wrong token", bearer("guess"), http.StatusUnauthorized},
+		{"wrong password", basic("admin", "guess"), http.StatusUnauthorized},
+		{"nothing", nil, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		if code := get(tt.auth); code != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.name, code, tt.want)
+		}
+	}
+}
+
+func TestImportRoomConfigs(t *testing.T) {
+	couch := scheduletest.NewCouch(t)
+	t.Setenv("DB_ADDRESS", couch.URL)
+
+	existingRev := couch.Put(t, schedule.Config{ID: "JET-1106", DisplayName: "Old Name", CalendarURL: "local://JET-1106"})
+
+	r := gin.New()
+	r.POST("/import", ImportRoomConfigs)
+
+	post := func(query, contentType, body string) []importResult {
+		t.Helper()
+
+		req := httptest.NewRequest(http.MethodPost, "/import?checkURLs=false&"+query, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("got %d importing: %s", w.Code, w.Body)
+		}
+
+		var results []importResult
+		if err := json.Unmarshal(w.Body.Bytes(), &results); err != nil {
+			t.Fatalf("unable to parse results: %s", err)
+		}
+
+		return results
+	}
+
+	csv := `_id,displayName,calendarURL,canCreateEvents
+JET-1106,The JET,local://JET-1106,true
+ITB-1010,ITB 1010,local://ITB-1010,false
+ITB-1011,,local://ITB-1011,false
+`
+
+	// a dry run only validates, and an existing config without a _rev is a conflict
+	results := post("dryRun=true", "text/csv", csv)
+	if len(results) != 3 || results[0].Result != "conflict" || results[1].Result != "created" || results[2].Result != "failed" {
+		t.Fatalf("got %+v from a dry run", results)
+	}
+
+	if len(results[2].Errors) != 1 || results[2].Errors[0] != "displayName is required" {
+		t.Errorf("got errors %v for a config without a displayName", results[2].Errors)
+	}
+
+	var jet schedule.Config
+	if couch.Get(t, "JET-1106", &jet); jet.DisplayName != "Old Name" {
+		t.Errorf("dry run changed JET-1106: %+v", jet)
+	}
+
+	if couch.Get(t, "ITB-1010", &schedule.Config{}) {
+		t.Errorf("dry run created ITB-1010")
+	}
+
+	results = post("", "text/csv", csv)
+	if results[0].Result != "conflict" || results[1].Result != "created" || len(results[1].Rev) == 0 {
+		t.Errorf("got %+v importing", results)
+	}
+
+	if couch.Get(t, "JET-1106", &jet); jet.DisplayName != "Old Name" {
+		t.Errorf("import without a _rev changed JET-1106: %+v", jet)
+	}
+
+	if couch.Get(t, "ITB-1011", &schedule.Config{}) {
+		t.Errorf("invalid config was imported")
+	}
+
+	// overwrite=true replaces configs without a _rev
+	results = post("overwrite=true", "text/csv", csv)
+	if results[0].Result != "updated" || results[0].Rev == existingRev || results[1].Result != "updated" {
+		t.Errorf("got %+v importing with overwrite=true", results)
+	}
+
+	if couch.Get(t, "JET-1106", &jet); jet.DisplayName != "The JET" || !jet.CanCreateEvents {
+		t.Errorf("got %+v after importing JET-1106", jet)
+	}
+
+	// a config with its current _rev is updated, and a stale one is a conflict
+	results = post("", "application/json", `[
+		{"_id": "JET-1106", "_rev": "`+jet.Rev+`", "displayName": "The JET, again", "calendarURL": "local://JET-1106"},
+		{"_id": "ITB-1010", "_rev": "1-stale", "displayName": "ITB 1010", "calendarURL": "local://ITB-1010"}
+	]`)
+	if len(results) != 2 || results[0].Result != "updated" || results[1].Result != "conflict" {
+		t.Errorf("got %+v importing with revisions", results)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/import", strings.NewReader("_id,capacity\n"))
+	req.Header.Set("Content-Type", "text/csv")
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("got %d importing an unknown column, want 400", w.Code)
+	}
+}
+
+func TestUploadBackgroundSize(t *testing.T) {
+	r := gin.New()
+	r.PUT("/rooms/:id/background", UploadRoomBackground)
+
+	tests := map[string]string{
+		"width=3201":  "width must be a positive integer, up to 3200",
+		"height=1921": "height must be a positive integer, up to 1920",
+		"width=0":     "width must be a positive integer",
+		"height=tall": "height must be a positive integer",
+	}
+
+	for query, want := range tests {
+		req := httptest.NewRequest(http.MethodPut, "/rooms/JET-1106/background?"+query, strings.NewReader("not read"))
+		req.Header.Set("Content-Type", "image/png")
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), want) {
+			t.Errorf("%s: got %d %q, want 400 %q", query, w.Code, w.Body, want)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/byuoitav/scheduler/log"
+	"github.com/byuoitav/scheduler/schedule"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// defaultBackground is shown when couch doesn't have a background for a room
+var defaultBackground schedule.Attachment
+
+// SetDefaultBackground sets the image shown when a room, its building, and the static document don't have a background
+func SetDefaultBackground(data []byte, contentType string) {
+	defaultBackground = schedule.Attachment{
+		Data:        data,
+		ContentType: contentType,
+		ETag:        fmt.Sprintf(`"%x"`, sha1.Sum(data)),
+	}
+}
+
+type backgroundManifest struct {
+	// Source is where the images came from: room, building, static, or default
+	Source   string `json:"source"`
+	Mode     string `json:"mode,omitempty"`
+	Interval string `json:"interval,omitempty"`
+
+	// Current is the url of the image that should be showing now, until Next
+	Current string     `json:"current"`
+	Next    *time.Time `json:"next,omitempty"`
+
+	Images []manifestImage `json:"images"`
+}
+
+type manifestImage struct {
+	schedule.BackgroundImage
+	URL string `json:"url"`
+}
+
+// GetBackgroundImg returns the background image that should be showing now in this device's room, or the one named by the image query parameter.
+// Rooms without their own background use their building's, then the static document's, then the image built into the panel.
+func GetBackgroundImg(c *gin.Context) {
+	log.P.Debug("GetBackgroundImg handler called", zap.String("client_ip", c.ClientIP()))
+
+	roomID, err := panelRoom(c)
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	touch(roomID)
+	backgrounds := roomBackgrounds(c.Request.Context(), roomID)
+
+	name := c.Query("image")
+	if len(name) == 0 {
+		current, _ := backgrounds.Rotation.Current(roomNow(c.Request.Context(), roomID))
+		name = current.Attachment
+	}
+
+	if backgrounds.Source == schedule.SourceDefault {
+		serveBackground(c, defaultBackground)
+		return
+	}
+
+	img, err := backgrounds.Image(c.Request.Context(), name)
+	switch {
+	case errors.Is(err, schedule.ErrNotFound) && len(c.Query("image")) > 0:
+		c.String(http.StatusNotFound, err.Error())
+		return
+	case err != nil:
+		log.P.Warn("Failed to get background image, using the default", zap.Error(err), zap.String("roomID", roomID), zap.String("client_ip", c.ClientIP()))
+		img = defaultBackground
+	}
+
+	log.P.Debug("Background image returned successfully", zap.String("roomID", roomID), zap.String("source", backgrounds.Source), zap.String("image", name), zap.String("client_ip", c.ClientIP()))
+	serveBackground(c, img)
+}
+
+// GetBackgroundManifest describes the backgrounds this device's room rotates between, and which one should be showing now
+func GetBackgroundManifest(c *gin.Context) {
+	roomID, err := panelRoom(c)
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	touch(roomID)
+	backgrounds := roomBackgrounds(c.Request.Context(), roomID)
+
+	imageURL := func(name string) string {
+		u := APIPrefix + "/rooms/" + url.PathEscape(roomID) + "/background"
+		if len(name) > 0 {
+			u += "?image=" + url.QueryEscape(name)
+		}
+
+		return u
+	}
+
+	manifest := backgroundManifest{
+		Source:   backgrounds.Source,
+		Mode:     backgrounds.Rotation.Mode,
+		Interval: backgrounds.Rotation.Interval,
+		Current:  imageURL(""),
+		Images:   []manifestImage{},
+	}
+
+	if backgrounds.Source == schedule.SourceDefault {
+		manifest.Images = append(manifest.Images, manifestImage{URL: manifest.Current})
+		c.JSON(http.StatusOK, manifest)
+		return
+	}
+
+	for _, img := range backgrounds.Rotation.Images {
+		manifest.Images = append(manifest.Images, manifestImage{BackgroundImage: img, URL: imageURL(img.Attachment)})
+	}
+
+	current, next := backgrounds.Rotation.Current(roomNow(c.Request.Context(), roomID))
+	manifest.Current = imageURL(current.Attachment)
+	if !next.IsZero() {
+		manifest.Next = &next
+	}
+
+	c.JSON(http.StatusOK, manifest)
+}
+
+// roomBackgrounds finds roomID's backgrounds, falling back to the default image if couch can't be reached
+func roomBackgrounds(ctx context.Context, roomID string) schedule.Backgrounds {
+	backgrounds, err := schedule.GetBackgrounds(ctx, roomID, ident.Building(roomID))
+	if err != nil {
+		log.P.Warn("Failed to get backgrounds, using the default", zap.Error(err), zap.String("roomID", roomID))
+	}
+
+	return backgrounds
+}
+
+func serveBackground(c *gin.Context, img schedule.Attachment) {
+	contentType := img.ContentType
+	if len(contentType) == 0 {
+		contentType = "image/png"
+	}
+
+	c.Header("Cache-Control", "no-cache")
+	if len(img.ETag) > 0 {
+		c.Header("ETag", img.ETag)
+		if c.GetHeader("If-None-Match") == img.ETag {
+			c.Status(http.StatusNotModified)
+			return
+		}
+	}
+
+	c.Data(http.StatusOK, contentType, img.Data)
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/byuoitav/scheduler/clock"
+	"github.com/byuoitav/scheduler/log"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// ServerTimeHeader is the response header the panel keeps its clock in step with the server's by
+const ServerTimeHeader = "X-Server-Time"
+
+// clockChange moves the server's clock. Time moves it to a time, Shift by a duration (like -2h30m), and Frozen stops or starts it.
+type clockChange struct {
+	Time   *time.Time `json:"time"`
+	Shift  string     `json:"shift"`
+	Frozen *bool      `json:"frozen"`
+}
+
+// GetClock returns what time the server thinks it is, and whether it's been moved
+func GetClock(c *gin.Context) {
+	c.JSON(http.StatusOK, clock.Status())
+}
+
+// SetClock moves the server's clock, if it was started with --fake-time
+func SetClock(c *gin.Context) {
+	fake, err := clock.TimeTravel()
+	if err != nil {
+		c.String(http.StatusForbidden, err.Error())
+		return
+	}
+
+	var change clockChange
+	if err := c.ShouldBindJSON(&change); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var shift time.Duration
+	if len(change.Shift) > 0 {
+		if shift, err = time.ParseDuration(change.Shift); err != nil {
+			c.String(http.StatusBadRequest, "invalid shift: "+err.Error())
+			return
+		}
+	}
+
+	frozen := change.Frozen != nil && *change.Frozen
+	switch {
+	case change.Time != nil && frozen:
+		fake.Freeze(*change.Time)
+	case change.Time != nil:
+		fake.Travel(*change.Time)
+	case change.Frozen == nil:
+	case frozen:
+		fake.Freeze(fake.Now())
+	default:
+		fake.Resume()
+	}
+
+	fake.Shift(shift)
+
+	log.P.Warn("Server clock moved", zap.Time("now", fake.Now()), zap.Bool("frozen", frozen), zap.String("client_ip", c.ClientIP()))
+	c.JSON(http.StatusOK, clock.Status())
+}
+
+// ResetClock puts the server's clock back to the real time
+func ResetClock(c *gin.Context) {
+	fake, err := clock.TimeTravel()
+	if errors.Is(err, clock.ErrDisabled) {
+		c.JSON(http.StatusOK, clock.Status())
+		return
+	}
+
+	fake.Reset()
+
+	log.P.Warn("Server clock reset", zap.String("client_ip", c.ClientIP()))
+	c.JSON(http.StatusOK, clock.Status())
+}
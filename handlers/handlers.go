@@ -1,99 +1,91 @@
 package handlers
 
 import (
-	"bytes"
-	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/byuoitav/common/v2/events"
 	"github.com/byuoitav/scheduler/calendars"
+	"github.com/byuoitav/scheduler/clock"
 	"github.com/byuoitav/scheduler/log"
 	"github.com/byuoitav/scheduler/schedule"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
 
-var lastRequest time.Time
-
-// GetConfig returns the config for this device, based on its SYSTEM_ID
+// GetConfig returns the config for this device's room
 func GetConfig(c *gin.Context) {
-	id := os.Getenv("SYSTEM_ID")
-	log.P.Debug("GetConfig handler called", zap.String("SYSTEM_ID", id), zap.String("client_ip", c.ClientIP()))
-	if len(id) == 0 {
-		log.P.Error("SYSTEM_ID is not set", zap.String("client_ip", c.ClientIP()))
-		c.String(http.StatusInternalServerError, "SYSTEM_ID is not set")
-		return
-	}
-	split := strings.Split(id, "-")
-	if len(split) != 3 {
-		log.P.Error("Invalid SYSTEM_ID format", zap.String("SYSTEM_ID", id), zap.String("client_ip", c.ClientIP()))
-		c.String(http.StatusInternalServerError, fmt.Sprintf("invalid SYSTEM_ID %q", id))
-		return
-	}
+	log.P.Debug("GetConfig handler called", zap.String("client_ip", c.ClientIP()))
 
-	config, err := schedule.GetConfig(c.Request.Context(), split[0]+"-"+split[1])
+	roomID, err := panelRoom(c)
 	if err != nil {
-		log.P.Error("Failed to get config", zap.Error(err), zap.String("SYSTEM_ID", id), zap.String("client_ip", c.ClientIP()))
-		c.String(http.StatusInternalServerError, err.Error())
+		c.String(http.StatusBadRequest, err.Error())
 		return
 	}
 
-	lastRequest = time.Now()
-	log.P.Debug("Config returned successfully", zap.String("SYSTEM_ID", id), zap.String("client_ip", c.ClientIP()))
-	c.JSON(http.StatusOK, config)
-}
-
-// GetBackgroundImg retrieves the background image for the device based on its SYSTEM_ID
-func GetBackgroundImg(c *gin.Context) {
-	id := os.Getenv("SYSTEM_ID")
-	log.P.Debug("GetBackgroundImg handler called", zap.String("SYSTEM_ID", id), zap.String("client_ip", c.ClientIP()))
-	if len(id) == 0 {
-		log.P.Error("SYSTEM_ID is not set", zap.String("client_ip", c.ClientIP()))
-		c.String(http.StatusInternalServerError, "SYSTEM_ID is not set")
+	config, err := schedule.GetConfig(c.Request.Context(), roomID)
+	if err != nil {
+		log.P.Error("Failed to get config", zap.Error(err), zap.String("roomID", roomID), zap.String("client_ip", c.ClientIP()))
+		c.String(http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	split := strings.Split(id, "-")
-	if len(split) != 3 {
-		log.P.Error("Invalid SYSTEM_ID format", zap.String("SYSTEM_ID", id), zap.String("client_ip", c.ClientIP()))
-		c.String(http.StatusInternalServerError, fmt.Sprintf("invalid SYSTEM_ID %q", id))
-		return
-	}
+	// the panel only shows book now if the calendar can take the booking
+	config = schedule.PanelCapabilities(c.Request.Context(), config)
 
-	imgBytes, err := schedule.GetBackgroundImage(c.Request.Context(), split[0]+"-"+split[1])
-	if err != nil {
-		log.P.Error("Failed to get background image", zap.Error(err), zap.String("SYSTEM_ID", id), zap.String("client_ip", c.ClientIP()))
-		c.String(http.StatusInternalServerError, err.Error())
-		return
+	// notifier settings can hold credentials, and the panel has no use for them
+	config.HelpNotifiers = nil
+	config.FallbackCalendarURLs = nil
+	config.Calendars = append([]schedule.CalendarSource(nil), config.Calendars...)
+	for i := range config.Calendars {
+		config.Calendars[i].FallbackURLs = nil
 	}
 
-	lastRequest = time.Now()
-	log.P.Debug("Background image returned successfully", zap.String("SYSTEM_ID", id), zap.String("client_ip", c.ClientIP()))
-	c.Header("Content-Type", "image/png")
-	c.Header("Cache-Control", "no-cache")
-	c.Data(http.StatusOK, "image/png", imgBytes)
+	touch(roomID)
+	log.P.Debug("Config returned successfully", zap.String("roomID", roomID), zap.String("client_ip", c.ClientIP()))
+	c.JSON(http.StatusOK, config)
 }
 
+// GetEvents returns a room's events in the room's timezone. With a day query parameter
+// (today, tomorrow, yesterday, or 2006-01-02), only events on that day are returned.
 func GetEvents(c *gin.Context) {
 	roomID := c.Param("roomID")
 	log.P.Debug("GetEvents handler called", zap.String("roomID", roomID), zap.String("client_ip", c.ClientIP()))
 
-	eventsList, err := schedule.GetEvents(c.Request.Context(), roomID)
+	var eventsList []calendars.Event
+	var read schedule.EventSources
+	var err error
+
+	if day := c.Query("day"); len(day) > 0 {
+		eventsList, read, err = schedule.GetEventsOn(c.Request.Context(), roomID, day)
+	} else {
+		eventsList, read, err = schedule.GetEvents(c.Request.Context(), roomID)
+	}
+
+	if errors.Is(err, schedule.ErrInvalidDay) {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
 	if err != nil {
 		log.P.Error("Failed to get events", zap.Error(err), zap.String("roomID", roomID), zap.String("client_ip", c.ClientIP()))
 		c.String(http.StatusInternalServerError, fmt.Sprintf("unable to get events in %q: %s", roomID, err))
 		return
 	}
 
-	lastRequest = time.Now()
-	log.P.Debug("Events returned successfully", zap.String("roomID", roomID), zap.String("client_ip", c.ClientIP()), zap.Int("event_count", len(eventsList)))
+	// which of the room's calendars answered, so the panel can stop offering booking while it's on a fallback
+	c.Header("X-Calendar-Source", read.String())
+	c.Header("X-Calendar-Fallback", strconv.FormatBool(read.BookingFallback()))
+	c.Header(ServerTimeHeader, clock.Now().Format(time.RFC3339Nano))
+
+	touch(roomID)
+	log.P.Debug("Events returned successfully", zap.String("roomID", roomID), zap.String("client_ip", c.ClientIP()), zap.Int("event_count", len(eventsList)), zap.Stringer("sources", read))
 	c.JSON(http.StatusOK, eventsList)
 }
 
@@ -108,7 +100,20 @@ func CreateEvent(c *gin.Context) {
 		return
 	}
 
-	if err := schedule.CreateEvent(c.Request.Context(), roomID, event); err != nil {
+	err := schedule.CreateEvent(c.Request.Context(), roomID, event)
+	switch {
+	case errors.Is(err, schedule.ErrBookingUnavailable):
+		c.String(http.StatusServiceUnavailable, err.Error())
+		return
+	case errors.Is(err, calendars.ErrReadOnly):
+		c.String(http.StatusForbidden, err.Error())
+		return
+	case errors.Is(err, schedule.ErrOverlap):
+		c.String(http.StatusConflict, err.Error())
+		return
+	}
+
+	if err != nil {
 		log.P.Error("Failed to create event", zap.Error(err), zap.String("roomID", roomID), zap.String("event_title", event.Title), zap.String("client_ip", c.ClientIP()))
 		c.String(http.StatusInternalServerError, fmt.Sprintf("unable to create event %q in %q: %s", event.Title, roomID, err))
 		return
@@ -118,124 +123,139 @@ func CreateEvent(c *gin.Context) {
 	c.JSON(http.StatusOK, fmt.Sprintf("Successfully created %q in %q", event.Title, roomID))
 }
 
+// GetStaticElements serves a file attached to the static document, with support for conditional and range requests
 func GetStaticElements(c *gin.Context) {
 	docName := c.Param("doc")
 	log.P.Debug("GetStaticElements handler called", zap.String("doc", docName), zap.String("client_ip", c.ClientIP()))
 
-	file, fileType, err := schedule.GetStatic(c.Request.Context(), docName)
-	if err != nil {
+	file, err := schedule.OpenStatic(c.Request.Context(), docName)
+	switch {
+	case errors.Is(err, schedule.ErrNotFound):
+		c.String(http.StatusNotFound, err.Error())
+		return
+	case err != nil:
 		log.P.Error("Unable to get static element", zap.Error(err), zap.String("doc", docName), zap.String("client_ip", c.ClientIP()))
 		c.String(http.StatusInternalServerError, "unable to get static element")
 		return
 	}
 	defer file.Close()
 
+	if len(file.ContentType) > 0 {
+		c.Header("Content-Type", file.ContentType)
+	}
+
+	c.Header("ETag", strconv.Quote(file.Digest))
+	c.Header("Cache-Control", "no-cache")
+
 	log.P.Debug("Static element returned successfully", zap.String("doc", docName), zap.String("client_ip", c.ClientIP()))
-	c.DataFromReader(http.StatusOK, -1, fileType, file, nil)
+	http.ServeContent(c.Writer, c.Request, file.Name, file.ModTime, file)
 }
 
-func SendHelpRequest(c *gin.Context) {
-	log.P.Debug("SendHelpRequest handler called", zap.String("client_ip", c.ClientIP()))
-	var request schedule.HelpRequest
-	if err := c.ShouldBindJSON(&request); err != nil {
-		log.P.Error("Failed to bind help request JSON", zap.Error(err), zap.String("client_ip", c.ClientIP()))
-		c.String(http.StatusBadRequest, err.Error())
+// ListStaticElements lists the files attached to the static document
+func ListStaticElements(c *gin.Context) {
+	assets, err := schedule.ListStatic(c.Request.Context())
+	if err != nil {
+		log.P.Error("Unable to list static elements", zap.Error(err), zap.String("client_ip", c.ClientIP()))
+		c.String(http.StatusInternalServerError, "unable to list static elements")
 		return
 	}
 
-	id := os.Getenv("SYSTEM_ID")
-	deviceInfo := events.GenerateBasicDeviceInfo(id)
-	roomInfo := events.GenerateBasicRoomInfo(deviceInfo.RoomID)
-
-	event := events.Event{
-		GeneratingSystem: id,
-		Timestamp:        time.Now(),
-		EventTags:        []string{events.DetailState},
-		TargetDevice:     deviceInfo,
-		AffectedRoom:     roomInfo,
-		Key:              "help-request",
-		Value:            "confirm",
-	}
-
-	sendEvent(c.Request.Context(), event)
-	log.P.Debug("Help request sent successfully", zap.String("device_id", request.DeviceID), zap.String("client_ip", c.ClientIP()))
-	c.JSON(http.StatusOK, fmt.Sprintf("Help request sent for device: %s", request.DeviceID))
+	c.JSON(http.StatusOK, assets)
 }
 
+// SendWebsocketCount periodically reports whether a panel is showing each room this server has served
 func SendWebsocketCount(frequency time.Duration) {
-	id := os.Getenv("SYSTEM_ID")
-	deviceInfo := events.GenerateBasicDeviceInfo(id)
-	roomInfo := events.GenerateBasicRoomInfo(deviceInfo.RoomID)
-
 	ticker := time.NewTicker(frequency)
 	defer ticker.Stop()
 
 	for range ticker.C {
-		event := events.Event{
-			GeneratingSystem: id,
-			Timestamp:        time.Now(),
-			EventTags:        []string{events.DetailState},
-			TargetDevice:     deviceInfo,
-			AffectedRoom:     roomInfo,
-			Key:              "websocket-count",
-		}
+		for room, last := range activeRooms() {
+			event := events.Event{
+				GeneratingSystem: ident.SystemID,
+				Timestamp:        clock.Now(),
+				EventTags:        []string{events.DetailState},
+				TargetDevice:     ident.DeviceInfo(),
+				AffectedRoom:     ident.RoomInfo(),
+				Key:              "websocket-count",
+			}
 
-		if time.Since(lastRequest).Seconds() >= 120 {
-			event.Value = strconv.Itoa(0)
-		} else {
-			event.Value = strconv.Itoa(1)
-		}
+			if room != ident.RoomID {
+				event.TargetDevice = ident.DeviceInfoFor(room)
+				event.AffectedRoom = event.TargetDevice.BasicRoomInfo
+			}
 
-		sendEvent(context.Background(), event)
+			if clock.Since(last).Seconds() >= 120 {
+				event.Value = strconv.Itoa(0)
+			} else {
+				event.Value = strconv.Itoa(1)
+			}
+
+			// the next count replaces this one, so it isn't worth keeping across a restart
+			if urls := eventURLs(event); len(urls) > 0 {
+				queue.EnqueueTransient(event, urls...)
+			}
+		}
 	}
 }
 
-func sendEvent(ctx context.Context, event events.Event) {
-	eventProcs := strings.Split(os.Getenv("EVENT_URLS"), ",")
-
-	body, err := json.Marshal(event)
-	if err != nil {
-		log.P.Warn("unable to marshal event", zap.Error(err))
-		return
+// sendEvent queues event for delivery to each of the EVENT_URLS
+func sendEvent(event events.Event) {
+	if urls := eventURLs(event); len(urls) > 0 {
+		queue.Enqueue(event, urls...)
 	}
+}
 
-	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
-	defer cancel()
-
-	wg := &sync.WaitGroup{}
-
-	for i := range eventProcs {
-		if len(eventProcs[i]) == 0 {
-			continue
+// eventURLs returns where event should be sent, or nothing if it can't be
+func eventURLs(event events.Event) []string {
+	var urls []string
+	for _, url := range strings.Split(os.Getenv("EVENT_URLS"), ",") {
+		if len(url) > 0 {
+			urls = append(urls, url)
 		}
+	}
 
-		wg.Add(1)
+	if len(urls) > 0 && queue == nil {
+		log.P.Warn("event queue is not running, dropping event", zap.String("key", event.Key), zap.String("value", event.Value))
+		return nil
+	}
 
-		go func(url string) {
-			log.P.Debug("Sending event", zap.String("url", url), zap.String("key", event.Key), zap.String("value", event.Value))
-			defer wg.Done()
+	return urls
+}
 
-			req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
-			if err != nil {
-				log.P.Warn("unable to create request", zap.Error(err), zap.String("url", url))
-				return
-			}
+// WatchConfig streams server-sent events to a panel, telling it to reload whenever its room's config changes
+func WatchConfig(c *gin.Context) {
+	roomID, err := panelRoom(c)
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
 
-			req.Header.Add("content-type", "application/json")
+	log.P.Debug("Panel watching config", zap.String("roomID", roomID), zap.String("client_ip", c.ClientIP()))
 
-			resp, err := http.DefaultClient.Do(req)
-			if err != nil {
-				log.P.Warn("unable to send request", zap.Error(err), zap.String("url", url))
-				return
-			}
-			defer resp.Body.Close()
+	changes, unsubscribe := schedule.Subscribe()
+	defer unsubscribe()
 
-			if resp.StatusCode/100 != 2 {
-				log.P.Warn("non 200 response", zap.String("url", url), zap.Int("statusCode", resp.StatusCode))
-				return
+	keepAlive := time.NewTicker(30 * time.Second)
+	defer keepAlive.Stop()
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case <-keepAlive.C:
+			c.SSEvent("ping", time.Now().Unix())
+			return true
+		case change := <-changes:
+			if change.ID != roomID {
+				return true
 			}
-		}(eventProcs[i])
-	}
 
-	wg.Wait()
+			log.P.Info("Telling panel to reload", zap.String("roomID", roomID), zap.String("rev", change.Rev), zap.String("client_ip", c.ClientIP()))
+			c.SSEvent("reload", change)
+			return true
+		}
+	})
 }
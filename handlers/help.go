@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/byuoitav/common/v2/events"
+	"github.com/byuoitav/scheduler/clock"
+	"github.com/byuoitav/scheduler/log"
+	"github.com/byuoitav/scheduler/schedule"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const maxHelpMessageLength = 500
+
+var helpRequests = schedule.NewHelpRequests(schedule.DefaultHelpCooldown)
+
+// SetHelpCooldown sets how long repeated help requests from the same device are suppressed.
+// It should be called before the server starts handling requests.
+func SetHelpCooldown(cooldown time.Duration) {
+	helpRequests = schedule.NewHelpRequests(cooldown)
+}
+
+type helpAcknowledgement struct {
+	By       string `json:"by"`
+	Response string `json:"response"`
+}
+
+// SendHelpRequest creates a help request for the device and alerts support staff
+func SendHelpRequest(c *gin.Context) {
+	log.P.Debug("SendHelpRequest handler called", zap.String("client_ip", c.ClientIP()))
+	var request schedule.HelpRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.P.Error("Failed to bind help request JSON", zap.Error(err), zap.String("client_ip", c.ClientIP()))
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if len(request.Message) > maxHelpMessageLength {
+		c.String(http.StatusBadRequest, "help request message is too long")
+		return
+	}
+
+	if len(request.DeviceID) == 0 {
+		request.DeviceID = c.Query("device")
+	}
+
+	if len(request.DeviceID) > 0 {
+		room, device, err := ident.Device(request.DeviceID)
+		if err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+
+		request.RoomID, request.DeviceID = room, device
+	} else {
+		room, err := panelRoom(c)
+		if err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+
+		request.RoomID = room
+		if room == ident.RoomID {
+			request.DeviceID = ident.DeviceID
+		}
+	}
+
+	if !ident.Serves(request.RoomID) {
+		c.String(http.StatusBadRequest, fmt.Sprintf("room %q is not served by %s", request.RoomID, ident.SystemID))
+		return
+	}
+
+	request, created := helpRequests.Create(request)
+	if !created {
+		log.P.Info("Suppressing duplicate help request", zap.String("id", request.ID), zap.String("device_id", request.DeviceID), zap.String("category", request.Category), zap.String("client_ip", c.ClientIP()))
+		c.JSON(http.StatusOK, request)
+		return
+	}
+
+	sendEvent(helpEvent(request, "confirm"))
+	notifyRoom(c.Request.Context(), request)
+	log.P.Debug("Help request sent successfully", zap.String("id", request.ID), zap.String("device_id", request.DeviceID), zap.String("client_ip", c.ClientIP()))
+	c.JSON(http.StatusCreated, request)
+}
+
+// GetHelpRequest returns the current status of a help request
+func GetHelpRequest(c *gin.Context) {
+	id := c.Param("id")
+	log.P.Debug("GetHelpRequest handler called", zap.String("id", id), zap.String("client_ip", c.ClientIP()))
+
+	request, err := helpRequests.Get(id)
+	if err != nil {
+		writeHelpError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, request)
+}
+
+// AcknowledgeHelpRequest records that support staff have seen a help request
+func AcknowledgeHelpRequest(c *gin.Context) {
+	id := c.Param("id")
+	log.P.Debug("AcknowledgeHelpRequest handler called", zap.String("id", id), zap.String("client_ip", c.ClientIP()))
+
+	var ack helpAcknowledgement
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&ack); err != nil {
+			log.P.Error("Failed to bind help acknowledgement JSON", zap.Error(err), zap.String("client_ip", c.ClientIP()))
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	request, err := helpRequests.Acknowledge(id, ack.By, ack.Response)
+	if err != nil {
+		writeHelpError(c, err)
+		return
+	}
+
+	sendEvent(helpEvent(request, "acknowledged"))
+	log.P.Info("Help request acknowledged", zap.String("id", id), zap.String("by", ack.By))
+	c.JSON(http.StatusOK, request)
+}
+
+// CancelHelpRequest withdraws a help request from the panel
+func CancelHelpRequest(c *gin.Context) {
+	id := c.Param("id")
+	log.P.Debug("CancelHelpRequest handler called", zap.String("id", id), zap.String("client_ip", c.ClientIP()))
+
+	request, err := helpRequests.Cancel(id)
+	if err != nil {
+		writeHelpError(c, err)
+		return
+	}
+
+	sendEvent(helpEvent(request, "cancel"))
+	notifyRoom(c.Request.Context(), request)
+
+	log.P.Info("Help request cancelled", zap.String("id", id))
+	c.JSON(http.StatusOK, request)
+}
+
+// notifyRoom alerts the notifiers configured for the request's room
+func notifyRoom(ctx context.Context, request schedule.HelpRequest) {
+	config, err := schedule.GetConfig(ctx, request.RoomID)
+	if err != nil {
+		log.P.Warn("unable to get room config for help notifications", zap.String("room", request.RoomID), zap.Error(err))
+		return
+	}
+
+	notifyHelp(config, request)
+}
+
+func helpEvent(request schedule.HelpRequest, value string) events.Event {
+	roomInfo := events.GenerateBasicRoomInfo(request.RoomID)
+	deviceInfo := events.BasicDeviceInfo{
+		BasicRoomInfo: roomInfo,
+		DeviceID:      request.DeviceID,
+	}
+
+	return events.Event{
+		GeneratingSystem: ident.SystemID,
+		Timestamp:        clock.Now(),
+		EventTags:        []string{events.DetailState},
+		TargetDevice:     deviceInfo,
+		AffectedRoom:     roomInfo,
+		Key:              "help-request",
+		Value:            value,
+		Data:             request,
+	}
+}
+
+func writeHelpError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, schedule.ErrHelpRequestNotFound):
+		c.String(http.StatusNotFound, err.Error())
+	case errors.Is(err, schedule.ErrHelpRequestClosed):
+		c.String(http.StatusConflict, err.Error())
+	default:
+		log.P.Error("Help request failed", zap.Error(err), zap.String("client_ip", c.ClientIP()))
+		c.String(http.StatusInternalServerError, err.Error())
+	}
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/byuoitav/scheduler/clock"
+	"github.com/byuoitav/scheduler/identity"
+	"github.com/gin-gonic/gin"
+)
+
+var ident identity.Identity
+
+// SetIdentity sets who this scheduler is. It should be called before the server starts handling requests.
+func SetIdentity(id identity.Identity) {
+	ident = id
+}
+
+// GetIdentity returns the device and rooms this scheduler was configured as
+func GetIdentity(c *gin.Context) {
+	c.JSON(http.StatusOK, ident)
+}
+
+// panelRoom returns the room a panel request is for. It is taken from, in order:
+// the roomID path parameter, the room query parameter, the device query parameter,
+// and (on multi-tenant servers) the request's host name, falling back to this device's own room.
+func panelRoom(c *gin.Context) (string, error) {
+	for _, room := range []string{c.Param("roomID"), c.Query("room")} {
+		if len(room) == 0 {
+			continue
+		}
+
+		if !ident.Serves(room) {
+			return "", fmt.Errorf("room %q is not served by %s", room, ident.SystemID)
+		}
+
+		return room, nil
+	}
+
+	if device := c.Query("device"); len(device) > 0 {
+		room, _, err := ident.Device(device)
+		if err != nil {
+			return "", err
+		}
+
+		if !ident.Serves(room) {
+			return "", fmt.Errorf("room %q is not served by %s", room, ident.SystemID)
+		}
+
+		return room, nil
+	}
+
+	if ident.MultiTenant {
+		if room, ok := hostRoom(c.Request.Host); ok {
+			return room, nil
+		}
+	}
+
+	if len(ident.RoomID) == 0 {
+		return "", errors.New("unable to tell which room this request is for. include a room or device parameter")
+	}
+
+	return ident.RoomID, nil
+}
+
+// hostRoom resolves a room from the first label of a host name, which can be either
+// a device id (JET-1106-SP1.scheduler.byu.edu) or a room id (JET-1106.scheduler.byu.edu)
+func hostRoom(host string) (string, bool) {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	label := strings.ToUpper(strings.SplitN(host, ".", 2)[0])
+	if len(label) == 0 || net.ParseIP(host) != nil {
+		return "", false
+	}
+
+	if room, _, err := ident.Device(label); err == nil && ident.Serves(room) {
+		return room, true
+	}
+
+	if ident.Serves(label) && strings.Contains(label, "-") {
+		return label, true
+	}
+
+	return "", false
+}
+
+// staleRoomAge is how long a room that isn't one of this server's own is reported on after its panels stop making requests
+const staleRoomAge = 24 * time.Hour
+
+var (
+	lastRequests   = make(map[string]time.Time)
+	lastRequestsMu sync.Mutex
+)
+
+// touch records that a panel showing room just made a request. Rooms this server doesn't serve are ignored.
+func touch(room string) {
+	if !ident.Serves(room) {
+		return
+	}
+
+	lastRequestsMu.Lock()
+	defer lastRequestsMu.Unlock()
+
+	lastRequests[room] = clock.Now()
+}
+
+// activeRooms returns the last time a panel made a request for each of this server's rooms, and each room
+// a panel has shown within staleRoomAge. Rooms older than that are forgotten.
+func activeRooms() map[string]time.Time {
+	lastRequestsMu.Lock()
+	defer lastRequestsMu.Unlock()
+
+	rooms := make(map[string]time.Time, len(lastRequests))
+	for room, last := range lastRequests {
+		if clock.Since(last) > staleRoomAge && !ident.HasRoom(room) {
+			delete(lastRequests, room)
+			continue
+		}
+
+		rooms[room] = last
+	}
+
+	for _, room := range ident.Rooms {
+		if _, ok := rooms[room]; !ok {
+			rooms[room] = time.Time{}
+		}
+	}
+
+	return rooms
+}
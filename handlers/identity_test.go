@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/byuoitav/scheduler/clock"
+	"github.com/byuoitav/scheduler/identity"
+)
+
+func TestActiveRooms(t *testing.T) {
+	id, err := identity.Parse(identity.Options{SystemID: "JET-1106-SP1", MultiTenant: true, RoomPattern: `^JET-`})
+	if err != nil {
+		t.Fatalf("unable to parse identity: %s", err)
+	}
+
+	SetIdentity(id)
+	t.Cleanup(func() { SetIdentity(identity.Identity{}) })
+
+	fake := clock.EnableTimeTravel()
+	fake.Freeze(time.Date(2030, time.January, 1, 9, 0, 0, 0, time.UTC))
+	t.Cleanup(func() { clock.Set(clock.Real{}) })
+
+	lastRequests = make(map[string]time.Time)
+
+	touch("JET-1106")
+	touch("JET-1108")
+	touch("ITB-1010")
+	touch("JET 1110")
+
+	rooms := activeRooms()
+	if len(rooms) != 2 || rooms["JET-1106"].IsZero() || rooms["JET-1108"].IsZero() {
+		t.Fatalf("got active rooms %v, want only the served rooms", rooms)
+	}
+
+	fake.Shift(staleRoomAge + time.Minute)
+
+	// this server's own rooms are always reported
+	rooms = activeRooms()
+	if _, ok := rooms["JET-1106"]; len(rooms) != 1 || !ok {
+		t.Errorf("got active rooms %v after a day without requests", rooms)
+	}
+
+	if _, ok := lastRequests["JET-1108"]; ok {
+		t.Errorf("stale room wasn't forgotten")
+	}
+}
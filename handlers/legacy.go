@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/byuoitav/scheduler/clock"
+	"github.com/byuoitav/scheduler/log"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// APIPrefix is where the panel api is served
+const APIPrefix = "/api/v1"
+
+// LegacyRoute is a route from before the panel api moved under APIPrefix. It is still served,
+// by the same handler as its successor, until every panel has picked up the new frontend.
+type LegacyRoute struct {
+	Method    string
+	Path      string
+	Successor string
+}
+
+// LegacyRoutes are the panel api's old routes, and the routes that replaced them
+var LegacyRoutes = []LegacyRoute{
+	{http.MethodGet, "/:roomID/events", APIPrefix + "/rooms/:roomID/events"},
+	{http.MethodPost, "/:roomID/events", APIPrefix + "/rooms/:roomID/events"},
+	{http.MethodGet, "/config", APIPrefix + "/panel/config"},
+	{http.MethodGet, "/:roomID/config", APIPrefix + "/rooms/:roomID/config"},
+	{http.MethodGet, "/config/updates", APIPrefix + "/panel/config/updates"},
+	{http.MethodGet, "/:roomID/config/updates", APIPrefix + "/rooms/:roomID/config/updates"},
+	{http.MethodGet, "/identity", APIPrefix + "/identity"},
+	{http.MethodGet, "/background", APIPrefix + "/panel/background"},
+	{http.MethodGet, "/:roomID/background", APIPrefix + "/rooms/:roomID/background"},
+	{http.MethodGet, "/background/manifest", APIPrefix + "/panel/background/manifest"},
+	{http.MethodGet, "/:roomID/background/manifest", APIPrefix + "/rooms/:roomID/background/manifest"},
+	{http.MethodGet, "/locale", APIPrefix + "/panel/locale"},
+	{http.MethodGet, "/:roomID/locale", APIPrefix + "/rooms/:roomID/locale"},
+	{http.MethodGet, "/translations", APIPrefix + "/translations"},
+	{http.MethodGet, "/static", APIPrefix + "/static"},
+	{http.MethodGet, "/static/:doc", APIPrefix + "/static/:doc"},
+	{http.MethodPost, "/help", APIPrefix + "/panel/help"},
+	{http.MethodPost, "/:roomID/help", APIPrefix + "/rooms/:roomID/help"},
+	{http.MethodGet, "/help/:id", APIPrefix + "/help/:id"},
+	{http.MethodPost, "/help/:id/ack", APIPrefix + "/help/:id/ack"},
+	{http.MethodPost, "/help/:id/cancel", APIPrefix + "/help/:id/cancel"},
+}
+
+// deprecationLogInterval is how often each client's use of each legacy route is logged
+const deprecationLogInterval = time.Hour
+
+type legacyUse struct {
+	logged time.Time
+	count  int
+}
+
+var (
+	legacyUses   = make(map[string]*legacyUse)
+	legacyPruned time.Time
+	legacyUsesMu sync.Mutex
+)
+
+// Deprecated marks responses from a legacy route with the Deprecation header and a Link to its successor,
+// and logs which clients still use it (once an hour per client, with how many requests they made since).
+func Deprecated(route LegacyRoute) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		successor := route.Successor
+		for _, p := range c.Params {
+			successor = strings.Replace(successor, ":"+p.Key, url.PathEscape(p.Value), 1)
+		}
+
+		c.Header("Deprecation", "true")
+		c.Header("Link", "<"+successor+">; rel=\"successor-version\"")
+
+		key := route.Method + " " + route.Path + " " + c.ClientIP()
+		now := clock.Now()
+
+		legacyUsesMu.Lock()
+		if now.Sub(legacyPruned) >= deprecationLogInterval {
+			pruneLegacyUses(now, key)
+		}
+
+		use, ok := legacyUses[key]
+		if !ok {
+			use = &legacyUse{}
+			legacyUses[key] = use
+		}
+
+		use.count++
+		count := use.count
+		shouldLog := now.Sub(use.logged) >= deprecationLogInterval
+		if shouldLog {
+			use.logged, use.count = now, 0
+		}
+		legacyUsesMu.Unlock()
+
+		if shouldLog {
+			log.P.Warn("Deprecated route used", zap.String("route", route.Method+" "+route.Path), zap.String("successor", route.Successor),
+				zap.String("client_ip", c.ClientIP()), zap.Int("requests", count), zap.String("user_agent", c.Request.UserAgent()))
+		}
+
+		c.Next()
+	}
+}
+
+// pruneLegacyUses forgets clients that haven't been logged in the last deprecationLogInterval, except for keep.
+// legacyUsesMu must be held.
+func pruneLegacyUses(now time.Time, keep string) {
+	for key, use := range legacyUses {
+		if key != keep && now.Sub(use.logged) >= deprecationLogInterval {
+			delete(legacyUses, key)
+		}
+	}
+
+	legacyPruned = now
+}
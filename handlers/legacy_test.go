@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/byuoitav/scheduler/clock"
+	"github.com/gin-gonic/gin"
+)
+
+func TestDeprecated(t *testing.T) {
+	fake := clock.EnableTimeTravel()
+	fake.Freeze(time.Date(2030, time.January, 1, 9, 0, 0, 0, time.UTC))
+	t.Cleanup(func() { clock.Set(clock.Real{}) })
+
+	legacyUses, legacyPruned = make(map[string]*legacyUse), time.Time{}
+
+	route := LegacyRoute{http.MethodGet, "/:roomID/events", APIPrefix + "/rooms/:roomID/events"}
+
+	r := gin.New()
+	r.GET(route.Path, Deprecated(route), func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	get := func(clientIP string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/JET%201106/events", nil)
+		req.RemoteAddr = clientIP + ":51234"
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("10.5.34.1")
+	if w.Code != http.StatusNoContent || w.Header().Get("Deprecation") != "true" {
+		t.Errorf("got %d with Deprecation %q", w.Code, w.Header().Get("Deprecation"))
+	}
+
+	if link := w.Header().Get("Link"); link != `</api/v1/rooms/JET%201106/events>; rel="successor-version"` {
+		t.Errorf("got Link %q", link)
+	}
+
+	use := func(clientIP string) (legacyUse, bool) {
+		legacyUsesMu.Lock()
+		defer legacyUsesMu.Unlock()
+
+		u, ok := legacyUses[route.Method+" "+route.Path+" "+clientIP]
+		if !ok {
+			return legacyUse{}, false
+		}
+
+		return *u, true
+	}
+
+	// the first request is logged, and the rest are counted until the next log
+	get("10.5.34.1")
+	get("10.5.34.1")
+	if u, _ := use("10.5.34.1"); !u.logged.Equal(clock.Now()) || u.count != 2 {
+		t.Errorf("got %+v, want logged now with 2 requests since", u)
+	}
+
+	fake.Shift(deprecationLogInterval / 2)
+	get("10.5.34.2")
+
+	fake.Shift(deprecationLogInterval / 2)
+	get("10.5.34.1")
+	if u, _ := use("10.5.34.1"); !u.logged.Equal(clock.Now()) || u.count != 0 {
+		t.Errorf("got %+v, want logged again once the interval passed", u)
+	}
+
+	if _, ok := use("10.5.34.2"); !ok {
+		t.Errorf("client logged within the interval was forgotten")
+	}
+
+	// clients that stop using legacy routes are forgotten
+	fake.Shift(deprecationLogInterval)
+	get("10.5.34.1")
+	if _, ok := use("10.5.34.2"); ok {
+		t.Errorf("client that stopped using the route is still remembered")
+	}
+
+	if _, ok := use("10.5.34.1"); !ok {
+		t.Errorf("client making the request was forgotten")
+	}
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/byuoitav/scheduler/clock"
+	"github.com/byuoitav/scheduler/i18n"
+	"github.com/byuoitav/scheduler/log"
+	"github.com/byuoitav/scheduler/schedule"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// GetLocale returns the timezone, locale, and clock format for this device's room, along with the room's current time and day
+func GetLocale(c *gin.Context) {
+	roomID, err := panelRoom(c)
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	locale, err := schedule.GetLocale(c.Request.Context(), roomID)
+	if err != nil {
+		log.P.Error("Failed to get locale", zap.Error(err), zap.String("roomID", roomID), zap.String("client_ip", c.ClientIP()))
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	touch(roomID)
+	c.Header(ServerTimeHeader, locale.Now.Format(time.RFC3339Nano))
+	c.JSON(http.StatusOK, locale)
+}
+
+// GetTranslations returns the panel's UI strings in the language given by the lang query parameter,
+// or in the language of this device's room
+func GetTranslations(c *gin.Context) {
+	lang := c.Query("lang")
+	if len(lang) == 0 {
+		roomID, err := panelRoom(c)
+		if err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+
+		locale, err := schedule.GetLocale(c.Request.Context(), roomID)
+		if err != nil {
+			log.P.Error("Failed to get locale", zap.Error(err), zap.String("roomID", roomID), zap.String("client_ip", c.ClientIP()))
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		lang = locale.Locale
+	}
+
+	matched, catalog := i18n.Catalog(lang)
+	c.JSON(http.StatusOK, gin.H{
+		"language":  matched,
+		"available": i18n.Languages(),
+		"strings":   catalog,
+	})
+}
+
+// roomNow returns the current time in roomID's timezone
+func roomNow(ctx context.Context, roomID string) time.Time {
+	config, err := schedule.GetConfig(ctx, roomID)
+	if err != nil {
+		// rooms without a config use the default timezone
+		config = schedule.Config{}
+	}
+
+	return clock.Now().In(config.Location())
+}
@@ -0,0 +1,332 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/byuoitav/scheduler/clock"
+	"github.com/byuoitav/scheduler/log"
+	"github.com/byuoitav/scheduler/schedule"
+	"go.uber.org/zap"
+)
+
+// Notifier alerts someone about a help request
+type Notifier interface {
+	Notify(ctx context.Context, room schedule.Config, request schedule.HelpRequest) error
+}
+
+// NewNotifier builds the notifier described by config
+func NewNotifier(config schedule.NotifierConfig) (Notifier, error) {
+	switch config.Type {
+	case "webhook", "slack", "teams":
+		if len(config.URL) == 0 {
+			return nil, fmt.Errorf("%s notifier must have a url", config.Type)
+		}
+	}
+
+	switch config.Type {
+	case "webhook":
+		return &webhookNotifier{url: config.URL, payload: webhookPayload}, nil
+	case "slack":
+		return &webhookNotifier{url: config.URL, payload: slackPayload}, nil
+	case "teams":
+		return &webhookNotifier{url: config.URL, payload: teamsPayload}, nil
+	case "email":
+		return newEmailNotifier(config)
+	default:
+		return nil, fmt.Errorf("unknown notifier type %q", config.Type)
+	}
+}
+
+// notifyHelp sends request to each of the room's notifiers in the background
+func notifyHelp(room schedule.Config, request schedule.HelpRequest) {
+	if len(room.HelpNotifiers) == 0 {
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		wg := &sync.WaitGroup{}
+
+		for _, config := range room.HelpNotifiers {
+			notifier, err := NewNotifier(config)
+			if err != nil {
+				log.P.Warn("invalid help notifier", zap.String("room", room.ID), zap.String("type", config.Type), zap.Error(err))
+				continue
+			}
+
+			wg.Add(1)
+
+			go func(config schedule.NotifierConfig, notifier Notifier) {
+				defer wg.Done()
+
+				if err := notifier.Notify(ctx, room, request); err != nil {
+					log.P.Warn("unable to send help notification", zap.String("room", room.ID), zap.String("type", config.Type), zap.String("id", request.ID), zap.Error(err))
+					return
+				}
+
+				log.P.Debug("Sent help notification", zap.String("room", room.ID), zap.String("type", config.Type), zap.String("id", request.ID))
+			}(config, notifier)
+		}
+
+		wg.Wait()
+	}()
+}
+
+// helpSubject is a one line description of the request
+func helpSubject(room schedule.Config, request schedule.HelpRequest) string {
+	name := room.DisplayName
+	if len(name) == 0 {
+		name = request.RoomID
+	}
+
+	if request.Status == schedule.HelpRequestCancelled {
+		return fmt.Sprintf("Help request cancelled in %s", name)
+	}
+
+	return fmt.Sprintf("Help requested in %s (%s)", name, request.Category)
+}
+
+// helpBody is a plain text description of the request
+func helpBody(room schedule.Config, request schedule.HelpRequest) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "Room: %s\n", request.RoomID)
+	fmt.Fprintf(&b, "Device: %s\n", request.DeviceID)
+	fmt.Fprintf(&b, "Category: %s\n", request.Category)
+	fmt.Fprintf(&b, "Status: %s\n", request.Status)
+	fmt.Fprintf(&b, "Requested: %s\n", request.CreatedAt.Format(time.RFC1123))
+
+	if len(request.Message) > 0 {
+		fmt.Fprintf(&b, "Message: %s\n", request.Message)
+	}
+
+	fmt.Fprintf(&b, "Request ID: %s\n", request.ID)
+	return b.String()
+}
+
+// webhookNotifier posts a JSON payload to a url
+type webhookNotifier struct {
+	url     string
+	payload func(schedule.Config, schedule.HelpRequest) interface{}
+}
+
+func (w *webhookNotifier) Notify(ctx context.Context, room schedule.Config, request schedule.HelpRequest) error {
+	body, err := json.Marshal(w.payload(room, request))
+	if err != nil {
+		return fmt.Errorf("unable to marshal payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("unable to build request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("bad response (%v)", resp.StatusCode)
+	}
+
+	return nil
+}
+
+func webhookPayload(room schedule.Config, request schedule.HelpRequest) interface{} {
+	return struct {
+		Key      string               `json:"key"`
+		RoomName string               `json:"roomName"`
+		Summary  string               `json:"summary"`
+		Request  schedule.HelpRequest `json:"request"`
+	}{
+		Key:      "help-request",
+		RoomName: room.DisplayName,
+		Summary:  helpSubject(room, request),
+		Request:  request,
+	}
+}
+
+// slackPayload is the body of a slack (or mattermost, rocket.chat, etc.) incoming webhook
+func slackPayload(room schedule.Config, request schedule.HelpRequest) interface{} {
+	return map[string]string{
+		"text": fmt.Sprintf("*%s*\n```%s```", helpSubject(room, request), helpBody(room, request)),
+	}
+}
+
+// teamsPayload is the body of a microsoft teams incoming webhook
+func teamsPayload(room schedule.Config, request schedule.HelpRequest) interface{} {
+	color := "D70000"
+	if request.Status == schedule.HelpRequestCancelled {
+		color = "74BE06"
+	}
+
+	return map[string]string{
+		"@type":      "MessageCard",
+		"@context":   "https://schema.org/extensions",
+		"summary":    helpSubject(room, request),
+		"title":      helpSubject(room, request),
+		"themeColor": color,
+		"text":       strings.ReplaceAll(helpBody(room, request), "\n", "<br>"),
+	}
+}
+
+// headerReplacer keeps user supplied text from adding email headers
+var headerReplacer = strings.NewReplacer("\r", " ", "\n", " ")
+
+// smtpsPort is the port smtp servers take implicit tls connections on, instead of upgrading with STARTTLS
+const smtpsPort = "465"
+
+// errSMTPNoTLS is returned when an smtp server can't upgrade the connection to tls, since credentials are only sent over tls
+var errSMTPNoTLS = errors.New("smtp server doesn't support STARTTLS, and credentials are only sent over tls")
+
+// emailNotifier sends a plain text email through an smtp server. Connections are upgraded to tls with STARTTLS
+// when the server supports it, or use tls from the start on port 465. Servers that need credentials must support one of them.
+type emailNotifier struct {
+	addr string
+	host string
+	auth smtp.Auth
+	from string
+	to   []string
+}
+
+func newEmailNotifier(config schedule.NotifierConfig) (*emailNotifier, error) {
+	switch {
+	case len(config.SMTPAddress) == 0:
+		return nil, fmt.Errorf("email notifier must have an smtpAddress")
+	case len(config.From) == 0:
+		return nil, fmt.Errorf("email notifier must have a from address")
+	case len(config.To) == 0:
+		return nil, fmt.Errorf("email notifier must have at least one to address")
+	}
+
+	username, password := config.Username, config.Password
+	if len(username) == 0 {
+		username = os.Getenv("SMTP_USERNAME")
+	}
+
+	if len(password) == 0 {
+		password = os.Getenv("SMTP_PASSWORD")
+	}
+
+	host, _, err := net.SplitHostPort(config.SMTPAddress)
+	if err != nil {
+		return nil, fmt.Errorf("email notifier's smtpAddress must look like host:port: %w", err)
+	}
+
+	e := &emailNotifier{
+		addr: config.SMTPAddress,
+		host: host,
+		from: config.From,
+		to:   config.To,
+	}
+
+	if len(username) > 0 {
+		e.auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return e, nil
+}
+
+func (e *emailNotifier) Notify(ctx context.Context, room schedule.Config, request schedule.HelpRequest) error {
+	var msg bytes.Buffer
+
+	fmt.Fprintf(&msg, "From: %s\r\n", e.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(e.to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", headerReplacer.Replace(helpSubject(room, request)))
+	fmt.Fprintf(&msg, "Date: %s\r\n", clock.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "Content-Type: text/plain; charset=utf-8\r\n")
+	fmt.Fprintf(&msg, "\r\n")
+	msg.WriteString(strings.ReplaceAll(helpBody(room, request), "\n", "\r\n"))
+
+	if err := e.send(ctx, msg.Bytes()); err != nil {
+		return fmt.Errorf("unable to send email: %w", err)
+	}
+
+	return nil
+}
+
+// send is smtp.SendMail, but it gives up when ctx is done and never sends credentials without tls
+func (e *emailNotifier) send(ctx context.Context, msg []byte) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", e.addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	tlsConfig := &tls.Config{ServerName: e.host}
+
+	_, port, _ := net.SplitHostPort(e.addr)
+	if port == smtpsPort {
+		conn = tls.Client(conn, tlsConfig)
+	}
+
+	c, err := smtp.NewClient(conn, e.host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if port != smtpsPort {
+		if ok, _ := c.Extension("STARTTLS"); ok {
+			if err := c.StartTLS(tlsConfig); err != nil {
+				return err
+			}
+		} else if e.auth != nil {
+			return errSMTPNoTLS
+		}
+	}
+
+	if e.auth != nil {
+		if err := c.Auth(e.auth); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Mail(e.from); err != nil {
+		return err
+	}
+
+	for _, to := range e.to {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
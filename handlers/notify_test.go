@@ -0,0 +1,287 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/byuoitav/scheduler/schedule"
+)
+
+var (
+	notifyRoomConfig = schedule.Config{ID: "ITB-1010", DisplayName: "ITB 1010"}
+	notifyRequest    = schedule.HelpRequest{
+		ID:        "abc123",
+		RoomID:    "ITB-1010",
+		DeviceID:  "ITB-1010-SP1",
+		Category:  "audio",
+		Message:   "no sound",
+		Status:    schedule.HelpRequestOpen,
+		CreatedAt: time.Date(2030, time.January, 1, 9, 0, 0, 0, time.UTC),
+	}
+)
+
+// postedPayload notifies through a notifier of type typ, and returns the json it posted
+func postedPayload(t *testing.T, typ string, request schedule.HelpRequest) map[string]interface{} {
+	t.Helper()
+
+	var payload map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("%s notifier sent content type %q", typ, r.Header.Get("Content-Type"))
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("%s notifier sent invalid json: %s", typ, err)
+		}
+	}))
+	defer srv.Close()
+
+	notifier, err := NewNotifier(schedule.NotifierConfig{Type: typ, URL: srv.URL})
+	if err != nil {
+		t.Fatalf("unable to create %s notifier: %s", typ, err)
+	}
+
+	if err := notifier.Notify(context.Background(), notifyRoomConfig, request); err != nil {
+		t.Fatalf("%s notifier failed: %s", typ, err)
+	}
+
+	return payload
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	payload := postedPayload(t, "webhook", notifyRequest)
+
+	if payload["key"] != "help-request" || payload["roomName"] != "ITB 1010" || payload["summary"] != "Help requested in ITB 1010 (audio)" {
+		t.Errorf("got payload %v", payload)
+	}
+
+	request, _ := payload["request"].(map[string]interface{})
+	if request["id"] != "abc123" || request["deviceID"] != "ITB-1010-SP1" || request["message"] != "no sound" {
+		t.Errorf("got request %v", payload["request"])
+	}
+}
+
+func TestSlackNotifier(t *testing.T) {
+	text, _ := postedPayload(t, "slack", notifyRequest)["text"].(string)
+
+	if !strings.HasPrefix(text, "*Help requested in ITB 1010 (audio)*\n```") || !strings.Contains(text, "Message: no sound\n") {
+		t.Errorf("got text %q", text)
+	}
+}
+
+func TestTeamsNotifier(t *testing.T) {
+	payload := postedPayload(t, "teams", notifyRequest)
+	if payload["@type"] != "MessageCard" || payload["title"] != "Help requested in ITB 1010 (audio)" || payload["themeColor"] != "D70000" {
+		t.Errorf("got payload %v", payload)
+	}
+
+	if text, _ := payload["text"].(string); !strings.Contains(text, "Device: ITB-1010-SP1<br>") {
+		t.Errorf("got text %q", text)
+	}
+
+	cancelled := notifyRequest
+	cancelled.Status = schedule.HelpRequestCancelled
+
+	payload = postedPayload(t, "teams", cancelled)
+	if payload["title"] != "Help request cancelled in ITB 1010" || payload["themeColor"] != "74BE06" {
+		t.Errorf("got payload %v for a cancelled request", payload)
+	}
+}
+
+func TestWebhookNotifierError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	notifier, _ := NewNotifier(schedule.NotifierConfig{Type: "slack", URL: srv.URL})
+	if err := notifier.Notify(context.Background(), notifyRoomConfig, notifyRequest); err == nil {
+		t.Errorf("got no error from a failing webhook")
+	}
+}
+
+func TestNewNotifier(t *testing.T) {
+	tests := []schedule.NotifierConfig{
+		{Type: "carrier-pigeon"},
+		{Type: "webhook"},
+		{Type: "email", From: "a@byu.edu", To: []string{"b@byu.edu"}},
+		{Type: "email", SMTPAddress: "smtp.byu.edu", From: "a@byu.edu", To: []string{"b@byu.edu"}},
+		{Type: "email", SMTPAddress: "smtp.byu.edu:25", To: []string{"b@byu.edu"}},
+		{Type: "email", SMTPAddress: "smtp.byu.edu:25", From: "a@byu.edu"},
+	}
+
+	for _, config := range tests {
+		if _, err := NewNotifier(config); err == nil {
+			t.Errorf("got no error creating %+v", config)
+		}
+	}
+}
+
+// smtpServer is a plain text smtp server that accepts every message
+type smtpServer struct {
+	addr       string
+	extensions []string
+
+	mu       sync.Mutex
+	commands []string
+	data     string
+}
+
+func newSMTPServer(t *testing.T, extensions ...string) *smtpServer {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %s", err)
+	}
+	t.Cleanup(func() { lis.Close() })
+
+	s := &smtpServer{addr: lis.Addr().String(), extensions: extensions}
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+
+			go s.serve(conn)
+		}
+	}()
+
+	return s
+}
+
+func (s *smtpServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	reply := func(lines ...string) {
+		for i, line := range lines {
+			sep := "-"
+			if i == len(lines)-1 {
+				sep = " "
+			}
+
+			conn.Write([]byte(line[:3] + sep + line[4:] + "\r\n"))
+		}
+	}
+
+	reply("220 localhost ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+		verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+
+		s.mu.Lock()
+		s.commands = append(s.commands, verb)
+		s.mu.Unlock()
+
+		switch verb {
+		case "EHLO":
+			lines := []string{"250 localhost"}
+			for _, ext := range s.extensions {
+				lines = append(lines, "250 "+ext)
+			}
+
+			reply(lines...)
+		case "AUTH":
+			reply("235 ok")
+		case "MAIL", "RCPT":
+			reply("250 ok")
+		case "DATA":
+			reply("354 go ahead")
+
+			var data strings.Builder
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+
+				if line == ".\r\n" {
+					break
+				}
+
+				data.WriteString(line)
+			}
+
+			s.mu.Lock()
+			s.data = data.String()
+			s.mu.Unlock()
+
+			reply("250 queued")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
+
+func (s *smtpServer) received() ([]string, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.commands...), s.data
+}
+
+func TestEmailNotifier(t *testing.T) {
+	t.Setenv("SMTP_USERNAME", "")
+	t.Setenv("SMTP_PASSWORD", "")
+
+	srv := newSMTPServer(t)
+	notifier, err := NewNotifier(schedule.NotifierConfig{Type: "email", SMTPAddress: srv.addr, From: "scheduler@byu.edu", To: []string{"av@byu.edu", "desk@byu.edu"}})
+	if err != nil {
+		t.Fatalf("unable to create email notifier: %s", err)
+	}
+
+	request := notifyRequest
+	request.Category = "audio\r\nBcc: everyone@byu.edu"
+	if err := notifier.Notify(context.Background(), notifyRoomConfig, request); err != nil {
+		t.Fatalf("unable to send email: %s", err)
+	}
+
+	commands, data := srv.received()
+	if got := strings.Join(commands, " "); got != "EHLO MAIL RCPT RCPT DATA QUIT" {
+		t.Errorf("got commands %q", got)
+	}
+
+	for _, want := range []string{
+		"From: scheduler@byu.edu\r\n",
+		"To: av@byu.edu, desk@byu.edu\r\n",
+		"Subject: Help requested in ITB 1010 (audio  Bcc: everyone@byu.edu)\r\n",
+		"\r\n\r\nRoom: ITB-1010\r\nDevice: ITB-1010-SP1\r\n",
+	} {
+		if !strings.Contains(data, want) {
+			t.Errorf("email is missing %q:\n%s", want, data)
+		}
+	}
+}
+
+func TestEmailNotifierNeedsTLSForCredentials(t *testing.T) {
+	srv := newSMTPServer(t, "AUTH PLAIN")
+	notifier, err := NewNotifier(schedule.NotifierConfig{Type: "email", SMTPAddress: srv.addr, Username: "scheduler", Password: "hunter2", From: "scheduler@byu.edu", To: []string{"av@byu.edu"}})
+	if err != nil {
+		t.Fatalf("unable to create email notifier: %s", err)
+	}
+
+	if err := notifier.Notify(context.Background(), notifyRoomConfig, notifyRequest); !errors.Is(err, errSMTPNoTLS) {
+		t.Errorf("got %v sending credentials to a server without STARTTLS", err)
+	}
+
+	if commands, _ := srv.received(); strings.Contains(strings.Join(commands, " "), "AUTH") {
+		t.Errorf("credentials were sent without tls: %v", commands)
+	}
+}
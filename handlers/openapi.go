@@ -0,0 +1,436 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/byuoitav/scheduler/calendars"
+	"github.com/byuoitav/scheduler/clock"
+	"github.com/byuoitav/scheduler/identity"
+	"github.com/byuoitav/scheduler/log"
+	"github.com/byuoitav/scheduler/openapi"
+	"github.com/byuoitav/scheduler/schedule"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// maxValidatedBody is the largest request or response body that is checked against the api
+const maxValidatedBody = 1 << 20
+
+// dayPattern is what the day query parameter of events can be
+const dayPattern = `^(today|tomorrow|yesterday|\d{4}-\d{2}-\d{2})$`
+
+// API describes every route the scheduler serves. It is served at /openapi.json.
+var API = newAPI()
+
+func newAPI() *openapi.Document {
+	api := openapi.New("Scheduler", "1.0.0", "The scheduling panel's api: room events, configs, backgrounds, help requests, and the admin api.")
+
+	admin := api.BearerAuth("admin", "ADMIN_TOKEN as a bearer token, or ADMIN_USERNAME and ADMIN_PASSWORD with basic auth")
+	adminOp := func(op openapi.Operation) openapi.Operation {
+		op.Tags = []string{"admin"}
+		op.Security = admin
+		if op.Responses == nil {
+			op.Responses = make(map[string]*openapi.Response)
+		}
+
+		op.Responses["401"] = openapi.JSONResponse("the request isn't from an admin", openapi.String(""))
+		if _, ok := op.Responses["403"]; !ok {
+			op.Responses["403"] = openapi.JSONResponse("the admin api is disabled", openapi.String(""))
+		}
+		return op
+	}
+
+	var (
+		room    = openapi.QueryParam("room", "room the request is for, instead of this device's", openapi.String(""))
+		device  = openapi.QueryParam("device", "device the request is from, which says which room it is for", openapi.String(""))
+		roomID  = openapi.PathParam("roomID", "room the request is for")
+		event   = api.Schema(calendars.Event{})
+		config  = api.Schema(schedule.Config{})
+		help    = api.Schema(schedule.HelpRequest{})
+		serverT = map[string]openapi.Header{ServerTimeHeader: {Description: "the server's clock, which the panel keeps in step with", Schema: &openapi.Schema{Type: "string", Format: "date-time"}}}
+	)
+
+	ok := func(description string, schema *openapi.Schema) map[string]*openapi.Response {
+		return map[string]*openapi.Response{"200": openapi.JSONResponse(description, schema)}
+	}
+
+	// events
+	events := openapi.JSONResponse("the room's events, sorted by start time, in the room's timezone", openapi.Array(event))
+	events.Headers = map[string]openapi.Header{
+		"X-Calendar-Source":   {Description: "which of the room's calendars answered, like teamup=primary", Schema: openapi.String("")},
+		"X-Calendar-Fallback": {Description: "true if bookings would go to a fallback calendar", Schema: openapi.Boolean("")},
+		ServerTimeHeader:      serverT[ServerTimeHeader],
+	}
+
+	api.Add(http.MethodGet, APIPrefix+"/rooms/{roomID}/events", openapi.Operation{
+		Summary:    "Get a room's events",
+		Tags:       []string{"panel"},
+		Parameters: []openapi.Parameter{roomID, openapi.QueryParam("day", "only return events on this day in the room (today, tomorrow, yesterday, or 2006-01-02)", openapi.String("").Matching(dayPattern))},
+		Responses:  map[string]*openapi.Response{"200": events},
+	})
+	api.Add(http.MethodPost, APIPrefix+"/rooms/{roomID}/events", openapi.Operation{
+		Summary:     "Book an event on a room's calendar",
+		Tags:        []string{"panel"},
+		Parameters:  []openapi.Parameter{roomID},
+		RequestBody: openapi.JSONBody("the event", event.Requiring("startTime", "endTime")),
+		Responses: map[string]*openapi.Response{
+			"200": openapi.JSONResponse("a message saying the event was booked", openapi.String("")),
+			"403": openapi.TextResponse("the room's calendar can't be booked"),
+			"409": openapi.TextResponse("the event overlaps another one"),
+			"503": openapi.TextResponse("the room's calendar is on a fallback that can't be booked"),
+		},
+	})
+
+	// configs
+	for _, path := range []string{APIPrefix + "/panel/config", APIPrefix + "/rooms/{roomID}/config"} {
+		params := []openapi.Parameter{room, device}
+		if strings.Contains(path, "{roomID}") {
+			params = []openapi.Parameter{roomID}
+		}
+
+		api.Add(http.MethodGet, path, openapi.Operation{
+			Summary:    "Get a room's config, without notifier or fallback settings",
+			Tags:       []string{"panel"},
+			Parameters: params,
+			Responses:  ok("the room's config", config),
+		})
+		api.Add(http.MethodGet, path+"/updates", openapi.Operation{
+			Summary:    "Server-sent events telling the panel to reload when its room's config changes",
+			Tags:       []string{"panel"},
+			Parameters: params,
+			Responses:  map[string]*openapi.Response{"200": {Description: "reload events with the changed config's id and rev, and pings", Content: map[string]openapi.MediaType{"text/event-stream": {}}}},
+		})
+	}
+
+	api.Add(http.MethodGet, APIPrefix+"/identity", openapi.Operation{
+		Summary:   "Get the device and rooms this server is for",
+		Tags:      []string{"panel"},
+		Responses: ok("who this server is", api.Schema(identity.Identity{})),
+	})
+
+	// backgrounds
+	image := map[string]*openapi.Response{
+		"200": {Description: "the image", Content: map[string]openapi.MediaType{"image/*": {}}},
+		"304": {Description: "the image hasn't changed since If-None-Match"},
+		"404": openapi.TextResponse("the room has no image by that name"),
+	}
+	manifest := ok("the images the room rotates between, and which is showing now", api.Schema(backgroundManifest{}))
+
+	for _, path := range []string{APIPrefix + "/panel/background", APIPrefix + "/rooms/{roomID}/background"} {
+		params := []openapi.Parameter{room, device}
+		if strings.Contains(path, "{roomID}") {
+			params = []openapi.Parameter{roomID}
+		}
+
+		api.Add(http.MethodGet, path, openapi.Operation{
+			Summary:    "Get a room's background image",
+			Tags:       []string{"panel"},
+			Parameters: append(params, openapi.QueryParam("image", "one of the images the room rotates between, instead of the current one", openapi.String(""))),
+			Responses:  image,
+		})
+		api.Add(http.MethodGet, path+"/manifest", openapi.Operation{
+			Summary:    "Get the backgrounds a room rotates between",
+			Tags:       []string{"panel"},
+			Parameters: params,
+			Responses:  manifest,
+		})
+	}
+
+	// locale
+	locale := ok("the room's timezone, locale, and clock, and its current time and day", api.Schema(schedule.RoomLocale{}))
+	locale["200"].Headers = serverT
+
+	api.Add(http.MethodGet, APIPrefix+"/panel/locale", openapi.Operation{
+		Summary:    "Get the timezone and locale of the device's room",
+		Tags:       []string{"panel"},
+		Parameters: []openapi.Parameter{room, device},
+		Responses:  locale,
+	})
+	api.Add(http.MethodGet, APIPrefix+"/rooms/{roomID}/locale", openapi.Operation{
+		Summary:   "Get the timezone and locale of a room",
+		Tags:      []string{"panel"},
+		Responses: locale,
+	})
+	api.Add(http.MethodGet, APIPrefix+"/translations", openapi.Operation{
+		Summary:    "Get the panel's UI strings in a language",
+		Tags:       []string{"panel"},
+		Parameters: []openapi.Parameter{openapi.QueryParam("lang", "language, instead of the room's", openapi.String("")), room, device},
+		Responses: ok("the strings, in the closest language there are translations for", openapi.Object(map[string]*openapi.Schema{
+			"language":  openapi.String("the language the strings are in"),
+			"available": openapi.Array(openapi.String("")),
+			"strings":   {Type: "object", AdditionalProperties: openapi.String("")},
+		})),
+	})
+
+	// static files
+	api.Add(http.MethodGet, APIPrefix+"/static", openapi.Operation{
+		Summary:   "List the files on the static document",
+		Tags:      []string{"panel"},
+		Responses: ok("the files", api.Schema([]schedule.StaticAsset{})),
+	})
+	api.Add(http.MethodGet, APIPrefix+"/static/{doc}", openapi.Operation{
+		Summary: "Get a file on the static document",
+		Tags:    []string{"panel"},
+		Responses: map[string]*openapi.Response{
+			"200": {Description: "the file", Content: map[string]openapi.MediaType{"*/*": {}}},
+			"206": {Description: "the requested range of the file", Content: map[string]openapi.MediaType{"*/*": {}}},
+			"304": {Description: "the file hasn't changed since If-None-Match"},
+		},
+	})
+
+	// help requests
+	for _, path := range []string{APIPrefix + "/panel/help", APIPrefix + "/rooms/{roomID}/help"} {
+		params := []openapi.Parameter{room, device}
+		if strings.Contains(path, "{roomID}") {
+			params = []openapi.Parameter{roomID, device}
+		}
+
+		api.Add(http.MethodPost, path, openapi.Operation{
+			Summary:     "Ask for help in a room",
+			Tags:        []string{"help"},
+			Parameters:  params,
+			RequestBody: openapi.JSONBody("what the panel needs help with", help),
+			Responses: map[string]*openapi.Response{
+				"201": openapi.JSONResponse("the new help request", help),
+				"200": openapi.JSONResponse("the same request, made recently from the same device", help),
+			},
+		})
+	}
+
+	api.Add(http.MethodGet, APIPrefix+"/help/{id}", openapi.Operation{
+		Summary:   "Get the status of a help request",
+		Tags:      []string{"help"},
+		Responses: map[string]*openapi.Response{"200": openapi.JSONResponse("the help request", help), "404": openapi.TextResponse("there's no help request with that id")},
+	})
+	api.Add(http.MethodPost, APIPrefix+"/help/{id}/ack", openapi.Operation{
+		Summary:     "Acknowledge a help request (support desk)",
+		Tags:        []string{"help"},
+		RequestBody: &openapi.RequestBody{Description: "who acknowledged it, and what they said", Content: map[string]openapi.MediaType{openapi.ContentJSON: {Schema: api.Schema(helpAcknowledgement{})}}},
+		Responses:   map[string]*openapi.Response{"200": openapi.JSONResponse("the help request", help), "404": openapi.TextResponse("there's no help request with that id")},
+	})
+	api.Add(http.MethodPost, APIPrefix+"/help/{id}/cancel", openapi.Operation{
+		Summary:   "Cancel a help request",
+		Tags:      []string{"help"},
+		Responses: map[string]*openapi.Response{"200": openapi.JSONResponse("the help request", help), "404": openapi.TextResponse("there's no help request with that id")},
+	})
+
+	// server
+	api.Add(http.MethodGet, "/status", openapi.Operation{
+		Summary:   "Health check",
+		Tags:      []string{"server"},
+		Responses: map[string]*openapi.Response{"200": openapi.TextResponse("healthy")},
+	})
+	api.Add(http.MethodGet, "/log/{level}", openapi.Operation{
+		Summary:    "Set the log level",
+		Tags:       []string{"server"},
+		Parameters: []openapi.Parameter{{Name: "level", In: openapi.InPath, Required: true, Schema: openapi.Enum("", "debug", "info", "warn", "error", "panic")}},
+		Responses:  map[string]*openapi.Response{"200": openapi.TextResponse("the new level")},
+	})
+	api.Add(http.MethodGet, "/openapi.json", openapi.Operation{
+		Summary:   "Get this document",
+		Tags:      []string{"server"},
+		Responses: ok("the scheduler's OpenAPI document", &openapi.Schema{Type: "object"}),
+	})
+
+	web := map[string]*openapi.Response{"200": {Description: "the file, or the panel for paths that aren't files", Content: map[string]openapi.MediaType{"*/*": {}}}}
+	api.Add(http.MethodGet, "/web/{filepath}", openapi.Operation{Summary: "Get the panel's web assets", Tags: []string{"server"}, Responses: web})
+	api.Add(http.MethodHead, "/web/{filepath}", openapi.Operation{Summary: "Check the panel's web assets", Tags: []string{"server"}, Responses: web})
+
+	// admin
+	rev := openapi.QueryParam("rev", "revision the change is to, instead of If-Match", openapi.String(""))
+	configs := openapi.JSONResponse("the room config", config)
+	invalid := openapi.JSONResponse("the config isn't valid", api.Schema(schedule.ValidationError{}))
+
+	api.Add(http.MethodGet, "/admin/rooms", adminOp(openapi.Operation{
+		Summary:   "List room configs",
+		Responses: ok("every room config", openapi.Array(config)),
+	}))
+	api.Add(http.MethodPost, "/admin/rooms", adminOp(openapi.Operation{
+		Summary:     "Create a room config",
+		Parameters:  []openapi.Parameter{openapi.QueryParam("checkURLs", "make sure the calendar urls answer (default true)", openapi.Boolean(""))},
+		RequestBody: openapi.JSONBody("the new config, without a _rev", config),
+		Responses:   map[string]*openapi.Response{"201": configs, "409": openapi.TextResponse("the room already has a config"), "422": invalid},
+	}))
+	api.Add(http.MethodGet, "/admin/rooms/{id}", adminOp(openapi.Operation{
+		Summary:   "Get a room config",
+		Responses: map[string]*openapi.Response{"200": configs, "404": openapi.TextResponse("the room has no config")},
+	}))
+	api.Add(http.MethodPut, "/admin/rooms/{id}", adminOp(openapi.Operation{
+		Summary:     "Update a room config",
+		Parameters:  []openapi.Parameter{openapi.QueryParam("checkURLs", "make sure the calendar urls answer (default true)", openapi.Boolean(""))},
+		RequestBody: openapi.JSONBody("the config, with the _rev it is a change to (or If-Match)", config),
+		Responses:   map[string]*openapi.Response{"200": configs, "409": openapi.TextResponse("the config has changed since _rev"), "422": invalid, "428": openapi.TextResponse("_rev or If-Match is missing")},
+	}))
+	api.Add(http.MethodDelete, "/admin/rooms/{id}", adminOp(openapi.Operation{
+		Summary:    "Delete a room config",
+		Parameters: []openapi.Parameter{rev},
+		Responses:  map[string]*openapi.Response{"204": {Description: "the config was deleted"}, "409": openapi.TextResponse("the config has changed since rev"), "428": openapi.TextResponse("rev or If-Match is missing")},
+	}))
+	api.Add(http.MethodPost, "/admin/rooms/import", adminOp(openapi.Operation{
+		Summary: "Create or update many room configs",
+		Parameters: []openapi.Parameter{
+			openapi.QueryParam("dryRun", "only validate the configs", openapi.Boolean("")),
+			openapi.QueryParam("overwrite", "replace existing configs that don't have a _rev", openapi.Boolean("")),
+			openapi.QueryParam("checkURLs", "make sure the calendar urls answer (default true)", openapi.Boolean("")),
+		},
+		RequestBody: &openapi.RequestBody{Required: true, Content: map[string]openapi.MediaType{
+			openapi.ContentJSON: {Schema: openapi.Array(config)},
+			"text/csv":          {},
+		}},
+		Responses: ok("what happened to each config", api.Schema([]importResult{})),
+	}))
+
+	upload := adminOp(openapi.Operation{
+		Summary: "Upload a background image, resized to fit panels",
+		Parameters: []openapi.Parameter{
+			openapi.QueryParam("name", "attachment to save it as (default background)", openapi.String("")),
+			openapi.QueryParam("darken", "how much to darken it, from 0 to 1", openapi.Number("").Between(0, 1)),
+			openapi.QueryParam("width", "width to resize it to", openapi.Integer("").Between(1, schedule.MaxBackgroundWidth)),
+			openapi.QueryParam("height", "height to resize it to", openapi.Integer("").Between(1, schedule.MaxBackgroundHeight)),
+		},
+		RequestBody: &openapi.RequestBody{Required: true, Content: map[string]openapi.MediaType{"image/*": {}, "multipart/form-data": {}}},
+		Responses: map[string]*openapi.Response{
+			"200": openapi.JSONResponse("the saved image", openapi.Object(map[string]*openapi.Schema{
+				"id":     openapi.String("document the image is attached to"),
+				"name":   openapi.String("attachment name"),
+				"rev":    openapi.String("the document's new revision"),
+				"width":  openapi.Integer(""),
+				"height": openapi.Integer(""),
+				"size":   openapi.Integer("bytes"),
+			})),
+			"413": openapi.TextResponse("the image is too large"),
+			"422": openapi.TextResponse("the image can't be decoded"),
+		},
+	})
+	api.Add(http.MethodPut, "/admin/rooms/{id}/background", upload)
+	api.Add(http.MethodPut, "/admin/buildings/{id}/background", upload)
+
+	api.Add(http.MethodGet, "/admin/calendars", adminOp(openapi.Operation{
+		Summary:   "Health of each calendar rooms read events from",
+		Responses: ok("each calendar's health", api.Schema([]schedule.SourceHealth{})),
+	}))
+	api.Add(http.MethodGet, "/admin/queue", adminOp(openapi.Operation{
+		Summary: "List pending and failed event deliveries",
+		Responses: map[string]*openapi.Response{
+			"200": openapi.JSONResponse("the queue", api.Schema(QueueStatus{})),
+			"503": openapi.TextResponse("the queue isn't running"),
+		},
+	}))
+
+	clockState := ok("what time the server thinks it is", api.Schema(clock.State{}))
+	api.Add(http.MethodGet, "/admin/clock", adminOp(openapi.Operation{Summary: "What time the server thinks it is", Responses: clockState}))
+	api.Add(http.MethodPut, "/admin/clock", adminOp(openapi.Operation{
+		Summary:     "Move the server's clock (with --fake-time)",
+		RequestBody: openapi.JSONBody("where to move it", api.Schema(clockChange{})),
+		Responses: map[string]*openapi.Response{
+			"200": clockState["200"],
+			"403": {Description: "the admin api is disabled (json), or time travel is (text)", Content: map[string]openapi.MediaType{
+				openapi.ContentJSON: {Schema: openapi.String("")},
+				openapi.ContentText: {Schema: openapi.String("")},
+			}},
+		},
+	}))
+	api.Add(http.MethodDelete, "/admin/clock", adminOp(openapi.Operation{Summary: "Put the server's clock back to the real time", Responses: clockState}))
+
+	for _, method := range BackendAdminMethods {
+		api.Add(method, "/admin/backends/{backend}/{path}", adminOp(openapi.Operation{
+			Summary:   "A linked-in calendar backend's admin endpoints, like the fake backend's faults. path can have slashes in it.",
+			Responses: map[string]*openapi.Response{"200": {Description: "whatever the backend returns", Content: map[string]openapi.MediaType{"*/*": {}}}},
+		}))
+	}
+
+	// legacy routes are the same operations as their successors
+	for _, route := range LegacyRoutes {
+		op := *api.Paths[specPath(route.Successor)][strings.ToLower(route.Method)]
+		op.Description = "Use " + route.Method + " " + specPath(route.Successor) + " instead."
+		op.Deprecated = true
+		api.Add(route.Method, specPath(route.Path), op)
+	}
+
+	return api
+}
+
+// specPath converts a gin route's :params to an OpenAPI path's {params}
+func specPath(route string) string {
+	return ginParam.ReplaceAllString(route, "{$1}")
+}
+
+var ginParam = regexp.MustCompile(`:(\w+)`)
+
+// BackendAdminMethods are the methods calendar backends' admin endpoints are served with
+var BackendAdminMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+// GetOpenAPI serves the api's OpenAPI document
+func GetOpenAPI(c *gin.Context) {
+	API.ServeHTTP(c.Writer, c.Request)
+}
+
+// ValidateAPI rejects requests that don't match the api with a 400. While debug logging is on,
+// it also logs responses that don't match, which means the api (or the document) has drifted.
+// Admin requests are only checked once they're authorized, by ValidateAPI running again after RequireAdmin.
+func ValidateAPI(c *gin.Context) {
+	op, _, ok := API.Find(c.Request.Method, c.Request.URL.EscapedPath())
+	if !ok || (len(op.Security) > 0 && !c.GetBool(adminKey)) {
+		c.Next()
+		return
+	}
+
+	var body []byte
+	if op.BodyIsJSON(c.ContentType()) {
+		var err error
+		body, err = openapi.ReadBody(c.Request, maxValidatedBody)
+		switch {
+		case errors.Is(err, openapi.ErrBodyTooLarge):
+			c.String(http.StatusRequestEntityTooLarge, err.Error())
+			c.Abort()
+			return
+		case err != nil:
+			c.String(http.StatusBadRequest, err.Error())
+			c.Abort()
+			return
+		}
+	}
+
+	if err := API.ValidateRequest(c.Request, body); err != nil {
+		log.P.Debug("Invalid request", zap.Error(err), zap.String("client_ip", c.ClientIP()))
+		c.String(http.StatusBadRequest, err.Error())
+		c.Abort()
+		return
+	}
+
+	if !log.Config.Level.Enabled(zapcore.DebugLevel) {
+		c.Next()
+		return
+	}
+
+	w := &recordingWriter{ResponseWriter: c.Writer}
+	c.Writer = w
+	c.Next()
+
+	if err := API.ValidateResponse(c.Request.Method, c.Request.URL.EscapedPath(), w.Status(), w.Header(), w.body.Bytes()); err != nil {
+		log.P.Warn("Response doesn't match the api", zap.Error(err))
+	}
+}
+
+// recordingWriter keeps a copy of json responses, to check them once they're written
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	if w.body.Len() < maxValidatedBody && strings.HasPrefix(w.Header().Get("Content-Type"), openapi.ContentJSON) {
+		w.body.Write(b)
+	}
+
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestValidateAPIBody(t *testing.T) {
+	r := gin.New()
+	r.Use(ValidateAPI)
+	r.POST(APIPrefix+"/rooms/:roomID/events", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	post := func(body string, chunked bool) int {
+		req := httptest.NewRequest(http.MethodPost, APIPrefix+"/rooms/JET-1106/events", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if chunked {
+			req.ContentLength = -1
+		}
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	event := `{"title": "Walk-up", "startTime": "2030-06-03T10:00:00Z", "endTime": "2030-06-03T10:30:00Z"}`
+	huge := `{"title": "` + strings.Repeat("x", maxValidatedBody) + `", "startTime": "2030-06-03T10:00:00Z", "endTime": "2030-06-03T10:30:00Z"}`
+
+	tests := []struct {
+		name    string
+		body    string
+		chunked bool
+		want    int
+	}{
+		{"valid", event, false, http.StatusNoContent},
+		{"valid and chunked", event, true, http.StatusNoContent},
+		{"invalid and chunked", `{"title": "Walk-up"}`, true, http.StatusBadRequest},
+		{"too large", huge, false, http.StatusRequestEntityTooLarge},
+		{"too large and chunked", huge, true, http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		if code := post(tt.body, tt.chunked); code != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.name, code, tt.want)
+		}
+	}
+}
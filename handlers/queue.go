@@ -0,0 +1,437 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/byuoitav/common/v2/events"
+	"github.com/byuoitav/scheduler/log"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultMaxDeliveryAttempts is how many times a delivery is tried before it is dead-lettered
+	DefaultMaxDeliveryAttempts = 10
+
+	baseRetryDelay = 5 * time.Second
+	maxRetryDelay  = 10 * time.Minute
+
+	// maxFailedDeliveries caps how many dead-lettered deliveries are kept around
+	maxFailedDeliveries = 500
+)
+
+// delivery is a single event waiting to be sent to a single destination
+type delivery struct {
+	ID          string       `json:"id"`
+	URL         string       `json:"url"`
+	Event       events.Event `json:"event"`
+	Attempts    int          `json:"attempts"`
+	CreatedAt   time.Time    `json:"createdAt"`
+	NextAttempt time.Time    `json:"nextAttempt"`
+	LastError   string       `json:"lastError,omitempty"`
+
+	// transient deliveries aren't persisted
+	transient bool
+}
+
+// QueueStatus is a snapshot of the deliveries in the event queue
+type QueueStatus struct {
+	Pending []delivery `json:"pending"`
+	Failed  []delivery `json:"failed"`
+}
+
+// EventQueue delivers events to every EVENT_URLS destination, retrying each destination
+// independently with backoff and persisting undelivered events to disk so they survive a restart.
+type EventQueue struct {
+	path        string
+	maxAttempts int
+	client      *http.Client
+
+	mu      sync.Mutex
+	pending []*delivery
+	failed  []*delivery
+	wake    chan struct{}
+}
+
+var queue *EventQueue
+
+// StartEventQueue loads any deliveries persisted at path and starts delivering them in the background.
+// It must be called before any events are sent.
+func StartEventQueue(ctx context.Context, path string, maxAttempts int) error {
+	q := newEventQueue(path, maxAttempts)
+	if err := q.load(); err != nil {
+		return fmt.Errorf("unable to load event queue: %w", err)
+	}
+
+	log.P.Info("Event queue started", zap.String("path", path), zap.Int("pending", len(q.pending)), zap.Int("failed", len(q.failed)))
+
+	queue = q
+	go q.run(ctx)
+	return nil
+}
+
+func newEventQueue(path string, maxAttempts int) *EventQueue {
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultMaxDeliveryAttempts
+	}
+
+	return &EventQueue{
+		path:        path,
+		maxAttempts: maxAttempts,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+// Enqueue adds a delivery of event for each url
+func (q *EventQueue) Enqueue(event events.Event, urls ...string) {
+	q.enqueue(event, false, urls)
+}
+
+// EnqueueTransient adds a delivery of event for each url that is retried like any other, but isn't persisted.
+// It's for events that are stale by the time the server restarts, like websocket counts.
+func (q *EventQueue) EnqueueTransient(event events.Event, urls ...string) {
+	q.enqueue(event, true, urls)
+}
+
+func (q *EventQueue) enqueue(event events.Event, transient bool, urls []string) {
+	now := time.Now()
+
+	q.mu.Lock()
+	for _, url := range urls {
+		q.pending = append(q.pending, &delivery{
+			ID:          newDeliveryID(),
+			URL:         url,
+			Event:       event,
+			CreatedAt:   now,
+			NextAttempt: now,
+			transient:   transient,
+		})
+	}
+
+	if !transient {
+		q.persist()
+	}
+	q.mu.Unlock()
+
+	q.notify()
+}
+
+// Status returns a copy of the pending and failed deliveries
+func (q *EventQueue) Status() QueueStatus {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	status := QueueStatus{
+		Pending: make([]delivery, 0, len(q.pending)),
+		Failed:  make([]delivery, 0, len(q.failed)),
+	}
+
+	for _, d := range q.pending {
+		status.Pending = append(status.Pending, *d)
+	}
+
+	for _, d := range q.failed {
+		status.Failed = append(status.Failed, *d)
+	}
+
+	return status
+}
+
+func (q *EventQueue) notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (q *EventQueue) run(ctx context.Context) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		case <-q.wake:
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+		}
+
+		q.deliverDue(ctx)
+		timer.Reset(q.untilNextAttempt())
+	}
+}
+
+// untilNextAttempt returns how long until the next pending delivery is due. Only the first delivery
+// to each destination is counted, since the ones after it wait for it to be delivered.
+func (q *EventQueue) untilNextAttempt() time.Duration {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	next := maxRetryDelay
+	first := make(map[string]bool)
+	for _, d := range q.pending {
+		if first[d.URL] {
+			continue
+		}
+
+		first[d.URL] = true
+		if until := time.Until(d.NextAttempt); until < next {
+			next = until
+		}
+	}
+
+	if next < 0 {
+		return 0
+	}
+
+	return next
+}
+
+// deliverDue sends every due delivery. Each destination is handled concurrently, but
+// deliveries to the same destination are sent in order and stop at the first failure;
+// the ones after a failed delivery wait until it has been retried.
+func (q *EventQueue) deliverDue(ctx context.Context) {
+	now := time.Now()
+	byURL := make(map[string][]*delivery)
+	waiting := make(map[string]bool)
+
+	q.mu.Lock()
+	for _, d := range q.pending {
+		switch {
+		case waiting[d.URL]:
+		case d.NextAttempt.After(now):
+			waiting[d.URL] = true
+		default:
+			byURL[d.URL] = append(byURL[d.URL], d)
+		}
+	}
+	q.mu.Unlock()
+
+	if len(byURL) == 0 {
+		return
+	}
+
+	wg := &sync.WaitGroup{}
+	for url, deliveries := range byURL {
+		wg.Add(1)
+
+		go func(url string, deliveries []*delivery) {
+			defer wg.Done()
+
+			for _, d := range deliveries {
+				err := postEvent(ctx, q.client, d.URL, d.Event)
+				q.finish(d, err)
+				if err != nil {
+					return
+				}
+			}
+		}(url, deliveries)
+	}
+
+	wg.Wait()
+
+	q.mu.Lock()
+	q.persist()
+	q.mu.Unlock()
+}
+
+// finish records the result of an attempt to send d
+func (q *EventQueue) finish(d *delivery, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	d.Attempts++
+
+	if err == nil {
+		log.P.Debug("Delivered event", zap.String("url", d.URL), zap.String("key", d.Event.Key), zap.Int("attempts", d.Attempts))
+		q.remove(d)
+		return
+	}
+
+	d.LastError = err.Error()
+
+	if d.Attempts >= q.maxAttempts {
+		log.P.Error("Giving up on event delivery", zap.String("url", d.URL), zap.String("key", d.Event.Key), zap.Int("attempts", d.Attempts), zap.Error(err))
+		q.remove(d)
+
+		q.failed = append(q.failed, d)
+		if len(q.failed) > maxFailedDeliveries {
+			q.failed = q.failed[len(q.failed)-maxFailedDeliveries:]
+		}
+		return
+	}
+
+	d.NextAttempt = time.Now().Add(retryDelay(d.Attempts))
+	log.P.Warn("Unable to deliver event, will retry", zap.String("url", d.URL), zap.String("key", d.Event.Key), zap.Int("attempts", d.Attempts), zap.Time("nextAttempt", d.NextAttempt), zap.Error(err))
+}
+
+// remove takes d out of the pending list. q.mu must be held.
+func (q *EventQueue) remove(d *delivery) {
+	for i := range q.pending {
+		if q.pending[i] == d {
+			q.pending = append(q.pending[:i], q.pending[i+1:]...)
+			return
+		}
+	}
+}
+
+type queueFile struct {
+	Pending []*delivery `json:"pending"`
+	Failed  []*delivery `json:"failed"`
+}
+
+func (q *EventQueue) load() error {
+	if len(q.path) == 0 {
+		return nil
+	}
+
+	b, err := os.ReadFile(q.path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return nil
+	case err != nil:
+		return err
+	}
+
+	var file queueFile
+	if err := json.Unmarshal(b, &file); err != nil {
+		return fmt.Errorf("unable to parse %s: %w", q.path, err)
+	}
+
+	sort.SliceStable(file.Pending, func(i, j int) bool {
+		return file.Pending[i].CreatedAt.Before(file.Pending[j].CreatedAt)
+	})
+
+	q.pending = file.Pending
+	q.failed = file.Failed
+	return nil
+}
+
+// persist writes the queue to disk. q.mu must be held.
+func (q *EventQueue) persist() {
+	if len(q.path) == 0 {
+		return
+	}
+
+	b, err := json.Marshal(queueFile{Pending: persistent(q.pending), Failed: persistent(q.failed)})
+	if err != nil {
+		log.P.Warn("unable to marshal event queue", zap.Error(err))
+		return
+	}
+
+	// write to a temp file first so a crash never leaves a half written queue
+	tmp, err := os.CreateTemp(filepath.Dir(q.path), filepath.Base(q.path)+".*")
+	if err != nil {
+		log.P.Warn("unable to persist event queue", zap.String("path", q.path), zap.Error(err))
+		return
+	}
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		log.P.Warn("unable to persist event queue", zap.String("path", q.path), zap.Error(err))
+		return
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		log.P.Warn("unable to persist event queue", zap.String("path", q.path), zap.Error(err))
+		return
+	}
+
+	if err := os.Rename(tmp.Name(), q.path); err != nil {
+		os.Remove(tmp.Name())
+		log.P.Warn("unable to persist event queue", zap.String("path", q.path), zap.Error(err))
+	}
+}
+
+// persistent returns the deliveries that aren't transient
+func persistent(deliveries []*delivery) []*delivery {
+	kept := make([]*delivery, 0, len(deliveries))
+	for _, d := range deliveries {
+		if !d.transient {
+			kept = append(kept, d)
+		}
+	}
+
+	return kept
+}
+
+// retryDelay doubles the wait after each failed attempt, up to maxRetryDelay
+func retryDelay(attempts int) time.Duration {
+	delay := baseRetryDelay
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= maxRetryDelay {
+			return maxRetryDelay
+		}
+	}
+
+	return delay
+}
+
+func newDeliveryID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+
+	return hex.EncodeToString(b)
+}
+
+func postEvent(ctx context.Context, client *http.Client, url string, event events.Event) error {
+	log.P.Debug("Sending event", zap.String("url", url), zap.String("key", event.Key), zap.String("value", event.Value))
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("unable to marshal event: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("unable to create request: %w", err)
+	}
+
+	req.Header.Add("content-type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("non 200 response: %v", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// GetEventQueueStatus returns the pending and dead-lettered event deliveries
+func GetEventQueueStatus(c *gin.Context) {
+	if queue == nil {
+		c.String(http.StatusServiceUnavailable, "event queue is not running")
+		return
+	}
+
+	c.JSON(http.StatusOK, queue.Status())
+}
@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/byuoitav/common/v2/events"
+)
+
+// eventSink is a destination that fails until it's told not to, and keeps the keys of the events it accepts
+type eventSink struct {
+	mu       sync.Mutex
+	failing  bool
+	attempts int
+	received []string
+}
+
+func newEventSink(t *testing.T, failing bool) (*eventSink, string) {
+	sink := &eventSink{failing: failing}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event events.Event
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		sink.mu.Lock()
+		defer sink.mu.Unlock()
+
+		sink.attempts++
+		if sink.failing {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+
+		sink.received = append(sink.received, event.Key)
+	}))
+	t.Cleanup(srv.Close)
+
+	return sink, srv.URL
+}
+
+func (s *eventSink) setFailing(failing bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failing = failing
+}
+
+func (s *eventSink) attemptCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.attempts
+}
+
+func (s *eventSink) keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.received...)
+}
+
+// retryNow makes every pending delivery due
+func (q *EventQueue) retryNow() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, d := range q.pending {
+		d.NextAttempt = time.Now()
+	}
+}
+
+func TestEventQueueRetry(t *testing.T) {
+	sink, url := newEventSink(t, true)
+	q := newEventQueue("", 0)
+
+	q.Enqueue(events.Event{Key: "first"}, url)
+	q.Enqueue(events.Event{Key: "second"}, url)
+
+	start := time.Now()
+	q.deliverDue(context.Background())
+
+	// the second delivery waits for the first
+	if sink.attemptCount() != 1 {
+		t.Fatalf("got %d attempts, want 1", sink.attemptCount())
+	}
+
+	status := q.Status()
+	if len(status.Pending) != 2 || status.Pending[0].Attempts != 1 || status.Pending[1].Attempts != 0 {
+		t.Fatalf("got pending %+v after the first attempt failed", status.Pending)
+	}
+
+	if next := status.Pending[0].NextAttempt.Sub(start); next < baseRetryDelay || next > baseRetryDelay+time.Second {
+		t.Errorf("first retry is in %s, want %s", next, baseRetryDelay)
+	}
+
+	if until := q.untilNextAttempt(); until < baseRetryDelay-time.Second {
+		t.Errorf("queue wakes up in %s, before the first delivery is due", until)
+	}
+
+	// nothing is due yet
+	q.deliverDue(context.Background())
+	if sink.attemptCount() != 1 {
+		t.Fatalf("got %d attempts before the retry was due, want 1", sink.attemptCount())
+	}
+
+	sink.setFailing(false)
+	q.retryNow()
+	q.deliverDue(context.Background())
+
+	if received := sink.keys(); len(received) != 2 || received[0] != "first" || received[1] != "second" {
+		t.Errorf("received %v, want [first second]", received)
+	}
+
+	if status := q.Status(); len(status.Pending) != 0 || len(status.Failed) != 0 {
+		t.Errorf("got %+v after every event was delivered", status)
+	}
+}
+
+func TestEventQueueMaxAttempts(t *testing.T) {
+	sink, url := newEventSink(t, true)
+	_, other := newEventSink(t, false)
+	q := newEventQueue("", 3)
+
+	q.Enqueue(events.Event{Key: "lost"}, url, other)
+
+	for i := 0; i < 5; i++ {
+		q.retryNow()
+		q.deliverDue(context.Background())
+	}
+
+	if sink.attemptCount() != 3 {
+		t.Errorf("got %d attempts, want 3", sink.attemptCount())
+	}
+
+	status := q.Status()
+	if len(status.Pending) != 0 || len(status.Failed) != 1 {
+		t.Fatalf("got %+v, want one failed delivery", status)
+	}
+
+	if failed := status.Failed[0]; failed.URL != url || failed.Attempts != 3 || len(failed.LastError) == 0 {
+		t.Errorf("got failed delivery %+v", failed)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, baseRetryDelay},
+		{2, 2 * baseRetryDelay},
+		{3, 4 * baseRetryDelay},
+		{20, maxRetryDelay},
+	}
+
+	for _, tt := range tests {
+		if got := retryDelay(tt.attempts); got != tt.want {
+			t.Errorf("retryDelay(%d) = %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestEventQueuePersist(t *testing.T) {
+	_, url := newEventSink(t, true)
+	path := filepath.Join(t.TempDir(), "queue.json")
+
+	q := newEventQueue(path, 1)
+	q.Enqueue(events.Event{Key: "dead"}, url)
+	q.deliverDue(context.Background())
+
+	q.Enqueue(events.Event{Key: "help-request"}, url)
+	q.EnqueueTransient(events.Event{Key: "websocket-count"}, url)
+
+	reloaded := newEventQueue(path, 1)
+	if err := reloaded.load(); err != nil {
+		t.Fatalf("unable to reload queue: %s", err)
+	}
+
+	status := reloaded.Status()
+	if len(status.Pending) != 1 || status.Pending[0].Event.Key != "help-request" {
+		t.Errorf("reloaded pending %+v, want only the help-request", status.Pending)
+	}
+
+	if len(status.Failed) != 1 || status.Failed[0].Event.Key != "dead" || status.Failed[0].Attempts != 1 {
+		t.Errorf("reloaded failed %+v, want the dead-lettered event", status.Failed)
+	}
+}
@@ -0,0 +1,403 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/byuoitav/scheduler/calendars"
+	"github.com/byuoitav/scheduler/calendars/calendartest"
+	"github.com/byuoitav/scheduler/clock"
+	"github.com/byuoitav/scheduler/handlers"
+	"github.com/byuoitav/scheduler/identity"
+	"github.com/byuoitav/scheduler/log"
+	"github.com/byuoitav/scheduler/schedule"
+	"github.com/byuoitav/scheduler/schedule/scheduletest"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// adminToken is the token admin requests in scenarios are made with
+const adminToken = "harness"
+
+// harness runs the scheduler's router against a stand-in couch and calendar server.
+// The scheduler keeps its settings in package variables, so harnesses can't run in parallel.
+type harness struct {
+	t         *testing.T
+	router    *gin.Engine
+	couch     *scheduletest.Couch
+	calendars *calendartest.Server
+}
+
+var setupOnce sync.Once
+
+func newHarness(t *testing.T) *harness {
+	t.Helper()
+
+	setupOnce.Do(func() {
+		gin.SetMode(gin.TestMode)
+		log.Config.Level.SetLevel(zap.ErrorLevel)
+
+		handlers.SetHelpCooldown(schedule.DefaultHelpCooldown)
+	})
+
+	h := &harness{
+		t:         t,
+		couch:     scheduletest.NewCouch(t),
+		calendars: calendartest.NewServer(t),
+	}
+
+	t.Setenv("DB_ADDRESS", h.couch.URL)
+	t.Setenv("ADMIN_TOKEN", adminToken)
+
+	id, err := identity.Parse(identity.Options{MultiTenant: true})
+	if err != nil {
+		t.Fatalf("unable to parse identity: %s", err)
+	}
+
+	handlers.SetIdentity(id)
+
+	if err := schedule.SetDefaultLocale("America/Denver", "en-US"); err != nil {
+		t.Fatalf("unable to set default locale: %s", err)
+	}
+
+	if err := schedule.SetStaticCacheDir(""); err != nil {
+		t.Fatalf("unable to set static cache dir: %s", err)
+	}
+
+	web, err := fs.Sub(embeddedFiles, "web")
+	if err != nil {
+		t.Fatalf("unable to open web files: %s", err)
+	}
+
+	bg, err := fs.ReadFile(web, "assets/bg.png")
+	if err != nil {
+		t.Fatalf("unable to read default background: %s", err)
+	}
+
+	handlers.SetDefaultBackground(bg, "image/png")
+
+	h.router = newRouter(web)
+	return h
+}
+
+// room saves a room's config to couch. If the config doesn't have a calendar, the room's calendar is served by the stand-in calendar server.
+func (h *harness) room(rm room) {
+	h.t.Helper()
+
+	config := rm.config
+	if len(config.CalendarURL) == 0 && len(config.Calendars) == 0 {
+		cal := rm.calendar
+		if cal == nil {
+			cal = calendartest.NewMemory()
+		}
+
+		config.CalendarURL = h.calendars.Add(config.ID, cal)
+	}
+
+	h.couch.Put(h.t, config)
+
+	if rm.background != nil {
+		h.couch.PutAttachment(config.ID, schedule.BackgroundAttachment, "image/png", rm.background)
+	}
+}
+
+// static attaches files to the static document, with a content type from their extension
+func (h *harness) static(files map[string]string) {
+	for name, data := range files {
+		h.couch.PutAttachment("static", name, mime.TypeByExtension(path.Ext(name)), []byte(data))
+	}
+}
+
+// do makes a request against the router. body is sent as is if it's a string or []byte, and as json otherwise.
+func (h *harness) do(method, target string, body interface{}, headers ...string) *response {
+	h.t.Helper()
+
+	var r io.Reader
+	switch b := body.(type) {
+	case nil:
+	case string:
+		r = strings.NewReader(b)
+	case []byte:
+		r = bytes.NewReader(b)
+	default:
+		j, err := json.Marshal(b)
+		if err != nil {
+			h.t.Fatalf("unable to marshal request body: %s", err)
+		}
+
+		r = bytes.NewReader(j)
+	}
+
+	req := httptest.NewRequest(method, target, r)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+
+	if strings.HasPrefix(target, "/admin/") {
+		req.Header.Set("Authorization", "Bearer "+adminToken)
+	}
+
+	w := httptest.NewRecorder()
+	h.router.ServeHTTP(w, req)
+
+	// every response in a scenario has to match the api the scheduler describes
+	if err := handlers.API.ValidateResponse(method, req.URL.EscapedPath(), w.Code, w.Header(), w.Body.Bytes()); err != nil {
+		h.t.Errorf("%s", err)
+	}
+
+	return &response{t: h.t, req: method + " " + target, ResponseRecorder: w}
+}
+
+// response is the result of a request, with assertions about it
+type response struct {
+	*httptest.ResponseRecorder
+	t   *testing.T
+	req string
+}
+
+func (r *response) status(want int) *response {
+	r.t.Helper()
+
+	if r.Code != want {
+		r.t.Fatalf("%s: got status %d, want %d. body: %s", r.req, r.Code, want, r.Body)
+	}
+
+	return r
+}
+
+func (r *response) header(name, want string) *response {
+	r.t.Helper()
+
+	if got := r.Header().Get(name); got != want {
+		r.t.Errorf("%s: got %s %q, want %q", r.req, name, got, want)
+	}
+
+	return r
+}
+
+func (r *response) contains(want string) *response {
+	r.t.Helper()
+
+	if !strings.Contains(r.Body.String(), want) {
+		r.t.Errorf("%s: body doesn't contain %q. body: %s", r.req, want, r.Body)
+	}
+
+	return r
+}
+
+// decode unmarshals the body into out
+func (r *response) decode(out interface{}) *response {
+	r.t.Helper()
+
+	if err := json.Unmarshal(r.Body.Bytes(), out); err != nil {
+		r.t.Fatalf("%s: unable to parse response: %s. body: %s", r.req, err, r.Body)
+	}
+
+	return r
+}
+
+func (r *response) events() []calendars.Event {
+	r.t.Helper()
+
+	var events []calendars.Event
+	r.decode(&events)
+	return events
+}
+
+// scenario is a set of rooms, and the requests a panel (or admin) makes against them
+type scenario struct {
+	name  string
+	rooms []room
+
+	// static are files on the static document, by name
+	static map[string]string
+
+	// timeTravel lets the scenario move the server's clock with /admin/clock
+	timeTravel bool
+
+	steps []step
+}
+
+type room struct {
+	config schedule.Config
+
+	// calendar is the room's calendar on the stand-in calendar server. rooms without one get an empty calendar.
+	calendar calendars.Calendar
+
+	// background is attached to the room's config as bg.png
+	background []byte
+}
+
+type step struct {
+	method string
+	path   string
+	body   interface{}
+
+	// status is the expected status code. it defaults to 200.
+	status int
+	expect []expectation
+}
+
+// expectation checks a step's response
+type expectation func(t *testing.T, r *response)
+
+func (h *harness) run(sc scenario) {
+	h.t.Helper()
+
+	if sc.timeTravel {
+		clock.EnableTimeTravel()
+		h.t.Cleanup(func() { clock.Set(clock.Real{}) })
+	}
+
+	for _, rm := range sc.rooms {
+		h.room(rm)
+	}
+
+	h.static(sc.static)
+
+	for _, st := range sc.steps {
+		want := st.status
+		if want == 0 {
+			want = http.StatusOK
+		}
+
+		r := h.do(st.method, st.path, st.body).status(want)
+		for _, expect := range st.expect {
+			expect(h.t, r)
+		}
+	}
+}
+
+// runScenarios runs each scenario as a subtest, with its own harness
+func runScenarios(t *testing.T, scenarios []scenario) {
+	for _, sc := range scenarios {
+		t.Run(sc.name, func(t *testing.T) {
+			newHarness(t).run(sc)
+		})
+	}
+}
+
+func get(path string, expect ...expectation) step {
+	return step{method: http.MethodGet, path: path, expect: expect}
+}
+
+func post(path string, body interface{}, status int, expect ...expectation) step {
+	return step{method: http.MethodPost, path: path, body: body, status: status, expect: expect}
+}
+
+func put(path string, body interface{}, status int, expect ...expectation) step {
+	return step{method: http.MethodPut, path: path, body: body, status: status, expect: expect}
+}
+
+func eventsSorted() expectation {
+	return func(t *testing.T, r *response) {
+		t.Helper()
+
+		events := r.events()
+		for i := 1; i < len(events); i++ {
+			if events[i].StartTime.Before(events[i-1].StartTime) {
+				t.Errorf("%s: event %d starts before event %d", r.req, i, i-1)
+			}
+		}
+	}
+}
+
+func eventCount(want int) expectation {
+	return func(t *testing.T, r *response) {
+		t.Helper()
+
+		if got := len(r.events()); got != want {
+			t.Errorf("%s: got %d events, want %d", r.req, got, want)
+		}
+	}
+}
+
+func titles(want ...string) expectation {
+	return func(t *testing.T, r *response) {
+		t.Helper()
+
+		var got []string
+		for _, e := range r.events() {
+			got = append(got, e.Title)
+		}
+
+		if strings.Join(got, "|") != strings.Join(want, "|") {
+			t.Errorf("%s: got titles %q, want %q", r.req, got, want)
+		}
+	}
+}
+
+func titlesHidden() expectation {
+	return func(t *testing.T, r *response) {
+		t.Helper()
+
+		for _, e := range r.events() {
+			if len(e.Title) > 0 {
+				t.Errorf("%s: event title %q is shown, want it hidden", r.req, e.Title)
+			}
+		}
+	}
+}
+
+func inTimezone(name string) expectation {
+	return func(t *testing.T, r *response) {
+		t.Helper()
+
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			t.Fatalf("invalid timezone %q: %s", name, err)
+		}
+
+		for _, e := range r.events() {
+			_, got := e.StartTime.Zone()
+			_, want := e.StartTime.In(loc).Zone()
+			if got != want {
+				t.Errorf("%s: event starts at a %ds offset, want %ds (%s)", r.req, got, want, name)
+			}
+		}
+	}
+}
+
+func header(name, want string) expectation {
+	return func(t *testing.T, r *response) {
+		t.Helper()
+		r.header(name, want)
+	}
+}
+
+func bodyContains(want string) expectation {
+	return func(t *testing.T, r *response) {
+		t.Helper()
+		r.contains(want)
+	}
+}
+
+// configField checks a field of a returned config (or any json object)
+func configField(name string, want interface{}) expectation {
+	return func(t *testing.T, r *response) {
+		t.Helper()
+
+		var doc map[string]interface{}
+		r.decode(&doc)
+
+		got, _ := json.Marshal(doc[name])
+		w, _ := json.Marshal(want)
+		if !bytes.Equal(got, w) {
+			t.Errorf("%s: got %s %s, want %s", r.req, name, got, w)
+		}
+	}
+}
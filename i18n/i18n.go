@@ -0,0 +1,89 @@
+// Package i18n holds translations of the panel's UI strings
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// DefaultLanguage is used for any strings missing from another language's catalog
+const DefaultLanguage = "en"
+
+//go:embed catalogs/*.json
+var files embed.FS
+
+// catalogs maps a language (en, es, pt-BR...) to its translations
+var catalogs = make(map[string]map[string]string)
+
+func init() {
+	entries, err := files.ReadDir("catalogs")
+	if err != nil {
+		panic(fmt.Sprintf("unable to read translation catalogs: %s", err))
+	}
+
+	for _, entry := range entries {
+		b, err := files.ReadFile(path.Join("catalogs", entry.Name()))
+		if err != nil {
+			panic(fmt.Sprintf("unable to read %s: %s", entry.Name(), err))
+		}
+
+		var catalog map[string]string
+		if err := json.Unmarshal(b, &catalog); err != nil {
+			panic(fmt.Sprintf("unable to parse %s: %s", entry.Name(), err))
+		}
+
+		catalogs[strings.TrimSuffix(entry.Name(), ".json")] = catalog
+	}
+
+	if _, ok := catalogs[DefaultLanguage]; !ok {
+		panic("missing translation catalog for " + DefaultLanguage)
+	}
+}
+
+// Languages returns the languages that have a catalog
+func Languages() []string {
+	langs := make([]string, 0, len(catalogs))
+	for lang := range catalogs {
+		langs = append(langs, lang)
+	}
+
+	sort.Strings(langs)
+	return langs
+}
+
+// Catalog returns the best translations for locale (like es-MX), and the language they are in.
+// The locale's full tag is tried first, then just its language. Strings a language doesn't
+// have are filled in from DefaultLanguage.
+func Catalog(locale string) (string, map[string]string) {
+	lang := match(locale)
+
+	catalog := make(map[string]string, len(catalogs[DefaultLanguage]))
+	for key, value := range catalogs[DefaultLanguage] {
+		catalog[key] = value
+	}
+
+	for key, value := range catalogs[lang] {
+		catalog[key] = value
+	}
+
+	return lang, catalog
+}
+
+// match returns the catalog that best fits locale
+func match(locale string) string {
+	parts := strings.Split(strings.ReplaceAll(locale, "_", "-"), "-")
+	for i := len(parts); i > 0; i-- {
+		tag := strings.Join(parts[:i], "-")
+		for lang := range catalogs {
+			if strings.EqualFold(lang, tag) {
+				return lang
+			}
+		}
+	}
+
+	return DefaultLanguage
+}
@@ -0,0 +1,33 @@
+package i18n
+
+import "testing"
+
+func TestCatalog(t *testing.T) {
+	tests := map[string]string{
+		"es":    "es",
+		"es-MX": "es",
+		"ES_mx": "es",
+		"en-US": "en",
+		"pt-BR": DefaultLanguage,
+		"":      DefaultLanguage,
+	}
+
+	for locale, want := range tests {
+		if got, _ := Catalog(locale); got != want {
+			t.Errorf("got %q for %q, want %q", got, locale, want)
+		}
+	}
+
+	_, es := Catalog("es-MX")
+	if es["cancel"] != "Cancelar" {
+		t.Errorf("got %q for cancel in spanish, want Cancelar", es["cancel"])
+	}
+
+	// every string is filled in, from english if the language doesn't have it
+	_, en := Catalog(DefaultLanguage)
+	for key := range en {
+		if len(es[key]) == 0 {
+			t.Errorf("spanish catalog is missing %q", key)
+		}
+	}
+}
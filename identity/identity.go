@@ -0,0 +1,260 @@
+package identity
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/byuoitav/common/v2/events"
+)
+
+// Schemes are the built in ways of splitting a SYSTEM_ID into its building, room, and device.
+// Any other scheme is treated as a regular expression with named building, room, and (optionally) device groups.
+var Schemes = map[string]string{
+	// JET-1106-SP1
+	"building-room-device": `^(?P<building>[^-]+)-(?P<room>[^-]+)-(?P<device>[^-]+)$`,
+
+	// JET-1106, for panels that are named after the room they are in
+	"building-room": `^(?P<building>[^-]+)-(?P<room>[^-]+)$`,
+}
+
+// DefaultScheme is the scheme used when none is given
+const DefaultScheme = "building-room-device"
+
+// Identity is who this scheduler is, and which rooms it serves
+type Identity struct {
+	SystemID   string `json:"systemID"`
+	Scheme     string `json:"scheme"`
+	BuildingID string `json:"buildingID"`
+	RoomID     string `json:"roomID"`
+	DeviceID   string `json:"deviceID"`
+
+	// Rooms is every room this panel can show, starting with RoomID
+	Rooms []string `json:"rooms"`
+
+	// MultiTenant servers serve any room matching RoomPattern, rather than just Rooms
+	MultiTenant bool   `json:"multiTenant"`
+	RoomPattern string `json:"roomPattern,omitempty"`
+
+	scheme      *regexp.Regexp
+	roomPattern *regexp.Regexp
+}
+
+// Options control how an Identity is built
+type Options struct {
+	// SystemID defaults to the SYSTEM_ID environment variable
+	SystemID string
+
+	// Scheme is the name of one of Schemes, or a regular expression
+	Scheme string
+
+	// RoomID overrides the room parsed from the SystemID
+	RoomID string
+
+	// Rooms are additional rooms served by a multi-room panel
+	Rooms []string
+
+	// MultiTenant lets one server serve many rooms. The SystemID is optional, and
+	// when it is set but doesn't match the Scheme, there is no primary room.
+	MultiTenant bool
+
+	// RoomPattern limits which rooms a multi-tenant server will serve
+	RoomPattern string
+}
+
+// Parse builds and validates an Identity
+func Parse(opts Options) (Identity, error) {
+	id := Identity{
+		SystemID:    opts.SystemID,
+		Scheme:      opts.Scheme,
+		MultiTenant: opts.MultiTenant,
+		RoomPattern: opts.RoomPattern,
+	}
+
+	if len(id.SystemID) == 0 {
+		id.SystemID = os.Getenv("SYSTEM_ID")
+	}
+
+	if len(id.SystemID) == 0 && id.MultiTenant {
+		id.SystemID, _ = os.Hostname()
+	}
+
+	if len(id.Scheme) == 0 {
+		id.Scheme = DefaultScheme
+	}
+
+	if len(id.SystemID) == 0 {
+		return id, errors.New("SYSTEM_ID is not set")
+	}
+
+	pattern, ok := Schemes[id.Scheme]
+	if !ok {
+		pattern = id.Scheme
+	}
+
+	var err error
+	id.scheme, err = regexp.Compile(pattern)
+	if err != nil {
+		return id, fmt.Errorf("invalid naming scheme %q: %w", id.Scheme, err)
+	}
+
+	if id.scheme.SubexpIndex("building") < 0 || id.scheme.SubexpIndex("room") < 0 {
+		return id, fmt.Errorf("naming scheme %q must have named building and room groups", id.Scheme)
+	}
+
+	if len(id.RoomPattern) > 0 {
+		id.roomPattern, err = regexp.Compile(id.RoomPattern)
+		if err != nil {
+			return id, fmt.Errorf("invalid room pattern %q: %w", id.RoomPattern, err)
+		}
+	}
+
+	building, room, device, err := id.split(id.SystemID)
+	switch {
+	case err == nil:
+		id.BuildingID = building
+		id.RoomID = room
+		id.DeviceID = device
+	case id.MultiTenant:
+		id.DeviceID = id.SystemID
+	default:
+		return id, err
+	}
+
+	if len(opts.RoomID) > 0 {
+		id.RoomID = opts.RoomID
+	}
+
+	id.Rooms = []string{}
+	if len(id.RoomID) > 0 {
+		id.Rooms = append(id.Rooms, id.RoomID)
+	}
+
+	for _, room := range opts.Rooms {
+		if !id.HasRoom(room) {
+			id.Rooms = append(id.Rooms, room)
+		}
+	}
+
+	if len(id.Rooms) == 0 && !id.MultiTenant {
+		return id, errors.New("no rooms to serve")
+	}
+
+	for _, room := range id.Rooms {
+		if err := ValidateRoomID(room); err != nil {
+			return id, err
+		}
+	}
+
+	return id, nil
+}
+
+// split breaks a system or device id into its building, room, and device using the naming scheme
+func (i Identity) split(systemID string) (building, room, device string, err error) {
+	match := i.scheme.FindStringSubmatch(systemID)
+	if match == nil {
+		return "", "", "", fmt.Errorf("%q does not match naming scheme %q", systemID, i.Scheme)
+	}
+
+	building = match[i.scheme.SubexpIndex("building")]
+	room = building + "-" + match[i.scheme.SubexpIndex("room")]
+	device = systemID
+
+	if idx := i.scheme.SubexpIndex("device"); idx >= 0 && len(match[idx]) > 0 {
+		device = room + "-" + match[idx]
+	}
+
+	return building, room, device, nil
+}
+
+// Device parses another device's id using this identity's naming scheme
+func (i Identity) Device(deviceID string) (roomID, normalizedID string, err error) {
+	if i.scheme == nil {
+		return "", "", errors.New("identity has not been parsed")
+	}
+
+	_, roomID, normalizedID, err = i.split(deviceID)
+	return roomID, normalizedID, err
+}
+
+// Serves returns true if this server will show room
+func (i Identity) Serves(room string) bool {
+	if i.HasRoom(room) {
+		return true
+	}
+
+	if !i.MultiTenant || ValidateRoomID(room) != nil {
+		return false
+	}
+
+	return i.roomPattern == nil || i.roomPattern.MatchString(room)
+}
+
+// ValidateRoomID checks that room can be used as a room ID
+func ValidateRoomID(room string) error {
+	switch {
+	case len(room) == 0:
+		return errors.New("room ID must not be empty")
+	case strings.ContainsAny(room, "/?#% "):
+		return fmt.Errorf("invalid room ID %q", room)
+	}
+
+	return nil
+}
+
+// HasRoom returns true if room is one of the rooms served by this panel
+func (i Identity) HasRoom(room string) bool {
+	for _, r := range i.Rooms {
+		if r == room {
+			return true
+		}
+	}
+
+	return false
+}
+
+// DeviceInfo is this device's info for events
+func (i Identity) DeviceInfo() events.BasicDeviceInfo {
+	return events.BasicDeviceInfo{
+		BasicRoomInfo: i.RoomInfo(),
+		DeviceID:      i.DeviceID,
+	}
+}
+
+// DeviceInfoFor is this device's info for events about room, one of the other rooms it shows. The device keeps
+// its own suffix, so JET-1106-SP1 is JET-1108-SP1 in JET-1108. DeviceID is empty if this device's id doesn't have one.
+func (i Identity) DeviceInfoFor(room string) events.BasicDeviceInfo {
+	info := events.BasicDeviceInfo{
+		BasicRoomInfo: events.BasicRoomInfo{
+			BuildingID: i.Building(room),
+			RoomID:     room,
+		},
+	}
+
+	if suffix, ok := strings.CutPrefix(i.DeviceID, i.RoomID+"-"); ok && len(i.RoomID) > 0 && len(suffix) > 0 {
+		info.DeviceID = room + "-" + suffix
+	}
+
+	return info
+}
+
+// RoomInfo is this device's room info for events
+func (i Identity) RoomInfo() events.BasicRoomInfo {
+	return events.BasicRoomInfo{
+		BuildingID: i.BuildingID,
+		RoomID:     i.RoomID,
+	}
+}
+
+// Building returns the building a room is in
+func (i Identity) Building(room string) string {
+	// rooms are always named building-room, but a custom scheme's building can have dashes in it
+	if len(i.BuildingID) > 0 && strings.HasPrefix(room, i.BuildingID+"-") {
+		return i.BuildingID
+	}
+
+	building, _, _ := strings.Cut(room, "-")
+	return building
+}
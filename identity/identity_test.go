@@ -0,0 +1,198 @@
+package identity
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+
+		building, room, device string
+		rooms                  []string
+		err                    string
+	}{
+		{
+			name:     "building-room-device",
+			opts:     Options{SystemID: "JET-1106-SP1"},
+			building: "JET", room: "JET-1106", device: "JET-1106-SP1",
+			rooms: []string{"JET-1106"},
+		},
+		{
+			name:     "building-room",
+			opts:     Options{SystemID: "JET-1106", Scheme: "building-room"},
+			building: "JET", room: "JET-1106", device: "JET-1106",
+			rooms: []string{"JET-1106"},
+		},
+		{
+			name:     "custom scheme",
+			opts:     Options{SystemID: "JET1106-SP1", Scheme: `^(?P<building>[A-Z]+)(?P<room>\d+)-(?P<device>\w+)$`},
+			building: "JET", room: "JET-1106", device: "JET-1106-SP1",
+			rooms: []string{"JET-1106"},
+		},
+		{
+			name:     "custom scheme without a device",
+			opts:     Options{SystemID: "JET1106", Scheme: `^(?P<building>[A-Z]+)(?P<room>\d+)$`},
+			building: "JET", room: "JET-1106", device: "JET1106",
+			rooms: []string{"JET-1106"},
+		},
+		{
+			name:     "room override and extra rooms",
+			opts:     Options{SystemID: "JET-1106-SP1", RoomID: "JET-1108", Rooms: []string{"JET-1110", "JET-1108"}},
+			building: "JET", room: "JET-1108", device: "JET-1106-SP1",
+			rooms: []string{"JET-1108", "JET-1110"},
+		},
+		{
+			name:   "multi-tenant without a matching system id",
+			opts:   Options{SystemID: "scheduler", Scheme: "building-room", MultiTenant: true},
+			device: "scheduler",
+			rooms:  []string{},
+		},
+		{
+			name: "doesn't match the scheme",
+			opts: Options{SystemID: "JET1106"},
+			err:  "does not match naming scheme",
+		},
+		{
+			name: "invalid scheme",
+			opts: Options{SystemID: "JET-1106-SP1", Scheme: `(?P<building>[A-Z+`},
+			err:  "invalid naming scheme",
+		},
+		{
+			name: "scheme without a room group",
+			opts: Options{SystemID: "JET-1106-SP1", Scheme: `^(?P<building>[A-Z]+)-.*$`},
+			err:  "must have named building and room groups",
+		},
+		{
+			name: "invalid room pattern",
+			opts: Options{SystemID: "JET-1106-SP1", MultiTenant: true, RoomPattern: `JET-(`},
+			err:  "invalid room pattern",
+		},
+		{
+			name: "invalid extra room",
+			opts: Options{SystemID: "JET-1106-SP1", Rooms: []string{"JET 1110"}},
+			err:  "invalid room ID",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := Parse(tt.opts)
+			switch {
+			case len(tt.err) > 0:
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got error %v, want one containing %q", err, tt.err)
+				}
+
+				return
+			case err != nil:
+				t.Fatalf("unable to parse: %s", err)
+			}
+
+			if id.BuildingID != tt.building || id.RoomID != tt.room || id.DeviceID != tt.device {
+				t.Errorf("got building %q, room %q, device %q, want %q, %q, %q", id.BuildingID, id.RoomID, id.DeviceID, tt.building, tt.room, tt.device)
+			}
+
+			if strings.Join(id.Rooms, ",") != strings.Join(tt.rooms, ",") {
+				t.Errorf("got rooms %v, want %v", id.Rooms, tt.rooms)
+			}
+		})
+	}
+}
+
+func TestParseSystemIDFromEnvironment(t *testing.T) {
+	t.Setenv("SYSTEM_ID", "ITB-1010-CP1")
+
+	id, err := Parse(Options{})
+	if err != nil || id.SystemID != "ITB-1010-CP1" || id.RoomID != "ITB-1010" {
+		t.Errorf("got %+v, %v", id, err)
+	}
+
+	t.Setenv("SYSTEM_ID", "")
+	if _, err := Parse(Options{}); err == nil {
+		t.Errorf("got no error without a SYSTEM_ID")
+	}
+}
+
+func TestDevice(t *testing.T) {
+	id, err := Parse(Options{SystemID: "JET-1106-SP1"})
+	if err != nil {
+		t.Fatalf("unable to parse: %s", err)
+	}
+
+	if room, device, err := id.Device("ITB-1010-CP2"); err != nil || room != "ITB-1010" || device != "ITB-1010-CP2" {
+		t.Errorf("got %q, %q, %v", room, device, err)
+	}
+
+	if _, _, err := id.Device("ITB1010"); err == nil {
+		t.Errorf("got no error for a device that doesn't match the scheme")
+	}
+
+	if _, _, err := (Identity{}).Device("ITB-1010-CP2"); err == nil {
+		t.Errorf("got no error from an identity that wasn't parsed")
+	}
+}
+
+func TestDeviceInfoFor(t *testing.T) {
+	panel, _ := Parse(Options{SystemID: "JET-1106-SP1", Rooms: []string{"JET-1108"}})
+	named, _ := Parse(Options{SystemID: "JET-1106", Scheme: "building-room", Rooms: []string{"JET-1108"}})
+	tenant, _ := Parse(Options{SystemID: "scheduler", MultiTenant: true})
+	custom, _ := Parse(Options{SystemID: "B-66-101-SP1", Scheme: `^(?P<building>B-\d+)-(?P<room>\d+)-(?P<device>\w+)$`})
+
+	tests := []struct {
+		id       Identity
+		room     string
+		building string
+		device   string
+	}{
+		{panel, "JET-1108", "JET", "JET-1108-SP1"},
+		{named, "JET-1108", "JET", ""},
+		{tenant, "ITB-1010", "ITB", ""},
+		{custom, "B-66-102", "B-66", "B-66-102-SP1"},
+	}
+
+	for _, tt := range tests {
+		info := tt.id.DeviceInfoFor(tt.room)
+		if info.RoomID != tt.room || info.BuildingID != tt.building || info.DeviceID != tt.device {
+			t.Errorf("%s in %s: got %+v, want building %q, device %q", tt.id.SystemID, tt.room, info, tt.building, tt.device)
+		}
+	}
+}
+
+func TestServes(t *testing.T) {
+	single, _ := Parse(Options{SystemID: "JET-1106-SP1", Rooms: []string{"JET-1108"}})
+	tenant, _ := Parse(Options{SystemID: "scheduler", MultiTenant: true, RoomPattern: `^JET-`})
+
+	tests := []struct {
+		id    Identity
+		room  string
+		serve bool
+	}{
+		{single, "JET-1106", true},
+		{single, "JET-1108", true},
+		{single, "JET-1110", false},
+		{tenant, "JET-1110", true},
+		{tenant, "ITB-1010", false},
+		{tenant, "JET-1110/../admin", false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.id.Serves(tt.room); got != tt.serve {
+			t.Errorf("%s serves %q = %t, want %t", tt.id.SystemID, tt.room, got, tt.serve)
+		}
+	}
+}
+
+func TestBuilding(t *testing.T) {
+	id, _ := Parse(Options{SystemID: "B-66-101-SP1", Scheme: `^(?P<building>B-\d+)-(?P<room>\d+)-(?P<device>\w+)$`})
+
+	if got := id.Building("B-66-101"); got != "B-66" {
+		t.Errorf("got building %q for a room in this building", got)
+	}
+
+	if got := id.Building("JET-1106"); got != "JET" {
+		t.Errorf("got building %q for a room in another building", got)
+	}
+}
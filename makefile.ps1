@@ -58,6 +58,22 @@ function Deps {
     Invoke-Expression "go mod download"
 }
 
+function Tidy {
+    # tidy considers every build tag, so it also records the provider backends' go.sum entries
+    Write-Output "Tidy"
+    Invoke-Expression "go mod tidy"
+}
+
+function Check-providers {
+    # the release binaries are built with the providers tag, so fail early if its dependencies aren't in go.sum
+    Write-Output "Checking the build with the providers tag"
+    Invoke-Expression "go build -tags providers $PKG_LIST"
+    if ($LASTEXITCODE -ne 0) {
+        Write-Output "Unable to build with the providers tag. Run the Tidy command and commit go.sum."
+        exit $LASTEXITCODE
+    }
+}
+
 function Build {
     Write-Output "Build"
 
@@ -74,7 +90,7 @@ function Build {
         Set-Item -Path env:CGO_ENABLED -Value 0
         Set-Item -Path env:GOOS -Value "linux"
         Set-Item -Path env:GOARCH -Value "amd64"
-        Invoke-Expression "go build -v -o ./dist/${NAME}-amd64"
+        Invoke-Expression "go build -v -tags providers -o ./dist/${NAME}-amd64"
 
 
         Write-Output "*****************************************"
@@ -82,7 +98,7 @@ function Build {
         Set-Item -Path env:CGO_ENABLED -Value 0
         Set-Item -Path env:GOOS -Value "linux"
         Set-Item -Path env:GOARCH -Value "arm64"
-        Invoke-Expression "go build -v -o ./dist/${NAME}-arm"
+        Invoke-Expression "go build -v -tags providers -o ./dist/${NAME}-arm"
 
         Write-Output "Build output is located in ./dist/."
         Set-Item -Path env:GOOS -Value "windows"
@@ -177,6 +193,7 @@ if ($COMMAND -eq "All") {
 }
 elseif ($COMMAND -eq "Test") {
     Deps
+    Check-providers
     Test
 }
 elseif ($COMMAND -eq "Test-cov") {
@@ -190,9 +207,17 @@ elseif ($COMMAND -eq "Lint") {
 elseif ($COMMAND -eq "Deps") {
     Deps
 }
+elseif ($COMMAND -eq "Tidy") {
+    Tidy
+}
+elseif ($COMMAND -eq "Check-providers") {
+    Deps
+    Check-providers
+}
 elseif ($COMMAND -eq "Build") {
     Cleanup
     Deps
+    Check-providers
     Build
 }
 elseif ($COMMAND -eq "Clean") {
@@ -201,6 +226,7 @@ elseif ($COMMAND -eq "Clean") {
 elseif ($COMMAND -eq "Docker" ) {
     Cleanup
     Deps
+    Check-providers
     Build
     DockerFunc
     Cleanup
@@ -208,6 +234,7 @@ elseif ($COMMAND -eq "Docker" ) {
 elseif ($COMMAND -eq "Deploy" ) {
     Cleanup
     Deps
+    Check-providers
     Build
     DockerFunc
     Deploy
@@ -0,0 +1,260 @@
+// Package openapi describes an http API with an OpenAPI 3.0 document, and checks requests and responses against it.
+//
+// Documents are built in code, with schemas reflected from the Go types handlers bind and return,
+// so the contract can't drift from the json those types encode to.
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Version is the OpenAPI version documents are written in
+const Version = "3.0.3"
+
+// Document is an OpenAPI document
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+
+	mu      sync.Mutex
+	types   map[string]string // schema names, by go type
+	routes  []route
+	encoded []byte
+}
+
+// Info describes the API
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// PathItem is the operations on a path, by lowercase http method
+type PathItem map[string]*Operation
+
+// Operation is one method on one path
+type Operation struct {
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+	Deprecated  bool                  `json:"deprecated,omitempty"`
+}
+
+// Parameter is a path, query, or header parameter
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema,omitempty"`
+}
+
+// RequestBody is what an operation accepts, by content type
+type RequestBody struct {
+	Description string               `json:"description,omitempty"`
+	Required    bool                 `json:"required,omitempty"`
+	Content     map[string]MediaType `json:"content"`
+}
+
+// Response is what an operation returns with a status, by content type
+type Response struct {
+	Description string               `json:"description"`
+	Headers     map[string]Header    `json:"headers,omitempty"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// Header is a response header
+type Header struct {
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema,omitempty"`
+}
+
+// MediaType is the schema of a body with one content type
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+// Components are the schemas and security schemes operations refer to
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas,omitempty"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme is how an operation is authorized
+type SecurityScheme struct {
+	Type        string `json:"type"`
+	Scheme      string `json:"scheme,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// route matches request paths to one of the document's paths
+type route struct {
+	path     string
+	re       *regexp.Regexp
+	params   []string
+	literals int
+}
+
+var pathParam = regexp.MustCompile(`\{([^}/]+)\}`)
+
+// New returns an empty document
+func New(title, version, description string) *Document {
+	return &Document{
+		OpenAPI: Version,
+		Info:    Info{Title: title, Version: version, Description: description},
+		Paths:   make(map[string]PathItem),
+		Components: Components{
+			Schemas:         make(map[string]*Schema),
+			SecuritySchemes: make(map[string]SecurityScheme),
+		},
+		types: make(map[string]string),
+	}
+}
+
+// Add documents method on path, a template like /{roomID}/events. Path parameters that op doesn't describe are added as strings,
+// and responses default to a plain text error message.
+func (d *Document) Add(method, path string, op Operation) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	described := make(map[string]bool)
+	for _, p := range op.Parameters {
+		if p.In == InPath {
+			described[p.Name] = true
+		}
+	}
+
+	var params []string
+	for _, m := range pathParam.FindAllStringSubmatch(path, -1) {
+		params = append(params, m[1])
+		if !described[m[1]] {
+			op.Parameters = append(op.Parameters, PathParam(m[1], ""))
+		}
+	}
+
+	if op.Responses == nil {
+		op.Responses = make(map[string]*Response)
+	}
+
+	if _, ok := op.Responses["default"]; !ok {
+		op.Responses["default"] = TextResponse("an error message")
+	}
+
+	item, ok := d.Paths[path]
+	if !ok {
+		item = make(PathItem)
+		d.Paths[path] = item
+
+		literals := strings.Count(path, "/") - len(params)
+		d.routes = append(d.routes, route{path: path, re: regexp.MustCompile(routePattern(path)), params: params, literals: literals})
+
+		// the most specific paths are tried first, so /config/updates wins over /{roomID}/config
+		sort.SliceStable(d.routes, func(i, j int) bool {
+			return d.routes[i].literals > d.routes[j].literals
+		})
+	}
+
+	item[strings.ToLower(method)] = &op
+	d.encoded = nil
+}
+
+// routePattern returns a regular expression that matches request paths for a path template
+func routePattern(path string) string {
+	var b strings.Builder
+	b.WriteString("^")
+
+	last := 0
+	for _, loc := range pathParam.FindAllStringIndex(path, -1) {
+		b.WriteString(regexp.QuoteMeta(path[last:loc[0]]))
+		b.WriteString("([^/]+)")
+		last = loc[1]
+	}
+
+	b.WriteString(regexp.QuoteMeta(path[last:]))
+	b.WriteString("$")
+	return b.String()
+}
+
+// Find returns the operation a request is for, and its path parameters (still escaped)
+func (d *Document) Find(method, path string) (*Operation, map[string]string, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	method = strings.ToLower(method)
+	for _, r := range d.routes {
+		m := r.re.FindStringSubmatch(path)
+		if m == nil {
+			continue
+		}
+
+		op, ok := d.Paths[r.path][method]
+		if !ok {
+			continue
+		}
+
+		params := make(map[string]string, len(r.params))
+		for i, name := range r.params {
+			params[name] = m[i+1]
+		}
+
+		return op, params, true
+	}
+
+	return nil, nil, false
+}
+
+// Operations returns every documented method and path, sorted by path
+func (d *Document) Operations() [][2]string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var ops [][2]string
+	for path, item := range d.Paths {
+		for method := range item {
+			ops = append(ops, [2]string{strings.ToUpper(method), path})
+		}
+	}
+
+	sort.Slice(ops, func(i, j int) bool {
+		if ops[i][1] != ops[j][1] {
+			return ops[i][1] < ops[j][1]
+		}
+
+		return ops[i][0] < ops[j][0]
+	})
+
+	return ops
+}
+
+// ServeHTTP serves the document as json
+func (d *Document) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	if d.encoded == nil {
+		b, err := json.MarshalIndent(d, "", "  ")
+		if err != nil {
+			d.mu.Unlock()
+			http.Error(w, fmt.Sprintf("unable to encode openapi document: %s", err), http.StatusInternalServerError)
+			return
+		}
+
+		d.encoded = b
+	}
+
+	b := d.encoded
+	d.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
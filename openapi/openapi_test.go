@@ -0,0 +1,108 @@
+package openapi
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type booking struct {
+	Title string    `json:"title"`
+	Start time.Time `json:"start"`
+	Seats *int      `json:"seats,omitempty"`
+	Notes []string  `json:"notes"`
+}
+
+func TestValidateRequest(t *testing.T) {
+	d := New("Test", "1.0.0", "")
+	d.Add(http.MethodPost, "/{room}/bookings", Operation{
+		Parameters:  []Parameter{QueryParam("count", "", Integer("").Between(1, 10))},
+		RequestBody: JSONBody("the booking", d.Schema(booking{}).Requiring("start")),
+		Responses:   map[string]*Response{"200": TextResponse("booked")},
+	})
+
+	tests := []struct {
+		target, contentType, body string
+		problems                  []string
+	}{
+		{"/ITB-1010/bookings", ContentJSON, `{"start": "2030-01-01T09:00:00Z", "seats": null, "notes": null}`, nil},
+		{"/ITB-1010/bookings?count=11", ContentJSON, `{"start": "9am", "title": 5}`, []string{"query.count: must be at most 10", `body.start: must be an RFC3339 date-time, not "9am"`, "body.title: must be a string"}},
+		{"/ITB-1010/bookings", ContentJSON, `{"title": "Standup"}`, []string{"body.start: is required"}},
+		{"/ITB-1010/bookings", ContentJSON, ``, []string{"body: is required"}},
+		// curl -d sends json as a form, which handlers bind anyway
+		{"/ITB-1010/bookings", "application/x-www-form-urlencoded", `{"start": "2030-01-01T09:00:00Z"}`, nil},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, tt.target, strings.NewReader(tt.body))
+		r.Header.Set("Content-Type", tt.contentType)
+
+		err := d.ValidateRequest(r, []byte(tt.body))
+		if len(tt.problems) == 0 {
+			if err != nil {
+				t.Errorf("%s %s: %s", tt.target, tt.body, err)
+			}
+
+			continue
+		}
+
+		if err == nil {
+			t.Errorf("%s %s: should have been invalid", tt.target, tt.body)
+			continue
+		}
+
+		for _, problem := range tt.problems {
+			if !strings.Contains(err.Error(), problem) {
+				t.Errorf("%s %s: %q is missing %q", tt.target, tt.body, err, problem)
+			}
+		}
+	}
+
+	header := http.Header{"Content-Type": {ContentJSON}}
+	if err := d.ValidateResponse(http.MethodPost, "/ITB-1010/bookings", http.StatusOK, header, []byte(`"booked"`)); err == nil {
+		t.Errorf("a json response should be invalid when the operation returns text")
+	}
+}
+
+func TestReadBody(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		chunked bool
+		want    string
+		err     error
+	}{
+		{name: "fits", body: `{"title": "Standup"}`, want: `{"title": "Standup"}`},
+		{name: "exactly max", body: strings.Repeat("x", 32), want: strings.Repeat("x", 32)},
+		{name: "too large", body: strings.Repeat("x", 33), err: ErrBodyTooLarge},
+		{name: "chunked", body: `{"title": "Standup"}`, chunked: true, want: `{"title": "Standup"}`},
+		{name: "chunked and too large", body: strings.Repeat("x", 33), chunked: true, err: ErrBodyTooLarge},
+		{name: "empty", body: ``, want: ``},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/bookings", strings.NewReader(tt.body))
+		if tt.chunked {
+			r.ContentLength = -1
+		}
+
+		body, err := ReadBody(r, 32)
+		if !errors.Is(err, tt.err) || string(body) != tt.want {
+			t.Errorf("%s: got %q, %v, want %q, %v", tt.name, body, err, tt.want, tt.err)
+			continue
+		}
+
+		if err != nil {
+			continue
+		}
+
+		// the handler can still read the whole body
+		if b, _ := io.ReadAll(r.Body); string(b) != tt.body {
+			t.Errorf("%s: handler read %q, want %q", tt.name, b, tt.body)
+		}
+	}
+}
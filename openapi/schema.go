@@ -0,0 +1,280 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Where parameters are
+const (
+	InPath   = "path"
+	InQuery  = "query"
+	InHeader = "header"
+)
+
+// Content types of request and response bodies
+const (
+	ContentJSON = "application/json"
+	ContentText = "text/plain"
+)
+
+// Schema is the subset of OpenAPI schemas this package writes and validates
+type Schema struct {
+	Ref         string `json:"$ref,omitempty"`
+	Type        string `json:"type,omitempty"`
+	Format      string `json:"format,omitempty"`
+	Description string `json:"description,omitempty"`
+	Nullable    bool   `json:"nullable,omitempty"`
+
+	Enum    []interface{} `json:"enum,omitempty"`
+	Pattern string        `json:"pattern,omitempty"`
+	Minimum *float64      `json:"minimum,omitempty"`
+	Maximum *float64      `json:"maximum,omitempty"`
+
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"`
+}
+
+// String is a string schema
+func String(description string) *Schema {
+	return &Schema{Type: "string", Description: description}
+}
+
+// Integer is an integer schema
+func Integer(description string) *Schema {
+	return &Schema{Type: "integer", Description: description}
+}
+
+// Number is a number schema
+func Number(description string) *Schema {
+	return &Schema{Type: "number", Description: description}
+}
+
+// Boolean is a boolean schema
+func Boolean(description string) *Schema {
+	return &Schema{Type: "boolean", Description: description}
+}
+
+// Enum is a string schema that must be one of values
+func Enum(description string, values ...string) *Schema {
+	s := String(description)
+	for _, v := range values {
+		s.Enum = append(s.Enum, v)
+	}
+
+	return s
+}
+
+// Object is an object schema with properties
+func Object(properties map[string]*Schema, required ...string) *Schema {
+	return &Schema{Type: "object", Properties: properties, Required: required}
+}
+
+// Array is an array of items
+func Array(items *Schema) *Schema {
+	return &Schema{Type: "array", Items: items}
+}
+
+// Between sets the smallest and largest values a number can be
+func (s *Schema) Between(min, max float64) *Schema {
+	s.Minimum, s.Maximum = &min, &max
+	return s
+}
+
+// Matching sets the regular expression a string must match. It panics if the pattern doesn't compile.
+func (s *Schema) Matching(pattern string) *Schema {
+	compile(pattern)
+	s.Pattern = pattern
+	return s
+}
+
+// Requiring returns a copy of an object schema (or a reference to one) that must have properties
+func (s *Schema) Requiring(properties ...string) *Schema {
+	if len(s.Ref) > 0 {
+		return &Schema{AllOf: []*Schema{s, {Required: properties}}}
+	}
+
+	c := *s
+	c.Required = append(append([]string{}, s.Required...), properties...)
+	return &c
+}
+
+// PathParam is a required path parameter
+func PathParam(name, description string) Parameter {
+	return Parameter{Name: name, In: InPath, Description: description, Required: true, Schema: String("")}
+}
+
+// QueryParam is an optional query parameter
+func QueryParam(name, description string, schema *Schema) Parameter {
+	return Parameter{Name: name, In: InQuery, Description: description, Schema: schema}
+}
+
+// JSONBody is a required json request body
+func JSONBody(description string, schema *Schema) *RequestBody {
+	return &RequestBody{Description: description, Required: true, Content: map[string]MediaType{ContentJSON: {Schema: schema}}}
+}
+
+// JSONResponse is a json response body
+func JSONResponse(description string, schema *Schema) *Response {
+	return &Response{Description: description, Content: map[string]MediaType{ContentJSON: {Schema: schema}}}
+}
+
+// TextResponse is a plain text response body
+func TextResponse(description string) *Response {
+	return &Response{Description: description, Content: map[string]MediaType{ContentText: {Schema: String("")}}}
+}
+
+// BearerAuth requires an operation to be called with the bearer token of the security scheme name
+func (d *Document) BearerAuth(name, description string) []map[string][]string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.Components.SecuritySchemes[name] = SecurityScheme{Type: "http", Scheme: "bearer", Description: description}
+	d.encoded = nil
+	return []map[string][]string{{name: {}}}
+}
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	rawType  = reflect.TypeOf(json.RawMessage{})
+)
+
+// Schema returns the schema of the json v encodes to. Named struct types are added to the document's components and referred to.
+// Properties aren't required, since handlers fill in what clients leave out; operations that need them use Requiring.
+func (d *Document) Schema(v interface{}) *Schema {
+	if v == nil {
+		return &Schema{}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.encoded = nil
+	return d.schema(reflect.TypeOf(v))
+}
+
+func (d *Document) schema(t reflect.Type) *Schema {
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case rawType:
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		s := d.schema(t.Elem())
+		if len(s.Ref) > 0 {
+			return &Schema{AllOf: []*Schema{s}, Nullable: true}
+		}
+
+		s.Nullable = true
+		return s
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte", Nullable: true}
+		}
+
+		// nil slices encode to null
+		return &Schema{Type: "array", Items: d.schema(t.Elem()), Nullable: true}
+	case reflect.Array:
+		return &Schema{Type: "array", Items: d.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: d.schema(t.Elem()), Nullable: true}
+	case reflect.Struct:
+		if len(t.Name()) == 0 {
+			return d.structSchema(t)
+		}
+
+		name := d.schemaName(t)
+		if _, ok := d.Components.Schemas[name]; !ok {
+			// added before its fields, so types that refer to themselves don't recurse forever
+			d.Components.Schemas[name] = &Schema{}
+			*d.Components.Schemas[name] = *d.structSchema(t)
+		}
+
+		return &Schema{Ref: "#/components/schemas/" + name}
+	}
+
+	// interfaces, and anything else, can be any json
+	return &Schema{}
+}
+
+// schemaName returns the name t's schema has in the components, qualified by its package if another type has the same name
+func (d *Document) schemaName(t reflect.Type) string {
+	key := t.PkgPath() + "." + t.Name()
+	if name, ok := d.types[key]; ok {
+		return name
+	}
+
+	name := t.Name()
+	for _, taken := range d.types {
+		if taken == name {
+			name = t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:] + "." + t.Name()
+			break
+		}
+	}
+
+	d.types[key] = name
+	return name
+}
+
+// structSchema returns the properties of a struct, the way encoding/json writes them
+func (d *Document) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name := strings.Split(tag, ",")[0]
+
+		// embedded structs' fields are promoted, unless the struct is named in the tag
+		if f.Anonymous && len(name) == 0 {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+
+			if ft.Kind() == reflect.Struct {
+				for prop, schema := range d.structSchema(ft).Properties {
+					if _, ok := s.Properties[prop]; !ok {
+						s.Properties[prop] = schema
+					}
+				}
+
+				continue
+			}
+		}
+
+		if !f.IsExported() {
+			continue
+		}
+
+		if len(name) == 0 {
+			name = f.Name
+		}
+
+		s.Properties[name] = d.schema(f.Type)
+	}
+
+	return s
+}
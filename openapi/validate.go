@@ -0,0 +1,403 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Error lists every way a request or response doesn't match its operation
+type Error struct {
+	Operation string   `json:"operation"`
+	Problems  []string `json:"problems"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s doesn't match the api: %s", e.Operation, strings.Join(e.Problems, "; "))
+}
+
+// problems collects what's wrong, at json paths like body.events[2].startTime
+type problems []string
+
+func (p *problems) add(at, format string, a ...interface{}) {
+	*p = append(*p, at+": "+fmt.Sprintf(format, a...))
+}
+
+func (p problems) err(method, path string) error {
+	if len(p) == 0 {
+		return nil
+	}
+
+	return &Error{Operation: method + " " + path, Problems: p}
+}
+
+// ValidateRequest checks a request's path and query parameters, and its json body, against the operation it is for.
+// Requests for operations that aren't documented aren't checked.
+func (d *Document) ValidateRequest(r *http.Request, body []byte) error {
+	op, params, ok := d.Find(r.Method, r.URL.EscapedPath())
+	if !ok {
+		return nil
+	}
+
+	var p problems
+	query := r.URL.Query()
+
+	for _, param := range op.Parameters {
+		var (
+			value   string
+			present bool
+		)
+
+		switch param.In {
+		case InPath:
+			value, present = params[param.Name]
+			if unescaped, err := url.PathUnescape(value); err == nil {
+				value = unescaped
+			}
+		case InQuery:
+			present = query.Has(param.Name)
+			value = query.Get(param.Name)
+		case InHeader:
+			value = r.Header.Get(param.Name)
+			present = len(value) > 0
+		}
+
+		at := param.In + "." + param.Name
+		switch {
+		case !present && param.Required:
+			p.add(at, "is required")
+		case present && param.Schema != nil:
+			d.validateParam(param.Schema, value, at, &p)
+		}
+	}
+
+	if op.RequestBody != nil {
+		contentType := r.Header.Get("Content-Type")
+		if op.BodyIsJSON(contentType) {
+			contentType = ContentJSON
+		}
+
+		d.validateBody(op.RequestBody.Content, op.RequestBody.Required, contentType, body, "body", &p)
+	}
+
+	return p.err(r.Method, r.URL.EscapedPath())
+}
+
+// ErrBodyTooLarge is returned by ReadBody when a request's body is too large to validate
+var ErrBodyTooLarge = errors.New("request body is too large")
+
+// ReadBody reads r's body so it can be validated, and puts it back so the handler can read it too.
+// Bodies longer than max, whether their length was sent or they were chunked, return ErrBodyTooLarge.
+func ReadBody(r *http.Request, max int64) ([]byte, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, nil
+	}
+
+	if r.ContentLength > max {
+		return nil, ErrBodyTooLarge
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, max+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(body)) > max {
+		return nil, ErrBodyTooLarge
+	}
+
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// BodyIsJSON returns true if a request body with contentType is checked as json. Handlers bind json bodies whatever
+// their content type says (like curl -d's form encoding), so json is assumed unless the operation accepts contentType.
+func (op *Operation) BodyIsJSON(contentType string) bool {
+	if op.RequestBody == nil {
+		return false
+	}
+
+	if _, ok := op.RequestBody.Content[ContentJSON]; !ok {
+		return false
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	_, accepted := op.RequestBody.Content[mediaType]
+	return mediaType == ContentJSON || !accepted
+}
+
+// ValidateResponse checks a response's status and json body against the operation the request was for.
+// Responses to operations that aren't documented aren't checked.
+func (d *Document) ValidateResponse(method, path string, status int, header http.Header, body []byte) error {
+	op, _, ok := d.Find(method, path)
+	if !ok {
+		return nil
+	}
+
+	var p problems
+
+	resp, ok := op.Responses[strconv.Itoa(status)]
+	if !ok {
+		resp, ok = op.Responses["default"]
+	}
+
+	switch {
+	case !ok:
+		p.add("status", "%d isn't documented", status)
+	case len(body) > 0 && len(resp.Content) > 0:
+		d.validateBody(resp.Content, false, header.Get("Content-Type"), body, "body", &p)
+	}
+
+	return p.err(method, path)
+}
+
+// validateBody checks that a body has one of the content types, and that json bodies match their schema
+func (d *Document) validateBody(content map[string]MediaType, required bool, contentType string, body []byte, at string, p *problems) {
+	if len(bytes.TrimSpace(body)) == 0 {
+		if required {
+			p.add(at, "is required")
+		}
+
+		return
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if len(mediaType) == 0 {
+		// clients often leave out the content type of json bodies
+		mediaType = ContentJSON
+	}
+
+	media, ok := content[mediaType]
+	if !ok {
+		media, ok = content[strings.SplitN(mediaType, "/", 2)[0]+"/*"]
+	}
+
+	if !ok {
+		media, ok = content["*/*"]
+	}
+
+	if !ok {
+		var types []string
+		for t := range content {
+			types = append(types, t)
+		}
+
+		sort.Strings(types)
+		p.add(at, "content type %s must be one of %s", mediaType, strings.Join(types, ", "))
+		return
+	}
+
+	if mediaType != ContentJSON || media.Schema == nil {
+		return
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		p.add(at, "isn't valid json: %s", err)
+		return
+	}
+
+	d.validate(media.Schema, v, at, p)
+}
+
+// validateParam checks a parameter's value, after converting it to its schema's type
+func (d *Document) validateParam(s *Schema, value, at string, p *problems) {
+	s = d.resolve(s)
+
+	var v interface{} = value
+	switch s.Type {
+	case "integer", "number":
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			p.add(at, "must be a number, not %q", value)
+			return
+		}
+
+		v = f
+	case "boolean":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			p.add(at, "must be true or false, not %q", value)
+			return
+		}
+
+		v = b
+	}
+
+	d.validate(s, v, at, p)
+}
+
+// resolve returns the schema a reference refers to
+func (d *Document) resolve(s *Schema) *Schema {
+	for len(s.Ref) > 0 {
+		d.mu.Lock()
+		ref, ok := d.Components.Schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+		d.mu.Unlock()
+
+		if !ok {
+			return &Schema{}
+		}
+
+		s = ref
+	}
+
+	return s
+}
+
+// validate checks a decoded json value against s
+func (d *Document) validate(s *Schema, v interface{}, at string, p *problems) {
+	s = d.resolve(s)
+
+	if v == nil {
+		if !s.Nullable && len(s.Type) > 0 {
+			p.add(at, "must not be null")
+		}
+
+		return
+	}
+
+	for _, sub := range s.AllOf {
+		d.validate(sub, v, at, p)
+	}
+
+	if len(s.Enum) > 0 {
+		found := false
+		for _, e := range s.Enum {
+			if e == v {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			p.add(at, "must be one of %v, not %v", s.Enum, v)
+		}
+	}
+
+	switch s.Type {
+	case "":
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			p.add(at, "must be a string, not %s", jsonType(v))
+			return
+		}
+
+		if s.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339, str); err != nil {
+				p.add(at, "must be an RFC3339 date-time, not %q", str)
+			}
+		}
+
+		if len(s.Pattern) > 0 && !compile(s.Pattern).MatchString(str) {
+			p.add(at, "must match %s, not %q", s.Pattern, str)
+		}
+	case "integer", "number":
+		f, ok := v.(float64)
+		if !ok {
+			p.add(at, "must be a number, not %s", jsonType(v))
+			return
+		}
+
+		if s.Type == "integer" && f != float64(int64(f)) {
+			p.add(at, "must be an integer, not %v", f)
+		}
+
+		if s.Minimum != nil && f < *s.Minimum {
+			p.add(at, "must be at least %v, not %v", *s.Minimum, f)
+		}
+
+		if s.Maximum != nil && f > *s.Maximum {
+			p.add(at, "must be at most %v, not %v", *s.Maximum, f)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			p.add(at, "must be true or false, not %s", jsonType(v))
+		}
+	case "array":
+		items, ok := v.([]interface{})
+		if !ok {
+			p.add(at, "must be an array, not %s", jsonType(v))
+			return
+		}
+
+		if s.Items != nil {
+			for i, item := range items {
+				d.validate(s.Items, item, fmt.Sprintf("%s[%d]", at, i), p)
+			}
+		}
+	case "object":
+		if _, ok := v.(map[string]interface{}); !ok {
+			p.add(at, "must be an object, not %s", jsonType(v))
+			return
+		}
+	}
+
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	for _, name := range s.Required {
+		if _, ok := obj[name]; !ok {
+			p.add(at+"."+name, "is required")
+		}
+	}
+
+	// sorted, so problems are listed in the same order every time
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		switch prop, ok := s.Properties[name]; {
+		case ok:
+			d.validate(prop, obj[name], at+"."+name, p)
+		case s.AdditionalProperties != nil:
+			d.validate(s.AdditionalProperties, obj[name], at+"."+name, p)
+		}
+	}
+}
+
+func jsonType(v interface{}) string {
+	switch v.(type) {
+	case string:
+		return "a string"
+	case float64:
+		return "a number"
+	case bool:
+		return "a boolean"
+	case []interface{}:
+		return "an array"
+	case map[string]interface{}:
+		return "an object"
+	}
+
+	return "null"
+}
+
+var patterns sync.Map
+
+// compile returns the compiled pattern, which was checked when the document was built
+func compile(pattern string) *regexp.Regexp {
+	if re, ok := patterns.Load(pattern); ok {
+		return re.(*regexp.Regexp)
+	}
+
+	re := regexp.MustCompile(pattern)
+	patterns.Store(pattern, re)
+	return re
+}
@@ -0,0 +1,10 @@
+//go:build providers
+
+package main
+
+import (
+	// rooms can use inproc://<backend>/<roomID> calendars for the provider backends
+	_ "github.com/byuoitav/scheduler/calendars/backends/exchange"
+	_ "github.com/byuoitav/scheduler/calendars/backends/gsuite"
+	_ "github.com/byuoitav/scheduler/calendars/backends/teamup"
+)
@@ -0,0 +1,448 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"net/http"
+
+	"github.com/byuoitav/scheduler/calendars"
+	"github.com/byuoitav/scheduler/handlers"
+	"github.com/byuoitav/scheduler/log"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// newRouter builds the gin server with every route, serving the panel from web
+func newRouter(web fs.FS) *gin.Engine {
+	r := gin.New()
+
+	// requests are checked against the api described at /openapi.json
+	r.Use(handlers.ValidateAPI)
+
+	// the panel api. rooms/:roomID is any room, and panel is the room of the panel making the request.
+	v1 := r.Group(handlers.APIPrefix)
+
+	// get/create event
+	v1.GET("/rooms/:roomID/events", func(c *gin.Context) {
+		log.P.Debug("GET /api/v1/rooms/:roomID/events", zap.String("roomID", c.Param("roomID")))
+		if c.IsAborted() {
+			log.P.Error("Request aborted before processing")
+			c.String(http.StatusInternalServerError, "event retrieval request aborted before processing")
+			return
+		}
+		handlers.GetEvents(c)
+	})
+	v1.POST("/rooms/:roomID/events", func(c *gin.Context) {
+		logRequestAndStatus(c, "POST /api/v1/rooms/:roomID/events", zap.String("roomID", c.Param("roomID")))
+		if c.IsAborted() {
+			log.P.Error("Request aborted before processing")
+			c.String(http.StatusInternalServerError, "event creation request aborted before processing")
+			return
+		}
+		handlers.CreateEvent(c)
+	})
+
+	// get config for the room
+	v1.GET("/panel/config", func(c *gin.Context) {
+		logRequestAndStatus(c, "GET /api/v1/panel/config")
+		if c.IsAborted() {
+			log.P.Error("Request aborted before processing")
+			c.String(http.StatusInternalServerError, "config request aborted before processing")
+			return
+		}
+		handlers.GetConfig(c)
+	})
+	v1.GET("/rooms/:roomID/config", func(c *gin.Context) {
+		logRequestAndStatus(c, "GET /api/v1/rooms/:roomID/config", zap.String("roomID", c.Param("roomID")))
+		if c.IsAborted() {
+			log.P.Error("Request aborted before processing")
+			c.String(http.StatusInternalServerError, "config request aborted before processing")
+			return
+		}
+		handlers.GetConfig(c)
+	})
+
+	// get the identity this device was configured with
+	v1.GET("/identity", func(c *gin.Context) {
+		logRequestAndStatus(c, "GET /api/v1/identity")
+		if c.IsAborted() {
+			log.P.Error("Request aborted before processing")
+			c.String(http.StatusInternalServerError, "identity request aborted before processing")
+			return
+		}
+		handlers.GetIdentity(c)
+	})
+
+	// get told when the room's config changes
+	v1.GET("/panel/config/updates", func(c *gin.Context) {
+		logRequestAndStatus(c, "GET /api/v1/panel/config/updates")
+		if c.IsAborted() {
+			log.P.Error("Request aborted before processing")
+			c.String(http.StatusInternalServerError, "config updates request aborted before processing")
+			return
+		}
+		handlers.WatchConfig(c)
+	})
+	v1.GET("/rooms/:roomID/config/updates", func(c *gin.Context) {
+		logRequestAndStatus(c, "GET /api/v1/rooms/:roomID/config/updates", zap.String("roomID", c.Param("roomID")))
+		if c.IsAborted() {
+			log.P.Error("Request aborted before processing")
+			c.String(http.StatusInternalServerError, "config updates request aborted before processing")
+			return
+		}
+		handlers.WatchConfig(c)
+	})
+
+	// get background image
+	v1.GET("/panel/background", func(c *gin.Context) {
+		logRequestAndStatus(c, "GET /api/v1/panel/background")
+		if c.IsAborted() {
+			log.P.Error("Request aborted before processing")
+			c.String(http.StatusInternalServerError, "background image request aborted before processing")
+			return
+		}
+		handlers.GetBackgroundImg(c)
+	})
+	v1.GET("/rooms/:roomID/background", func(c *gin.Context) {
+		logRequestAndStatus(c, "GET /api/v1/rooms/:roomID/background", zap.String("roomID", c.Param("roomID")))
+		if c.IsAborted() {
+			log.P.Error("Request aborted before processing")
+			c.String(http.StatusInternalServerError, "background image request aborted before processing")
+			return
+		}
+		handlers.GetBackgroundImg(c)
+	})
+
+	// get the backgrounds to rotate between
+	v1.GET("/panel/background/manifest", func(c *gin.Context) {
+		logRequestAndStatus(c, "GET /api/v1/panel/background/manifest")
+		if c.IsAborted() {
+			log.P.Error("Request aborted before processing")
+			c.String(http.StatusInternalServerError, "background manifest request aborted before processing")
+			return
+		}
+		handlers.GetBackgroundManifest(c)
+	})
+	v1.GET("/rooms/:roomID/background/manifest", func(c *gin.Context) {
+		logRequestAndStatus(c, "GET /api/v1/rooms/:roomID/background/manifest", zap.String("roomID", c.Param("roomID")))
+		if c.IsAborted() {
+			log.P.Error("Request aborted before processing")
+			c.String(http.StatusInternalServerError, "background manifest request aborted before processing")
+			return
+		}
+		handlers.GetBackgroundManifest(c)
+	})
+
+	// get how the room shows dates and times, and its ui strings
+	v1.GET("/panel/locale", func(c *gin.Context) {
+		logRequestAndStatus(c, "GET /api/v1/panel/locale")
+		if c.IsAborted() {
+			log.P.Error("Request aborted before processing")
+			c.String(http.StatusInternalServerError, "locale request aborted before processing")
+			return
+		}
+		handlers.GetLocale(c)
+	})
+	v1.GET("/rooms/:roomID/locale", func(c *gin.Context) {
+		logRequestAndStatus(c, "GET /api/v1/rooms/:roomID/locale", zap.String("roomID", c.Param("roomID")))
+		if c.IsAborted() {
+			log.P.Error("Request aborted before processing")
+			c.String(http.StatusInternalServerError, "locale request aborted before processing")
+			return
+		}
+		handlers.GetLocale(c)
+	})
+	v1.GET("/translations", func(c *gin.Context) {
+		logRequestAndStatus(c, "GET /api/v1/translations", zap.String("lang", c.Query("lang")))
+		if c.IsAborted() {
+			log.P.Error("Request aborted before processing")
+			c.String(http.StatusInternalServerError, "translations request aborted before processing")
+			return
+		}
+		handlers.GetTranslations(c)
+	})
+
+	// get static elements
+	v1.GET("/static", func(c *gin.Context) {
+		logRequestAndStatus(c, "GET /api/v1/static")
+		if c.IsAborted() {
+			log.P.Error("Request aborted before processing")
+			c.String(http.StatusInternalServerError, "static elements request aborted before processing")
+			return
+		}
+		handlers.ListStaticElements(c)
+	})
+	v1.GET("/static/:doc", func(c *gin.Context) {
+		logRequestAndStatus(c, "GET /api/v1/static/:doc", zap.String("doc", c.Param("doc")))
+		if c.IsAborted() {
+			log.P.Error("Request aborted before processing")
+			c.String(http.StatusInternalServerError, "static elements request aborted before processing")
+			return
+		}
+		handlers.GetStaticElements(c)
+	})
+
+	// send help request
+	v1.POST("/panel/help", func(c *gin.Context) {
+		logRequestAndStatus(c, "POST /api/v1/panel/help")
+		if c.IsAborted() {
+			log.P.Error("Request aborted before processing")
+			c.String(http.StatusInternalServerError, "help request aborted before processing")
+			return
+		}
+		handlers.SendHelpRequest(c)
+	})
+	v1.POST("/rooms/:roomID/help", func(c *gin.Context) {
+		logRequestAndStatus(c, "POST /api/v1/rooms/:roomID/help", zap.String("roomID", c.Param("roomID")))
+		if c.IsAborted() {
+			log.P.Error("Request aborted before processing")
+			c.String(http.StatusInternalServerError, "help request aborted before processing")
+			return
+		}
+		handlers.SendHelpRequest(c)
+	})
+
+	// get the status of a help request
+	v1.GET("/help/:id", func(c *gin.Context) {
+		logRequestAndStatus(c, "GET /api/v1/help/:id", zap.String("id", c.Param("id")))
+		if c.IsAborted() {
+			log.P.Error("Request aborted before processing")
+			c.String(http.StatusInternalServerError, "help request status request aborted before processing")
+			return
+		}
+		handlers.GetHelpRequest(c)
+	})
+
+	// acknowledge a help request (from the support desk)
+	v1.POST("/help/:id/ack", func(c *gin.Context) {
+		logRequestAndStatus(c, "POST /api/v1/help/:id/ack", zap.String("id", c.Param("id")))
+		if c.IsAborted() {
+			log.P.Error("Request aborted before processing")
+			c.String(http.StatusInternalServerError, "help request acknowledgement aborted before processing")
+			return
+		}
+		handlers.AcknowledgeHelpRequest(c)
+	})
+
+	// cancel a help request (from the panel)
+	v1.POST("/help/:id/cancel", func(c *gin.Context) {
+		logRequestAndStatus(c, "POST /api/v1/help/:id/cancel", zap.String("id", c.Param("id")))
+		if c.IsAborted() {
+			log.P.Error("Request aborted before processing")
+			c.String(http.StatusInternalServerError, "help request cancellation aborted before processing")
+			return
+		}
+		handlers.CancelHelpRequest(c)
+	})
+
+	// routes from before the panel api was versioned, for panels that haven't picked up the new frontend yet
+	successors := make(map[string]gin.HandlerFunc)
+	for _, route := range r.Routes() {
+		successors[route.Method+" "+route.Path] = route.HandlerFunc
+	}
+
+	for _, route := range handlers.LegacyRoutes {
+		r.Handle(route.Method, route.Path, handlers.Deprecated(route), successors[route.Method+" "+route.Successor])
+	}
+
+	// handle load balancer status check
+	r.GET("/status", func(c *gin.Context) {
+		logRequestAndStatus(c, "GET /status")
+		if c.IsAborted() {
+			log.P.Error("Request aborted before processing")
+			c.String(http.StatusInternalServerError, "status check request aborted before processing")
+			return
+		}
+		c.String(http.StatusOK, "healthy")
+	})
+
+	// manage room configs
+	admin := r.Group("/admin", handlers.RequireAdmin, handlers.ValidateAPI)
+	admin.GET("/rooms", func(c *gin.Context) {
+		logRequestAndStatus(c, "GET /admin/rooms")
+		if c.IsAborted() {
+			log.P.Error("Request aborted before processing")
+			c.String(http.StatusInternalServerError, "room config list request aborted before processing")
+			return
+		}
+		handlers.ListRoomConfigs(c)
+	})
+	admin.GET("/rooms/:id", func(c *gin.Context) {
+		logRequestAndStatus(c, "GET /admin/rooms/:id", zap.String("id", c.Param("id")))
+		if c.IsAborted() {
+			log.P.Error("Request aborted before processing")
+			c.String(http.StatusInternalServerError, "room config request aborted before processing")
+			return
+		}
+		handlers.GetRoomConfig(c)
+	})
+	admin.POST("/rooms", func(c *gin.Context) {
+		logRequestAndStatus(c, "POST /admin/rooms")
+		if c.IsAborted() {
+			log.P.Error("Request aborted before processing")
+			c.String(http.StatusInternalServerError, "room config creation aborted before processing")
+			return
+		}
+		handlers.CreateRoomConfig(c)
+	})
+	admin.PUT("/rooms/:id", func(c *gin.Context) {
+		logRequestAndStatus(c, "PUT /admin/rooms/:id", zap.String("id", c.Param("id")))
+		if c.IsAborted() {
+			log.P.Error("Request aborted before processing")
+			c.String(http.StatusInternalServerError, "room config update aborted before processing")
+			return
+		}
+		handlers.UpdateRoomConfig(c)
+	})
+	admin.DELETE("/rooms/:id", func(c *gin.Context) {
+		logRequestAndStatus(c, "DELETE /admin/rooms/:id", zap.String("id", c.Param("id")))
+		if c.IsAborted() {
+			log.P.Error("Request aborted before processing")
+			c.String(http.StatusInternalServerError, "room config deletion aborted before processing")
+			return
+		}
+		handlers.DeleteRoomConfig(c)
+	})
+	admin.PUT("/rooms/:id/background", func(c *gin.Context) {
+		logRequestAndStatus(c, "PUT /admin/rooms/:id/background", zap.String("id", c.Param("id")))
+		if c.IsAborted() {
+			log.P.Error("Request aborted before processing")
+			c.String(http.StatusInternalServerError, "background upload aborted before processing")
+			return
+		}
+		handlers.UploadRoomBackground(c)
+	})
+	admin.PUT("/buildings/:id/background", func(c *gin.Context) {
+		logRequestAndStatus(c, "PUT /admin/buildings/:id/background", zap.String("id", c.Param("id")))
+		if c.IsAborted() {
+			log.P.Error("Request aborted before processing")
+			c.String(http.StatusInternalServerError, "background upload aborted before processing")
+			return
+		}
+		handlers.UploadBuildingBackground(c)
+	})
+	admin.POST("/rooms/import", func(c *gin.Context) {
+		logRequestAndStatus(c, "POST /admin/rooms/import")
+		if c.IsAborted() {
+			log.P.Error("Request aborted before processing")
+			c.String(http.StatusInternalServerError, "room config import aborted before processing")
+			return
+		}
+		handlers.ImportRoomConfigs(c)
+	})
+	admin.GET("/calendars", func(c *gin.Context) {
+		logRequestAndStatus(c, "GET /admin/calendars")
+		if c.IsAborted() {
+			log.P.Error("Request aborted before processing")
+			c.String(http.StatusInternalServerError, "calendar health request aborted before processing")
+			return
+		}
+		handlers.GetCalendarHealth(c)
+	})
+
+	// get the status of outbound event deliveries. their data includes help request ids, so it's admin only.
+	admin.GET("/queue", func(c *gin.Context) {
+		logRequestAndStatus(c, "GET /admin/queue")
+		if c.IsAborted() {
+			log.P.Error("Request aborted before processing")
+			c.String(http.StatusInternalServerError, "event queue status request aborted before processing")
+			return
+		}
+		handlers.GetEventQueueStatus(c)
+	})
+
+	// move the server's clock, for testing and demos (see --fake-time)
+	admin.GET("/clock", func(c *gin.Context) {
+		logRequestAndStatus(c, "GET /admin/clock")
+		if c.IsAborted() {
+			log.P.Error("Request aborted before processing")
+			c.String(http.StatusInternalServerError, "clock request aborted before processing")
+			return
+		}
+		handlers.GetClock(c)
+	})
+	admin.PUT("/clock", func(c *gin.Context) {
+		logRequestAndStatus(c, "PUT /admin/clock")
+		if c.IsAborted() {
+			log.P.Error("Request aborted before processing")
+			c.String(http.StatusInternalServerError, "clock change aborted before processing")
+			return
+		}
+		handlers.SetClock(c)
+	})
+	admin.DELETE("/clock", func(c *gin.Context) {
+		logRequestAndStatus(c, "DELETE /admin/clock")
+		if c.IsAborted() {
+			log.P.Error("Request aborted before processing")
+			c.String(http.StatusInternalServerError, "clock reset aborted before processing")
+			return
+		}
+		handlers.ResetClock(c)
+	})
+
+	// admin endpoints of calendar backends linked into the scheduler, like injecting faults into fake calendars
+	for _, b := range calendars.Backends() {
+		if b.Admin == nil {
+			continue
+		}
+
+		prefix := "/admin/backends/" + b.Name
+		h := gin.WrapH(http.StripPrefix(prefix, b.Admin))
+		for _, method := range handlers.BackendAdminMethods {
+			admin.Handle(method, "/backends/"+b.Name+"/*path", func(c *gin.Context) {
+				logRequestAndStatus(c, c.Request.Method+" "+prefix+"/*path", zap.String("path", c.Param("path")))
+				h(c)
+			})
+		}
+	}
+
+	// describe the api
+	r.GET("/openapi.json", func(c *gin.Context) {
+		logRequestAndStatus(c, "GET /openapi.json")
+		if c.IsAborted() {
+			log.P.Error("Request aborted before processing")
+			c.String(http.StatusInternalServerError, "openapi request aborted before processing")
+			return
+		}
+		handlers.GetOpenAPI(c)
+	})
+
+	// set the log level
+	r.GET("/log/:level", func(c *gin.Context) {
+		levelStr := c.Param("level")
+		log.P.Info("GET /log/:level", zap.String("level", levelStr))
+		if err := setLogLevel(levelStr); err != nil {
+			log.P.Error("Invalid log level string", zap.String("level", levelStr))
+			c.String(http.StatusBadRequest, "invalid log level: must be one of debug, info, warn, error, panic")
+			return
+		}
+		c.String(http.StatusOK, fmt.Sprintf("Set log level to %s", levelStr))
+	})
+
+	r.StaticFS("/web", http.FS(web))
+
+	r.NoRoute(func(c *gin.Context) {
+		if c.Request.URL.Path == "/" {
+			// keep ?room= and ?device= for panels pointed at a multi-tenant server
+			target := "/web/"
+			if len(c.Request.URL.RawQuery) > 0 {
+				target += "?" + c.Request.URL.RawQuery
+			}
+			c.Redirect(http.StatusFound, target)
+		} else if len(c.Request.URL.Path) >= 5 && c.Request.URL.Path[:5] == "/web/" {
+			c.FileFromFS("index.html", http.FS(web))
+		} else {
+			c.String(http.StatusNotFound, "Not found")
+			log.P.Error("404 Not Found", zap.String("path", c.Request.URL.Path))
+		}
+	})
+
+	return r
+}
+
+// Helper: log request and response status
+func logRequestAndStatus(c *gin.Context, msg string, fields ...zap.Field) {
+	c.Next() // process the handler
+	status := c.Writer.Status()
+	log.P.Info(msg, append(fields, zap.Int("status", status))...)
+}
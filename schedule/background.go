@@ -0,0 +1,277 @@
+package schedule
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/jpeg" // accepted for uploads
+	"image/png"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/byuoitav/scheduler/log"
+	"go.uber.org/zap"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp" // accepted for uploads
+)
+
+const (
+	// BackgroundAttachment is the name of the attachment on a room's config holding its background
+	BackgroundAttachment = "bg.png"
+
+	// PanelWidth and PanelHeight are the resolution of the scheduling touchpanels
+	PanelWidth  = 800
+	PanelHeight = 480
+
+	// MaxBackgroundWidth and MaxBackgroundHeight are the largest backgrounds uploads are resized to
+	MaxBackgroundWidth  = 4 * PanelWidth
+	MaxBackgroundHeight = 4 * PanelHeight
+
+	// DefaultDarken is how much uploaded backgrounds are darkened by default, so the overlays stay readable
+	DefaultDarken = 0.4
+
+	// MaxBackgroundSize is the largest upload accepted, in bytes
+	MaxBackgroundSize = 20 << 20
+
+	// uploads must be at least half the panel resolution, and no bigger than this on either side
+	maxBackgroundDimension = 10000
+
+	// or have more pixels than this, which is about 100MB decoded
+	maxBackgroundPixels = 4 * MaxBackgroundWidth * MaxBackgroundHeight
+)
+
+// ErrInvalidImage is returned when an uploaded background can't be used
+var ErrInvalidImage = errors.New("invalid image")
+
+// BackgroundOptions control how an uploaded background is processed
+type BackgroundOptions struct {
+	Width  int
+	Height int
+
+	// Darken is how much to darken the image, from 0 (unchanged) to 1 (black)
+	Darken float64
+}
+
+// Background sources, from most to least specific
+const (
+	SourceRoom     = "room"
+	SourceBuilding = "building"
+	SourceStatic   = "static"
+
+	// SourceDefault is the image built into the panel, used when couch has nothing better
+	SourceDefault = "default"
+)
+
+// building defaults live in their own documents, which aren't room configs
+const buildingDocPrefix = "building:"
+
+// BuildingDoc returns the id of the document holding a building's default backgrounds
+func BuildingDoc(buildingID string) string {
+	return buildingDocPrefix + buildingID
+}
+
+var validBackgroundName = regexp.MustCompile(`^[A-Za-z0-9_.-]+\.png$`)
+
+// Backgrounds are the images a room's panel should show behind the schedule
+type Backgrounds struct {
+	// Source is where the images came from: room, building, static, or default
+	Source string
+
+	// DocID is the document the images are attached to
+	DocID string
+
+	Rotation BackgroundRotation
+}
+
+type backgroundDoc struct {
+	Backgrounds *BackgroundRotation       `json:"backgrounds"`
+	Attachments map[string]fileAttachment `json:"_attachments"`
+}
+
+// GetBackgrounds finds the backgrounds for a room. If the room doesn't have any, its building's are used,
+// then the global default on the static document. Source is SourceDefault if none of them have any.
+func GetBackgrounds(ctx context.Context, roomID, buildingID string) (Backgrounds, error) {
+	docs := []Backgrounds{{Source: SourceRoom, DocID: roomID}}
+	if len(buildingID) > 0 {
+		docs = append(docs, Backgrounds{Source: SourceBuilding, DocID: BuildingDoc(buildingID)})
+	}
+
+	docs = append(docs, Backgrounds{Source: SourceStatic, DocID: "static"})
+
+	for _, b := range docs {
+		var doc backgroundDoc
+		err := couchRequest(ctx, http.MethodGet, url.PathEscape(b.DocID), nil, &doc)
+		switch {
+		case errors.Is(err, ErrNotFound):
+			continue
+		case err != nil:
+			return Backgrounds{Source: SourceDefault}, fmt.Errorf("unable to get %s: %w", b.DocID, err)
+		}
+
+		if rotation, ok := doc.rotation(); ok {
+			b.Rotation = rotation
+			return b, nil
+		}
+	}
+
+	return Backgrounds{Source: SourceDefault}, nil
+}
+
+// rotation returns the document's rotation without any images that aren't attached,
+// or just its bg.png if it doesn't have a rotation
+func (d backgroundDoc) rotation() (BackgroundRotation, bool) {
+	var rotation BackgroundRotation
+	if d.Backgrounds != nil {
+		rotation = *d.Backgrounds
+		rotation.Images = nil
+
+		for _, img := range d.Backgrounds.Images {
+			if _, ok := d.Attachments[img.Attachment]; ok {
+				rotation.Images = append(rotation.Images, img)
+			}
+		}
+	}
+
+	if len(rotation.Images) > 0 {
+		return rotation, true
+	}
+
+	if _, ok := d.Attachments[BackgroundAttachment]; ok {
+		return BackgroundRotation{Images: []BackgroundImage{{Attachment: BackgroundAttachment}}}, true
+	}
+
+	return rotation, false
+}
+
+// Has returns true if name is one of the background images
+func (b Backgrounds) Has(name string) bool {
+	for _, img := range b.Rotation.Images {
+		if img.Attachment == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Image downloads one of the background images
+func (b Backgrounds) Image(ctx context.Context, name string) (Attachment, error) {
+	if !b.Has(name) {
+		return Attachment{}, fmt.Errorf("%w: %s is not one of the backgrounds on %q", ErrNotFound, name, b.DocID)
+	}
+
+	img, err := getAttachment(ctx, b.DocID, name)
+	if err != nil {
+		return img, fmt.Errorf("unable to get %s from %s: %w", name, b.DocID, err)
+	}
+
+	return img, nil
+}
+
+// ProcessBackground validates an uploaded png, jpeg, or webp image, scales and crops it to
+// exactly fill opts.Width x opts.Height, darkens it, and returns it encoded as a png.
+func ProcessBackground(r io.Reader, opts BackgroundOptions) ([]byte, error) {
+	if opts.Width <= 0 || opts.Height <= 0 {
+		opts.Width, opts.Height = PanelWidth, PanelHeight
+	}
+
+	if opts.Width > MaxBackgroundWidth || opts.Height > MaxBackgroundHeight {
+		return nil, fmt.Errorf("%w: backgrounds can't be bigger than %dx%d", ErrInvalidImage, MaxBackgroundWidth, MaxBackgroundHeight)
+	}
+
+	if opts.Darken < 0 || opts.Darken > 1 {
+		return nil, fmt.Errorf("%w: darken must be between 0 and 1", ErrInvalidImage)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r, MaxBackgroundSize+1))
+	switch {
+	case err != nil:
+		return nil, fmt.Errorf("unable to read image: %w", err)
+	case len(data) > MaxBackgroundSize:
+		return nil, fmt.Errorf("%w: image is larger than %d bytes", ErrInvalidImage, MaxBackgroundSize)
+	}
+
+	// check the size before decoding, so a huge image can't eat all of our memory
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	switch {
+	case err != nil:
+		return nil, fmt.Errorf("%w: must be a png, jpeg, or webp: %s", ErrInvalidImage, err)
+	case config.Width > maxBackgroundDimension || config.Height > maxBackgroundDimension:
+		return nil, fmt.Errorf("%w: %dx%d is too large", ErrInvalidImage, config.Width, config.Height)
+	case config.Width*config.Height > maxBackgroundPixels:
+		return nil, fmt.Errorf("%w: %dx%d is too large, it can have at most %d pixels", ErrInvalidImage, config.Width, config.Height, maxBackgroundPixels)
+	case config.Width < opts.Width/2 || config.Height < opts.Height/2:
+		return nil, fmt.Errorf("%w: %dx%d is too small, it should be at least %dx%d", ErrInvalidImage, config.Width, config.Height, opts.Width/2, opts.Height/2)
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to decode %s: %s", ErrInvalidImage, format, err)
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, opts.Width, opts.Height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, coverRect(src.Bounds(), opts.Width, opts.Height), draw.Src, nil)
+
+	if opts.Darken > 0 {
+		overlay := image.NewUniform(color.NRGBA{A: uint8(opts.Darken * 255)})
+		draw.Draw(dst, dst.Bounds(), overlay, image.Point{}, draw.Over)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, dst); err != nil {
+		return nil, fmt.Errorf("unable to encode background: %w", err)
+	}
+
+	log.P.Debug("Processed background", zap.String("format", format), zap.Int("srcWidth", config.Width), zap.Int("srcHeight", config.Height), zap.Int("size", buf.Len()))
+	return buf.Bytes(), nil
+}
+
+// coverRect returns the largest part of src, centered, with the same aspect ratio as width x height
+func coverRect(src image.Rectangle, width, height int) image.Rectangle {
+	w, h := src.Dx(), src.Dy()
+
+	if w*height > h*width {
+		// too wide, trim the sides
+		cropped := h * width / height
+		x := src.Min.X + (w-cropped)/2
+		return image.Rect(x, src.Min.Y, x+cropped, src.Max.Y)
+	}
+
+	// too tall, trim the top and bottom
+	cropped := w * height / width
+	y := src.Min.Y + (h-cropped)/2
+	return image.Rect(src.Min.X, y, src.Max.X, y+cropped)
+}
+
+// PutBackgroundImage stores a processed png on a document as name, returning the document's new revision.
+// Building documents are created if they don't exist yet, but room configs must already exist.
+func PutBackgroundImage(ctx context.Context, docID, name string, img []byte) (string, error) {
+	if !validBackgroundName.MatchString(name) {
+		return "", fmt.Errorf("%w: %q must be a file name ending in .png", ErrInvalidImage, name)
+	}
+
+	// always attach to the latest revision, not a cached one
+	var doc struct {
+		Rev string `json:"_rev"`
+	}
+
+	err := couchRequest(ctx, http.MethodGet, url.PathEscape(docID), nil, &doc)
+	switch {
+	case errors.Is(err, ErrNotFound) && strings.HasPrefix(docID, buildingDocPrefix):
+	case err != nil:
+		return "", fmt.Errorf("unable to get %s: %w", docID, err)
+	}
+
+	rev, err := putAttachment(ctx, docID, doc.Rev, name, "image/png", img)
+	if err != nil {
+		return "", fmt.Errorf("unable to save background: %w", err)
+	}
+
+	return rev, nil
+}
@@ -0,0 +1,94 @@
+package schedule
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+// solidPNG returns a width x height png filled with c
+func solidPNG(t *testing.T, width, height int, c color.Color) []byte {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, c)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("unable to encode png: %s", err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestProcessBackground(t *testing.T) {
+	white := solidPNG(t, 1000, 1000, color.White)
+
+	out, err := ProcessBackground(bytes.NewReader(white), BackgroundOptions{Width: 400, Height: 200, Darken: 0.5})
+	if err != nil {
+		t.Fatalf("unable to process background: %s", err)
+	}
+
+	img, err := png.Decode(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("processed background isn't a png: %s", err)
+	}
+
+	if size := img.Bounds().Size(); size.X != 400 || size.Y != 200 {
+		t.Errorf("got a %dx%d background, want 400x200", size.X, size.Y)
+	}
+
+	if r, _, _, _ := img.At(200, 100).RGBA(); r>>8 < 120 || r>>8 > 135 {
+		t.Errorf("got red %d after darkening white by half", r>>8)
+	}
+}
+
+// pngHeader returns just the signature and header of a width x height png, with none of its pixels
+func pngHeader(width, height uint32) []byte {
+	ihdr := []byte("IHDR")
+	ihdr = binary.BigEndian.AppendUint32(ihdr, width)
+	ihdr = binary.BigEndian.AppendUint32(ihdr, height)
+	ihdr = append(ihdr, 8, 6, 0, 0, 0) // 8 bit rgba, no interlacing
+
+	b := []byte("\x89PNG\r\n\x1a\n")
+	b = binary.BigEndian.AppendUint32(b, uint32(len(ihdr)-4))
+	b = append(b, ihdr...)
+	return binary.BigEndian.AppendUint32(b, crc32.ChecksumIEEE(ihdr))
+}
+
+func TestProcessBackgroundErrors(t *testing.T) {
+	small := solidPNG(t, 300, 200, color.White)
+
+	tests := []struct {
+		name string
+		data []byte
+		opts BackgroundOptions
+		want string
+	}{
+		{"not an image", []byte("hello"), BackgroundOptions{}, "must be a png, jpeg, or webp"},
+		// these would fail to decode, so the size must be checked first
+		{"too wide", pngHeader(maxBackgroundDimension+1, 1000), BackgroundOptions{}, "10001x1000 is too large"},
+		{"too many pixels", pngHeader(8000, 8000), BackgroundOptions{}, "8000x8000 is too large, it can have at most 24576000 pixels"},
+		{"too small", small, BackgroundOptions{}, "300x200 is too small, it should be at least 400x240"},
+		{"too small for the requested size", small, BackgroundOptions{Width: 1600, Height: 300}, "it should be at least 800x150"},
+		{"too big to resize to", small, BackgroundOptions{Width: MaxBackgroundWidth + 1, Height: PanelHeight}, "backgrounds can't be bigger than 3200x1920"},
+		{"darken", small, BackgroundOptions{Width: 300, Height: 200, Darken: 2}, "darken must be between 0 and 1"},
+	}
+
+	for _, tt := range tests {
+		_, err := ProcessBackground(bytes.NewReader(tt.data), tt.opts)
+		if !errors.Is(err, ErrInvalidImage) || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got %v, want an invalid image error containing %q", tt.name, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package schedule
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/byuoitav/scheduler/calendars"
+	"github.com/byuoitav/scheduler/clock"
+	"github.com/byuoitav/scheduler/log"
+	"go.uber.org/zap"
+)
+
+const (
+	// how long a calendar's capabilities are kept before asking again
+	capabilitiesTTL = 5 * time.Minute
+
+	// how long a calendar that couldn't say what it can do isn't asked again.
+	// capabilities are asked for on every config load, so a down calendar can't slow each one down.
+	capabilitiesFailureTTL = 30 * time.Second
+
+	// how long a calendar has to say what it can do
+	capabilitiesTimeout = 2 * time.Second
+)
+
+type cachedCapabilities struct {
+	caps    calendars.Capabilities
+	expires time.Time
+}
+
+var (
+	capabilitiesMu    sync.Mutex
+	capabilitiesCache = make(map[string]cachedCapabilities)
+)
+
+// PanelCapabilities returns config with what its booking calendar can do filled in.
+// CanCreateEvents is turned off when the calendar can't be booked.
+func PanelCapabilities(ctx context.Context, config Config) Config {
+	caps := CalendarCapabilities(ctx, config.BookingSource().URL, config.Location())
+
+	config.Capabilities = &caps
+	config.CanCreateEvents = config.CanCreateEvents && caps.CreateEvents
+	return config
+}
+
+// CalendarCapabilities returns what the calendar at calendarURL can do. In-process calendars are asked directly,
+// ics files are read-only, and calendar servers are asked at /:roomID/capabilities. Calendars that can't say are assumed to do everything,
+// or what they said last time, and calendars that are backing off aren't asked.
+func CalendarCapabilities(ctx context.Context, calendarURL string, loc *time.Location) calendars.Capabilities {
+	capabilitiesMu.Lock()
+	cached, ok := capabilitiesCache[calendarURL]
+	capabilitiesMu.Unlock()
+
+	if ok && clock.Now().Before(cached.expires) {
+		return cached.caps
+	}
+
+	stale := calendars.AllCapabilities
+	if ok {
+		stale = cached.caps
+	}
+
+	if !sources.available(calendarURL) {
+		return stale
+	}
+
+	caps, err := getCapabilities(ctx, calendarURL, loc)
+	ttl := capabilitiesTTL
+	if err != nil {
+		log.P.Warn("Unable to get calendar capabilities", zap.String("url", calendarURL), zap.Error(err))
+		caps, ttl = stale, capabilitiesFailureTTL
+	}
+
+	capabilitiesMu.Lock()
+	capabilitiesCache[calendarURL] = cachedCapabilities{caps: caps, expires: clock.Now().Add(ttl)}
+	capabilitiesMu.Unlock()
+
+	return caps
+}
+
+func getCapabilities(ctx context.Context, calendarURL string, loc *time.Location) (calendars.Capabilities, error) {
+	if isInproc(calendarURL) {
+		cal, err := inprocCalendar(calendarURL, loc)
+		if err != nil {
+			return calendars.Capabilities{}, err
+		}
+
+		return calendars.CalendarCapabilities(cal), nil
+	}
+
+	if isICS(calendarURL, "") {
+		return calendars.Capabilities{}, nil
+	}
+
+	// calendar servers serve events at /:roomID/events, and capabilities next to them
+	u, err := url.Parse(calendarURL)
+	if err != nil {
+		return calendars.Capabilities{}, err
+	}
+
+	path := u.EscapedPath()
+	if !strings.HasSuffix(path, "/events") {
+		return calendars.AllCapabilities, nil
+	}
+
+	// keep room ids with escaped characters (like gsuite's) escaped
+	u.RawPath = strings.TrimSuffix(path, "/events") + "/capabilities"
+	if u.Path, err = url.PathUnescape(u.RawPath); err != nil {
+		return calendars.Capabilities{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, capabilitiesTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return calendars.Capabilities{}, fmt.Errorf("unable to build capabilities request: %w", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return calendars.Capabilities{}, fmt.Errorf("unable to make capabilities request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		// calendar servers from before capabilities can do everything
+		return calendars.AllCapabilities, nil
+	case resp.StatusCode/100 != 2:
+		return calendars.Capabilities{}, fmt.Errorf("bad response from calendar (%v)", resp.StatusCode)
+	}
+
+	var caps calendars.Capabilities
+	if err := json.NewDecoder(resp.Body).Decode(&caps); err != nil {
+		return calendars.Capabilities{}, fmt.Errorf("unable to parse capabilities: %w", err)
+	}
+
+	return caps, nil
+}
@@ -0,0 +1,156 @@
+package schedule
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/byuoitav/scheduler/calendars"
+)
+
+// resetCapabilities forgets every calendar's capabilities until the test ends
+func resetCapabilities(t *testing.T) {
+	capabilitiesMu.Lock()
+	old := capabilitiesCache
+	capabilitiesCache = make(map[string]cachedCapabilities)
+	capabilitiesMu.Unlock()
+
+	t.Cleanup(func() {
+		capabilitiesMu.Lock()
+		capabilitiesCache = old
+		capabilitiesMu.Unlock()
+	})
+}
+
+// capabilitiesServer answers /:roomID/capabilities with body, or status if it isn't 200
+type capabilitiesServer struct {
+	*httptest.Server
+
+	mu     sync.Mutex
+	status int
+	body   string
+	asked  int
+}
+
+func newCapabilitiesServer(t *testing.T, body string) *capabilitiesServer {
+	s := &capabilitiesServer{status: http.StatusOK, body: body}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if r.URL.Path != "/JET-1106/capabilities" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		s.asked++
+		w.WriteHeader(s.status)
+		w.Write([]byte(s.body))
+	}))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+func (s *capabilitiesServer) set(status int, body string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.status, s.body = status, body
+}
+
+func (s *capabilitiesServer) askedCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.asked
+}
+
+func TestPanelCapabilities(t *testing.T) {
+	resetSources(t)
+	resetCapabilities(t)
+
+	readOnly := newCapabilitiesServer(t, `{"createEvents": false}`)
+	bookable := newCapabilitiesServer(t, `{"createEvents": true}`)
+
+	tests := []struct {
+		name            string
+		config          Config
+		createEvents    bool
+		canCreateEvents bool
+	}{
+		{"read-only calendar", Config{CanCreateEvents: true, CalendarURL: readOnly.URL + "/JET-1106/events"}, false, false},
+		{"bookable calendar", Config{CanCreateEvents: true, CalendarURL: bookable.URL + "/JET-1106/events"}, true, true},
+		{"bookings turned off", Config{CanCreateEvents: false, CalendarURL: bookable.URL + "/JET-1106/events"}, true, false},
+		{"server from before capabilities", Config{CanCreateEvents: true, CalendarURL: bookable.URL + "/ITB-1010/events"}, true, true},
+		{"ics file", Config{CanCreateEvents: true, CalendarURL: bookable.URL + "/JET-1106.ics"}, false, false},
+		{"not a calendar server", Config{CanCreateEvents: true, CalendarURL: bookable.URL + "/calendar"}, true, true},
+		{"booking calendar", Config{CanCreateEvents: true, Calendars: []CalendarSource{
+			{Name: "teamup", URL: bookable.URL + "/JET-1106/events"},
+			{Name: "exchange", URL: readOnly.URL + "/JET-1106/events", Bookings: true},
+		}}, false, false},
+	}
+
+	for _, tt := range tests {
+		got := PanelCapabilities(context.Background(), tt.config)
+		if got.Capabilities == nil || got.Capabilities.CreateEvents != tt.createEvents || got.CanCreateEvents != tt.canCreateEvents {
+			t.Errorf("%s: got capabilities %+v, canCreateEvents %t, want createEvents %t, canCreateEvents %t", tt.name, got.Capabilities, got.CanCreateEvents, tt.createEvents, tt.canCreateEvents)
+		}
+	}
+}
+
+func TestCalendarCapabilitiesCache(t *testing.T) {
+	resetSources(t)
+	resetCapabilities(t)
+	fake := freezeClock(t, time.Date(2030, time.June, 3, 9, 0, 0, 0, time.UTC))
+
+	srv := newCapabilitiesServer(t, `{"createEvents": false}`)
+	u := srv.URL + "/JET-1106/events"
+
+	get := func() calendars.Capabilities {
+		return CalendarCapabilities(context.Background(), u, time.UTC)
+	}
+
+	if caps := get(); caps.CreateEvents || srv.askedCount() != 1 {
+		t.Fatalf("got %+v after asking %d times", caps, srv.askedCount())
+	}
+
+	fake.Shift(capabilitiesTTL - time.Second)
+	if get(); srv.askedCount() != 1 {
+		t.Errorf("asked %d times before the cached answer expired, want 1", srv.askedCount())
+	}
+
+	// a failure keeps the last answer, and isn't retried right away
+	srv.set(http.StatusBadGateway, "")
+	fake.Shift(time.Second)
+	if caps := get(); caps.CreateEvents || srv.askedCount() != 2 {
+		t.Errorf("got %+v after asking %d times, want the last answer", caps, srv.askedCount())
+	}
+
+	if get(); srv.askedCount() != 2 {
+		t.Errorf("asked %d times right after a failure, want 2", srv.askedCount())
+	}
+
+	srv.set(http.StatusOK, `{"createEvents": true}`)
+	fake.Shift(capabilitiesFailureTTL)
+	if caps := get(); !caps.CreateEvents || srv.askedCount() != 3 {
+		t.Errorf("got %+v after asking %d times, want the new answer", caps, srv.askedCount())
+	}
+
+	// calendars that are backing off aren't asked
+	fake.Shift(capabilitiesTTL)
+	sources.failed(u, context.DeadlineExceeded)
+	if caps := get(); !caps.CreateEvents || srv.askedCount() != 3 {
+		t.Errorf("got %+v after asking %d times, want the last answer without asking", caps, srv.askedCount())
+	}
+
+	// a calendar that has never answered can do everything
+	other := srv.URL + "/ITB-1010/events"
+	sources.failed(other, context.DeadlineExceeded)
+	if caps := CalendarCapabilities(context.Background(), other, time.UTC); caps != calendars.AllCapabilities {
+		t.Errorf("got %+v for a calendar that is backing off, want everything", caps)
+	}
+}
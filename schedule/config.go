@@ -3,11 +3,14 @@ package schedule
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io/ioutil"
 	"net/http"
-	"os"
+	"net/url"
+	"reflect"
+	"strings"
 
+	"github.com/byuoitav/scheduler/calendars"
 	"github.com/byuoitav/scheduler/log"
 	"go.uber.org/zap"
 )
@@ -22,6 +25,14 @@ type Config struct {
 	ImageURL    string `json:"image-url"`
 	StyleURL    string `json:"style-url"`
 
+	// background images to rotate between, instead of just bg.png
+	Backgrounds *BackgroundRotation `json:"backgrounds,omitempty"`
+
+	// where the room is, and how its panel should show dates and times
+	Timezone string `json:"timezone,omitempty"` // like America/Denver
+	Locale   string `json:"locale,omitempty"`   // like en-US
+	Clock    string `json:"clock,omitempty"`    // 12h or 24h
+
 	// functionality
 	CanCreateEvents     bool `json:"canCreateEvents"`
 	DisplayMeetingTitle bool `json:"displayMeetingTitle"`
@@ -29,6 +40,36 @@ type Config struct {
 
 	// how to get events - from one of our calendars (gsuite, exchange, etc.)
 	CalendarURL string `json:"calendarURL"`
+
+	// where to get events when calendarURL is down, in order - like a nightly ics export or a local mirror.
+	// the room can't be booked while its events come from one of them.
+	FallbackCalendarURLs []string `json:"fallbackCalendarURLs,omitempty"`
+
+	// calendars to merge into one schedule, instead of calendarURL - like a department teamup calendar and an exchange resource
+	Calendars []CalendarSource `json:"calendars,omitempty"`
+
+	// who else to alert when help is requested, besides the event router
+	HelpNotifiers []NotifierConfig `json:"helpNotifiers,omitempty"`
+
+	// what the calendar bookings are made on can do. it's filled in for the panel, and never saved.
+	Capabilities *calendars.Capabilities `json:"capabilities,omitempty"`
+}
+
+// NotifierConfig describes one destination for help request alerts
+type NotifierConfig struct {
+	// Type is one of webhook, email, slack, or teams
+	Type string `json:"type"`
+
+	// URL is where webhook, slack, and teams alerts are posted
+	URL string `json:"url,omitempty"`
+
+	// SMTPAddress (host:port) is the mail server email alerts are sent through.
+	// SMTP_USERNAME and SMTP_PASSWORD are used when Username and Password aren't set, and are only sent over tls.
+	SMTPAddress string   `json:"smtpAddress,omitempty"`
+	Username    string   `json:"username,omitempty"`
+	Password    string   `json:"password,omitempty"`
+	From        string   `json:"from,omitempty"`
+	To          []string `json:"to,omitempty"`
 }
 
 const (
@@ -36,68 +77,143 @@ const (
 )
 
 func GetConfig(ctx context.Context, roomID string) (Config, error) {
+	if config, ok := cachedConfig(roomID); ok {
+		return config, nil
+	}
+
 	var config Config
 
-	url := fmt.Sprintf("%s/%s/%s", os.Getenv("DB_ADDRESS"), database, roomID)
-	log.P.Debug("Getting scheduler config", zap.String("room", roomID), zap.String("url", url))
+	log.P.Debug("Getting scheduler config", zap.String("room", roomID))
 
-	// build request
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-	if err != nil {
+	if err := couchRequest(ctx, http.MethodGet, url.PathEscape(roomID), nil, &config); err != nil {
 		return config, err
 	}
 
-	// add auth
-	req.SetBasicAuth(os.Getenv("DB_USERNAME"), os.Getenv("DB_PASSWORD"))
+	return config, nil
+}
 
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return config, err
-	}
-	defer resp.Body.Close()
+// reservedDocs are documents in the schedulers database that aren't room configs
+var reservedDocs = map[string]bool{
+	"static": true,
+}
 
-	b, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return config, err
+// IsRoomConfig returns true if id is the id of a room config document
+func IsRoomConfig(id string) bool {
+	return len(id) > 0 && !reservedDocs[id] && !strings.HasPrefix(id, "_") && !strings.HasPrefix(id, buildingDocPrefix) && !strings.HasPrefix(id, localCalendarPrefix)
+}
+
+// ListConfigs returns every room config in the schedulers database
+func ListConfigs(ctx context.Context) ([]Config, error) {
+	var resp struct {
+		Rows []struct {
+			ID  string          `json:"id"`
+			Doc json.RawMessage `json:"doc"`
+		} `json:"rows"`
 	}
 
-	if resp.StatusCode/100 != 2 {
-		return config, fmt.Errorf("bad response (%v): %s", resp.StatusCode, b)
+	if err := couchRequest(ctx, http.MethodGet, "_all_docs?include_docs=true", nil, &resp); err != nil {
+		return nil, fmt.Errorf("unable to list configs: %w", err)
 	}
 
-	if err := json.Unmarshal(b, &config); err != nil {
-		return config, err
+	configs := []Config{}
+	for _, row := range resp.Rows {
+		if !IsRoomConfig(row.ID) {
+			continue
+		}
+
+		var config Config
+		if err := json.Unmarshal(row.Doc, &config); err != nil {
+			log.P.Warn("skipping unparsable config", zap.String("id", row.ID), zap.Error(err))
+			continue
+		}
+
+		configs = append(configs, config)
 	}
 
-	return config, nil
+	return configs, nil
 }
 
-// GetBackgroundImage retrieves the background image for a given room ID.
-func GetBackgroundImage(ctx context.Context, roomID string) ([]byte, error) {
-	url := fmt.Sprintf("%s/%s/%s/bg.png", os.Getenv("DB_ADDRESS"), database, roomID)
-	log.P.Debug("Getting background image", zap.String("room", roomID), zap.String("url", url))
+// PutConfig creates or updates a room config. Updates must include the current _rev.
+// Fields and attachments (like bg.png) on the existing document that aren't part of Config are kept.
+// The returned config has the new _rev.
+func PutConfig(ctx context.Context, config Config) (Config, error) {
+	if !IsRoomConfig(config.ID) {
+		return config, fmt.Errorf("invalid config id %q", config.ID)
+	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-	if err != nil {
-		return nil, err
+	path := url.PathEscape(config.ID)
+	config.Capabilities = nil
+
+	doc := make(map[string]interface{})
+	err := couchRequest(ctx, http.MethodGet, path, nil, &doc)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		if len(config.Rev) > 0 {
+			return config, fmt.Errorf("%w: %s no longer exists", ErrConflict, config.ID)
+		}
+	case err != nil:
+		return config, fmt.Errorf("unable to get existing config: %w", err)
 	}
 
-	req.SetBasicAuth(os.Getenv("DB_USERNAME"), os.Getenv("DB_PASSWORD"))
+	// lay the new config over the existing document
+	for _, field := range configFields() {
+		delete(doc, field)
+	}
 
-	resp, err := http.DefaultClient.Do(req)
+	b, err := json.Marshal(config)
 	if err != nil {
-		return nil, err
+		return config, fmt.Errorf("unable to marshal config: %w", err)
+	}
+
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return config, fmt.Errorf("unable to merge config: %w", err)
+	}
+
+	if len(config.Rev) == 0 {
+		delete(doc, "_rev")
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode == http.StatusNotFound {
-		return nil, fmt.Errorf("background image not found for room %s", roomID)
+	var resp struct {
+		Rev string `json:"rev"`
 	}
 
-	if resp.StatusCode/100 != 2 {
-		body, _ := ioutil.ReadAll(resp.Body)
-		return nil, fmt.Errorf("failed to fetch bg.png: %s (status: %d)", body, resp.StatusCode)
+	if err := couchRequest(ctx, http.MethodPut, path, doc, &resp); err != nil {
+		return config, fmt.Errorf("unable to save config: %w", err)
+	}
+
+	config.Rev = resp.Rev
+	return config, nil
+}
+
+// DeleteConfig deletes the room config with the given id and revision
+func DeleteConfig(ctx context.Context, id, rev string) error {
+	if !IsRoomConfig(id) {
+		return fmt.Errorf("invalid config id %q", id)
+	}
+
+	if len(rev) == 0 {
+		return fmt.Errorf("%w: _rev is required to delete %s", ErrConflict, id)
+	}
+
+	path := url.PathEscape(id) + "?rev=" + url.QueryEscape(rev)
+	if err := couchRequest(ctx, http.MethodDelete, path, nil, nil); err != nil {
+		return fmt.Errorf("unable to delete config: %w", err)
+	}
+
+	return nil
+}
+
+// configFields returns the json names of Config's fields
+func configFields() []string {
+	var fields []string
+
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if len(name) > 0 && name != "-" {
+			fields = append(fields, name)
+		}
 	}
 
-	return ioutil.ReadAll(resp.Body)
+	return fields
 }
@@ -0,0 +1,183 @@
+package schedule
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	neturl "net/url"
+	"os"
+
+	"github.com/byuoitav/scheduler/log"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrNotFound is returned when a document doesn't exist in couch
+	ErrNotFound = errors.New("document not found")
+
+	// ErrConflict is returned when a document's _rev doesn't match the latest revision in couch
+	ErrConflict = errors.New("document update conflict")
+)
+
+// couchError is a non-2xx response from couch
+type couchError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *couchError) Error() string {
+	return fmt.Sprintf("bad response from couch (%v): %s", e.StatusCode, e.Body)
+}
+
+func (e *couchError) Unwrap() error {
+	switch e.StatusCode {
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusConflict:
+		return ErrConflict
+	}
+
+	return nil
+}
+
+// couchRequest makes a request against the schedulers database. body (if not nil) is sent as json,
+// and a successful response is decoded into out (if not nil).
+func couchRequest(ctx context.Context, method, path string, body, out interface{}) error {
+	url := fmt.Sprintf("%s/%s/%s", os.Getenv("DB_ADDRESS"), database, path)
+	log.P.Debug("Making couch request", zap.String("method", method), zap.String("url", url))
+
+	var reqBody []byte
+	if body != nil {
+		var err error
+		reqBody, err = json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("unable to marshal request: %w", err)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(reqBody))
+	if err != nil {
+		return err
+	}
+
+	req.SetBasicAuth(os.Getenv("DB_USERNAME"), os.Getenv("DB_PASSWORD"))
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode/100 != 2 {
+		return &couchError{StatusCode: resp.StatusCode, Body: string(b)}
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	if err := json.Unmarshal(b, out); err != nil {
+		return fmt.Errorf("unable to parse response from couch: %w", err)
+	}
+
+	return nil
+}
+
+// Attachment is a file attached to a couch document
+type Attachment struct {
+	Data        []byte
+	ContentType string
+
+	// ETag identifies this version of the attachment (couch uses the attachment's digest)
+	ETag string
+}
+
+// getAttachment downloads an attachment from a document in the schedulers database
+func getAttachment(ctx context.Context, docID, name string) (Attachment, error) {
+	url := fmt.Sprintf("%s/%s/%s/%s", os.Getenv("DB_ADDRESS"), database, neturl.PathEscape(docID), neturl.PathEscape(name))
+	log.P.Debug("Getting attachment", zap.String("doc", docID), zap.String("name", name), zap.String("url", url))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return Attachment{}, err
+	}
+
+	req.SetBasicAuth(os.Getenv("DB_USERNAME"), os.Getenv("DB_PASSWORD"))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return Attachment{}, err
+	}
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return Attachment{}, err
+	}
+
+	if resp.StatusCode/100 != 2 {
+		return Attachment{}, &couchError{StatusCode: resp.StatusCode, Body: string(b)}
+	}
+
+	return Attachment{
+		Data:        b,
+		ContentType: resp.Header.Get("Content-Type"),
+		ETag:        resp.Header.Get("ETag"),
+	}, nil
+}
+
+// putAttachment adds (or replaces) an attachment on a document, returning the document's new revision
+func putAttachment(ctx context.Context, docID, rev, name, contentType string, data []byte) (string, error) {
+	url := fmt.Sprintf("%s/%s/%s/%s", os.Getenv("DB_ADDRESS"), database, neturl.PathEscape(docID), neturl.PathEscape(name))
+	if len(rev) > 0 {
+		// without a rev, couch creates the document
+		url += "?rev=" + neturl.QueryEscape(rev)
+	}
+
+	log.P.Debug("Putting attachment", zap.String("doc", docID), zap.String("name", name), zap.Int("length", len(data)))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, url, bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+
+	req.SetBasicAuth(os.Getenv("DB_USERNAME"), os.Getenv("DB_PASSWORD"))
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	if resp.StatusCode/100 != 2 {
+		return "", &couchError{StatusCode: resp.StatusCode, Body: string(b)}
+	}
+
+	var result struct {
+		Rev string `json:"rev"`
+	}
+
+	if err := json.Unmarshal(b, &result); err != nil {
+		return "", fmt.Errorf("unable to parse response from couch: %w", err)
+	}
+
+	return result.Rev, nil
+}
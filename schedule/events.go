@@ -10,47 +10,63 @@ import (
 	"sort"
 
 	"github.com/byuoitav/scheduler/calendars"
+	"github.com/byuoitav/scheduler/clock"
 	"github.com/byuoitav/scheduler/log"
-	"go.uber.org/zap"
 )
 
-func GetEvents(ctx context.Context, roomID string) ([]calendars.Event, error) {
-	var events []calendars.Event
-
+// GetEvents returns a room's events from all of its calendars, sorted by start time, in the room's timezone,
+// and where each calendar's events were read from
+func GetEvents(ctx context.Context, roomID string) ([]calendars.Event, EventSources, error) {
 	// get config for this room
 	config, err := GetConfig(ctx, roomID)
 	if err != nil {
-		return events, fmt.Errorf("unable to get schedule config: %w", err)
+		return nil, nil, fmt.Errorf("unable to get schedule config: %w", err)
 	}
 
-	log.P.Debug("Getting events", zap.String("room", roomID), zap.String("url", config.CalendarURL))
+	return getEvents(ctx, config)
+}
 
-	// build request
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, config.CalendarURL, nil)
+// GetEventsOn returns a room's events that happen on day (see ParseDay), according to the room's timezone
+func GetEventsOn(ctx context.Context, roomID, day string) ([]calendars.Event, EventSources, error) {
+	config, err := GetConfig(ctx, roomID)
 	if err != nil {
-		return events, fmt.Errorf("unable to build events request: %w", err)
+		return nil, nil, fmt.Errorf("unable to get schedule config: %w", err)
 	}
 
-	// make http request
-	resp, err := http.DefaultClient.Do(req)
+	t, err := ParseDay(day, clock.Now().In(config.Location()))
 	if err != nil {
-		return events, fmt.Errorf("unable to make events request: %w", err)
+		return nil, nil, err
 	}
-	defer resp.Body.Close()
 
-	// read response
-	b, err := ioutil.ReadAll(resp.Body)
+	events, source, err := getEvents(ctx, config)
 	if err != nil {
-		return events, fmt.Errorf("unable to read response from calendar: %w", err)
+		return nil, source, err
 	}
 
-	if resp.StatusCode/100 != 2 {
-		return events, fmt.Errorf("bad response from calendar (%v): %s", resp.StatusCode, b)
+	start, end := DayBounds(t)
+
+	// keep events that overlap the day at all, like one that started last night
+	filtered := []calendars.Event{}
+	for _, event := range events {
+		if event.StartTime.Before(end) && event.EndTime.After(start) {
+			filtered = append(filtered, event)
+		}
 	}
 
-	// parse response
-	if err := json.Unmarshal(b, &events); err != nil {
-		return events, fmt.Errorf("unable to parse response from calendar: %w. response body: %s", err, b)
+	return filtered, source, nil
+}
+
+func getEvents(ctx context.Context, config Config) ([]calendars.Event, EventSources, error) {
+	events, source, err := readSources(ctx, config)
+	if err != nil {
+		return events, source, err
+	}
+
+	// show times in the room's timezone, no matter where the calendar or the panel is
+	loc := config.Location()
+	for i := range events {
+		events[i].StartTime = events[i].StartTime.In(loc)
+		events[i].EndTime = events[i].EndTime.In(loc)
 	}
 
 	// sort events by start time
@@ -66,7 +82,7 @@ func GetEvents(ctx context.Context, roomID string) ([]calendars.Event, error) {
 		}
 	}
 
-	return events, nil
+	return events, source, nil
 }
 
 func CreateEvent(ctx context.Context, roomID string, event calendars.Event) error {
@@ -76,13 +92,31 @@ func CreateEvent(ctx context.Context, roomID string, event calendars.Event) erro
 		return fmt.Errorf("unable to get schedule config: %w", err)
 	}
 
+	// fallbacks are read-only copies. the room's last read decides, so a primary that answered since it failed takes bookings again.
+	cal := config.BookingSource()
+	if len(cal.FallbackURLs) > 0 && OnFallback(roomID) {
+		return ErrBookingUnavailable
+	}
+
+	// the event's source is only for the panel
+	event.Source = ""
+
+	if isInproc(cal.URL) {
+		c, err := inprocCalendar(cal.URL, config.Location())
+		if err != nil {
+			return err
+		}
+
+		return c.CreateEvent(ctx, event)
+	}
+
 	requestBody, err := json.Marshal(event)
 	if err != nil {
 		return fmt.Errorf("unable to marshal event into json: %w", err)
 	}
 
 	// build request
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, config.CalendarURL, bytes.NewBuffer(requestBody))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cal.URL, bytes.NewBuffer(requestBody))
 	if err != nil {
 		return fmt.Errorf("unable to build event request: %w", err)
 	}
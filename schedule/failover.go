@@ -0,0 +1,331 @@
+package schedule
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/byuoitav/scheduler/calendars"
+	"github.com/byuoitav/scheduler/clock"
+	"github.com/byuoitav/scheduler/log"
+	"go.uber.org/zap"
+)
+
+// ErrBookingUnavailable is returned when a room can't be booked because its events are coming from a fallback calendar
+var ErrBookingUnavailable = errors.New("booking is unavailable while the room's calendar is down")
+
+// PrimarySource is the name of a room's calendarURL. Fallbacks are named fallback-1, fallback-2...
+const PrimarySource = "primary"
+
+const (
+	// how long each calendar has to answer when a room has fallbacks to try
+	sourceTimeout = 10 * time.Second
+
+	// a calendar that fails isn't tried first again for a while, doubling with each failure
+	minSourceBackoff = 15 * time.Second
+	maxSourceBackoff = 5 * time.Minute
+)
+
+// EventSource is where one of a room's calendars read its events from
+type EventSource struct {
+	// Calendar is the name of the room's calendar. It's empty for rooms with just a calendarURL.
+	Calendar string `json:"calendar,omitempty"`
+
+	// Name is primary, or the fallback that answered (fallback-1, fallback-2...)
+	Name     string `json:"name"`
+	Fallback bool   `json:"fallback"`
+
+	// Bookings is true if this is the calendar walk-up bookings are made on
+	Bookings bool `json:"bookings"`
+
+	// Error is why none of the calendar's urls could be read
+	Error string `json:"error,omitempty"`
+}
+
+// EventSources are where each of a room's calendars read its events from
+type EventSources []EventSource
+
+// BookingFallback returns true if the calendar the room is booked on couldn't be read from its primary url
+func (s EventSources) BookingFallback() bool {
+	for _, source := range s {
+		if source.Bookings {
+			return source.Fallback || len(source.Error) > 0
+		}
+	}
+
+	return false
+}
+
+// String describes where the events came from, like primary or "teamup=primary, exchange=fallback-1"
+func (s EventSources) String() string {
+	parts := make([]string, 0, len(s))
+	for _, source := range s {
+		name := source.Name
+		if len(source.Error) > 0 {
+			name = "unavailable"
+		}
+
+		if len(source.Calendar) > 0 {
+			name = source.Calendar + "=" + name
+		}
+
+		parts = append(parts, name)
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// SourceHealth is how a calendar url has been responding
+type SourceHealth struct {
+	URL     string `json:"url"`
+	Healthy bool   `json:"healthy"`
+
+	ConsecutiveFailures int    `json:"consecutiveFailures"`
+	LastError           string `json:"lastError,omitempty"`
+
+	LastSuccess time.Time `json:"lastSuccess"`
+	LastFailure time.Time `json:"lastFailure"`
+
+	// RetryAt is when the calendar will be tried first again, if it's unhealthy
+	RetryAt time.Time `json:"retryAt"`
+}
+
+type sourceTracker struct {
+	mu      sync.Mutex
+	sources map[string]*SourceHealth
+
+	// rooms is where each room's events were last read from, by calendar
+	rooms map[string]EventSources
+}
+
+var sources = &sourceTracker{
+	sources: make(map[string]*SourceHealth),
+	rooms:   make(map[string]EventSources),
+}
+
+// CalendarHealth returns how each calendar url that has been read has been responding, sorted by url
+func CalendarHealth() []SourceHealth {
+	sources.mu.Lock()
+	defer sources.mu.Unlock()
+
+	list := make([]SourceHealth, 0, len(sources.sources))
+	for _, h := range sources.sources {
+		list = append(list, *h)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].URL < list[j].URL
+	})
+
+	return list
+}
+
+// OnFallback returns true if the calendar roomID is booked on couldn't be read from its primary url last time
+func OnFallback(roomID string) bool {
+	sources.mu.Lock()
+	defer sources.mu.Unlock()
+
+	return sources.rooms[roomID].BookingFallback()
+}
+
+// readSource reads one of a room's calendars from the first of its urls that answers. Urls that have been failing are tried last.
+func readSource(ctx context.Context, roomID string, cal CalendarSource, loc *time.Location) ([]calendars.Event, EventSource, error) {
+	urls := cal.urls()
+
+	type candidate struct {
+		url    string
+		source EventSource
+	}
+
+	var ready, backingOff []candidate
+	for i, u := range urls {
+		c := candidate{url: u, source: EventSource{Calendar: cal.Name, Name: PrimarySource, Bookings: cal.Bookings}}
+		if i > 0 {
+			c.source.Name = fmt.Sprintf("fallback-%d", i)
+			c.source.Fallback = true
+		}
+
+		if sources.available(u) {
+			ready = append(ready, c)
+		} else {
+			backingOff = append(backingOff, c)
+		}
+	}
+
+	var errs []error
+	for _, c := range append(ready, backingOff...) {
+		sourceCtx, cancel := ctx, context.CancelFunc(func() {})
+		if len(urls) > 1 {
+			sourceCtx, cancel = context.WithTimeout(ctx, sourceTimeout)
+		}
+
+		events, err := fetchEvents(sourceCtx, c.url, loc)
+		cancel()
+
+		if err != nil {
+			sources.failed(c.url, err)
+
+			if len(urls) > 1 {
+				err = fmt.Errorf("%s: %w", c.source.Name, err)
+			}
+
+			errs = append(errs, err)
+
+			if ctx.Err() != nil {
+				break
+			}
+
+			continue
+		}
+
+		sources.succeeded(c.url)
+
+		if c.source.Fallback {
+			log.P.Warn("Serving events from a fallback calendar", zap.String("room", roomID), zap.String("calendar", cal.Name), zap.String("source", c.source.Name), zap.Errors("errors", errs))
+		}
+
+		return events, c.source, nil
+	}
+
+	err := errors.Join(errs...)
+	return nil, EventSource{Calendar: cal.Name, Bookings: cal.Bookings, Error: err.Error()}, err
+}
+
+// fetchEvents gets the events from a calendar server, or an ics file
+func fetchEvents(ctx context.Context, calendarURL string, loc *time.Location) ([]calendars.Event, error) {
+	var events []calendars.Event
+
+	log.P.Debug("Getting events", zap.String("url", calendarURL))
+
+	if isInproc(calendarURL) {
+		cal, err := inprocCalendar(calendarURL, loc)
+		if err != nil {
+			return events, err
+		}
+
+		return cal.GetEvents(ctx)
+	}
+
+	// build request
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, calendarURL, nil)
+	if err != nil {
+		return events, fmt.Errorf("unable to build events request: %w", err)
+	}
+
+	// make http request
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return events, fmt.Errorf("unable to make events request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// read response
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return events, fmt.Errorf("unable to read response from calendar: %w", err)
+	}
+
+	if resp.StatusCode/100 != 2 {
+		return events, fmt.Errorf("bad response from calendar (%v): %s", resp.StatusCode, b)
+	}
+
+	if isICS(calendarURL, resp.Header.Get("Content-Type")) {
+		events, err = calendars.ParseICS(bytes.NewReader(b), loc)
+		if err != nil {
+			return events, fmt.Errorf("unable to parse ics from calendar: %w", err)
+		}
+
+		return events, nil
+	}
+
+	// parse response
+	if err := json.Unmarshal(b, &events); err != nil {
+		return events, fmt.Errorf("unable to parse response from calendar: %w. response body: %s", err, b)
+	}
+
+	return events, nil
+}
+
+// isICS returns true if a calendar's response is an ics file rather than a calendar server's json
+func isICS(calendarURL, contentType string) bool {
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil && mediaType == calendars.ICSContentType {
+		return true
+	}
+
+	u, err := url.Parse(calendarURL)
+	return err == nil && strings.HasSuffix(strings.ToLower(u.Path), ".ics")
+}
+
+// available returns true if u hasn't failed recently
+func (t *sourceTracker) available(u string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	h, ok := t.sources[u]
+	return !ok || h.Healthy || !clock.Now().Before(h.RetryAt)
+}
+
+func (t *sourceTracker) health(u string) *SourceHealth {
+	h, ok := t.sources[u]
+	if !ok {
+		h = &SourceHealth{URL: u, Healthy: true}
+		t.sources[u] = h
+	}
+
+	return h
+}
+
+func (t *sourceTracker) succeeded(u string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	h := t.health(u)
+	if !h.Healthy {
+		log.P.Info("Calendar is answering again", zap.String("url", u), zap.Int("failures", h.ConsecutiveFailures))
+	}
+
+	h.Healthy = true
+	h.ConsecutiveFailures = 0
+	h.LastError = ""
+	h.LastSuccess = clock.Now()
+	h.RetryAt = time.Time{}
+}
+
+func (t *sourceTracker) failed(u string, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	h := t.health(u)
+	h.Healthy = false
+	h.ConsecutiveFailures++
+	h.LastError = err.Error()
+	h.LastFailure = clock.Now()
+
+	backoff := minSourceBackoff
+	for i := 1; i < h.ConsecutiveFailures && backoff < maxSourceBackoff; i++ {
+		backoff *= 2
+	}
+
+	if backoff > maxSourceBackoff {
+		backoff = maxSourceBackoff
+	}
+
+	h.RetryAt = h.LastFailure.Add(backoff)
+}
+
+func (t *sourceTracker) answered(roomID string, read EventSources) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.rooms[roomID] = read
+}
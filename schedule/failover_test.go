@@ -0,0 +1,244 @@
+package schedule
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/byuoitav/scheduler/calendars"
+	"github.com/byuoitav/scheduler/schedule/scheduletest"
+)
+
+// calendarServer serves events, or fails while it's down
+type calendarServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	down     bool
+	reads    int
+	bookings []calendars.Event
+}
+
+func newCalendarServer(t *testing.T, events ...calendars.Event) *calendarServer {
+	s := &calendarServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if r.Method == http.MethodPost {
+			var event calendars.Event
+			json.NewDecoder(r.Body).Decode(&event)
+			s.bookings = append(s.bookings, event)
+			return
+		}
+
+		s.reads++
+		if s.down {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+
+		json.NewEncoder(w).Encode(append([]calendars.Event{}, events...))
+	}))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+func (s *calendarServer) setDown(down bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.down = down
+}
+
+func (s *calendarServer) readCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.reads
+}
+
+// resetSources forgets every calendar's health until the test ends
+func resetSources(t *testing.T) {
+	old := sources
+	sources = &sourceTracker{sources: make(map[string]*SourceHealth), rooms: make(map[string]EventSources)}
+	t.Cleanup(func() { sources = old })
+}
+
+func sourceHealth(u string) SourceHealth {
+	for _, h := range CalendarHealth() {
+		if h.URL == u {
+			return h
+		}
+	}
+
+	return SourceHealth{}
+}
+
+func TestReadSourceFailover(t *testing.T) {
+	resetSources(t)
+	fake := freezeClock(t, time.Date(2030, time.June, 3, 9, 0, 0, 0, time.UTC))
+
+	start := time.Date(2030, time.June, 3, 10, 0, 0, 0, time.UTC)
+	standup := calendars.Event{Title: "Standup", StartTime: start, EndTime: start.Add(15 * time.Minute)}
+
+	primary := newCalendarServer(t, standup)
+	first := newCalendarServer(t, standup)
+	second := newCalendarServer(t, standup)
+	cal := CalendarSource{URL: primary.URL, FallbackURLs: []string{first.URL, second.URL}, Bookings: true}
+
+	read := func() string {
+		t.Helper()
+
+		events, source, err := readSource(context.Background(), "JET-1106", cal, time.UTC)
+		if err != nil {
+			t.Fatalf("unable to read events: %s", err)
+		}
+
+		if len(events) != 1 || events[0].Title != "Standup" {
+			t.Errorf("got events %+v", events)
+		}
+
+		return source.Name
+	}
+
+	if name := read(); name != PrimarySource {
+		t.Errorf("got source %q with every calendar up, want %q", name, PrimarySource)
+	}
+
+	// fallbacks are tried in order
+	primary.setDown(true)
+	first.setDown(true)
+	if name := read(); name != "fallback-2" {
+		t.Errorf("got source %q, want fallback-2", name)
+	}
+
+	// calendars that are backing off are tried last, so nothing is waiting on them
+	first.setDown(false)
+	if name := read(); name != "fallback-2" || primary.readCount() != 2 || first.readCount() != 1 {
+		t.Errorf("got source %q after %d primary and %d fallback-1 reads, want fallback-2 without trying the others again", name, primary.readCount(), first.readCount())
+	}
+
+	// once their backoff is over, they're tried in order again
+	fake.Shift(minSourceBackoff)
+	if name := read(); name != "fallback-1" || primary.readCount() != 3 {
+		t.Errorf("got source %q after %d primary reads, want primary tried first again", name, primary.readCount())
+	}
+
+	if h := sourceHealth(primary.URL); h.Healthy || h.ConsecutiveFailures != 2 || !h.RetryAt.Equal(fake.Now().Add(2*minSourceBackoff)) {
+		t.Errorf("got primary health %+v", h)
+	}
+
+	primary.setDown(false)
+	fake.Shift(2 * minSourceBackoff)
+	if name := read(); name != PrimarySource {
+		t.Errorf("got source %q after primary came back, want %q", name, PrimarySource)
+	}
+
+	if h := sourceHealth(primary.URL); !h.Healthy || h.ConsecutiveFailures != 0 || len(h.LastError) > 0 {
+		t.Errorf("got primary health %+v after it answered", h)
+	}
+
+	// when nothing answers, every url is tried and every error is kept
+	primary.setDown(true)
+	first.setDown(true)
+	second.setDown(true)
+
+	_, source, err := readSource(context.Background(), "JET-1106", cal, time.UTC)
+	if err == nil || source.Error != err.Error() || len(source.Name) > 0 {
+		t.Fatalf("got source %+v, error %v with every calendar down", source, err)
+	}
+
+	for _, want := range []string{"primary: bad response from calendar (502)", "fallback-1:", "fallback-2:"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q is missing %q", err, want)
+		}
+	}
+}
+
+func TestSourceBackoff(t *testing.T) {
+	resetSources(t)
+	fake := freezeClock(t, time.Date(2030, time.June, 3, 9, 0, 0, 0, time.UTC))
+
+	const u = "http://calendar.byu.edu/JET-1106"
+
+	want := []time.Duration{15 * time.Second, 30 * time.Second, time.Minute, 2 * time.Minute, 4 * time.Minute, maxSourceBackoff, maxSourceBackoff}
+	for i, backoff := range want {
+		sources.failed(u, errors.New("down"))
+
+		h := sourceHealth(u)
+		if got := h.RetryAt.Sub(h.LastFailure); got != backoff {
+			t.Errorf("failure %d: got a %s backoff, want %s", i+1, got, backoff)
+		}
+
+		if sources.available(u) {
+			t.Errorf("failure %d: available while backing off", i+1)
+		}
+
+		fake.Shift(backoff - time.Second)
+		if sources.available(u) {
+			t.Errorf("failure %d: available before its backoff was over", i+1)
+		}
+
+		fake.Shift(time.Second)
+		if !sources.available(u) {
+			t.Errorf("failure %d: not available after its backoff was over", i+1)
+		}
+	}
+
+	sources.succeeded(u)
+	sources.failed(u, errors.New("down again"))
+	if h := sourceHealth(u); h.ConsecutiveFailures != 1 || h.RetryAt.Sub(h.LastFailure) != minSourceBackoff {
+		t.Errorf("got %+v, want the backoff to start over after a success", h)
+	}
+}
+
+func TestCreateEventOnFallback(t *testing.T) {
+	resetSources(t)
+	freezeClock(t, time.Date(2030, time.June, 3, 9, 0, 0, 0, time.UTC))
+
+	couch := scheduletest.NewCouch(t)
+	t.Setenv("DB_ADDRESS", couch.URL)
+
+	primary := newCalendarServer(t)
+	fallback := newCalendarServer(t)
+	couch.Put(t, Config{ID: "JET-1106", DisplayName: "The JET", CalendarURL: primary.URL, FallbackCalendarURLs: []string{fallback.URL}})
+
+	start := time.Date(2030, time.June, 3, 10, 0, 0, 0, time.UTC)
+	event := calendars.Event{Title: "Walk-up", StartTime: start, EndTime: start.Add(30 * time.Minute)}
+
+	primary.setDown(true)
+	if _, source, err := GetEvents(context.Background(), "JET-1106"); err != nil || !source.BookingFallback() {
+		t.Fatalf("got sources %v, error %v, want events from the fallback", source, err)
+	}
+
+	if err := CreateEvent(context.Background(), "JET-1106", event); !errors.Is(err, ErrBookingUnavailable) {
+		t.Errorf("got %v booking while on a fallback, want ErrBookingUnavailable", err)
+	}
+
+	// the room's last read decides, even if another room has seen its primary fail since
+	primary.setDown(false)
+	sources.succeeded(primary.URL)
+	if _, source, err := GetEvents(context.Background(), "JET-1106"); err != nil || source.BookingFallback() {
+		t.Fatalf("got sources %v, error %v, want events from the primary", source, err)
+	}
+
+	sources.failed(primary.URL, errors.New("down"))
+	if err := CreateEvent(context.Background(), "JET-1106", event); err != nil {
+		t.Errorf("got %s booking a room that last read its primary", err)
+	}
+
+	primary.mu.Lock()
+	defer primary.mu.Unlock()
+
+	if len(primary.bookings) != 1 || primary.bookings[0].Title != "Walk-up" || fallback.readCount() != 1 {
+		t.Errorf("got bookings %+v on the primary, want just the walk-up", primary.bookings)
+	}
+}
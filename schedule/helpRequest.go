@@ -1,5 +1,177 @@
 package schedule
 
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/byuoitav/scheduler/clock"
+)
+
+const (
+	HelpRequestOpen         = "open"
+	HelpRequestAcknowledged = "acknowledged"
+	HelpRequestCancelled    = "cancelled"
+
+	// DefaultHelpCategory is used when a panel doesn't say what kind of help it needs
+	DefaultHelpCategory = "general"
+
+	// DefaultHelpCooldown is how long a repeated help request from the same device is suppressed
+	DefaultHelpCooldown = 5 * time.Minute
+
+	// help requests are forgotten after this long
+	helpRequestRetention = 24 * time.Hour
+)
+
+var (
+	// ErrHelpRequestNotFound is returned when no help request exists with the given ID
+	ErrHelpRequestNotFound = errors.New("help request not found")
+
+	// ErrHelpRequestClosed is returned when trying to change a help request that has already been cancelled
+	ErrHelpRequestClosed = errors.New("help request has been cancelled")
+)
+
 type HelpRequest struct {
-	DeviceID string
+	ID       string `json:"id"`
+	DeviceID string `json:"deviceID"`
+	RoomID   string `json:"roomID"`
+	Category string `json:"category"`
+	Message  string `json:"message,omitempty"`
+	Status   string `json:"status"`
+
+	CreatedAt time.Time `json:"createdAt"`
+
+	AcknowledgedAt *time.Time `json:"acknowledgedAt,omitempty"`
+	AcknowledgedBy string     `json:"acknowledgedBy,omitempty"`
+	Response       string     `json:"response,omitempty"`
+
+	CancelledAt *time.Time `json:"cancelledAt,omitempty"`
+}
+
+// HelpRequests keeps track of the help requests made from panels
+type HelpRequests struct {
+	cooldown time.Duration
+
+	mu       sync.Mutex
+	requests map[string]*HelpRequest
+}
+
+// NewHelpRequests returns an empty set of help requests that suppresses duplicates made within cooldown
+func NewHelpRequests(cooldown time.Duration) *HelpRequests {
+	return &HelpRequests{
+		cooldown: cooldown,
+		requests: make(map[string]*HelpRequest),
+	}
+}
+
+// Create records a new help request. If the same device in the same room already has an active request
+// in the same category made within the cooldown window, that request is returned instead and created is false.
+func (h *HelpRequests) Create(req HelpRequest) (request HelpRequest, created bool) {
+	now := clock.Now()
+
+	if len(req.Category) == 0 {
+		req.Category = DefaultHelpCategory
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.prune(now)
+
+	for _, existing := range h.requests {
+		if existing.RoomID != req.RoomID || existing.DeviceID != req.DeviceID || existing.Category != req.Category || existing.Status == HelpRequestCancelled {
+			continue
+		}
+
+		if now.Sub(existing.CreatedAt) < h.cooldown {
+			return *existing, false
+		}
+	}
+
+	req.ID = newHelpRequestID()
+	req.Status = HelpRequestOpen
+	req.CreatedAt = now
+	req.AcknowledgedAt = nil
+	req.AcknowledgedBy = ""
+	req.Response = ""
+	req.CancelledAt = nil
+
+	h.requests[req.ID] = &req
+	return req, true
+}
+
+// Get returns the help request with the given id
+func (h *HelpRequests) Get(id string) (HelpRequest, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	req, ok := h.requests[id]
+	if !ok {
+		return HelpRequest{}, fmt.Errorf("%w: %s", ErrHelpRequestNotFound, id)
+	}
+
+	return *req, nil
+}
+
+// Acknowledge marks the help request as seen by support staff
+func (h *HelpRequests) Acknowledge(id, by, response string) (HelpRequest, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	req, ok := h.requests[id]
+	switch {
+	case !ok:
+		return HelpRequest{}, fmt.Errorf("%w: %s", ErrHelpRequestNotFound, id)
+	case req.Status == HelpRequestCancelled:
+		return *req, fmt.Errorf("%w: %s", ErrHelpRequestClosed, id)
+	}
+
+	now := clock.Now()
+	req.Status = HelpRequestAcknowledged
+	req.AcknowledgedAt = &now
+	req.AcknowledgedBy = by
+	req.Response = response
+
+	return *req, nil
+}
+
+// Cancel withdraws the help request
+func (h *HelpRequests) Cancel(id string) (HelpRequest, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	req, ok := h.requests[id]
+	switch {
+	case !ok:
+		return HelpRequest{}, fmt.Errorf("%w: %s", ErrHelpRequestNotFound, id)
+	case req.Status == HelpRequestCancelled:
+		return *req, fmt.Errorf("%w: %s", ErrHelpRequestClosed, id)
+	}
+
+	now := clock.Now()
+	req.Status = HelpRequestCancelled
+	req.CancelledAt = &now
+
+	return *req, nil
+}
+
+// prune forgets requests older than helpRequestRetention. h.mu must be held.
+func (h *HelpRequests) prune(now time.Time) {
+	for id, req := range h.requests {
+		if now.Sub(req.CreatedAt) > helpRequestRetention {
+			delete(h.requests, id)
+		}
+	}
+}
+
+func newHelpRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+
+	return hex.EncodeToString(b)
 }
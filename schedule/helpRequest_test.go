@@ -0,0 +1,122 @@
+package schedule
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/byuoitav/scheduler/clock"
+)
+
+// freezeClock stops the scheduler's clock for the rest of the test
+func freezeClock(t *testing.T, at time.Time) *clock.Fake {
+	t.Helper()
+
+	fake := clock.EnableTimeTravel()
+	fake.Freeze(at)
+	t.Cleanup(func() { clock.Set(clock.Real{}) })
+
+	return fake
+}
+
+func TestHelpRequestCooldown(t *testing.T) {
+	fake := freezeClock(t, time.Date(2030, time.January, 1, 9, 0, 0, 0, time.UTC))
+	h := NewHelpRequests(5 * time.Minute)
+
+	first, created := h.Create(HelpRequest{RoomID: "ITB-1010", DeviceID: "ITB-1010-SP1"})
+	if !created || first.Status != HelpRequestOpen || first.Category != DefaultHelpCategory {
+		t.Fatalf("got %+v, %t creating the first request", first, created)
+	}
+
+	fake.Shift(4 * time.Minute)
+	if dup, created := h.Create(HelpRequest{RoomID: "ITB-1010", DeviceID: "ITB-1010-SP1"}); created || dup.ID != first.ID {
+		t.Errorf("got %+v, %t repeating a request within the cooldown", dup, created)
+	}
+
+	// other categories and devices aren't duplicates
+	if _, created := h.Create(HelpRequest{RoomID: "ITB-1010", DeviceID: "ITB-1010-SP1", Category: "audio"}); !created {
+		t.Errorf("request in another category was suppressed")
+	}
+
+	if _, created := h.Create(HelpRequest{RoomID: "ITB-1010", DeviceID: "ITB-1010-SP2"}); !created {
+		t.Errorf("request from another device was suppressed")
+	}
+
+	fake.Shift(2 * time.Minute)
+	if again, created := h.Create(HelpRequest{RoomID: "ITB-1010", DeviceID: "ITB-1010-SP1"}); !created || again.ID == first.ID {
+		t.Errorf("got %+v, %t repeating a request after the cooldown", again, created)
+	}
+}
+
+func TestHelpRequestCancelEndsCooldown(t *testing.T) {
+	freezeClock(t, time.Date(2030, time.January, 1, 9, 0, 0, 0, time.UTC))
+	h := NewHelpRequests(5 * time.Minute)
+
+	first, _ := h.Create(HelpRequest{RoomID: "ITB-1010", DeviceID: "ITB-1010-SP1"})
+	if _, err := h.Cancel(first.ID); err != nil {
+		t.Fatalf("unable to cancel: %s", err)
+	}
+
+	if _, created := h.Create(HelpRequest{RoomID: "ITB-1010", DeviceID: "ITB-1010-SP1"}); !created {
+		t.Errorf("request after a cancelled one was suppressed")
+	}
+}
+
+func TestHelpRequestAcknowledgeAndCancel(t *testing.T) {
+	now := time.Date(2030, time.January, 1, 9, 0, 0, 0, time.UTC)
+	fake := freezeClock(t, now)
+	h := NewHelpRequests(DefaultHelpCooldown)
+
+	req, _ := h.Create(HelpRequest{RoomID: "ITB-1010", DeviceID: "ITB-1010-SP1", Message: "projector is off"})
+
+	fake.Shift(time.Minute)
+	acked, err := h.Acknowledge(req.ID, "tech", "on my way")
+	if err != nil {
+		t.Fatalf("unable to acknowledge: %s", err)
+	}
+
+	if acked.Status != HelpRequestAcknowledged || acked.AcknowledgedBy != "tech" || acked.Response != "on my way" || !acked.AcknowledgedAt.Equal(now.Add(time.Minute)) {
+		t.Errorf("got %+v after acknowledging", acked)
+	}
+
+	if got, err := h.Get(req.ID); err != nil || got.Status != HelpRequestAcknowledged {
+		t.Errorf("got %+v, %v getting an acknowledged request", got, err)
+	}
+
+	cancelled, err := h.Cancel(req.ID)
+	if err != nil || cancelled.Status != HelpRequestCancelled || cancelled.CancelledAt == nil {
+		t.Fatalf("got %+v, %v cancelling an acknowledged request", cancelled, err)
+	}
+
+	if _, err := h.Acknowledge(req.ID, "tech", ""); !errors.Is(err, ErrHelpRequestClosed) {
+		t.Errorf("got %v acknowledging a cancelled request", err)
+	}
+
+	if _, err := h.Cancel(req.ID); !errors.Is(err, ErrHelpRequestClosed) {
+		t.Errorf("got %v cancelling a cancelled request", err)
+	}
+
+	for _, err := range []error{
+		func() error { _, err := h.Get("missing"); return err }(),
+		func() error { _, err := h.Acknowledge("missing", "", ""); return err }(),
+		func() error { _, err := h.Cancel("missing"); return err }(),
+	} {
+		if !errors.Is(err, ErrHelpRequestNotFound) {
+			t.Errorf("got %v for a missing request", err)
+		}
+	}
+}
+
+func TestHelpRequestRetention(t *testing.T) {
+	fake := freezeClock(t, time.Date(2030, time.January, 1, 9, 0, 0, 0, time.UTC))
+	h := NewHelpRequests(DefaultHelpCooldown)
+
+	old, _ := h.Create(HelpRequest{RoomID: "ITB-1010"})
+
+	fake.Shift(helpRequestRetention + time.Minute)
+	h.Create(HelpRequest{RoomID: "ITB-1011"})
+
+	if _, err := h.Get(old.ID); !errors.Is(err, ErrHelpRequestNotFound) {
+		t.Errorf("got %v getting a request past retention", err)
+	}
+}
@@ -0,0 +1,78 @@
+package schedule
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ParseConfigsCSV reads room configs from a csv file. The first row names the columns,
+// using the same names as the config's json fields (_id, displayName, calendarURL, canCreateEvents, ...).
+func ParseConfigsCSV(r io.Reader) ([]Config, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	switch {
+	case errors.Is(err, io.EOF):
+		return nil, errors.New("csv is empty")
+	case err != nil:
+		return nil, fmt.Errorf("unable to read csv header: %w", err)
+	}
+
+	for i := range header {
+		header[i] = strings.TrimSpace(header[i])
+
+		if _, ok := csvFields[header[i]]; !ok {
+			return nil, fmt.Errorf("unknown csv column %q", header[i])
+		}
+	}
+
+	var configs []Config
+	for {
+		record, err := reader.Read()
+		switch {
+		case errors.Is(err, io.EOF):
+			return configs, nil
+		case err != nil:
+			return nil, fmt.Errorf("unable to read csv: %w", err)
+		}
+
+		var config Config
+		for i, value := range record {
+			if err := csvFields[header[i]](&config, strings.TrimSpace(value)); err != nil {
+				line, _ := reader.FieldPos(i)
+				return nil, fmt.Errorf("line %d, column %q: %w", line, header[i], err)
+			}
+		}
+
+		configs = append(configs, config)
+	}
+}
+
+// csvFields sets each config field that can be imported from a csv
+var csvFields = map[string]func(*Config, string) error{
+	"_id":                 func(c *Config, v string) error { c.ID = v; return nil },
+	"_rev":                func(c *Config, v string) error { c.Rev = v; return nil },
+	"displayName":         func(c *Config, v string) error { c.DisplayName = v; return nil },
+	"image-url":           func(c *Config, v string) error { c.ImageURL = v; return nil },
+	"style-url":           func(c *Config, v string) error { c.StyleURL = v; return nil },
+	"calendarURL":         func(c *Config, v string) error { c.CalendarURL = v; return nil },
+	"canCreateEvents":     func(c *Config, v string) error { return parseCSVBool(&c.CanCreateEvents, v) },
+	"displayMeetingTitle": func(c *Config, v string) error { return parseCSVBool(&c.DisplayMeetingTitle, v) },
+	"canRequestHelp":      func(c *Config, v string) error { return parseCSVBool(&c.CanRequestHelp, v) },
+}
+
+func parseCSVBool(b *bool, v string) error {
+	if len(v) == 0 {
+		*b = false
+		return nil
+	}
+
+	var err error
+	*b, err = strconv.ParseBool(v)
+	return err
+}
@@ -0,0 +1,51 @@
+package schedule
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseConfigsCSV(t *testing.T) {
+	csv := `_id, displayName, calendarURL, canCreateEvents, displayMeetingTitle, canRequestHelp
+JET-1106, The JET, http://localhost:11002/JET_1106@calendar.com/events, true, TRUE,
+"ITB-1010", "ITB 1010, upstairs", local://ITB-1010, false, 1, 0
+`
+
+	configs, err := ParseConfigsCSV(strings.NewReader(csv))
+	if err != nil {
+		t.Fatalf("unable to parse csv: %s", err)
+	}
+
+	if len(configs) != 2 {
+		t.Fatalf("got %d configs, want 2", len(configs))
+	}
+
+	jet := configs[0]
+	if jet.ID != "JET-1106" || jet.DisplayName != "The JET" || jet.CalendarURL != "http://localhost:11002/JET_1106@calendar.com/events" {
+		t.Errorf("got %+v", jet)
+	}
+
+	if !jet.CanCreateEvents || !jet.DisplayMeetingTitle || jet.CanRequestHelp {
+		t.Errorf("got canCreateEvents %t, displayMeetingTitle %t, canRequestHelp %t, want true, true, false", jet.CanCreateEvents, jet.DisplayMeetingTitle, jet.CanRequestHelp)
+	}
+
+	if itb := configs[1]; itb.DisplayName != "ITB 1010, upstairs" || itb.CanCreateEvents || !itb.DisplayMeetingTitle || itb.CanRequestHelp {
+		t.Errorf("got %+v", itb)
+	}
+}
+
+func TestParseConfigsCSVErrors(t *testing.T) {
+	tests := map[string]string{
+		"":                                    "csv is empty",
+		"_id,capacity\n":                      `unknown csv column "capacity"`,
+		"_id,canCreateEvents\nJET-1106,yes\n": `line 2, column "canCreateEvents"`,
+		"_id,displayName\nJET-1106\n":         "wrong number of fields",
+	}
+
+	for csv, want := range tests {
+		_, err := ParseConfigsCSV(strings.NewReader(csv))
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("got %v parsing %q, want an error containing %q", err, csv, want)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package schedule
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/byuoitav/scheduler/calendars"
+)
+
+// Calendar url schemes that are served by calendars linked into the scheduler, instead of a calendar server
+const (
+	// SchemeInproc is inproc://<backend>/<roomID>, for any registered backend, like inproc://exchange/JET_1106@calendar.com
+	SchemeInproc = "inproc"
+
+	// SchemeLocal is local://<roomID>, a calendar kept in couch by the scheduler itself
+	SchemeLocal = "local"
+
+	// SchemeICS is ics://<host>/<path>, a read-only ics file downloaded over https
+	SchemeICS = "ics"
+)
+
+// inprocCalendars are the calendars created for in-process urls, by url and timezone
+var inprocCalendars sync.Map
+
+// isInproc returns true if rawURL is served by a calendar linked into the scheduler
+func isInproc(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+
+	switch u.Scheme {
+	case SchemeInproc, SchemeLocal, SchemeICS:
+		return true
+	}
+
+	return false
+}
+
+// inprocCalendar returns the calendar an in-process url points at, creating it the first time. It's kept for the life of the
+// process, so it isn't tied to any request's context. Times without a timezone (in ics files) are in loc.
+func inprocCalendar(rawURL string, loc *time.Location) (calendars.Calendar, error) {
+	key := rawURL + " " + loc.String()
+	if cal, ok := inprocCalendars.Load(key); ok {
+		return cal.(calendars.Calendar), nil
+	}
+
+	backend, roomID, err := parseInproc(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var cal calendars.Calendar
+	if backend == SchemeICS {
+		u, _ := url.Parse(rawURL)
+		u.Scheme = "https"
+		cal = &calendars.ICSCalendar{URL: u.String(), Location: loc}
+	} else {
+		b, ok := calendars.LookupBackend(backend)
+		if !ok {
+			return nil, fmt.Errorf("calendar backend %q isn't linked into the scheduler", backend)
+		}
+
+		if cal, err = b.Create(context.Background(), roomID); err != nil {
+			return nil, fmt.Errorf("unable to create %s calendar for %q: %w", backend, roomID, err)
+		}
+	}
+
+	inprocCalendars.Store(key, cal)
+	return cal, nil
+}
+
+// parseInproc returns which backend an in-process url is for, and the room it names
+func parseInproc(rawURL string) (backend, roomID string, err error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", "", err
+	}
+
+	switch u.Scheme {
+	case SchemeInproc:
+		roomID = strings.TrimPrefix(u.EscapedPath(), "/")
+		if len(u.Host) == 0 || len(roomID) == 0 {
+			return "", "", fmt.Errorf("%q must look like inproc://<backend>/<roomID>", rawURL)
+		}
+
+		if roomID, err = url.PathUnescape(roomID); err != nil {
+			return "", "", err
+		}
+
+		return u.Host, roomID, nil
+	case SchemeLocal:
+		roomID = strings.TrimPrefix(rawURL, SchemeLocal+"://")
+		if len(roomID) == 0 {
+			return "", "", fmt.Errorf("%q must look like local://<roomID>", rawURL)
+		}
+
+		return localBackend, roomID, nil
+	case SchemeICS:
+		if len(u.Host) == 0 {
+			return "", "", fmt.Errorf("%q must look like ics://<host>/<path>", rawURL)
+		}
+
+		return SchemeICS, "", nil
+	}
+
+	return "", "", fmt.Errorf("%q isn't an in-process calendar url", rawURL)
+}
+
+// validateCalendarURL checks a url events are read from or booked on
+func validateCalendarURL(raw string) error {
+	if !isInproc(raw) {
+		return validateURL(raw)
+	}
+
+	backend, _, err := parseInproc(raw)
+	if err != nil {
+		return err
+	}
+
+	if backend != SchemeICS {
+		if _, ok := calendars.LookupBackend(backend); !ok {
+			return fmt.Errorf("calendar backend %q isn't linked into the scheduler", backend)
+		}
+	}
+
+	return nil
+}
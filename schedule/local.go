@@ -0,0 +1,136 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/byuoitav/scheduler/calendars"
+	"github.com/byuoitav/scheduler/clock"
+)
+
+const (
+	// localBackend is the name local calendars are registered under
+	localBackend = "local"
+
+	// localCalendarPrefix is the prefix of the documents local calendars keep their events on
+	localCalendarPrefix = "calendar:"
+
+	// how long finished events are kept on a local calendar
+	localCalendarRetention = 30 * 24 * time.Hour
+)
+
+// ErrOverlap is returned when an event is booked on a local calendar at the same time as another event
+var ErrOverlap = calendars.ErrOverlap
+
+func init() {
+	calendars.Register(calendars.Backend{
+		Name:        localBackend,
+		Description: "events kept in couch by the scheduler, on a calendar:<roomID> document",
+		Create: func(ctx context.Context, roomID string) (calendars.Calendar, error) {
+			if len(roomID) == 0 {
+				return nil, errors.New("roomID must be set")
+			}
+
+			return &localCalendar{roomID: roomID}, nil
+		},
+	})
+}
+
+// LocalCalendarDoc is the id of the document a room's local calendar is kept on
+func LocalCalendarDoc(roomID string) string {
+	return localCalendarPrefix + roomID
+}
+
+// localCalendar is a calendar kept in the schedulers database, for rooms without a calendar of their own
+type localCalendar struct {
+	roomID string
+}
+
+type localCalendarDoc struct {
+	ID     string            `json:"_id"`
+	Rev    string            `json:"_rev,omitempty"`
+	Events []calendars.Event `json:"events"`
+}
+
+func (c *localCalendar) path() string {
+	return url.PathEscape(LocalCalendarDoc(c.roomID))
+}
+
+func (c *localCalendar) get(ctx context.Context) (localCalendarDoc, error) {
+	doc := localCalendarDoc{ID: LocalCalendarDoc(c.roomID)}
+
+	err := couchRequest(ctx, http.MethodGet, c.path(), nil, &doc)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return doc, nil
+	case err != nil:
+		return doc, fmt.Errorf("unable to get local calendar: %w", err)
+	}
+
+	return doc, nil
+}
+
+// GetEvents returns the calendar's events, sorted by start time
+func (c *localCalendar) GetEvents(ctx context.Context) ([]calendars.Event, error) {
+	doc, err := c.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	events := append([]calendars.Event{}, doc.Events...)
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].StartTime.Before(events[j].StartTime)
+	})
+
+	return events, nil
+}
+
+// CreateEvent books an event, as long as it doesn't overlap another one
+func (c *localCalendar) CreateEvent(ctx context.Context, event calendars.Event) error {
+	if !event.EndTime.After(event.StartTime) {
+		return errors.New("event must end after it starts")
+	}
+
+	event.Title = strings.TrimSpace(event.Title)
+	event.Source = ""
+
+	// someone else may book the room at the same time, so retry with their booking if couch says we conflicted
+	for attempt := 0; ; attempt++ {
+		doc, err := c.get(ctx)
+		if err != nil {
+			return err
+		}
+
+		kept := doc.Events[:0]
+		for _, e := range doc.Events {
+			if clock.Since(e.EndTime) > localCalendarRetention {
+				continue
+			}
+
+			if e.StartTime.Before(event.EndTime) && e.EndTime.After(event.StartTime) {
+				loc := event.StartTime.Location()
+				return fmt.Errorf("%w (%s - %s)", ErrOverlap, e.StartTime.In(loc).Format(time.Kitchen), e.EndTime.In(loc).Format(time.Kitchen))
+			}
+
+			kept = append(kept, e)
+		}
+
+		doc.Events = append(kept, event)
+
+		err = couchRequest(ctx, http.MethodPut, c.path(), doc, nil)
+		switch {
+		case errors.Is(err, ErrConflict) && attempt < 3:
+			continue
+		case err != nil:
+			return fmt.Errorf("unable to save local calendar: %w", err)
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,171 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/byuoitav/scheduler/clock"
+)
+
+// Clock formats
+const (
+	Clock12Hour = "12h"
+	Clock24Hour = "24h"
+)
+
+var (
+	defaultTimezone = "America/Denver"
+	defaultLocale   = "en-US"
+)
+
+// ErrInvalidDay is returned when a day can't be parsed
+var ErrInvalidDay = errors.New("invalid day")
+
+// locales are BCP 47 tags like en, en-US, or zh-Hant-TW
+var validLocale = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+
+// regions that use a 12 hour clock by default
+var twelveHourRegions = map[string]bool{
+	"US": true, "CA": true, "AU": true, "NZ": true, "IN": true, "PH": true, "PK": true, "EG": true, "SA": true,
+}
+
+// DayRange is a single calendar day in a room's timezone. It isn't always 24 hours long.
+type DayRange struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// RoomLocale is how a room's panel should show dates and times
+type RoomLocale struct {
+	Timezone string `json:"timezone"`
+	Locale   string `json:"locale"`
+	Language string `json:"language"`
+	Clock    string `json:"clock"`
+
+	// Now is the server's time in the room's timezone, and UTCOffset is the room's current offset from UTC in seconds
+	Now       time.Time `json:"now"`
+	UTCOffset int       `json:"utcOffset"`
+
+	Today DayRange `json:"today"`
+}
+
+// SetDefaultLocale sets the timezone and locale used for rooms that don't have their own
+func SetDefaultLocale(timezone, locale string) error {
+	if _, err := time.LoadLocation(timezone); err != nil {
+		return fmt.Errorf("invalid timezone %q: %w", timezone, err)
+	}
+
+	if !validLocale.MatchString(locale) {
+		return fmt.Errorf("invalid locale %q", locale)
+	}
+
+	defaultTimezone = timezone
+	defaultLocale = locale
+	return nil
+}
+
+// Location returns the room's timezone, or the default timezone if it doesn't have a valid one
+func (c Config) Location() *time.Location {
+	if len(c.Timezone) > 0 {
+		if loc, err := time.LoadLocation(c.Timezone); err == nil {
+			return loc
+		}
+	}
+
+	loc, err := time.LoadLocation(defaultTimezone)
+	if err != nil {
+		return time.Local
+	}
+
+	return loc
+}
+
+// RoomLocale returns how the room's panel should show dates and times at now
+func (c Config) RoomLocale(now time.Time) RoomLocale {
+	loc := c.Location()
+	now = now.In(loc)
+
+	locale := c.Locale
+	if len(locale) == 0 {
+		locale = defaultLocale
+	}
+
+	language, region := splitLocale(locale)
+
+	clock := c.Clock
+	if len(clock) == 0 {
+		clock = Clock24Hour
+		if twelveHourRegions[region] || (len(region) == 0 && language == "en") {
+			clock = Clock12Hour
+		}
+	}
+
+	_, offset := now.Zone()
+	start, end := DayBounds(now)
+
+	return RoomLocale{
+		Timezone:  loc.String(),
+		Locale:    locale,
+		Language:  language,
+		Clock:     clock,
+		Now:       now,
+		UTCOffset: offset,
+		Today:     DayRange{Start: start, End: end},
+	}
+}
+
+// GetLocale returns how a room's panel should show dates and times right now
+func GetLocale(ctx context.Context, roomID string) (RoomLocale, error) {
+	config, err := GetConfig(ctx, roomID)
+	if err != nil {
+		return RoomLocale{}, fmt.Errorf("unable to get schedule config: %w", err)
+	}
+
+	return config.RoomLocale(clock.Now()), nil
+}
+
+// DayBounds returns the start of t's day and the start of the next day, in t's location.
+// Days that daylight saving time starts or ends on are 23 or 25 hours long.
+func DayBounds(t time.Time) (start, end time.Time) {
+	start = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	end = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+	return start, end
+}
+
+// ParseDay parses yesterday, today, tomorrow, or a date (2006-01-02) relative to now, in now's location
+func ParseDay(day string, now time.Time) (time.Time, error) {
+	switch strings.ToLower(day) {
+	case "yesterday":
+		return now.AddDate(0, 0, -1), nil
+	case "today":
+		return now, nil
+	case "tomorrow":
+		return now.AddDate(0, 0, 1), nil
+	}
+
+	t, err := time.ParseInLocation("2006-01-02", day, now.Location())
+	if err != nil {
+		return t, fmt.Errorf("%w %q: must be yesterday, today, tomorrow, or a date like 2006-01-02", ErrInvalidDay, day)
+	}
+
+	return t, nil
+}
+
+// splitLocale returns a locale's lowercase language and uppercase region (if it has one)
+func splitLocale(locale string) (language, region string) {
+	parts := strings.Split(locale, "-")
+	language = strings.ToLower(parts[0])
+
+	for _, part := range parts[1:] {
+		// regions are two letters or three digits, scripts are four letters
+		if len(part) == 2 || (len(part) == 3 && part[0] >= '0' && part[0] <= '9') {
+			return language, strings.ToUpper(part)
+		}
+	}
+
+	return language, ""
+}
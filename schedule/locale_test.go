@@ -0,0 +1,123 @@
+package schedule
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRoomLocale(t *testing.T) {
+	now := time.Date(2030, time.July, 1, 18, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		config   Config
+		timezone string
+		locale   string
+		language string
+		clock    string
+		offset   int
+	}{
+		{"defaults", Config{}, "America/Denver", "en-US", "en", Clock12Hour, -6 * 60 * 60},
+		{"invalid timezone uses the default", Config{Timezone: "Mountain Time"}, "America/Denver", "en-US", "en", Clock12Hour, -6 * 60 * 60},
+		{"24 hour region", Config{Timezone: "Europe/Berlin", Locale: "de-DE"}, "Europe/Berlin", "de-DE", "de", Clock24Hour, 2 * 60 * 60},
+		{"language without a region", Config{Locale: "en"}, "America/Denver", "en", "en", Clock12Hour, -6 * 60 * 60},
+		{"other language without a region", Config{Locale: "es"}, "America/Denver", "es", "es", Clock24Hour, -6 * 60 * 60},
+		{"script before the region", Config{Timezone: "Asia/Taipei", Locale: "zh-Hant-TW"}, "Asia/Taipei", "zh-Hant-TW", "zh", Clock24Hour, 8 * 60 * 60},
+		{"numeric region", Config{Locale: "es-419"}, "America/Denver", "es-419", "es", Clock24Hour, -6 * 60 * 60},
+		{"lowercase region", Config{Locale: "EN-ca"}, "America/Denver", "EN-ca", "en", Clock12Hour, -6 * 60 * 60},
+		{"clock overrides the region", Config{Locale: "en-US", Clock: Clock24Hour}, "America/Denver", "en-US", "en", Clock24Hour, -6 * 60 * 60},
+	}
+
+	for _, tt := range tests {
+		got := tt.config.RoomLocale(now)
+		if got.Timezone != tt.timezone || got.Locale != tt.locale || got.Language != tt.language || got.Clock != tt.clock || got.UTCOffset != tt.offset {
+			t.Errorf("%s: got %s %s %s %s %d, want %s %s %s %s %d", tt.name,
+				got.Timezone, got.Locale, got.Language, got.Clock, got.UTCOffset,
+				tt.timezone, tt.locale, tt.language, tt.clock, tt.offset)
+		}
+
+		if !got.Now.Equal(now) || got.Now.Location().String() != tt.timezone {
+			t.Errorf("%s: got now %s, want %s in %s", tt.name, got.Now, now, tt.timezone)
+		}
+	}
+}
+
+func TestSetDefaultLocale(t *testing.T) {
+	t.Cleanup(func() { defaultTimezone, defaultLocale = "America/Denver", "en-US" })
+
+	if err := SetDefaultLocale("Mountain Time", "en-US"); err == nil {
+		t.Errorf("got no error setting an invalid timezone")
+	}
+
+	if err := SetDefaultLocale("Europe/Paris", "french"); err == nil {
+		t.Errorf("got no error setting an invalid locale")
+	}
+
+	if err := SetDefaultLocale("Europe/Paris", "fr-FR"); err != nil {
+		t.Fatalf("unable to set default locale: %s", err)
+	}
+
+	got := Config{}.RoomLocale(time.Date(2030, time.January, 1, 12, 0, 0, 0, time.UTC))
+	if got.Timezone != "Europe/Paris" || got.Locale != "fr-FR" || got.Clock != Clock24Hour {
+		t.Errorf("got %s %s %s, want Europe/Paris fr-FR 24h", got.Timezone, got.Locale, got.Clock)
+	}
+}
+
+func TestDayBounds(t *testing.T) {
+	denver, err := time.LoadLocation("America/Denver")
+	if err != nil {
+		t.Skipf("unable to load America/Denver: %s", err)
+	}
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want time.Duration
+	}{
+		{"normal day", time.Date(2030, time.June, 3, 9, 0, 0, 0, denver), 24 * time.Hour},
+		{"dst starts", time.Date(2030, time.March, 10, 9, 0, 0, 0, denver), 23 * time.Hour},
+		{"dst ends", time.Date(2030, time.November, 3, 9, 0, 0, 0, denver), 25 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		start, end := DayBounds(tt.now)
+		if start.Hour() != 0 || start.Day() != tt.now.Day() || end.Hour() != 0 || end.Day() != tt.now.Day()+1 {
+			t.Errorf("%s: got %s to %s", tt.name, start, end)
+		}
+
+		if got := end.Sub(start); got != tt.want {
+			t.Errorf("%s: got a %s day, want %s", tt.name, got, tt.want)
+		}
+	}
+
+	// the room's day, not the server's
+	today := Config{Timezone: "America/Denver"}.RoomLocale(time.Date(2030, time.June, 4, 3, 0, 0, 0, time.UTC)).Today
+	if want := time.Date(2030, time.June, 3, 0, 0, 0, 0, denver); !today.Start.Equal(want) {
+		t.Errorf("got today starting %s, want %s", today.Start, want)
+	}
+}
+
+func TestParseDay(t *testing.T) {
+	now := time.Date(2030, time.March, 1, 9, 0, 0, 0, time.UTC)
+
+	tests := map[string]string{
+		"yesterday":  "2030-02-28",
+		"Today":      "2030-03-01",
+		"TOMORROW":   "2030-03-02",
+		"2030-12-25": "2030-12-25",
+	}
+
+	for day, want := range tests {
+		got, err := ParseDay(day, now)
+		if err != nil || got.Format("2006-01-02") != want {
+			t.Errorf("got %s, %v parsing %q, want %s", got, err, day, want)
+		}
+	}
+
+	for _, day := range []string{"", "next week", "12/25/2030", "2030-02-30"} {
+		if _, err := ParseDay(day, now); !errors.Is(err, ErrInvalidDay) {
+			t.Errorf("got %v parsing %q, want ErrInvalidDay", err, day)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package schedule
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Rotation modes
+const (
+	// RotateSlideshow shows each image for Interval, in order
+	RotateSlideshow = "slideshow"
+
+	// RotateTimeOfDay shows each image from its From time until the next image's From time
+	RotateTimeOfDay = "time-of-day"
+
+	// RotateWeekday shows each image on its Weekdays
+	RotateWeekday = "weekday"
+)
+
+const defaultSlideshowInterval = time.Minute
+
+// BackgroundRotation rotates a room's background between several attachments
+type BackgroundRotation struct {
+	// Mode is one of slideshow, time-of-day, or weekday
+	Mode string `json:"mode"`
+
+	// Interval is how long each image is shown in slideshow mode, like "30s" or "5m"
+	Interval string `json:"interval,omitempty"`
+
+	Images []BackgroundImage `json:"images"`
+}
+
+// BackgroundImage is one of the images in a BackgroundRotation
+type BackgroundImage struct {
+	// Attachment is the name of the image on the config document
+	Attachment string `json:"attachment"`
+
+	// From is when (15:04) this image starts being shown in time-of-day mode
+	From string `json:"from,omitempty"`
+
+	// Weekdays are the days (monday, tuesday...) this image is shown in weekday mode.
+	// An image without any weekdays is shown on days no other image claims.
+	Weekdays []string `json:"weekdays,omitempty"`
+}
+
+var weekdays = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+// Validate returns everything wrong with the rotation
+func (r BackgroundRotation) Validate() []string {
+	var problems []string
+
+	switch r.Mode {
+	case RotateSlideshow:
+		if len(r.Interval) > 0 {
+			if d, err := time.ParseDuration(r.Interval); err != nil || d < time.Second {
+				problems = append(problems, fmt.Sprintf("interval %q must be a duration of at least 1s", r.Interval))
+			}
+		}
+	case RotateTimeOfDay, RotateWeekday:
+	default:
+		problems = append(problems, fmt.Sprintf("unknown mode %q", r.Mode))
+	}
+
+	if len(r.Images) == 0 {
+		problems = append(problems, "at least one image is required")
+	}
+
+	for i, img := range r.Images {
+		if len(img.Attachment) == 0 {
+			problems = append(problems, fmt.Sprintf("images[%d]: attachment is required", i))
+		}
+
+		if r.Mode == RotateTimeOfDay {
+			if _, err := time.Parse("15:04", img.From); err != nil {
+				problems = append(problems, fmt.Sprintf("images[%d]: from %q must be a time like 07:30", i, img.From))
+			}
+		}
+
+		for _, day := range img.Weekdays {
+			if _, ok := weekdays[strings.ToLower(day)]; !ok {
+				problems = append(problems, fmt.Sprintf("images[%d]: unknown weekday %q", i, day))
+			}
+		}
+	}
+
+	return problems
+}
+
+// Current returns the image that should be shown at now, and when the next image should be shown.
+// next is zero if the image never changes.
+func (r BackgroundRotation) Current(now time.Time) (img BackgroundImage, next time.Time) {
+	if len(r.Images) == 0 {
+		return BackgroundImage{}, time.Time{}
+	}
+
+	if len(r.Images) == 1 {
+		return r.Images[0], time.Time{}
+	}
+
+	switch r.Mode {
+	case RotateSlideshow:
+		interval, err := time.ParseDuration(r.Interval)
+		if err != nil || interval < time.Second {
+			interval = defaultSlideshowInterval
+		}
+
+		// based on the clock rather than when the panel loaded, so every panel shows the same image
+		slot := now.UnixNano() / int64(interval)
+		img = r.Images[slot%int64(len(r.Images))]
+		next = time.Unix(0, (slot+1)*int64(interval)).In(now.Location())
+
+		return img, next
+	case RotateTimeOfDay:
+		return r.timeOfDay(now)
+	case RotateWeekday:
+		return r.weekday(now)
+	}
+
+	return r.Images[0], time.Time{}
+}
+
+func (r BackgroundRotation) timeOfDay(now time.Time) (BackgroundImage, time.Time) {
+	type start struct {
+		img    BackgroundImage
+		hour   int
+		minute int
+	}
+
+	var starts []start
+	for _, img := range r.Images {
+		t, err := time.Parse("15:04", img.From)
+		if err != nil {
+			continue
+		}
+
+		starts = append(starts, start{img: img, hour: t.Hour(), minute: t.Minute()})
+	}
+
+	if len(starts) == 0 {
+		return r.Images[0], time.Time{}
+	}
+
+	sort.SliceStable(starts, func(i, j int) bool {
+		return starts[i].hour*60+starts[i].minute < starts[j].hour*60+starts[j].minute
+	})
+
+	at := func(day time.Time, s start) time.Time {
+		t := time.Date(day.Year(), day.Month(), day.Day(), s.hour, s.minute, 0, 0, now.Location())
+
+		// a start time skipped when dst begins happens an hour later, rather than an hour early
+		if t.Hour() != s.hour {
+			t = t.Add(time.Hour)
+		}
+
+		return t
+	}
+
+	// before the first start, yesterday's last image is still showing
+	current := starts[len(starts)-1]
+	for _, s := range starts {
+		if !at(now, s).After(now) {
+			current = s
+			continue
+		}
+
+		return current.img, at(now, s)
+	}
+
+	return current.img, at(now.AddDate(0, 0, 1), starts[0])
+}
+
+func (r BackgroundRotation) weekday(now time.Time) (BackgroundImage, time.Time) {
+	tomorrow := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
+
+	var fallback *BackgroundImage
+	for i, img := range r.Images {
+		if len(img.Weekdays) == 0 && fallback == nil {
+			fallback = &r.Images[i]
+		}
+
+		for _, day := range img.Weekdays {
+			if weekdays[strings.ToLower(day)] == now.Weekday() {
+				return img, tomorrow
+			}
+		}
+	}
+
+	if fallback != nil {
+		return *fallback, tomorrow
+	}
+
+	return r.Images[0], tomorrow
+}
@@ -0,0 +1,182 @@
+package schedule
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/byuoitav/scheduler/schedule/scheduletest"
+)
+
+func TestRotationValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		rotation BackgroundRotation
+		want     []string
+	}{
+		{name: "slideshow", rotation: BackgroundRotation{Mode: RotateSlideshow, Interval: "30s", Images: []BackgroundImage{{Attachment: "a.png"}}}},
+		{name: "unknown mode", rotation: BackgroundRotation{Mode: "shuffle", Images: []BackgroundImage{{Attachment: "a.png"}}}, want: []string{`unknown mode "shuffle"`}},
+		{name: "no images", rotation: BackgroundRotation{Mode: RotateWeekday}, want: []string{"at least one image is required"}},
+		{name: "short interval", rotation: BackgroundRotation{Mode: RotateSlideshow, Interval: "500ms", Images: []BackgroundImage{{Attachment: "a.png"}}}, want: []string{`interval "500ms" must be a duration of at least 1s`}},
+		{name: "bad from", rotation: BackgroundRotation{Mode: RotateTimeOfDay, Images: []BackgroundImage{{Attachment: "a.png", From: "7am"}}}, want: []string{`images[0]: from "7am" must be a time like 07:30`}},
+		{name: "bad weekday", rotation: BackgroundRotation{Mode: RotateWeekday, Images: []BackgroundImage{{Weekdays: []string{"Monday", "someday"}}}}, want: []string{
+			"images[0]: attachment is required",
+			`images[0]: unknown weekday "someday"`,
+		}},
+	}
+
+	for _, tt := range tests {
+		problems := tt.rotation.Validate()
+		if len(problems) != len(tt.want) {
+			t.Errorf("%s: got problems %q, want %q", tt.name, problems, tt.want)
+			continue
+		}
+
+		for i := range problems {
+			if problems[i] != tt.want[i] {
+				t.Errorf("%s: got problem %q, want %q", tt.name, problems[i], tt.want[i])
+			}
+		}
+	}
+}
+
+func TestRotationCurrent(t *testing.T) {
+	denver, err := time.LoadLocation("America/Denver")
+	if err != nil {
+		t.Skipf("unable to load America/Denver: %s", err)
+	}
+
+	images := func(attachments ...string) []BackgroundImage {
+		var imgs []BackgroundImage
+		for _, a := range attachments {
+			imgs = append(imgs, BackgroundImage{Attachment: a})
+		}
+
+		return imgs
+	}
+
+	slideshow := BackgroundRotation{Mode: RotateSlideshow, Interval: "1m", Images: images("a.png", "b.png", "c.png")}
+	timeOfDay := BackgroundRotation{Mode: RotateTimeOfDay, Images: []BackgroundImage{
+		{Attachment: "evening.png", From: "18:00"},
+		{Attachment: "morning.png", From: "07:30"},
+		{Attachment: "dst.png", From: "02:30"},
+	}}
+	weekday := BackgroundRotation{Mode: RotateWeekday, Images: []BackgroundImage{
+		{Attachment: "weekend.png", Weekdays: []string{"Saturday", "sunday"}},
+		{Attachment: "weekday.png"},
+		{Attachment: "unused.png"},
+	}}
+
+	at := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2030, month, day, hour, minute, 0, 0, denver)
+	}
+
+	tests := []struct {
+		name     string
+		rotation BackgroundRotation
+		now      time.Time
+		want     string
+		next     time.Time
+	}{
+		{"one image never changes", BackgroundRotation{Mode: RotateSlideshow, Images: images("a.png")}, at(time.June, 3, 9, 0), "a.png", time.Time{}},
+		{"slideshow", slideshow, time.Unix(120, 0), "c.png", time.Unix(180, 0)},
+		{"slideshow wraps", slideshow, time.Unix(190, 0), "a.png", time.Unix(240, 0)},
+		{"slideshow default interval", BackgroundRotation{Mode: RotateSlideshow, Interval: "soon", Images: images("a.png", "b.png")}, time.Unix(61, 0), "b.png", time.Unix(120, 0)},
+		{"after the first start", timeOfDay, at(time.June, 3, 9, 0), "morning.png", at(time.June, 3, 18, 0)},
+		{"exactly at a start", timeOfDay, at(time.June, 3, 18, 0), "evening.png", at(time.June, 4, 2, 30)},
+		{"before the first start", timeOfDay, at(time.June, 3, 1, 0), "evening.png", at(time.June, 3, 2, 30)},
+		{"start skipped by dst", timeOfDay, at(time.March, 10, 1, 0), "evening.png", time.Date(2030, time.March, 10, 9, 30, 0, 0, time.UTC)},
+		{"weekend", weekday, at(time.June, 1, 9, 0), "weekend.png", at(time.June, 2, 0, 0)},
+		{"weekday falls back to the first image without days", weekday, at(time.June, 3, 9, 0), "weekday.png", at(time.June, 4, 0, 0)},
+		{"no fallback", BackgroundRotation{Mode: RotateWeekday, Images: []BackgroundImage{{Attachment: "a.png", Weekdays: []string{"sunday"}}, {Attachment: "b.png", Weekdays: []string{"monday"}}}}, at(time.June, 4, 9, 0), "a.png", at(time.June, 5, 0, 0)},
+		{"unknown mode", BackgroundRotation{Mode: "shuffle", Images: images("a.png", "b.png")}, at(time.June, 3, 9, 0), "a.png", time.Time{}},
+	}
+
+	for _, tt := range tests {
+		img, next := tt.rotation.Current(tt.now)
+		if img.Attachment != tt.want || !next.Equal(tt.next) {
+			t.Errorf("%s: got %s until %s, want %s until %s", tt.name, img.Attachment, next, tt.want, tt.next)
+		}
+	}
+}
+
+func TestGetBackgrounds(t *testing.T) {
+	couch := scheduletest.NewCouch(t)
+	t.Setenv("DB_ADDRESS", couch.URL)
+
+	get := func(roomID, buildingID string) Backgrounds {
+		t.Helper()
+
+		b, err := GetBackgrounds(context.Background(), roomID, buildingID)
+		if err != nil {
+			t.Fatalf("unable to get backgrounds: %s", err)
+		}
+
+		return b
+	}
+
+	attachments := func(b Backgrounds) string {
+		var names []string
+		for _, img := range b.Rotation.Images {
+			names = append(names, img.Attachment)
+		}
+
+		return strings.Join(names, ",")
+	}
+
+	if b := get("JET-1106", "JET"); b.Source != SourceDefault {
+		t.Errorf("got source %q with nothing in couch, want %q", b.Source, SourceDefault)
+	}
+
+	couch.PutAttachment("static", BackgroundAttachment, "image/png", []byte("png"))
+	if b := get("JET-1106", "JET"); b.Source != SourceStatic || b.DocID != "static" || attachments(b) != BackgroundAttachment {
+		t.Errorf("got %+v, want the static background", b)
+	}
+
+	couch.PutAttachment(BuildingDoc("JET"), "day.png", "image/png", []byte("png"))
+	couch.PutAttachment(BuildingDoc("JET"), "night.png", "image/png", []byte("png"))
+	couch.Put(t, map[string]interface{}{
+		"_id": BuildingDoc("JET"),
+		"backgrounds": BackgroundRotation{Mode: RotateTimeOfDay, Images: []BackgroundImage{
+			{Attachment: "day.png", From: "07:00"},
+			{Attachment: "missing.png", From: "12:00"},
+			{Attachment: "night.png", From: "19:00"},
+		}},
+	})
+
+	// images that aren't attached are dropped
+	b := get("JET-1106", "JET")
+	if b.Source != SourceBuilding || b.DocID != "building:JET" || b.Rotation.Mode != RotateTimeOfDay || attachments(b) != "day.png,night.png" {
+		t.Errorf("got %+v, want the building's rotation", b)
+	}
+
+	if !b.Has("night.png") || b.Has("missing.png") {
+		t.Errorf("got Has(night.png) %t, Has(missing.png) %t", b.Has("night.png"), b.Has("missing.png"))
+	}
+
+	// a room without a building skips straight to the static document
+	if b := get("ITB-1010", ""); b.Source != SourceStatic {
+		t.Errorf("got source %q for a room without a building, want %q", b.Source, SourceStatic)
+	}
+
+	// a rotation with none of its images attached falls back to the room's bg.png
+	couch.Put(t, map[string]interface{}{
+		"_id":         "JET-1106",
+		"backgrounds": BackgroundRotation{Mode: RotateSlideshow, Images: []BackgroundImage{{Attachment: "missing.png"}}},
+	})
+
+	if b := get("JET-1106", "JET"); b.Source != SourceBuilding {
+		t.Errorf("got source %q for a room whose rotation has no attachments, want %q", b.Source, SourceBuilding)
+	}
+
+	couch.PutAttachment("JET-1106", BackgroundAttachment, "image/png", []byte("png"))
+	if b := get("JET-1106", "JET"); b.Source != SourceRoom || attachments(b) != BackgroundAttachment {
+		t.Errorf("got %+v, want the room's bg.png", b)
+	}
+
+	couch.SetDown(true)
+	if _, err := GetBackgrounds(context.Background(), "JET-1106", "JET"); err == nil {
+		t.Errorf("got no error with couch down")
+	}
+}
@@ -0,0 +1,520 @@
+// Package scheduletest has stand-ins for the services the schedule package talks to, for tests that run the scheduler end to end.
+package scheduletest
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Database is the database the scheduler keeps its documents in
+const Database = "schedulers"
+
+// Couch is an in-memory stand-in for the parts of CouchDB the scheduler uses: documents with revisions,
+// attachments, _all_docs, and _changes (including continuous feeds). It only serves the schedulers database.
+type Couch struct {
+	URL string
+
+	mu   sync.Mutex
+	docs map[string]*couchDoc
+	seq  int
+	down bool
+
+	// stalled feeds stay open, but stop sending changes and heartbeats
+	stalled bool
+
+	// deleted is the seq each deleted document was deleted at
+	deleted map[string]int
+
+	// changed is closed and replaced whenever a document changes, to wake up continuous feeds
+	changed chan struct{}
+}
+
+type couchDoc struct {
+	fields      map[string]json.RawMessage
+	rev         int
+	seq         int
+	attachments map[string]couchAttachment
+}
+
+type couchAttachment struct {
+	contentType string
+	data        []byte
+	revpos      int
+}
+
+// NewCouch starts a stand-in couch, which is closed when the test ends. Point the scheduler at it by setting DB_ADDRESS to its URL.
+func NewCouch(t testing.TB) *Couch {
+	c := &Couch{
+		docs:    make(map[string]*couchDoc),
+		deleted: make(map[string]int),
+		changed: make(chan struct{}),
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(c.serveHTTP))
+	t.Cleanup(srv.Close)
+
+	c.URL = srv.URL
+	return c
+}
+
+// Put saves doc (anything that marshals to a json object with an _id) as the next revision of its document, and returns the new _rev
+func (c *Couch) Put(t testing.TB, doc interface{}) string {
+	t.Helper()
+
+	b, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("unable to marshal couch document: %s", err)
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		t.Fatalf("couch documents must be json objects: %s", err)
+	}
+
+	var id string
+	if err := json.Unmarshal(fields["_id"], &id); err != nil || len(id) == 0 {
+		t.Fatalf("couch document doesn't have an _id: %s", b)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.put(id, fields)
+}
+
+// PutAttachment attaches data to a document, creating the document if it doesn't exist
+func (c *Couch) PutAttachment(docID, name, contentType string, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.attach(docID, name, contentType, data)
+}
+
+// Delete deletes a document, returning false if it doesn't exist
+func (c *Couch) Delete(id string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.docs[id]; !ok {
+		return false
+	}
+
+	c.delete(id)
+	return true
+}
+
+// SetDown makes every request fail while down is true, and ends any continuous _changes feeds
+func (c *Couch) SetDown(down bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.down = down
+	c.notify()
+}
+
+// Stall makes continuous _changes feeds go silent while stalled is true, like a connection that died without being closed
+func (c *Couch) Stall(stalled bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.stalled = stalled
+	c.notify()
+}
+
+// Get unmarshals the latest revision of a document into out, returning false if it doesn't exist
+func (c *Couch) Get(t testing.TB, id string, out interface{}) bool {
+	t.Helper()
+
+	c.mu.Lock()
+	doc, ok := c.docs[id]
+	var b []byte
+	if ok {
+		b = c.marshal(id, doc)
+	}
+	c.mu.Unlock()
+
+	if !ok {
+		return false
+	}
+
+	if err := json.Unmarshal(b, out); err != nil {
+		t.Fatalf("unable to unmarshal couch document %q: %s", id, err)
+	}
+
+	return true
+}
+
+func (c *Couch) put(id string, fields map[string]json.RawMessage) string {
+	doc, ok := c.docs[id]
+	if !ok {
+		doc = &couchDoc{attachments: make(map[string]couchAttachment)}
+		c.docs[id] = doc
+	}
+
+	delete(fields, "_rev")
+	delete(fields, "_attachments")
+
+	doc.fields = fields
+	doc.rev++
+	c.changedDoc(id, doc)
+	return doc.revString()
+}
+
+func (c *Couch) delete(id string) {
+	delete(c.docs, id)
+
+	c.seq++
+	c.deleted[id] = c.seq
+	c.notify()
+}
+
+// changedDoc gives doc the next seq. c.mu must be held.
+func (c *Couch) changedDoc(id string, doc *couchDoc) {
+	c.seq++
+	doc.seq = c.seq
+	delete(c.deleted, id)
+	c.notify()
+}
+
+// notify wakes up continuous feeds. c.mu must be held.
+func (c *Couch) notify() {
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+func (c *Couch) attach(docID, name, contentType string, data []byte) string {
+	doc, ok := c.docs[docID]
+	if !ok {
+		doc = &couchDoc{fields: map[string]json.RawMessage{}, attachments: make(map[string]couchAttachment)}
+		c.docs[docID] = doc
+	}
+
+	doc.rev++
+	doc.attachments[name] = couchAttachment{contentType: contentType, data: data, revpos: doc.rev}
+	c.changedDoc(docID, doc)
+	return doc.revString()
+}
+
+func (d *couchDoc) revString() string {
+	return fmt.Sprintf("%d-%032x", d.rev, d.rev)
+}
+
+func (a couchAttachment) digest() string {
+	sum := md5.Sum(a.data)
+	return "md5-" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// marshal returns the document as couch would, with its _id, _rev, and attachment stubs
+func (c *Couch) marshal(id string, doc *couchDoc) []byte {
+	fields := make(map[string]interface{}, len(doc.fields)+3)
+	for k, v := range doc.fields {
+		fields[k] = v
+	}
+
+	fields["_id"] = id
+	fields["_rev"] = doc.revString()
+
+	if len(doc.attachments) > 0 {
+		stubs := make(map[string]interface{}, len(doc.attachments))
+		for name, att := range doc.attachments {
+			stubs[name] = map[string]interface{}{
+				"content_type": att.contentType,
+				"revpos":       att.revpos,
+				"digest":       att.digest(),
+				"length":       len(att.data),
+				"stub":         true,
+			}
+		}
+
+		fields["_attachments"] = stubs
+	}
+
+	b, _ := json.Marshal(fields)
+	return b
+}
+
+func (c *Couch) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.EscapedPath(), "/"), "/")
+	if parts[0] != Database {
+		couchError(w, http.StatusNotFound, "not_found", "Database does not exist.")
+		return
+	}
+
+	for i := range parts {
+		parts[i], _ = url.PathUnescape(parts[i])
+	}
+
+	c.mu.Lock()
+	down := c.down
+	c.mu.Unlock()
+
+	if down {
+		couchError(w, http.StatusServiceUnavailable, "unavailable", "couch is down")
+		return
+	}
+
+	// continuous feeds wait for changes, so they can't hold the lock
+	if len(parts) == 2 && parts[1] == "_changes" {
+		c.serveChanges(w, r)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch {
+	case len(parts) == 2 && parts[1] == "_all_docs":
+		c.allDocs(w, r)
+	case len(parts) == 2:
+		c.serveDoc(w, r, parts[1])
+	case len(parts) == 3:
+		c.serveAttachment(w, r, parts[1], parts[2])
+	default:
+		couchError(w, http.StatusNotFound, "not_found", "missing")
+	}
+}
+
+func (c *Couch) allDocs(w http.ResponseWriter, r *http.Request) {
+	ids := make([]string, 0, len(c.docs))
+	for id := range c.docs {
+		ids = append(ids, id)
+	}
+
+	sort.Strings(ids)
+
+	type row struct {
+		ID  string          `json:"id"`
+		Key string          `json:"key"`
+		Doc json.RawMessage `json:"doc,omitempty"`
+	}
+
+	rows := make([]row, 0, len(ids))
+	for _, id := range ids {
+		rw := row{ID: id, Key: id}
+		if r.URL.Query().Get("include_docs") == "true" {
+			rw.Doc = c.marshal(id, c.docs[id])
+		}
+
+		rows = append(rows, rw)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"total_rows": len(rows),
+		"offset":     0,
+		"rows":       rows,
+	})
+}
+
+type change struct {
+	Seq     string          `json:"seq"`
+	ID      string          `json:"id"`
+	Deleted bool            `json:"deleted,omitempty"`
+	Doc     json.RawMessage `json:"doc,omitempty"`
+}
+
+// changesSince returns each document's latest change after since, in order, and a channel that's
+// closed when there are more. c.mu must be held.
+func (c *Couch) changesSince(since int, includeDocs bool) ([]change, chan struct{}) {
+	var changes []change
+	for id, doc := range c.docs {
+		if doc.seq > since {
+			ch := change{Seq: strconv.Itoa(doc.seq), ID: id}
+			if includeDocs {
+				ch.Doc = c.marshal(id, doc)
+			}
+
+			changes = append(changes, ch)
+		}
+	}
+
+	for id, seq := range c.deleted {
+		if seq > since {
+			changes = append(changes, change{Seq: strconv.Itoa(seq), ID: id, Deleted: true})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		a, _ := strconv.Atoi(changes[i].Seq)
+		b, _ := strconv.Atoi(changes[j].Seq)
+		return a < b
+	})
+
+	return changes, c.changed
+}
+
+// serveChanges serves _changes, either all at once or (with feed=continuous) as a line of json per change
+// until the request is cancelled or the couch goes down
+func (c *Couch) serveChanges(w http.ResponseWriter, r *http.Request) {
+	since, _ := strconv.Atoi(r.URL.Query().Get("since"))
+	includeDocs := r.URL.Query().Get("include_docs") == "true"
+
+	if r.URL.Query().Get("feed") != "continuous" {
+		c.mu.Lock()
+		changes, _ := c.changesSince(since, includeDocs)
+		lastSeq := strconv.Itoa(c.seq)
+		c.mu.Unlock()
+
+		if changes == nil {
+			changes = []change{}
+		}
+
+		writeJSON(w, http.StatusOK, map[string]interface{}{"results": changes, "last_seq": lastSeq})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	var heartbeat <-chan time.Time
+	if ms, err := strconv.Atoi(r.URL.Query().Get("heartbeat")); err == nil && ms > 0 {
+		ticker := time.NewTicker(time.Duration(ms) * time.Millisecond)
+		defer ticker.Stop()
+
+		heartbeat = ticker.C
+	}
+
+	flush := func() {
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+	}
+
+	enc := json.NewEncoder(w)
+	for {
+		c.mu.Lock()
+		changes, changed := c.changesSince(since, includeDocs)
+		down, stalled := c.down, c.stalled
+		c.mu.Unlock()
+
+		if down {
+			return
+		}
+
+		if !stalled {
+			for _, ch := range changes {
+				enc.Encode(ch)
+				since, _ = strconv.Atoi(ch.Seq)
+			}
+
+			flush()
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-changed:
+		case <-heartbeat:
+			if !stalled {
+				w.Write([]byte("\n"))
+				flush()
+			}
+		}
+	}
+}
+
+func (c *Couch) serveDoc(w http.ResponseWriter, r *http.Request, id string) {
+	doc, exists := c.docs[id]
+
+	switch r.Method {
+	case http.MethodGet:
+		if !exists {
+			couchError(w, http.StatusNotFound, "not_found", "missing")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(c.marshal(id, doc))
+	case http.MethodPut:
+		var fields map[string]json.RawMessage
+		if err := json.NewDecoder(r.Body).Decode(&fields); err != nil {
+			couchError(w, http.StatusBadRequest, "bad_request", err.Error())
+			return
+		}
+
+		var rev string
+		json.Unmarshal(fields["_rev"], &rev)
+
+		if exists && rev != doc.revString() || !exists && len(rev) > 0 {
+			couchError(w, http.StatusConflict, "conflict", "Document update conflict.")
+			return
+		}
+
+		writeJSON(w, http.StatusCreated, map[string]interface{}{"ok": true, "id": id, "rev": c.put(id, fields)})
+	case http.MethodDelete:
+		if !exists {
+			couchError(w, http.StatusNotFound, "not_found", "missing")
+			return
+		}
+
+		if r.URL.Query().Get("rev") != doc.revString() {
+			couchError(w, http.StatusConflict, "conflict", "Document update conflict.")
+			return
+		}
+
+		c.delete(id)
+		writeJSON(w, http.StatusOK, map[string]interface{}{"ok": true, "id": id})
+	default:
+		couchError(w, http.StatusMethodNotAllowed, "method_not_allowed", r.Method)
+	}
+}
+
+func (c *Couch) serveAttachment(w http.ResponseWriter, r *http.Request, id, name string) {
+	doc, exists := c.docs[id]
+
+	switch r.Method {
+	case http.MethodGet:
+		var att couchAttachment
+		if exists {
+			att, exists = doc.attachments[name]
+		}
+
+		if !exists {
+			couchError(w, http.StatusNotFound, "not_found", "Document is missing attachment")
+			return
+		}
+
+		w.Header().Set("Content-Type", att.contentType)
+		w.Header().Set("Content-Length", strconv.Itoa(len(att.data)))
+		w.Header().Set("ETag", strconv.Quote(att.digest()))
+		w.Write(att.data)
+	case http.MethodPut:
+		rev := r.URL.Query().Get("rev")
+		if exists && rev != doc.revString() || !exists && len(rev) > 0 {
+			couchError(w, http.StatusConflict, "conflict", "Document update conflict.")
+			return
+		}
+
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			couchError(w, http.StatusBadRequest, "bad_request", err.Error())
+			return
+		}
+
+		rev = c.attach(id, name, r.Header.Get("Content-Type"), data)
+		writeJSON(w, http.StatusCreated, map[string]interface{}{"ok": true, "id": id, "rev": rev})
+	default:
+		couchError(w, http.StatusMethodNotAllowed, "method_not_allowed", r.Method)
+	}
+}
+
+func couchError(w http.ResponseWriter, status int, err, reason string) {
+	writeJSON(w, status, map[string]string{"error": err, "reason": reason})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
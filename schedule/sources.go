@@ -0,0 +1,164 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/byuoitav/scheduler/calendars"
+	"github.com/byuoitav/scheduler/log"
+	"go.uber.org/zap"
+)
+
+// CalendarSource is one of the calendars a room's schedule is merged from
+type CalendarSource struct {
+	// Name is what the room's events are tagged with, like teamup or exchange
+	Name string `json:"name"`
+	URL  string `json:"url"`
+
+	// FallbackURLs are read, in order, when URL is down
+	FallbackURLs []string `json:"fallbackURLs,omitempty"`
+
+	// Bookings is true for the calendar walk-up bookings are made on. If no calendar has it, the first one does.
+	Bookings bool `json:"bookings,omitempty"`
+}
+
+// urls are where the calendar can be read from, primary first
+func (s CalendarSource) urls() []string {
+	return append([]string{s.URL}, s.FallbackURLs...)
+}
+
+// Sources returns the calendars the room's schedule is merged from. Rooms with just a calendarURL have one, without a name.
+func (c Config) Sources() []CalendarSource {
+	if len(c.Calendars) == 0 {
+		return []CalendarSource{{
+			URL:          c.CalendarURL,
+			FallbackURLs: c.FallbackCalendarURLs,
+			Bookings:     true,
+		}}
+	}
+
+	list := make([]CalendarSource, len(c.Calendars))
+	copy(list, c.Calendars)
+
+	booking := 0
+	for i, s := range list {
+		if s.Bookings {
+			booking = i
+			break
+		}
+	}
+
+	for i := range list {
+		list[i].Bookings = i == booking
+	}
+
+	return list
+}
+
+// BookingSource returns the calendar walk-up bookings are made on
+func (c Config) BookingSource() CalendarSource {
+	for _, s := range c.Sources() {
+		if s.Bookings {
+			return s
+		}
+	}
+
+	return CalendarSource{}
+}
+
+// readSources reads each of a room's calendars at the same time and merges their events.
+// Calendars that can't be read are left out, unless none of them can be.
+func readSources(ctx context.Context, config Config) ([]calendars.Event, EventSources, error) {
+	cals := config.Sources()
+	loc := config.Location()
+
+	results := make([][]calendars.Event, len(cals))
+	read := make(EventSources, len(cals))
+	errs := make([]error, len(cals))
+
+	var wg sync.WaitGroup
+	for i := range cals {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+			results[i], read[i], errs[i] = readSource(ctx, config.ID, cals[i], loc)
+		}(i)
+	}
+
+	wg.Wait()
+	sources.answered(config.ID, read)
+
+	var merged [][]calendars.Event
+	var failed []error
+	for i, err := range errs {
+		if err != nil {
+			failed = append(failed, err)
+			continue
+		}
+
+		for j := range results[i] {
+			results[i][j].Source = cals[i].Name
+		}
+
+		merged = append(merged, results[i])
+	}
+
+	if len(merged) == 0 {
+		return nil, read, errors.Join(failed...)
+	}
+
+	if len(failed) > 0 {
+		log.P.Warn("Leaving out calendars that couldn't be read", zap.String("room", config.ID), zap.String("sources", read.String()), zap.Errors("errors", failed))
+	}
+
+	return mergeEvents(merged...), read, nil
+}
+
+// mergeEvents combines several calendars' events. When the same meeting is on more than one calendar
+// (the same start and end, to the minute, and the same title or no title), the copy from the calendar listed first is kept.
+// Events on the same calendar are never duplicates of each other, however alike they look.
+func mergeEvents(lists ...[]calendars.Event) []calendars.Event {
+	if len(lists) == 1 {
+		return lists[0]
+	}
+
+	merged := []calendars.Event{}
+	for _, list := range lists {
+		earlier := len(merged)
+		for _, event := range list {
+			duplicate := false
+			for _, kept := range merged[:earlier] {
+				if sameMeeting(kept, event) {
+					duplicate = true
+					break
+				}
+			}
+
+			if !duplicate {
+				merged = append(merged, event)
+			}
+		}
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].StartTime.Before(merged[j].StartTime)
+	})
+
+	return merged
+}
+
+func sameMeeting(a, b calendars.Event) bool {
+	if !a.StartTime.Truncate(time.Minute).Equal(b.StartTime.Truncate(time.Minute)) || !a.EndTime.Truncate(time.Minute).Equal(b.EndTime.Truncate(time.Minute)) {
+		return false
+	}
+
+	at := strings.Join(strings.Fields(a.Title), " ")
+	bt := strings.Join(strings.Fields(b.Title), " ")
+
+	return len(at) == 0 || len(bt) == 0 || strings.EqualFold(at, bt)
+}
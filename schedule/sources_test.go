@@ -0,0 +1,192 @@
+package schedule
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/byuoitav/scheduler/calendars"
+)
+
+func TestSameMeeting(t *testing.T) {
+	start := time.Date(2030, time.June, 3, 10, 0, 0, 0, time.UTC)
+	event := func(title string, start time.Time, length time.Duration) calendars.Event {
+		return calendars.Event{Title: title, StartTime: start, EndTime: start.Add(length)}
+	}
+
+	review := event("Design Review", start, time.Hour)
+
+	tests := []struct {
+		name  string
+		other calendars.Event
+		want  bool
+	}{
+		{"identical", review, true},
+		{"title case", event("design REVIEW", start, time.Hour), true},
+		{"title whitespace", event("  Design \t Review ", start, time.Hour), true},
+		{"empty title", event("", start, time.Hour), true},
+		{"blank title", event("   ", start, time.Hour), true},
+		{"same minute", event("Design Review", start.Add(45*time.Second), time.Hour), true},
+		{"different title", event("Lunch", start, time.Hour), false},
+		{"different start", event("Design Review", start.Add(time.Minute), time.Hour-time.Minute), false},
+		{"different end", event("Design Review", start, 90*time.Minute), false},
+		{"same time elsewhere", event("Design Review", start.In(time.FixedZone("MDT", -6*60*60)), time.Hour), true},
+	}
+
+	for _, tt := range tests {
+		if got := sameMeeting(review, tt.other); got != tt.want {
+			t.Errorf("%s: got %t, want %t", tt.name, got, tt.want)
+		}
+
+		if got := sameMeeting(tt.other, review); got != tt.want {
+			t.Errorf("%s (swapped): got %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMergeEvents(t *testing.T) {
+	at := func(hour int) time.Time {
+		return time.Date(2030, time.June, 3, hour, 0, 0, 0, time.UTC)
+	}
+
+	event := func(title, source string, hour int) calendars.Event {
+		return calendars.Event{Title: title, StartTime: at(hour), EndTime: at(hour + 1), Source: source}
+	}
+
+	describe := func(events []calendars.Event) string {
+		var parts []string
+		for _, e := range events {
+			parts = append(parts, e.Source+":"+e.Title)
+		}
+
+		return strings.Join(parts, ", ")
+	}
+
+	tests := []struct {
+		name  string
+		lists [][]calendars.Event
+		want  string
+	}{
+		{"one calendar", [][]calendars.Event{{event("Lunch", "a", 12), event("Standup", "a", 9)}}, "a:Lunch, a:Standup"},
+		{"no duplicates", [][]calendars.Event{{event("Lunch", "a", 12)}, {event("Standup", "b", 9)}}, "b:Standup, a:Lunch"},
+		{"first calendar's copy is kept", [][]calendars.Event{{event("Design Review", "a", 10)}, {event("design  review", "b", 10)}}, "a:Design Review"},
+		{"untitled copy", [][]calendars.Event{{event("", "a", 10)}, {event("Design Review", "b", 10)}}, "a:"},
+		{"same time, different meetings", [][]calendars.Event{{event("Design Review", "a", 10)}, {event("Lunch", "b", 10)}}, "a:Design Review, b:Lunch"},
+		{"three calendars", [][]calendars.Event{{event("Standup", "a", 9)}, {event("Standup", "b", 9), event("Lunch", "b", 12)}, {event("LUNCH", "c", 12)}}, "a:Standup, b:Lunch"},
+		{"alike events on one calendar", [][]calendars.Event{{event("", "a", 10), event("", "a", 10)}, {event("Lunch", "b", 12)}}, "a:, a:, b:Lunch"},
+		{"alike events on a later calendar", [][]calendars.Event{{event("Standup", "a", 9)}, {event("Lunch", "b", 12), event("lunch", "b", 12), event("Standup", "b", 9)}}, "a:Standup, b:Lunch, b:lunch"},
+		{"empty calendars", [][]calendars.Event{{}, {}}, ""},
+	}
+
+	for _, tt := range tests {
+		merged := mergeEvents(tt.lists...)
+		if got := describe(merged); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+
+		if merged == nil {
+			t.Errorf("%s: got nil, want an empty list", tt.name)
+		}
+	}
+}
+
+func TestSources(t *testing.T) {
+	describe := func(list []CalendarSource) string {
+		var parts []string
+		for _, s := range list {
+			part := s.Name + "=" + s.URL
+			if s.Bookings {
+				part += " (bookings)"
+			}
+
+			parts = append(parts, part)
+		}
+
+		return strings.Join(parts, ", ")
+	}
+
+	tests := []struct {
+		name   string
+		config Config
+		want   string
+	}{
+		{"calendarURL", Config{CalendarURL: "local://JET-1106"}, "=local://JET-1106 (bookings)"},
+		{"no calendar takes bookings", Config{Calendars: []CalendarSource{{Name: "teamup", URL: "a"}, {Name: "exchange", URL: "b"}}}, "teamup=a (bookings), exchange=b"},
+		{"one calendar takes bookings", Config{Calendars: []CalendarSource{{Name: "teamup", URL: "a"}, {Name: "exchange", URL: "b", Bookings: true}}}, "teamup=a, exchange=b (bookings)"},
+		{"only the first booking calendar takes bookings", Config{Calendars: []CalendarSource{{Name: "teamup", URL: "a"}, {Name: "exchange", URL: "b", Bookings: true}, {Name: "gsuite", URL: "c", Bookings: true}}}, "teamup=a, exchange=b (bookings), gsuite=c"},
+	}
+
+	for _, tt := range tests {
+		if got := describe(tt.config.Sources()); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	config := Config{CalendarURL: "local://JET-1106", FallbackCalendarURLs: []string{"local://JET-1106-backup"}}
+	if booking := config.BookingSource(); booking.URL != "local://JET-1106" || len(booking.FallbackURLs) != 1 {
+		t.Errorf("got booking source %+v", booking)
+	}
+
+	// sources are copies, so the config isn't changed
+	config = Config{Calendars: []CalendarSource{{Name: "teamup", URL: "a"}, {Name: "exchange", URL: "b"}}}
+	config.Sources()
+	if config.Calendars[0].Bookings {
+		t.Errorf("Sources changed the config's calendars")
+	}
+}
+
+func TestReadSources(t *testing.T) {
+	resetSources(t)
+
+	start := time.Date(2030, time.June, 3, 10, 0, 0, 0, time.UTC)
+	review := calendars.Event{Title: "Design Review", StartTime: start, EndTime: start.Add(time.Hour)}
+	lunch := calendars.Event{Title: "Lunch", StartTime: start.Add(2 * time.Hour), EndTime: start.Add(3 * time.Hour)}
+
+	teamup := newCalendarServer(t, review, lunch)
+	exchange := newCalendarServer(t, calendars.Event{Title: " design review ", StartTime: start, EndTime: start.Add(time.Hour)})
+
+	config := Config{ID: "JET-1106", Calendars: []CalendarSource{{Name: "teamup", URL: teamup.URL}, {Name: "exchange", URL: exchange.URL, Bookings: true}}}
+
+	events, read, err := readSources(context.Background(), config)
+	if err != nil {
+		t.Fatalf("unable to read sources: %s", err)
+	}
+
+	if len(events) != 2 || events[0].Source != "teamup" || events[1].Title != "Lunch" {
+		t.Errorf("got events %+v, want teamup's copy of the review and lunch", events)
+	}
+
+	if read.String() != "teamup=primary, exchange=primary" || read.BookingFallback() {
+		t.Errorf("got sources %q, booking fallback %t", read, read.BookingFallback())
+	}
+
+	// a calendar that fails is left out
+	teamup.setDown(true)
+	events, read, err = readSources(context.Background(), config)
+	if err != nil {
+		t.Fatalf("got %s with one calendar down", err)
+	}
+
+	if len(events) != 1 || events[0].Source != "exchange" || events[0].Title != " design review " {
+		t.Errorf("got events %+v, want just exchange's", events)
+	}
+
+	if read.String() != "teamup=unavailable, exchange=primary" || read.BookingFallback() {
+		t.Errorf("got sources %q, booking fallback %t with a calendar that doesn't take bookings down", read, read.BookingFallback())
+	}
+
+	// the calendar bookings are made on failing means the room is on a fallback
+	teamup.setDown(false)
+	exchange.setDown(true)
+	resetSources(t)
+
+	if _, read, err = readSources(context.Background(), config); err != nil || !read.BookingFallback() || !OnFallback("JET-1106") {
+		t.Errorf("got sources %q, error %v, want a booking fallback with the booking calendar down", read, err)
+	}
+
+	teamup.setDown(true)
+	if _, _, err = readSources(context.Background(), config); err == nil {
+		t.Errorf("got no error with every calendar down")
+	}
+}
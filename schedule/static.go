@@ -3,94 +3,335 @@ package schedule
 import (
 	"bytes"
 	"context"
-	"encoding/json"
+	"crypto/md5"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"net/http"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/byuoitav/scheduler/log"
 	"go.uber.org/zap"
 )
 
-type staticDoc struct {
-	ID  string `json:"_id"`
-	Rev string `json:"_rev"`
+// staticDocID is the document holding files shared by every panel
+const staticDocID = "static"
 
-	Attachments map[string]fileAttachment `json:"_attachments"`
-}
+// how long the static document's attachment list is trusted before it's fetched again.
+// changes are picked up immediately when the watcher is running.
+const staticMetadataTTL = time.Minute
+
+// cached bodies are named with this prefix, so the cache directory can be shared with other files
+const staticFilePrefix = "static-"
+
+// errDigestMismatch means a downloaded static file doesn't match the digest couch gave for it
+var errDigestMismatch = errors.New("downloaded file doesn't match its digest")
 
 type fileAttachment struct {
 	ContentType string `json:"content_type"`
 	RevPos      int    `json:"revpos"`
 	Digest      string `json:"digest"`
-	Length      int    `json:"length"`
+	Length      int64  `json:"length"`
 	Stub        bool   `json:"stub"`
 }
 
-func GetStatic(ctx context.Context, docName string) (io.ReadCloser, string, error) {
-	log.P.Info("Getting static document", zap.String("docName", docName))
+// StaticAsset describes a file attached to the static document
+type StaticAsset struct {
+	Name        string `json:"name"`
+	ContentType string `json:"contentType"`
+	Length      int64  `json:"length"`
+
+	// Digest is couch's digest of the file, which changes whenever the file does
+	Digest string `json:"digest"`
+}
+
+// StaticFile is an open static asset. It must be closed when it's no longer needed.
+type StaticFile struct {
+	StaticAsset
 
-	//get 'static' document
-	var static staticDoc
-	url := fmt.Sprintf("%s/%s/%s", os.Getenv("DB_ADDRESS"), "schedulers", "static")
+	// ModTime is when this version of the file was first downloaded
+	ModTime time.Time
 
-	body, err := makeRequest(ctx, "GET", url, "", nil)
+	io.ReadSeekCloser
+}
+
+type staticCache struct {
+	// dir is where file bodies are cached. if it's empty, they're kept in memory.
+	dir string
+
+	mu      sync.Mutex
+	assets  map[string]StaticAsset
+	fetched time.Time
+	bodies  map[string]cachedBody
+}
+
+type cachedBody struct {
+	data    []byte
+	modTime time.Time
+}
+
+var static = &staticCache{
+	bodies: make(map[string]cachedBody),
+}
+
+// SetStaticCacheDir sets the directory static files are cached in. With an empty dir, they're cached in memory.
+func SetStaticCacheDir(dir string) error {
+	if len(dir) > 0 {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("unable to create static cache directory: %w", err)
+		}
+	}
+
+	static.mu.Lock()
+	static.dir = dir
+	static.mu.Unlock()
+
+	return nil
+}
+
+// ListStatic returns every file attached to the static document, sorted by name
+func ListStatic(ctx context.Context) ([]StaticAsset, error) {
+	assets, err := static.metadata(ctx)
 	if err != nil {
-		return nil, "", err
+		return nil, err
+	}
+
+	list := make([]StaticAsset, 0, len(assets))
+	for _, asset := range assets {
+		list = append(list, asset)
 	}
-	defer body.Close()
 
-	b, err := ioutil.ReadAll(body)
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+
+	return list, nil
+}
+
+// OpenStatic opens a file attached to the static document, downloading it only if this version of it isn't already cached
+func OpenStatic(ctx context.Context, name string) (*StaticFile, error) {
+	assets, err := static.metadata(ctx)
 	if err != nil {
-		log.P.Error("failed to read http response body", zap.Error(err))
-		return nil, "", err
+		return nil, err
+	}
+
+	asset, ok := assets[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: static document has no file %q", ErrNotFound, name)
 	}
 
-	err = json.Unmarshal(b, &static)
+	if file, ok := static.open(asset); ok {
+		return file, nil
+	}
+
+	log.P.Info("Downloading static file", zap.String("name", name), zap.String("digest", asset.Digest), zap.Int64("length", asset.Length))
+
+	att, err := getAttachment(ctx, staticDocID, name)
 	if err != nil {
-		log.P.Error("failure to unmarshal resp body", zap.String("body", string(b)), zap.Error(err))
-		return nil, "", err
+		if errors.Is(err, ErrNotFound) {
+			// it was removed since we last looked
+			invalidateStatic()
+		}
+
+		return nil, fmt.Errorf("unable to get static file %q: %w", name, err)
+	}
+
+	if err := verifyDigest(asset.Digest, att.Data); err != nil {
+		// it was probably replaced since we last looked
+		invalidateStatic()
+		return nil, fmt.Errorf("unable to get static file %q: %w", name, err)
+	}
+
+	if err := static.store(asset, att.Data); err != nil {
+		log.P.Warn("unable to cache static file", zap.String("name", name), zap.Error(err))
 	}
 
-	//get file type
-	fileType := static.Attachments[docName].ContentType
-	if fileType == "" {
-		return nil, "", fmt.Errorf("document (%s) does not exist", docName)
+	if file, ok := static.open(asset); ok {
+		return file, nil
 	}
 
-	//get actual file
-	url = fmt.Sprintf("%s/%s/%s/%s", os.Getenv("DB_ADDRESS"), "schedulers", "static", docName)
-	file, err := makeRequest(ctx, "GET", url, "", nil)
+	return &StaticFile{
+		StaticAsset:    asset,
+		ModTime:        time.Now(),
+		ReadSeekCloser: nopCloser{bytes.NewReader(att.Data)},
+	}, nil
+}
 
-	//return stream
-	return file, fileType, nil
+// invalidateStatic makes the next static request fetch the static document's attachment list again
+func invalidateStatic() {
+	static.mu.Lock()
+	static.fetched = time.Time{}
+	static.mu.Unlock()
 }
 
-func makeRequest(ctx context.Context, method, url, contentType string, body []byte) (io.ReadCloser, error) {
-	log.P.Info("making http request", zap.String("dest-url", url))
-	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+// metadata returns the static document's attachments, fetching them if the cached list is stale
+func (s *staticCache) metadata(ctx context.Context) (map[string]StaticAsset, error) {
+	s.mu.Lock()
+	if s.assets != nil && time.Since(s.fetched) < staticMetadataTTL {
+		defer s.mu.Unlock()
+		return s.assets, nil
+	}
+	s.mu.Unlock()
+
+	var doc struct {
+		Attachments map[string]fileAttachment `json:"_attachments"`
+	}
+
+	err := couchRequest(ctx, http.MethodGet, staticDocID, nil, &doc)
+	switch {
+	case errors.Is(err, ErrNotFound):
+	case err != nil:
+		return nil, fmt.Errorf("unable to get static document: %w", err)
+	}
+
+	assets := make(map[string]StaticAsset, len(doc.Attachments))
+	for name, att := range doc.Attachments {
+		assets[name] = StaticAsset{
+			Name:        name,
+			ContentType: att.ContentType,
+			Length:      att.Length,
+			Digest:      att.Digest,
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.assets = assets
+	s.fetched = time.Now()
+	s.prune()
+
+	return assets, nil
+}
+
+// open returns the cached body of asset, if there is one
+func (s *staticCache) open(asset StaticAsset) (*StaticFile, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file := &StaticFile{StaticAsset: asset}
+
+	if len(s.dir) == 0 {
+		body, ok := s.bodies[asset.Digest]
+		if !ok {
+			return nil, false
+		}
+
+		file.ModTime = body.modTime
+		file.ReadSeekCloser = nopCloser{bytes.NewReader(body.data)}
+		return file, true
+	}
+
+	f, err := os.Open(s.path(asset.Digest))
 	if err != nil {
-		log.P.Error("failed to create new http request", zap.String("url", url), zap.Error(err))
-		return nil, err
+		return nil, false
 	}
 
-	req.SetBasicAuth(os.Getenv("DB_USERNAME"), os.Getenv("DB_PASSWORD"))
-	if len(contentType) > 0 {
-		req.Header.Add("Content-Type", contentType)
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, false
+	}
+
+	file.ModTime = info.ModTime()
+	file.ReadSeekCloser = f
+	return file, true
+}
+
+// store caches data as the body of asset
+func (s *staticCache) store(asset StaticAsset, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.dir) == 0 {
+		s.bodies[asset.Digest] = cachedBody{data: data, modTime: time.Now()}
+		return nil
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	// write to a temp file first so a half written file is never served
+	tmp, err := os.CreateTemp(s.dir, staticFilePrefix+"download-*")
 	if err != nil {
-		log.P.Error("failed to make http request", zap.String("url", url), zap.Error(err))
-		return nil, err
+		return err
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
 	}
 
-	if resp.StatusCode/100 != 2 {
-		log.P.Error("bad response code", zap.Int("resp code", resp.StatusCode))
-		return nil, fmt.Errorf("bad response code - %v", resp.StatusCode)
+	return os.Rename(tmp.Name(), s.path(asset.Digest))
+}
+
+// prune removes cached bodies that no longer belong to any asset. Only files this cache wrote are removed. s.mu must be held.
+func (s *staticCache) prune() {
+	digests := make(map[string]bool, len(s.assets))
+	for _, asset := range s.assets {
+		digests[asset.Digest] = true
+	}
+
+	if len(s.dir) == 0 {
+		for digest := range s.bodies {
+			if !digests[digest] {
+				delete(s.bodies, digest)
+			}
+		}
+
+		return
+	}
+
+	keep := make(map[string]bool, len(digests))
+	for digest := range digests {
+		keep[filepath.Base(s.path(digest))] = true
+	}
+
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		log.P.Warn("unable to read static cache directory", zap.Error(err))
+		return
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasPrefix(entry.Name(), staticFilePrefix) && !keep[entry.Name()] {
+			os.Remove(filepath.Join(s.dir, entry.Name()))
+		}
 	}
+}
 
-	return resp.Body, nil
+// path is where the body with digest is cached on disk
+func (s *staticCache) path(digest string) string {
+	// digests are base64, which can have slashes in it
+	return filepath.Join(s.dir, staticFilePrefix+strings.NewReplacer("/", "_", "+", "-").Replace(digest))
 }
+
+// verifyDigest checks data against a couch digest like md5-<base64>. Digests using other algorithms aren't checked.
+func verifyDigest(digest string, data []byte) error {
+	want, ok := strings.CutPrefix(digest, "md5-")
+	if !ok {
+		return nil
+	}
+
+	sum := md5.Sum(data)
+	if got := base64.StdEncoding.EncodeToString(sum[:]); got != want {
+		return fmt.Errorf("%w: got md5-%s, want %s", errDigestMismatch, got, digest)
+	}
+
+	return nil
+}
+
+type nopCloser struct {
+	io.ReadSeeker
+}
+
+func (nopCloser) Close() error { return nil }
@@ -0,0 +1,142 @@
+package schedule
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/byuoitav/scheduler/schedule/scheduletest"
+)
+
+// newStaticCache replaces the static cache with an empty one in dir until the test ends
+func newStaticCache(t *testing.T, dir string) {
+	old := static
+	static = &staticCache{bodies: make(map[string]cachedBody)}
+	t.Cleanup(func() { static = old })
+
+	if err := SetStaticCacheDir(dir); err != nil {
+		t.Fatalf("unable to set static cache dir: %s", err)
+	}
+}
+
+func readStatic(t *testing.T, name string) (string, error) {
+	t.Helper()
+
+	file, err := OpenStatic(context.Background(), name)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	b, err := io.ReadAll(file)
+	if err != nil {
+		t.Fatalf("unable to read %s: %s", name, err)
+	}
+
+	return string(b), nil
+}
+
+func TestStaticPrune(t *testing.T) {
+	couch := scheduletest.NewCouch(t)
+	t.Setenv("DB_ADDRESS", couch.URL)
+
+	dir := t.TempDir()
+	newStaticCache(t, dir)
+
+	// files the cache didn't write are left alone
+	for _, name := range []string{"notes.txt", "md5-abc"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("mine"), 0o600); err != nil {
+			t.Fatalf("unable to write %s: %s", name, err)
+		}
+	}
+
+	couch.PutAttachment("static", "logo.png", "image/png", []byte("v1"))
+	if body, err := readStatic(t, "logo.png"); err != nil || body != "v1" {
+		t.Fatalf("got %q, %v, want v1", body, err)
+	}
+
+	cached := func() []string {
+		entries, _ := filepath.Glob(filepath.Join(dir, staticFilePrefix+"*"))
+		return entries
+	}
+
+	if files := cached(); len(files) != 1 {
+		t.Fatalf("got cached files %v, want one", files)
+	}
+
+	// replacing the file prunes the old version once the new list is fetched
+	couch.PutAttachment("static", "logo.png", "image/png", []byte("v2"))
+	invalidateStatic()
+
+	if body, err := readStatic(t, "logo.png"); err != nil || body != "v2" {
+		t.Fatalf("got %q, %v, want v2", body, err)
+	}
+
+	if files := cached(); len(files) != 1 || !strings.HasSuffix(files[0], filepath.Base(static.path(couchDigest("v2")))) {
+		t.Errorf("got cached files %v, want just v2", files)
+	}
+
+	for _, name := range []string{"notes.txt", "md5-abc"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("%s was removed: %s", name, err)
+		}
+	}
+}
+
+func TestStaticDigest(t *testing.T) {
+	couch := scheduletest.NewCouch(t)
+	t.Setenv("DB_ADDRESS", couch.URL)
+
+	for _, dir := range []string{"", t.TempDir()} {
+		newStaticCache(t, dir)
+
+		couch.PutAttachment("static", "logo.png", "image/png", []byte("v1"))
+		if _, err := ListStatic(context.Background()); err != nil {
+			t.Fatalf("unable to list static files: %s", err)
+		}
+
+		// the file changes after its digest was fetched, so what's downloaded doesn't match it
+		couch.PutAttachment("static", "logo.png", "image/png", []byte("v2"))
+		if _, err := readStatic(t, "logo.png"); !errors.Is(err, errDigestMismatch) {
+			t.Errorf("dir %q: got %v, want a digest mismatch", dir, err)
+		}
+
+		if _, ok := static.open(StaticAsset{Digest: couchDigest("v1")}); ok {
+			t.Errorf("dir %q: mismatched file was cached", dir)
+		}
+
+		// the mismatch refetches the list, so the next request gets the new version
+		if body, err := readStatic(t, "logo.png"); err != nil || body != "v2" {
+			t.Errorf("dir %q: got %q, %v, want v2", dir, body, err)
+		}
+	}
+}
+
+func TestVerifyDigest(t *testing.T) {
+	// XUFAKrxLKna5cZ2REBfFkg== is the md5 of "hello"
+	digest := "md5-XUFAKrxLKna5cZ2REBfFkg=="
+
+	if err := verifyDigest(digest, []byte("hello")); err != nil {
+		t.Errorf("got %s verifying a matching digest", err)
+	}
+
+	if err := verifyDigest(digest, []byte("goodbye")); !errors.Is(err, errDigestMismatch) {
+		t.Errorf("got %v, want a digest mismatch", err)
+	}
+
+	if err := verifyDigest("sha1-abc", []byte("hello")); err != nil {
+		t.Errorf("got %s verifying an unknown digest algorithm", err)
+	}
+}
+
+// couchDigest returns couch's digest for data
+func couchDigest(data string) string {
+	sum := md5.Sum([]byte(data))
+	return "md5-" + base64.StdEncoding.EncodeToString(sum[:])
+}
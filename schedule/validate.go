@@ -0,0 +1,219 @@
+package schedule
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ValidationError lists everything wrong with a config
+type ValidationError struct {
+	ID       string   `json:"id"`
+	Problems []string `json:"problems"`
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid config %q: %s", e.ID, strings.Join(e.Problems, "; "))
+}
+
+// notifierTypes are the valid NotifierConfig types
+var notifierTypes = map[string]bool{
+	"webhook": true,
+	"email":   true,
+	"slack":   true,
+	"teams":   true,
+}
+
+// ValidateConfig checks that config has everything a panel needs. If checkURLs is true,
+// it also makes sure the calendar url answers. The returned error is a *ValidationError.
+func ValidateConfig(ctx context.Context, config Config, checkURLs bool) error {
+	verr := &ValidationError{ID: config.ID}
+	problem := func(format string, a ...interface{}) {
+		verr.Problems = append(verr.Problems, fmt.Sprintf(format, a...))
+	}
+
+	switch {
+	case len(config.ID) == 0:
+		problem("_id is required")
+	case !IsRoomConfig(config.ID) || strings.ContainsAny(config.ID, "/?#% "):
+		problem("_id %q is not a valid room id", config.ID)
+	}
+
+	if len(strings.TrimSpace(config.DisplayName)) == 0 {
+		problem("displayName is required")
+	}
+
+	// calendar urls that have to answer when checkURLs is set, by field
+	type calendarURL struct{ field, url string }
+	var reachable []calendarURL
+
+	if len(config.Calendars) == 0 {
+		if len(config.CalendarURL) == 0 {
+			problem("calendarURL is required")
+		} else if err := validateCalendarURL(config.CalendarURL); err != nil {
+			problem("calendarURL: %s", err)
+		} else {
+			reachable = append(reachable, calendarURL{"calendarURL", config.CalendarURL})
+		}
+
+		for i, u := range config.FallbackCalendarURLs {
+			if err := validateCalendarURL(u); err != nil {
+				problem("fallbackCalendarURLs[%d]: %s", i, err)
+			}
+		}
+	} else {
+		if len(config.CalendarURL) > 0 || len(config.FallbackCalendarURLs) > 0 {
+			problem("calendarURL and fallbackCalendarURLs can't be used with calendars")
+		}
+
+		names := make(map[string]bool)
+		bookings := 0
+
+		for i, cal := range config.Calendars {
+			switch {
+			case len(cal.Name) == 0:
+				problem("calendars[%d]: name is required", i)
+			case names[cal.Name]:
+				problem("calendars[%d]: name %q is used more than once", i, cal.Name)
+			}
+
+			names[cal.Name] = true
+
+			if cal.Bookings {
+				bookings++
+			}
+
+			if err := validateCalendarURL(cal.URL); err != nil {
+				problem("calendars[%d]: url: %s", i, err)
+			} else {
+				reachable = append(reachable, calendarURL{fmt.Sprintf("calendars[%d].url", i), cal.URL})
+			}
+
+			for j, u := range cal.FallbackURLs {
+				if err := validateCalendarURL(u); err != nil {
+					problem("calendars[%d]: fallbackURLs[%d]: %s", i, j, err)
+				}
+			}
+		}
+
+		if bookings > 1 {
+			problem("only one calendar can take bookings")
+		}
+	}
+
+	if len(config.ImageURL) > 0 {
+		if err := validateURL(config.ImageURL); err != nil {
+			problem("image-url: %s", err)
+		}
+	}
+
+	if len(config.StyleURL) > 0 {
+		if err := validateURL(config.StyleURL); err != nil {
+			problem("style-url: %s", err)
+		}
+	}
+
+	if len(config.Timezone) > 0 {
+		if _, err := time.LoadLocation(config.Timezone); err != nil {
+			problem("timezone %q is not a valid IANA timezone", config.Timezone)
+		}
+	}
+
+	if len(config.Locale) > 0 && !validLocale.MatchString(config.Locale) {
+		problem("locale %q is not a valid language tag, like en-US", config.Locale)
+	}
+
+	if len(config.Clock) > 0 && config.Clock != Clock12Hour && config.Clock != Clock24Hour {
+		problem("clock must be %s or %s", Clock12Hour, Clock24Hour)
+	}
+
+	if config.Backgrounds != nil {
+		for _, p := range config.Backgrounds.Validate() {
+			problem("backgrounds: %s", p)
+		}
+	}
+
+	for i, n := range config.HelpNotifiers {
+		switch {
+		case !notifierTypes[n.Type]:
+			problem("helpNotifiers[%d]: unknown type %q", i, n.Type)
+		case n.Type == "email" && (len(n.SMTPAddress) == 0 || len(n.From) == 0 || len(n.To) == 0):
+			problem("helpNotifiers[%d]: email notifiers need smtpAddress, from, and to", i)
+		case n.Type == "email" && !hasPort(n.SMTPAddress):
+			problem("helpNotifiers[%d]: smtpAddress must look like host:port", i)
+		case n.Type != "email" && len(n.URL) == 0:
+			problem("helpNotifiers[%d]: url is required", i)
+		}
+	}
+
+	if checkURLs {
+		for _, u := range reachable {
+			if err := checkReachable(ctx, u.url); err != nil {
+				problem("%s is unreachable: %s", u.field, err)
+			}
+		}
+	}
+
+	if len(verr.Problems) > 0 {
+		return verr
+	}
+
+	return nil
+}
+
+func validateURL(raw string) error {
+	u, err := url.Parse(raw)
+	switch {
+	case err != nil:
+		return err
+	case u.Scheme != "http" && u.Scheme != "https":
+		return fmt.Errorf("%q must be an http or https url", raw)
+	case len(u.Host) == 0:
+		return fmt.Errorf("%q is missing a host", raw)
+	}
+
+	return nil
+}
+
+// checkReachable makes sure a GET to url gets a 2xx response, or that an in-process calendar can be read
+func checkReachable(ctx context.Context, url string) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if isInproc(url) {
+		cal, err := inprocCalendar(url, time.Local)
+		if err != nil {
+			return err
+		}
+
+		_, err = cal.GetEvents(ctx)
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("bad response (%v)", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// hasPort returns true if addr looks like host:port
+func hasPort(addr string) bool {
+	_, port, err := net.SplitHostPort(addr)
+	return err == nil && len(port) > 0
+}
@@ -0,0 +1,114 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestValidateConfig(t *testing.T) {
+	valid := func(change func(*Config)) Config {
+		config := Config{ID: "JET-1106", DisplayName: "The JET", CalendarURL: "http://localhost:11002/JET_1106@calendar.com/events"}
+		if change != nil {
+			change(&config)
+		}
+
+		return config
+	}
+
+	tests := []struct {
+		name   string
+		config Config
+		want   []string
+	}{
+		{name: "valid", config: valid(nil)},
+		{name: "local calendar", config: valid(func(c *Config) { c.CalendarURL = "local://JET-1106" })},
+		{name: "merged calendars", config: valid(func(c *Config) {
+			c.CalendarURL = ""
+			c.Calendars = []CalendarSource{{Name: "teamup", URL: "http://localhost:11003/abc/events"}, {Name: "exchange", URL: "local://JET-1106", Bookings: true}}
+		})},
+		{name: "missing fields", config: Config{}, want: []string{"_id is required", "displayName is required", "calendarURL is required"}},
+		{name: "reserved id", config: valid(func(c *Config) { c.ID = "_design/rooms" }), want: []string{"is not a valid room id"}},
+		{name: "id with a space", config: valid(func(c *Config) { c.ID = "JET 1106" }), want: []string{"is not a valid room id"}},
+		{name: "blank display name", config: valid(func(c *Config) { c.DisplayName = "  " }), want: []string{"displayName is required"}},
+		{name: "ftp calendar", config: valid(func(c *Config) { c.CalendarURL = "ftp://calendars.byu.edu/JET-1106" }), want: []string{"calendarURL:", "must be an http or https url"}},
+		{name: "unknown backend", config: valid(func(c *Config) { c.CalendarURL = "inproc://sundial/JET-1106" }), want: []string{`calendar backend "sundial" isn't linked into the scheduler`}},
+		{name: "bad fallback", config: valid(func(c *Config) { c.FallbackCalendarURLs = []string{"calendars.byu.edu"} }), want: []string{"fallbackCalendarURLs[0]:"}},
+		{name: "calendarURL with calendars", config: valid(func(c *Config) {
+			c.Calendars = []CalendarSource{{Name: "teamup", URL: "http://localhost:11003/abc/events"}}
+		}), want: []string{"calendarURL and fallbackCalendarURLs can't be used with calendars"}},
+		{name: "calendar names", config: valid(func(c *Config) {
+			c.CalendarURL = ""
+			c.Calendars = []CalendarSource{{URL: "local://JET-1106"}, {Name: "a", URL: "local://JET-1106"}, {Name: "a", URL: "local://JET-1106"}}
+		}), want: []string{"calendars[0]: name is required", `calendars[2]: name "a" is used more than once`}},
+		{name: "two booking calendars", config: valid(func(c *Config) {
+			c.CalendarURL = ""
+			c.Calendars = []CalendarSource{{Name: "a", URL: "local://JET-1106", Bookings: true}, {Name: "b", URL: "local://JET-1106", Bookings: true}}
+		}), want: []string{"only one calendar can take bookings"}},
+		{name: "bad image url", config: valid(func(c *Config) { c.ImageURL = "/bg.png" }), want: []string{"image-url:"}},
+		{name: "timezone", config: valid(func(c *Config) { c.Timezone = "Mountain Time" }), want: []string{`timezone "Mountain Time" is not a valid IANA timezone`}},
+		{name: "locale", config: valid(func(c *Config) { c.Locale = "english" }), want: []string{`locale "english" is not a valid language tag`}},
+		{name: "clock", config: valid(func(c *Config) { c.Clock = "military" }), want: []string{"clock must be 12h or 24h"}},
+		{name: "notifiers", config: valid(func(c *Config) {
+			c.HelpNotifiers = []NotifierConfig{
+				{Type: "pager"},
+				{Type: "email", SMTPAddress: "smtp.byu.edu:25"},
+				{Type: "email", SMTPAddress: "smtp.byu.edu", From: "a@byu.edu", To: []string{"b@byu.edu"}},
+				{Type: "slack"},
+			}
+		}), want: []string{
+			`helpNotifiers[0]: unknown type "pager"`,
+			"helpNotifiers[1]: email notifiers need smtpAddress, from, and to",
+			"helpNotifiers[2]: smtpAddress must look like host:port",
+			"helpNotifiers[3]: url is required",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateConfig(context.Background(), tt.config, false)
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("got %s validating a valid config", err)
+				}
+
+				return
+			}
+
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("got %v, want a *ValidationError", err)
+			}
+
+			problems := strings.Join(verr.Problems, "\n")
+			for _, want := range tt.want {
+				if !strings.Contains(problems, want) {
+					t.Errorf("problems are missing %q:\n%s", want, problems)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateConfigCheckURLs(t *testing.T) {
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer up.Close()
+
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer down.Close()
+
+	config := Config{ID: "JET-1106", DisplayName: "The JET", CalendarURL: up.URL}
+	if err := ValidateConfig(context.Background(), config, true); err != nil {
+		t.Errorf("got %s checking a calendar that answers", err)
+	}
+
+	config.CalendarURL = down.URL
+	if err := ValidateConfig(context.Background(), config, true); err == nil || !strings.Contains(err.Error(), "calendarURL is unreachable: bad response (502)") {
+		t.Errorf("got %v checking a calendar that doesn't answer", err)
+	}
+}
@@ -0,0 +1,398 @@
+package schedule
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/byuoitav/scheduler/log"
+	"go.uber.org/zap"
+)
+
+var (
+	minReconnectDelay = time.Second
+	maxReconnectDelay = time.Minute
+
+	// feedHeartbeat is how often couch is asked to write to an idle continuous feed. A feed that's
+	// been silent for two heartbeats is assumed to be dead, and is reconnected.
+	feedHeartbeat = 30 * time.Second
+)
+
+// ConfigChange is sent to subscribers when a room config is changed or deleted
+type ConfigChange struct {
+	ID      string `json:"id"`
+	Rev     string `json:"rev"`
+	Deleted bool   `json:"deleted"`
+}
+
+type change struct {
+	Seq     json.RawMessage `json:"seq"`
+	ID      string          `json:"id"`
+	Deleted bool            `json:"deleted"`
+	Doc     json.RawMessage `json:"doc"`
+}
+
+// Watcher follows the schedulers database's _changes feed to keep an up to date copy of every room config
+type Watcher struct {
+	// path is where the configs and the sequence they're current as of are saved, so a restart
+	// only has to catch up on what changed while the scheduler was down
+	path string
+
+	mu      sync.RWMutex
+	configs map[string]Config
+	since   string
+	synced  bool
+
+	subsMu sync.Mutex
+	subs   map[chan ConfigChange]struct{}
+}
+
+var watcher *Watcher
+
+// StartWatcher starts following the _changes feed in the background. Once it has caught up,
+// GetConfig is answered from memory instead of making a request to couch. The feed is resumed from
+// the checkpoint saved at path, if there is one. An empty path keeps the checkpoint in memory only.
+func StartWatcher(ctx context.Context, path string) {
+	w := newWatcher(path)
+	if err := w.load(); err != nil {
+		log.P.Warn("unable to load config checkpoint, reading every config from couch", zap.String("path", path), zap.Error(err))
+	}
+
+	watcher = w
+	go w.run(ctx)
+}
+
+func newWatcher(path string) *Watcher {
+	return &Watcher{
+		path:    path,
+		configs: make(map[string]Config),
+		since:   "0",
+		subs:    make(map[chan ConfigChange]struct{}),
+	}
+}
+
+// Subscribe returns a channel that receives every config change, and a function to stop receiving them.
+// Changes are dropped for subscribers that aren't keeping up.
+func Subscribe() (<-chan ConfigChange, func()) {
+	ch := make(chan ConfigChange, 16)
+	if watcher == nil {
+		return ch, func() {}
+	}
+
+	watcher.subsMu.Lock()
+	watcher.subs[ch] = struct{}{}
+	watcher.subsMu.Unlock()
+
+	return ch, func() {
+		watcher.subsMu.Lock()
+		delete(watcher.subs, ch)
+		watcher.subsMu.Unlock()
+	}
+}
+
+// cachedConfig returns the watcher's copy of a config, if it has one
+func cachedConfig(roomID string) (Config, bool) {
+	if watcher == nil {
+		return Config{}, false
+	}
+
+	watcher.mu.RLock()
+	defer watcher.mu.RUnlock()
+
+	if !watcher.synced {
+		return Config{}, false
+	}
+
+	config, ok := watcher.configs[roomID]
+	return config, ok
+}
+
+func (w *Watcher) run(ctx context.Context) {
+	delay := minReconnectDelay
+
+	for {
+		err := w.follow(ctx)
+
+		// configs are read from couch until the feed has caught up again
+		w.mu.Lock()
+		connected := w.synced
+		w.synced = false
+		w.mu.Unlock()
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err != nil {
+			log.P.Warn("lost config changes feed, reconnecting", zap.Error(err), zap.Duration("in", delay))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+
+		// back off while couch is unavailable, but reconnect quickly if we just lost a working feed
+		if connected {
+			delay = minReconnectDelay
+		} else if delay *= 2; delay > maxReconnectDelay {
+			delay = maxReconnectDelay
+		}
+	}
+}
+
+// follow catches up on changes since the last checkpoint, then streams new changes until the feed ends
+func (w *Watcher) follow(ctx context.Context) error {
+	w.mu.RLock()
+	since := w.since
+	w.mu.RUnlock()
+
+	// catch up first, so we know when the cache is complete
+	var catchUp struct {
+		Results []change        `json:"results"`
+		LastSeq json.RawMessage `json:"last_seq"`
+	}
+
+	if err := couchRequest(ctx, http.MethodGet, "_changes?include_docs=true&since="+url.QueryEscape(since), nil, &catchUp); err != nil {
+		return fmt.Errorf("unable to get changes: %w", err)
+	}
+
+	for _, c := range catchUp.Results {
+		w.apply(c)
+	}
+
+	w.checkpoint(catchUp.LastSeq)
+	w.setSynced(true)
+
+	w.mu.RLock()
+	since = w.since
+	count := len(w.configs)
+	w.mu.RUnlock()
+
+	log.P.Info("Watching config changes", zap.String("since", since), zap.Int("configs", count))
+
+	// then stream changes as they happen
+	reqURL := fmt.Sprintf("%s/%s/_changes?feed=continuous&include_docs=true&heartbeat=%d&since=%s", os.Getenv("DB_ADDRESS"), database, feedHeartbeat.Milliseconds(), url.QueryEscape(since))
+
+	// a connection that silently died would otherwise leave us waiting forever with a stale cache
+	feedCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	idleTimeout := 2 * feedHeartbeat
+	idle := time.AfterFunc(idleTimeout, cancel)
+	defer idle.Stop()
+
+	req, err := http.NewRequestWithContext(feedCtx, http.MethodGet, reqURL, nil)
+	if err != nil {
+		return err
+	}
+
+	req.SetBasicAuth(os.Getenv("DB_USERNAME"), os.Getenv("DB_PASSWORD"))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("bad response from changes feed (%v)", resp.StatusCode)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	for scanner.Scan() {
+		idle.Reset(idleTimeout)
+
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue // heartbeat
+		}
+
+		var c change
+		if err := json.Unmarshal(line, &c); err != nil {
+			log.P.Warn("unable to parse config change", zap.ByteString("line", line), zap.Error(err))
+			continue
+		}
+
+		// the feed ends with just the last seq when couch closes it
+		if len(c.ID) == 0 {
+			continue
+		}
+
+		w.apply(c)
+		w.checkpoint(c.Seq)
+	}
+
+	if feedCtx.Err() != nil && ctx.Err() == nil {
+		return fmt.Errorf("changes feed was idle for %s", idleTimeout)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	return fmt.Errorf("changes feed closed")
+}
+
+// apply updates the cache with c and tells subscribers about it
+func (w *Watcher) apply(c change) {
+	if c.ID == staticDocID {
+		invalidateStatic()
+	}
+
+	if !IsRoomConfig(c.ID) {
+		return
+	}
+
+	cc := ConfigChange{ID: c.ID, Deleted: c.Deleted}
+
+	w.mu.Lock()
+	if c.Deleted {
+		delete(w.configs, c.ID)
+	} else {
+		var config Config
+		if err := json.Unmarshal(c.Doc, &config); err != nil {
+			w.mu.Unlock()
+			log.P.Warn("unable to parse changed config", zap.String("id", c.ID), zap.Error(err))
+			return
+		}
+
+		if old, ok := w.configs[c.ID]; ok && old.Rev == config.Rev {
+			w.mu.Unlock()
+			return
+		}
+
+		w.configs[c.ID] = config
+		cc.Rev = config.Rev
+	}
+	w.mu.Unlock()
+
+	log.P.Debug("Config changed", zap.String("id", cc.ID), zap.String("rev", cc.Rev), zap.Bool("deleted", cc.Deleted))
+
+	w.subsMu.Lock()
+	defer w.subsMu.Unlock()
+
+	for ch := range w.subs {
+		select {
+		case ch <- cc:
+		default:
+		}
+	}
+}
+
+// checkpoint records the sequence to resume from after a reconnect or restart
+func (w *Watcher) checkpoint(seq json.RawMessage) {
+	if len(seq) == 0 {
+		return
+	}
+
+	// couch 2+ uses opaque string sequences, 1.x uses numbers
+	var s string
+	if err := json.Unmarshal(seq, &s); err != nil {
+		s = string(seq)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.since == s {
+		return
+	}
+
+	w.since = s
+	w.save()
+}
+
+// checkpointFile is what the watcher saves to its path
+type checkpointFile struct {
+	Since   string            `json:"since"`
+	Configs map[string]Config `json:"configs"`
+}
+
+// load reads the checkpoint saved at w.path, if there is one
+func (w *Watcher) load() error {
+	if len(w.path) == 0 {
+		return nil
+	}
+
+	b, err := os.ReadFile(w.path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return nil
+	case err != nil:
+		return err
+	}
+
+	var file checkpointFile
+	if err := json.Unmarshal(b, &file); err != nil {
+		return fmt.Errorf("unable to parse %s: %w", w.path, err)
+	}
+
+	if len(file.Since) == 0 || file.Configs == nil {
+		return fmt.Errorf("%s is missing since or configs", w.path)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.since = file.Since
+	w.configs = file.Configs
+	return nil
+}
+
+// save writes the configs and the sequence they're current as of to w.path. w.mu must be held.
+func (w *Watcher) save() {
+	if len(w.path) == 0 {
+		return
+	}
+
+	b, err := json.Marshal(checkpointFile{Since: w.since, Configs: w.configs})
+	if err != nil {
+		log.P.Warn("unable to marshal config checkpoint", zap.Error(err))
+		return
+	}
+
+	// write to a temp file first so a crash never leaves a half written checkpoint. configs can hold
+	// notifier credentials, so only the scheduler's user can read it.
+	tmp, err := os.CreateTemp(filepath.Dir(w.path), filepath.Base(w.path)+".*")
+	if err != nil {
+		log.P.Warn("unable to save config checkpoint", zap.String("path", w.path), zap.Error(err))
+		return
+	}
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		log.P.Warn("unable to save config checkpoint", zap.String("path", w.path), zap.Error(err))
+		return
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		log.P.Warn("unable to save config checkpoint", zap.String("path", w.path), zap.Error(err))
+		return
+	}
+
+	if err := os.Rename(tmp.Name(), w.path); err != nil {
+		os.Remove(tmp.Name())
+		log.P.Warn("unable to save config checkpoint", zap.String("path", w.path), zap.Error(err))
+	}
+}
+
+func (w *Watcher) setSynced(synced bool) {
+	w.mu.Lock()
+	w.synced = synced
+	w.mu.Unlock()
+}
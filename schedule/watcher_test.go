@@ -0,0 +1,210 @@
+package schedule
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/byuoitav/scheduler/schedule/scheduletest"
+)
+
+// waitFor fails the test if cond isn't true within a few seconds
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	for deadline := time.Now().Add(5 * time.Second); !cond(); time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+	}
+}
+
+// startWatcher starts a watcher that is stopped when the test ends
+func startWatcher(t *testing.T, path string) (*Watcher, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	StartWatcher(ctx, path)
+	t.Cleanup(func() { watcher = nil })
+
+	return watcher, cancel
+}
+
+func (w *Watcher) isSynced() bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	return w.synced
+}
+
+func (w *Watcher) config(id string) (Config, bool) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	config, ok := w.configs[id]
+	return config, ok
+}
+
+func TestWatcher(t *testing.T) {
+	minReconnectDelay, maxReconnectDelay = 10*time.Millisecond, 50*time.Millisecond
+	t.Cleanup(func() { minReconnectDelay, maxReconnectDelay = time.Second, time.Minute })
+
+	couch := scheduletest.NewCouch(t)
+	t.Setenv("DB_ADDRESS", couch.URL)
+
+	couch.Put(t, Config{ID: "JET-1106", DisplayName: "The JET"})
+	couch.Put(t, Config{ID: "ITB-1010", DisplayName: "ITB 1010"})
+
+	w, _ := startWatcher(t, "")
+	waitFor(t, "the watcher to catch up", w.isSynced)
+
+	if config, ok := cachedConfig("JET-1106"); !ok || config.DisplayName != "The JET" {
+		t.Fatalf("got %+v, %t from the cache", config, ok)
+	}
+
+	changes, unsubscribe := Subscribe()
+	defer unsubscribe()
+
+	next := func() ConfigChange {
+		t.Helper()
+
+		select {
+		case change := <-changes:
+			return change
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for a config change")
+			return ConfigChange{}
+		}
+	}
+
+	rev := couch.Put(t, Config{ID: "JET-1106", DisplayName: "The JET (renamed)"})
+	if change := next(); change.ID != "JET-1106" || change.Rev != rev || change.Deleted {
+		t.Errorf("got change %+v after an update", change)
+	}
+
+	if config, _ := cachedConfig("JET-1106"); config.DisplayName != "The JET (renamed)" {
+		t.Errorf("got %+v from the cache after an update", config)
+	}
+
+	couch.Delete("ITB-1010")
+	if change := next(); change.ID != "ITB-1010" || !change.Deleted {
+		t.Errorf("got change %+v after a delete", change)
+	}
+
+	if _, ok := w.config("ITB-1010"); ok {
+		t.Errorf("deleted config is still cached")
+	}
+
+	// the static document isn't a room
+	couch.PutAttachment("static", "logo.png", "image/png", []byte("png"))
+	couch.Put(t, Config{ID: "ITB-1011", DisplayName: "ITB 1011"})
+	if change := next(); change.ID != "ITB-1011" {
+		t.Errorf("got change %+v, want ITB-1011", change)
+	}
+
+	// configs come from couch while the feed is down
+	couch.SetDown(true)
+	waitFor(t, "the watcher to notice the feed dropped", func() bool { return !w.isSynced() })
+
+	if _, ok := cachedConfig("JET-1106"); ok {
+		t.Errorf("cache was used while the feed was down")
+	}
+
+	couch.SetDown(false)
+	waitFor(t, "the watcher to reconnect", w.isSynced)
+
+	couch.Put(t, Config{ID: "ITB-1012", DisplayName: "ITB 1012"})
+	if change := next(); change.ID != "ITB-1012" {
+		t.Errorf("got change %+v after reconnecting, want ITB-1012", change)
+	}
+}
+
+func TestWatcherIdleFeed(t *testing.T) {
+	minReconnectDelay, feedHeartbeat = 10*time.Millisecond, 50*time.Millisecond
+	t.Cleanup(func() { minReconnectDelay, feedHeartbeat = time.Second, 30*time.Second })
+
+	couch := scheduletest.NewCouch(t)
+	t.Setenv("DB_ADDRESS", couch.URL)
+
+	couch.Put(t, Config{ID: "JET-1106", DisplayName: "The JET"})
+
+	w, _ := startWatcher(t, "")
+	waitFor(t, "the watcher to catch up", w.isSynced)
+
+	// heartbeats keep a quiet feed open
+	time.Sleep(5 * feedHeartbeat)
+	if !w.isSynced() {
+		t.Fatalf("watcher dropped a feed that was sending heartbeats")
+	}
+
+	// a feed that goes silent never delivers this change, so it's only seen by reconnecting
+	couch.Stall(true)
+	couch.Put(t, Config{ID: "JET-1106", DisplayName: "The JET (renamed)"})
+
+	waitFor(t, "the watcher to reconnect to the idle feed", func() bool {
+		config, _ := w.config("JET-1106")
+		return config.DisplayName == "The JET (renamed)"
+	})
+
+	couch.Stall(false)
+	waitFor(t, "the watcher to catch up", w.isSynced)
+}
+
+func TestWatcherCheckpoint(t *testing.T) {
+	couch := scheduletest.NewCouch(t)
+	t.Setenv("DB_ADDRESS", couch.URL)
+
+	couch.Put(t, Config{ID: "JET-1106", DisplayName: "The JET"})
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+
+	w, stop := startWatcher(t, path)
+	waitFor(t, "the watcher to catch up", w.isSynced)
+	stop()
+
+	var file checkpointFile
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("checkpoint wasn't saved: %s", err)
+	}
+
+	if err := json.Unmarshal(b, &file); err != nil {
+		t.Fatalf("unable to parse checkpoint: %s", err)
+	}
+
+	if file.Since != "1" || file.Configs["JET-1106"].DisplayName != "The JET" {
+		t.Fatalf("got checkpoint %+v", file)
+	}
+
+	// a restart only catches up on what changed since the checkpoint, so the checkpoint's copy of JET-1106
+	// is kept even though its revision doesn't match couch's
+	jet := file.Configs["JET-1106"]
+	jet.Rev, jet.DisplayName = "1-checkpoint", "From The Checkpoint"
+	file.Configs["JET-1106"] = jet
+
+	b, _ = json.Marshal(file)
+	if err := os.WriteFile(path, b, 0o600); err != nil {
+		t.Fatalf("unable to write checkpoint: %s", err)
+	}
+
+	couch.Put(t, Config{ID: "ITB-1010", DisplayName: "ITB 1010"})
+
+	w, _ = startWatcher(t, path)
+	waitFor(t, "the watcher to catch up", w.isSynced)
+
+	if config, _ := w.config("JET-1106"); config.DisplayName != "From The Checkpoint" {
+		t.Errorf("got %+v, want the checkpoint's copy", config)
+	}
+
+	if _, ok := w.config("ITB-1010"); !ok {
+		t.Errorf("didn't catch up on changes made after the checkpoint")
+	}
+
+	// a checkpoint that can't be read is ignored
+	os.WriteFile(path, []byte("{"), 0o600)
+	if err := newWatcher(path).load(); err == nil {
+		t.Errorf("got no error loading a corrupt checkpoint")
+	}
+}
@@ -1,16 +1,20 @@
 package main
 
 import (
+	"context"
 	"embed"
 	"errors"
 	"fmt"
 	"io/fs"
-	"net/http"
 	"time"
+	_ "time/tzdata" // rooms can be in any timezone, whether or not the host has tzdata
 
+	_ "github.com/byuoitav/scheduler/calendars/fake" // rooms can use inproc://fake/<roomID> calendars
+	"github.com/byuoitav/scheduler/clock"
 	"github.com/byuoitav/scheduler/handlers"
+	"github.com/byuoitav/scheduler/identity"
 	"github.com/byuoitav/scheduler/log"
-	"github.com/gin-gonic/gin"
+	"github.com/byuoitav/scheduler/schedule"
 	"github.com/spf13/pflag"
 	"go.uber.org/zap"
 )
@@ -23,37 +27,80 @@ var (
 func main() {
 	var port int
 	var logLevelStr string
+	var eventQueueFile string
+	var eventMaxAttempts int
+	var helpCooldown time.Duration
+	var identityOpts identity.Options
+	var watchConfig bool
+	var configCheckpointFile string
+	var staticCacheDir string
+	var timezone, locale string
+	var fakeTime string
 
 	pflag.IntVarP(&port, "port", "p", 80, "port to run the server on")
 	pflag.StringVarP(&logLevelStr, "log-level", "l", "info", "level of logging wanted. debug, info, warn, error, panic")
+	pflag.StringVar(&eventQueueFile, "event-queue-file", "event-queue.json", "file to persist undelivered events to. empty keeps them in memory only")
+	pflag.IntVar(&eventMaxAttempts, "event-max-attempts", handlers.DefaultMaxDeliveryAttempts, "number of times to try delivering an event before giving up on it")
+	pflag.DurationVar(&helpCooldown, "help-cooldown", schedule.DefaultHelpCooldown, "how long repeated help requests from the same device are suppressed")
+	pflag.StringVar(&identityOpts.SystemID, "system-id", "", "id of this device. defaults to SYSTEM_ID")
+	pflag.StringVar(&identityOpts.Scheme, "id-scheme", identity.DefaultScheme, "how to split the system id into building, room, and device. building-room-device, building-room, or a regular expression with named groups")
+	pflag.StringVar(&identityOpts.RoomID, "room-id", "", "room to serve, instead of the one in the system id")
+	pflag.StringSliceVar(&identityOpts.Rooms, "rooms", nil, "additional rooms served by a multi-room panel")
+	pflag.BoolVar(&identityOpts.MultiTenant, "multi-tenant", false, "serve any room, chosen per request by room/device parameter or host name")
+	pflag.StringVar(&identityOpts.RoomPattern, "room-pattern", "", "regular expression limiting which rooms a multi-tenant server serves")
+	pflag.BoolVar(&watchConfig, "watch-config", true, "follow the couch changes feed to keep room configs in memory and tell panels to reload when they change")
+	pflag.StringVar(&configCheckpointFile, "config-checkpoint-file", "config-checkpoint.json", "file to save watched room configs to, so a restart resumes the changes feed. empty keeps them in memory only")
+	pflag.StringVar(&staticCacheDir, "static-cache-dir", "static-cache", "directory to cache files from the static document in. empty keeps them in memory only")
+	pflag.StringVar(&timezone, "timezone", "America/Denver", "timezone of rooms that don't set their own")
+	pflag.StringVar(&locale, "locale", "en-US", "locale of rooms that don't set their own")
+	pflag.StringVar(&fakeTime, "fake-time", "", "start the server's clock at this time (RFC3339, or now) and let it be moved with /admin/clock. for testing and demos only")
 	pflag.Parse()
 
-	setLog := func(levelStr string) error {
-		switch levelStr {
-		case "debug":
-			fmt.Printf("\nSetting log level to *debug*\n\n")
-			log.Config.Level.SetLevel(zap.DebugLevel)
-		case "info":
-			fmt.Printf("\nSetting log level to *info*\n\n")
-			log.Config.Level.SetLevel(zap.InfoLevel)
-		case "warn":
-			fmt.Printf("\nSetting log level to *warn*\n\n")
-			log.Config.Level.SetLevel(zap.WarnLevel)
-		case "error":
-			fmt.Printf("\nSetting log level to *error*\n\n")
-			log.Config.Level.SetLevel(zap.ErrorLevel)
-		case "panic":
-			fmt.Printf("\nSetting log level to *panic*\n\n")
-			log.Config.Level.SetLevel(zap.PanicLevel)
-		default:
-			return errors.New("invalid log level: must be one of debug, info, warn, error, panic")
+	// set the initial log level
+	if err := setLogLevel(logLevelStr); err != nil {
+		log.P.Fatal("unable to set log level", zap.Error(err), zap.String("got", logLevelStr))
+	}
+
+	if len(fakeTime) > 0 {
+		fake := clock.EnableTimeTravel()
+		if fakeTime != "now" {
+			t, err := time.Parse(time.RFC3339, fakeTime)
+			if err != nil {
+				log.P.Fatal("invalid --fake-time: must be an RFC3339 time or now", zap.Error(err), zap.String("got", fakeTime))
+			}
+
+			fake.Travel(t)
 		}
-		return nil
+
+		log.P.Warn("Time travel is enabled, the server's clock can be moved", zap.Time("now", fake.Now()))
 	}
 
-	// set the initial log level
-	if err := setLog(logLevelStr); err != nil {
-		log.P.Fatal("unable to set log level", zap.Error(err), zap.String("got", logLevelStr))
+	// work out who we are before anything needs it
+	id, err := identity.Parse(identityOpts)
+	if err != nil {
+		log.P.Fatal("invalid device identity", zap.Error(err))
+	}
+
+	log.P.Info("Starting scheduler", zap.String("systemID", id.SystemID), zap.String("roomID", id.RoomID), zap.String("deviceID", id.DeviceID), zap.Strings("rooms", id.Rooms), zap.Bool("multiTenant", id.MultiTenant))
+	handlers.SetIdentity(id)
+
+	// start delivering events
+	if err := handlers.StartEventQueue(context.Background(), eventQueueFile, eventMaxAttempts); err != nil {
+		log.P.Fatal("failed to start event queue", zap.Error(err))
+	}
+
+	handlers.SetHelpCooldown(helpCooldown)
+
+	if watchConfig {
+		schedule.StartWatcher(context.Background(), configCheckpointFile)
+	}
+
+	if err := schedule.SetDefaultLocale(timezone, locale); err != nil {
+		log.P.Fatal("invalid default locale", zap.Error(err))
+	}
+
+	if err := schedule.SetStaticCacheDir(staticCacheDir); err != nil {
+		log.P.Fatal("failed to set up static cache", zap.Error(err))
 	}
 
 	// Setup the Frontend
@@ -62,108 +109,15 @@ func main() {
 		log.P.Fatal("failed to create sub filesystem for web files", zap.Error(err))
 	}
 
-	// build gin server
-	r := gin.New()
+	defaultBg, err := fs.ReadFile(subFS, "assets/bg.png")
+	if err != nil {
+		log.P.Fatal("failed to read default background image", zap.Error(err))
+	}
+
+	handlers.SetDefaultBackground(defaultBg, "image/png")
+
+	r := newRouter(subFS)
 
-	// get/create event
-	r.GET("/:roomID/events", func(c *gin.Context) {
-		log.P.Debug("GET /:roomID/events", zap.String("roomID", c.Param("roomID")))
-		if c.IsAborted() {
-			log.P.Error("Request aborted before processing")
-			c.String(http.StatusInternalServerError, "event retrieval request aborted before processing")
-			return
-		}
-		handlers.GetEvents(c)
-	})
-	r.POST("/:roomID/events", func(c *gin.Context) {
-		logRequestAndStatus(c, "POST /:roomID/events", zap.String("roomID", c.Param("roomID")))
-		if c.IsAborted() {
-			log.P.Error("Request aborted before processing")
-			c.String(http.StatusInternalServerError, "event creation request aborted before processing")
-			return
-		}
-		handlers.CreateEvent(c)
-	})
-
-	// get config for the room
-	r.GET("/config", func(c *gin.Context) {
-		logRequestAndStatus(c, "GET /config")
-		if c.IsAborted() {
-			log.P.Error("Request aborted before processing")
-			c.String(http.StatusInternalServerError, "config request aborted before processing")
-			return
-		}
-		handlers.GetConfig(c)
-	})
-
-	// get background image
-	r.GET("/background", func(c *gin.Context) {
-		logRequestAndStatus(c, "GET /background")
-		if c.IsAborted() {
-			log.P.Error("Request aborted before processing")
-			c.String(http.StatusInternalServerError, "background image request aborted before processing")
-			return
-		}
-		handlers.GetBackgroundImg(c)
-	})
-
-	// get static elements
-	r.GET("/static/:doc", func(c *gin.Context) {
-		logRequestAndStatus(c, "GET /static/:doc", zap.String("doc", c.Param("doc")))
-		if c.IsAborted() {
-			log.P.Error("Request aborted before processing")
-			c.String(http.StatusInternalServerError, "static elements request aborted before processing")
-			return
-		}
-		handlers.GetStaticElements(c)
-	})
-
-	// send help request
-	r.POST("/help", func(c *gin.Context) {
-		logRequestAndStatus(c, "POST /help")
-		if c.IsAborted() {
-			log.P.Error("Request aborted before processing")
-			c.String(http.StatusInternalServerError, "help request aborted before processing")
-			return
-		}
-		handlers.SendHelpRequest(c)
-	})
-
-	// handle load balancer status check
-	r.GET("/status", func(c *gin.Context) {
-		logRequestAndStatus(c, "GET /status")
-		if c.IsAborted() {
-			log.P.Error("Request aborted before processing")
-			c.String(http.StatusInternalServerError, "status check request aborted before processing")
-			return
-		}
-		c.String(http.StatusOK, "healthy")
-	})
-
-	// set the log level
-	r.GET("/log/:level", func(c *gin.Context) {
-		levelStr := c.Param("level")
-		log.P.Info("GET /log/:level", zap.String("level", levelStr))
-		if err := setLog(levelStr); err != nil {
-			log.P.Error("Invalid log level string", zap.String("level", levelStr))
-			c.String(http.StatusBadRequest, "invalid log level: must be one of debug, info, warn, error, panic")
-			return
-		}
-		c.String(http.StatusOK, fmt.Sprintf("Set log level to %s", levelStr))
-	})
-
-	r.StaticFS("/web", http.FS(subFS))
-
-	r.NoRoute(func(c *gin.Context) {
-		if c.Request.URL.Path == "/" {
-			c.Redirect(http.StatusFound, "/web/")
-		} else if len(c.Request.URL.Path) >= 5 && c.Request.URL.Path[:5] == "/web/" {
-			c.FileFromFS("index.html", http.FS(subFS))
-		} else {
-			c.String(http.StatusNotFound, "Not found")
-			log.P.Error("404 Not Found", zap.String("path", c.Request.URL.Path))
-		}
-	})
 	// i'm
 	go handlers.SendWebsocketCount(3 * time.Minute)
 
@@ -174,9 +128,26 @@ func main() {
 	}
 }
 
-// Helper: log request and response status
-func logRequestAndStatus(c *gin.Context, msg string, fields ...zap.Field) {
-	c.Next() // process the handler
-	status := c.Writer.Status()
-	log.P.Info(msg, append(fields, zap.Int("status", status))...)
+// setLogLevel sets the level of logging. levelStr is one of debug, info, warn, error, or panic.
+func setLogLevel(levelStr string) error {
+	switch levelStr {
+	case "debug":
+		fmt.Printf("\nSetting log level to *debug*\n\n")
+		log.Config.Level.SetLevel(zap.DebugLevel)
+	case "info":
+		fmt.Printf("\nSetting log level to *info*\n\n")
+		log.Config.Level.SetLevel(zap.InfoLevel)
+	case "warn":
+		fmt.Printf("\nSetting log level to *warn*\n\n")
+		log.Config.Level.SetLevel(zap.WarnLevel)
+	case "error":
+		fmt.Printf("\nSetting log level to *error*\n\n")
+		log.Config.Level.SetLevel(zap.ErrorLevel)
+	case "panic":
+		fmt.Printf("\nSetting log level to *panic*\n\n")
+		log.Config.Level.SetLevel(zap.PanicLevel)
+	default:
+		return errors.New("invalid log level: must be one of debug, info, warn, error, panic")
+	}
+	return nil
 }
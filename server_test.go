@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/byuoitav/scheduler/calendars"
+	"github.com/byuoitav/scheduler/calendars/calendartest"
+	"github.com/byuoitav/scheduler/handlers"
+	"github.com/byuoitav/scheduler/openapi"
+	"github.com/byuoitav/scheduler/schedule"
+)
+
+// unsortedCalendar returns its events in the order they were added, like some providers do
+type unsortedCalendar []calendars.Event
+
+func (c unsortedCalendar) GetEvents(ctx context.Context) ([]calendars.Event, error) {
+	return append([]calendars.Event{}, c...), nil
+}
+
+func (c unsortedCalendar) CreateEvent(ctx context.Context, event calendars.Event) error {
+	return calendars.ErrReadOnly
+}
+
+// at returns hour o'clock, days from today, in Denver (where rooms are by default)
+func at(days, hour int) time.Time {
+	denver, _ := time.LoadLocation("America/Denver")
+	y, m, d := time.Now().In(denver).AddDate(0, 0, days).Date()
+	return time.Date(y, m, d, hour, 0, 0, 0, denver)
+}
+
+func event(title string, days, hour int) calendars.Event {
+	return calendars.Event{Title: title, StartTime: at(days, hour), EndTime: at(days, hour+1)}
+}
+
+func TestEvents(t *testing.T) {
+	shuffled := unsortedCalendar{event("Lunch", 1, 12), event("Standup", 1, 9), event("Review", 1, 15), event("Planning", 1, 10)}
+
+	runScenarios(t, []scenario{
+		{
+			name:  "SortedWithTitles",
+			rooms: []room{{config: schedule.Config{ID: "ITB-1010", DisplayName: "ITB 1010", DisplayMeetingTitle: true}, calendar: shuffled}},
+			steps: []step{
+				get("/api/v1/rooms/ITB-1010/events", eventsSorted(), titles("Standup", "Planning", "Lunch", "Review"), header("X-Calendar-Source", "primary")),
+			},
+		},
+		{
+			name:  "TitlesHidden",
+			rooms: []room{{config: schedule.Config{ID: "ITB-1010", DisplayName: "ITB 1010"}, calendar: shuffled}},
+			steps: []step{
+				get("/api/v1/rooms/ITB-1010/events", eventsSorted(), eventCount(4), titlesHidden()),
+			},
+		},
+		{
+			name: "RoomTimezone",
+			rooms: []room{{
+				config:   schedule.Config{ID: "TKY-101", DisplayName: "Tokyo 101", Timezone: "Asia/Tokyo"},
+				calendar: calendartest.NewMemory(event("Standup", 1, 9)),
+			}},
+			steps: []step{
+				get("/api/v1/rooms/TKY-101/events", inTimezone("Asia/Tokyo")),
+			},
+		},
+		{
+			name: "OneDay",
+			rooms: []room{{
+				config:   schedule.Config{ID: "ITB-1010", DisplayName: "ITB 1010", DisplayMeetingTitle: true},
+				calendar: calendartest.NewMemory(event("Today", 0, 12), event("Tomorrow", 1, 12), event("Later", 3, 12)),
+			}},
+			steps: []step{
+				get("/api/v1/rooms/ITB-1010/events?day=tomorrow", titles("Tomorrow")),
+				get("/api/v1/rooms/ITB-1010/events", eventCount(3)),
+				{method: http.MethodGet, path: "/api/v1/rooms/ITB-1010/events?day=someday", status: http.StatusBadRequest},
+			},
+		},
+		{
+			name:  "Booking",
+			rooms: []room{{config: schedule.Config{ID: "ITB-1010", DisplayName: "ITB 1010", CanCreateEvents: true, DisplayMeetingTitle: true}}},
+			steps: []step{
+				post("/api/v1/rooms/ITB-1010/events", event("Walk-up", 1, 9), http.StatusOK),
+				get("/api/v1/rooms/ITB-1010/events", titles("Walk-up")),
+			},
+		},
+		{
+			name:  "LocalCalendar",
+			rooms: []room{{config: schedule.Config{ID: "ITB-1010", DisplayName: "ITB 1010", CanCreateEvents: true, CalendarURL: "local://ITB-1010"}}},
+			steps: []step{
+				get("/api/v1/rooms/ITB-1010/events", eventCount(0)),
+				post("/api/v1/rooms/ITB-1010/events", event("Walk-up", 1, 9), http.StatusOK),
+				post("/api/v1/rooms/ITB-1010/events", event("Double booked", 1, 9), http.StatusConflict),
+				get("/api/v1/rooms/ITB-1010/events", eventCount(1)),
+			},
+		},
+		{
+			name:  "FakeCalendarFaults",
+			rooms: []room{{config: schedule.Config{ID: "FAKE-1", DisplayName: "Fake 1", CalendarURL: "inproc://fake/FAKE-1"}}},
+			steps: []step{
+				get("/api/v1/rooms/FAKE-1/events", eventsSorted()),
+				put("/admin/backends/fake/faults/FAKE-1", map[string]string{"error": "provider is down"}, http.StatusOK),
+				{method: http.MethodGet, path: "/api/v1/rooms/FAKE-1/events", status: http.StatusInternalServerError, expect: []expectation{bodyContains("provider is down")}},
+				{method: http.MethodDelete, path: "/admin/backends/fake/faults/FAKE-1", status: http.StatusOK},
+				get("/api/v1/rooms/FAKE-1/events", eventsSorted()),
+			},
+		},
+	})
+}
+
+func TestConfig(t *testing.T) {
+	runScenarios(t, []scenario{
+		{
+			name: "PanelConfig",
+			rooms: []room{{config: schedule.Config{
+				ID:              "ITB-1010",
+				DisplayName:     "ITB 1010",
+				CanCreateEvents: true,
+				HelpNotifiers:   []schedule.NotifierConfig{{Type: "webhook", URL: "http://example.com/secret"}},
+			}}},
+			steps: []step{
+				get("/api/v1/rooms/ITB-1010/config",
+					configField("displayName", "ITB 1010"),
+					configField("canCreateEvents", true),
+					configField("capabilities", calendars.AllCapabilities),
+					configField("helpNotifiers", nil),
+				),
+				get("/api/v1/panel/config?room=ITB-1010", configField("_id", "ITB-1010")),
+			},
+		},
+		{
+			name:  "ReadOnlyCalendar",
+			rooms: []room{{config: schedule.Config{ID: "ITB-1010", DisplayName: "ITB 1010", CanCreateEvents: true}, calendar: &calendars.ICSCalendar{URL: "http://127.0.0.1:1/room.ics"}}},
+			steps: []step{
+				get("/api/v1/rooms/ITB-1010/config", configField("canCreateEvents", false)),
+			},
+		},
+		{
+			name: "AdminCreate",
+			steps: []step{
+				post("/admin/rooms", schedule.Config{ID: "ITB-1010", DisplayName: "ITB 1010", CalendarURL: "local://ITB-1010"}, http.StatusCreated),
+				get("/api/v1/rooms/ITB-1010/config", configField("calendarURL", "local://ITB-1010")),
+				post("/admin/rooms", schedule.Config{ID: "ITB-1011"}, http.StatusUnprocessableEntity, bodyContains("displayName is required")),
+			},
+		},
+	})
+}
+
+func TestStaticFiles(t *testing.T) {
+	runScenarios(t, []scenario{
+		{
+			name:   "BackgroundAndStatic",
+			rooms:  []room{{config: schedule.Config{ID: "ITB-1010", DisplayName: "ITB 1010"}, background: []byte("room background")}},
+			static: map[string]string{"logo.svg": "<svg/>"},
+			steps: []step{
+				get("/api/v1/rooms/ITB-1010/background", bodyContains("room background"), header("Content-Type", "image/png")),
+				get("/api/v1/static/logo.svg", bodyContains("<svg/>")),
+				get("/api/v1/static", bodyContains(`"logo.svg"`)),
+				{method: http.MethodGet, path: "/api/v1/static/missing.css", status: http.StatusNotFound},
+			},
+		},
+	})
+}
+
+func TestLegacyRoutes(t *testing.T) {
+	runScenarios(t, []scenario{
+		{
+			name:  "Shims",
+			rooms: []room{{config: schedule.Config{ID: "ITB-1010", DisplayName: "ITB 1010", CanCreateEvents: true}, background: []byte("room background")}},
+			steps: []step{
+				get("/ITB-1010/events", eventCount(0), header("Deprecation", "true"), header("Link", `</api/v1/rooms/ITB-1010/events>; rel="successor-version"`)),
+				post("/ITB-1010/events", event("Walk-up", 1, 9), http.StatusOK, header("Deprecation", "true")),
+				get("/api/v1/rooms/ITB-1010/events", eventCount(1), header("Deprecation", "")),
+				get("/config?room=ITB-1010", configField("_id", "ITB-1010"), header("Link", `</api/v1/panel/config>; rel="successor-version"`)),
+				get("/ITB-1010/background/manifest", bodyContains(`"current":"/api/v1/rooms/ITB-1010/background?image=`)),
+			},
+		},
+	})
+}
+
+func TestClock(t *testing.T) {
+	denver, _ := time.LoadLocation("America/Denver")
+	midnight := time.Date(2030, time.March, 9, 23, 59, 0, 0, denver) // the night clocks spring forward
+
+	cal := calendartest.NewMemory(
+		calendars.Event{Title: "Saturday", StartTime: midnight.Add(-2 * time.Hour), EndTime: midnight.Add(-time.Hour)},
+		calendars.Event{Title: "Sunday", StartTime: time.Date(2030, time.March, 10, 9, 0, 0, 0, denver), EndTime: time.Date(2030, time.March, 10, 10, 0, 0, 0, denver)},
+	)
+
+	runScenarios(t, []scenario{
+		{
+			name:       "AcrossMidnightAndDST",
+			timeTravel: true,
+			rooms:      []room{{config: schedule.Config{ID: "ITB-1010", DisplayName: "ITB 1010", DisplayMeetingTitle: true}, calendar: cal}},
+			steps: []step{
+				put("/admin/clock", map[string]interface{}{"time": midnight, "frozen": true}, http.StatusOK, configField("frozen", true)),
+				get("/api/v1/rooms/ITB-1010/events?day=today", titles("Saturday"), header("X-Server-Time", midnight.Format(time.RFC3339Nano))),
+				get("/api/v1/rooms/ITB-1010/locale", configField("utcOffset", -7*60*60)),
+				put("/admin/clock", map[string]string{"shift": "2m"}, http.StatusOK),
+				get("/api/v1/rooms/ITB-1010/events?day=today", titles("Sunday")),
+				put("/admin/clock", map[string]string{"shift": "3h"}, http.StatusOK),
+				get("/api/v1/rooms/ITB-1010/locale", configField("utcOffset", -6*60*60)),
+			},
+		},
+		{
+			name: "Disabled",
+			steps: []step{
+				put("/admin/clock", map[string]string{"shift": "1h"}, http.StatusForbidden, bodyContains("--fake-time")),
+				get("/admin/clock", configField("fake", false)),
+			},
+		},
+	})
+}
+
+func TestOpenAPI(t *testing.T) {
+	r := newRouter(fstest.MapFS{})
+
+	// every route is described, and everything described is a route
+	routes := make(map[string]bool)
+	for _, route := range r.Routes() {
+		example := ginParam.ReplaceAllString(route.Path, "x")
+		routes[route.Method+" "+ginParam.ReplaceAllString(route.Path, "{}")] = true
+
+		if _, _, ok := handlers.API.Find(route.Method, example); !ok {
+			t.Errorf("%s %s isn't in the openapi document", route.Method, route.Path)
+		}
+	}
+
+	for _, op := range handlers.API.Operations() {
+		path := strings.ReplaceAll(op[1], "{backend}", "fake")
+		if !routes[op[0]+" "+specParam.ReplaceAllString(path, "{}")] {
+			t.Errorf("%s %s is in the openapi document, but isn't a route", op[0], op[1])
+		}
+	}
+
+	runScenarios(t, []scenario{
+		{
+			name:  "Requests",
+			rooms: []room{{config: schedule.Config{ID: "ITB-1010", DisplayName: "ITB 1010", CanCreateEvents: true}}},
+			steps: []step{
+				get("/openapi.json", configField("openapi", openapi.Version)),
+				post("/api/v1/rooms/ITB-1010/events", map[string]string{"title": "Walk-up", "startTime": "9am"}, http.StatusBadRequest,
+					bodyContains("body.endTime: is required"), bodyContains("body.startTime: must be an RFC3339 date-time")),
+				{method: http.MethodGet, path: "/api/v1/rooms/ITB-1010/events?day=someday", status: http.StatusBadRequest, expect: []expectation{bodyContains("query.day")}},
+				put("/admin/clock", map[string]interface{}{"frozen": "yes"}, http.StatusBadRequest, bodyContains("body.frozen: must be true or false")),
+			},
+		},
+	})
+
+	t.Run("UnauthorizedAdmin", func(t *testing.T) {
+		h := newHarness(t)
+
+		// admin requests are authorized before they're validated, and the event queue's data is admin only
+		for _, req := range []*http.Request{
+			httptest.NewRequest(http.MethodPut, "/admin/rooms/ITB-1010?checkURLs=maybe", strings.NewReader("{}")),
+			httptest.NewRequest(http.MethodGet, "/admin/queue", nil),
+		} {
+			w := httptest.NewRecorder()
+			h.router.ServeHTTP(w, req)
+
+			if w.Code != http.StatusUnauthorized {
+				t.Errorf("%s %s: got status %d, want %d. body: %s", req.Method, req.URL, w.Code, http.StatusUnauthorized, w.Body)
+			}
+		}
+	})
+}
+
+var (
+	ginParam  = regexp.MustCompile(`[:*][^/]+`)
+	specParam = regexp.MustCompile(`\{[^}/]+\}`)
+)
@@ -41,18 +41,18 @@ window.components.book = {
         const header = document.querySelector('.header');
         const title = document.createElement('h1');
         title.classList.add('book-title');
-        const { dayOfWeek, dayOfMonth, monthName, roomName } = this.getDateRoomInfo();
-        title.textContent = `Booking ${roomName} for ${dayOfWeek}, ${monthName} ${dayOfMonth}`;
+        const { date, roomName } = this.getDateRoomInfo();
+        title.textContent = t("bookingTitle", { room: roomName, date });
         header.appendChild(title);
     },
 
     addNavButtons() {
         const footer = document.querySelector('.footer');
         if (window.dataService.status.displayHelp) {
-            footer.appendChild(this.createFooterButton('Help', 'assets/help.svg', 40, 40, showHelp));
+            footer.appendChild(this.createFooterButton(t('help'), 'assets/help.svg', 40, 40, showHelp));
         }
-        footer.appendChild(this.createFooterButton('Cancel', 'assets/cancel.svg', 32, 32, () => loadComponent('home')));
-        footer.appendChild(this.createFooterButton('Save', 'assets/save.svg', 32, 32, null, 'save-button'));
+        footer.appendChild(this.createFooterButton(t('cancel'), 'assets/cancel.svg', 32, 32, () => loadComponent('home')));
+        footer.appendChild(this.createFooterButton(t('save'), 'assets/save.svg', 32, 32, null, 'save-button'));
         const saveBtn = footer.querySelector('.save-button');
         saveBtn.disabled = true;
         saveBtn.classList.add('disabled');
@@ -209,7 +209,7 @@ window.components.book = {
         const endTime = endSelect.value;
 
         if (!title || !startTime || !endTime) {
-            alert("Please fill in all fields.");
+            alert(t("fillAllFields"));
             return;
         }
 
@@ -217,7 +217,7 @@ window.components.book = {
         const endDate = this.parseTimeToDate(endTime);
 
         if (startDate >= endDate) {
-            alert("End time must be after start time.");
+            alert(t("endBeforeStart"));
             return;
         }
 
@@ -237,21 +237,21 @@ window.components.book = {
                 symbol.src = 'assets/check.png';
                 symbol.width = 60;
                 symbol.height = 60;
-                confirmationText.textContent = 'Event Submitted Successfully.';
+                confirmationText.textContent = t('eventSubmitted');
                 window.dataService.getScheduleData();
             })
             .catch(() => {
                 symbol.src = 'assets/x.png';
                 symbol.width = 60;
                 symbol.height = 60;
-                confirmationText.textContent = 'Failed to Submit Event.';
+                confirmationText.textContent = t('eventFailed');
             })
             .finally(() => {
                 spinner.style.display = 'none';
 
                 setTimeout(() => {
                     overlay.style.display = 'none';
-                    if (confirmationText.textContent === 'Event Submitted Successfully.') {
+                    if (confirmationText.textContent === t('eventSubmitted')) {
                         loadComponent('home');
                     }
                 }, 2000);
@@ -305,11 +305,8 @@ window.components.book = {
     },
 
     getDateRoomInfo() {
-        const now = new Date();
         return {
-            dayOfWeek: now.toLocaleDateString('en-US', { weekday: 'long' }),
-            dayOfMonth: now.getDate(),
-            monthName: now.toLocaleDateString('en-US', { month: 'short' }),
+            date: window.dataService.formatDate(window.dataService.now(), { weekday: 'long', month: 'short', day: 'numeric' }),
             roomName: window.dataService.status.roomName
         };
     },
@@ -400,7 +397,7 @@ window.components.book = {
             const suffix = h >= 12 ? 'PM' : 'AM';
             return `${hour12}:${pad(m)} ${suffix}`;
         };
-        const now = new Date();
+        const now = window.dataService.now();
         let hour = now.getHours();
         let minute = now.getMinutes();
         if (minute >= 30) { hour += 1; minute = 0; } else { minute = 30; }
@@ -441,7 +438,7 @@ window.components.book = {
 
             const option = document.createElement('option');
             option.value = time;
-            const textContent = blocked ? `${time} (In Use)` : time;
+            const textContent = blocked ? `${time} (${t("inUseEvent")})` : time;
             blocked = blocked || !isAfterStart || !isWithin2Hours || !isBeforeNextMeeting;
             select.addOption(textContent, time, blocked);
         }
@@ -462,7 +459,7 @@ window.components.book = {
         if (meridian === "PM" && hour !== 12) hour += 12;
         if (meridian === "AM" && hour === 12) hour = 0;
 
-        const now = new Date();
+        const now = window.dataService.now();
         const localDate = new Date(
             now.getFullYear(),
             now.getMonth(),
@@ -498,7 +495,7 @@ window.components.book = {
 
     // Parse time strings into local Date objects with today's date
     parseTimeToDate(timeStr) {
-        const today = new Date();
+        const today = window.dataService.now();
         const [time, modifier] = timeStr.split(' ');
         let [hours, minutes] = time.split(':').map(Number);
         if (modifier === 'PM' && hours < 12) hours += 12;
@@ -24,6 +24,11 @@ window.components.home = {
         }
     },
 
+    refreshNavButtons: function () {
+        document.querySelectorAll('.footer button').forEach(button => button.remove());
+        this.addNavButtons();
+    },
+
     addNavButtons: function () {
         console.log("Adding nav buttons");
         const footer = document.querySelector('.footer');
@@ -37,7 +42,7 @@ window.components.home = {
             helpImg.width = 40;
             helpImg.height = 40;
             helpButton.appendChild(helpImg);
-            helpButton.appendChild(document.createTextNode("Help"));
+            helpButton.appendChild(document.createTextNode(t("help")));
             footer.appendChild(helpButton);
 
         }
@@ -49,7 +54,7 @@ window.components.home = {
             bookImg.width = 32;
             bookImg.height = 32;
             bookButton.appendChild(bookImg);
-            bookButton.appendChild(document.createTextNode("Book Now"));
+            bookButton.appendChild(document.createTextNode(t("bookNow")));
             footer.appendChild(bookButton);
             bookButton.addEventListener('click', () => {
                 console.log("Book button clicked");
@@ -64,7 +69,7 @@ window.components.home = {
             scheduleImg.width = 40;
             scheduleImg.height = 40;
             scheduleButton.appendChild(scheduleImg);
-            scheduleButton.appendChild(document.createTextNode("View Schedule"));
+            scheduleButton.appendChild(document.createTextNode(t("viewSchedule")));
             footer.appendChild(scheduleButton);
             scheduleButton.addEventListener('click', () => {
                 console.log("Schedule button clicked");
@@ -85,7 +90,7 @@ window.components.home = {
 
         // if occupied
         if (!unoccupied) {
-            roomStatus.innerText = "IN USE";
+            roomStatus.innerText = t("inUse");
             roomStatus.classList.add('occupied');
             roomStatus.classList.remove('unoccupied');
             // Show the meeting name if configured to do so
@@ -95,7 +100,7 @@ window.components.home = {
                 meetingName.innerText = "";
             }
         } else {
-            roomStatus.innerText = "AVAILABLE";
+            roomStatus.innerText = t("available");
             meetingName.innerText = "";
             roomStatus.classList.add('unoccupied');
             roomStatus.classList.remove('occupied');
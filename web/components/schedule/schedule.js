@@ -92,7 +92,7 @@ window.components.schedule = {
         const header = document.querySelector('.header');
         const title = document.createElement('h1');
         title.classList.add('schedule-title');
-        title.textContent = "Schedule";
+        title.textContent = t("schedule");
         header.appendChild(title);
     },
 
@@ -108,7 +108,7 @@ window.components.schedule = {
             helpImg.width = 40;
             helpImg.height = 40;
             helpButton.appendChild(helpImg);
-            helpButton.appendChild(document.createTextNode("Help"));
+            helpButton.appendChild(document.createTextNode(t("help")));
             footer.appendChild(helpButton);
 
         }
@@ -122,30 +122,13 @@ window.components.schedule = {
         }
 
         var schedule = window.dataService.getSchedule();
+        // the server only sends today's events, in the room's timezone
         schedule = this.sortEvents(schedule);
 
-        // Get current local date (America/Denver)
-        const now = new Date();
-        const localYear = now.getFullYear();
-        const localMonth = now.getMonth();
-        const localDate = now.getDate();
-
-        // Only keep events that are on the same local day
-        schedule = schedule.filter(event => {
-            // Convert event start time to local (America/Denver)
-            const eventStart = new Date(event.startTime);
-            const eventLocal = new Date(eventStart.toLocaleString('en-US', { timeZone: 'America/Denver' }));
-            return (
-                eventLocal.getFullYear() === localYear &&
-                eventLocal.getMonth() === localMonth &&
-                eventLocal.getDate() === localDate
-            );
-        });
-
         if (!schedule || schedule.length === 0) {
             console.warn("Schedule is empty");
             const emptyMessage = document.createElement('p');
-            emptyMessage.textContent = "No events scheduled.";
+            emptyMessage.textContent = t("noEvents");
             eventList.appendChild(emptyMessage);
             return;
         }
@@ -164,7 +147,7 @@ window.components.schedule = {
             if (window.dataService.status.displayTitle) {
                 name.textContent = event.title;
             } else {
-                name.textContent = "In Use";
+                name.textContent = t("inUseEvent");
             }
             scheduleItemHeader.appendChild(name);
 
@@ -178,14 +161,14 @@ window.components.schedule = {
             const endTime = new Date(event.endTime);
             const hours = this.calculateHours(event.startTime, event.endTime);
             const minutes = this.calculateMinutes(event.startTime, event.endTime);
-            scheduleItemLength.textContent = `${hours} Hours ${minutes} minutes`;
+            scheduleItemLength.textContent = t("duration", { hours, minutes });
             scheduleItemContent.appendChild(scheduleItemLength);
 
             // start time and end time (11:42 AM - 12:14 PM)
             const timeElement = document.createElement('p');
             timeElement.classList.add('schedule-item-times');
-            const startTimeFormatted = startTime.toLocaleTimeString([], { hour: '2-digit', minute: '2-digit', hour12: true });
-            const endTimeFormatted = endTime.toLocaleTimeString([], { hour: '2-digit', minute: '2-digit', hour12: true });
+            const startTimeFormatted = window.dataService.formatTime(startTime, { hour: '2-digit' });
+            const endTimeFormatted = window.dataService.formatTime(endTime, { hour: '2-digit' });
             timeElement.textContent = `${startTimeFormatted} - ${endTimeFormatted}`;
             scheduleItemContent.appendChild(timeElement);
 
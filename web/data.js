@@ -51,7 +51,9 @@ class RoomStatus {
 
 /**
  * @typedef {Object} HelpRequestParams
- * @property {string} roomId
+ * @property {string} deviceID
+ * @property {string} [category]
+ * @property {string} [message]
  */
 
 class OutPutEvent {
@@ -97,11 +99,18 @@ class HelpRequest {
      * @param {HelpRequestParams} params
      */
     constructor(params) {
-        this.roomId = params?.roomId ?? "";
+        this.deviceID = params?.deviceID ?? "";
+        this.category = params?.category ?? "general";
+        this.message = params?.message ?? "";
     }
 
-    setRoomId(roomId) { this.roomId = roomId; }
-    getRoomId() { return this.roomId; }
+    setDeviceID(deviceID) { this.deviceID = deviceID; }
+    setCategory(category) { this.category = category; }
+    setMessage(message) { this.message = message; }
+
+    getDeviceID() { return this.deviceID; }
+    getCategory() { return this.category; }
+    getMessage() { return this.message; }
 }
 
 class DataService {
@@ -109,6 +118,16 @@ class DataService {
         const base = location.origin.split(":");
         this.url = base[0] + ":" + base[1];
         this.port = base[2] ?? "80";
+        this.api = this.url + ":" + this.port + "/api/v1";
+
+        // panels pointed at a shared scheduler say which room they are with ?room= or ?device=
+        const params = new URLSearchParams(location.search);
+        const panel = new URLSearchParams();
+        for (const key of ["room", "device"]) {
+            const value = params.get(key);
+            if (value) panel.set(key, value);
+        }
+        this.panelQuery = panel.toString() ? "?" + panel.toString() : "";
 
         this.status = new RoomStatus({
             roomName: "",
@@ -122,23 +141,44 @@ class DataService {
 
         this.currentSchedule = [];
         this.config = {};
+
+        // how the room shows dates and times, until the server says otherwise
+        this.locale = { timezone: undefined, locale: "en-US", clock: "12h" };
+        this.strings = {};
+
+        // how far the server's clock is ahead of this one, in ms. it's only off when the server's clock has been moved for a demo.
+        this.clockSkew = 0;
+    }
+
+    // now returns the current time by the server's clock
+    now() {
+        return new Date(Date.now() + this.clockSkew);
+    }
+
+    // syncClock keeps now() in step with the time a response was sent at
+    syncClock(res) {
+        const serverTime = Date.parse(res.headers.get("X-Server-Time") ?? "");
+        if (!isNaN(serverTime)) this.clockSkew = serverTime - Date.now();
     }
 
     async init() {
         await this.getConfig();
+        await this.getLocale();
+        await this.getTranslations();
         await this.getScheduleData();
         this.getCurrentEvent();
+        this.watchConfig();
 
         // Update every minute on the minute
         const updateOnMinute = () => {
             this.getScheduleData();
             // Schedule next update at the next minute boundary
-            const now = new Date();
+            const now = this.now();
             const msToNextMinute = 60000 - (now.getSeconds() * 1000 + now.getMilliseconds());
             setTimeout(updateOnMinute, msToNextMinute);
         };
         // Start the first update at the next minute boundary
-        const now = new Date();
+        const now = this.now();
         const msToNextMinute = 60000 - (now.getSeconds() * 1000 + now.getMilliseconds());
         setTimeout(updateOnMinute, msToNextMinute);
     }
@@ -147,7 +187,7 @@ class DataService {
     getSchedule() { return this.currentSchedule; }
 
     getCurrentEvent() {
-        const time = new Date();
+        const time = this.now();
 
         if (!this.status.emptySchedule) {
             for (const event of this.currentSchedule) {
@@ -194,7 +234,7 @@ class DataService {
 
     async getConfig() {
         console.log("Getting config...");
-        const res = await this.safeFetch(this.url + ":" + this.port + "/config", {}, "getting config from couchdb");
+        const res = await this.safeFetch(this.api + "/panel/config" + this.panelQuery, {}, "getting config from couchdb");
         if (!res) return;
         const data = await res.json();
         this.config = data;
@@ -206,8 +246,65 @@ class DataService {
         this.status.setDisplayHelp(this.config["canRequestHelp"] ?? true);
     }
 
-    async getBgImage() {
-        return fetch(this.url + ":" + this.port + "/background")
+    async getLocale() {
+        const res = await this.safeFetch(this.api + "/panel/locale" + this.panelQuery, {}, "getting the room's locale");
+        if (!res) return;
+        this.locale = await res.json();
+        this.syncClock(res);
+        console.log("locale", this.locale);
+    }
+
+    async getTranslations() {
+        const res = await this.safeFetch(this.api + "/translations" + this.panelQuery, {}, "getting translations");
+        if (!res) return;
+        const data = await res.json();
+        this.strings = data.strings ?? {};
+        document.documentElement.lang = data.language ?? "en";
+    }
+
+    // format a time like 2:30 PM or 14:30, in the room's timezone
+    formatTime(date, options = {}) {
+        return new Date(date).toLocaleTimeString(this.locale.locale, {
+            timeZone: this.locale.timezone,
+            hour12: this.locale.clock === "12h",
+            hour: "numeric",
+            minute: "2-digit",
+            ...options
+        });
+    }
+
+    // format a date in the room's timezone
+    formatDate(date, options) {
+        return new Date(date).toLocaleDateString(this.locale.locale, { timeZone: this.locale.timezone, ...options });
+    }
+
+    // reload the panel whenever its config is changed in couch
+    watchConfig() {
+        if (!window.EventSource) return;
+
+        const source = new EventSource(this.api + "/panel/config/updates" + this.panelQuery);
+        source.addEventListener("reload", (e) => {
+            console.log("Config changed, reloading", e.data);
+            location.reload();
+        });
+    }
+
+    // which background should be showing, and when it changes
+    async getBgManifest() {
+        return fetch(this.api + "/panel/background/manifest" + this.panelQuery)
+            .then((res) => {
+                if (!res.ok) {
+                    throw new Error(`Server responded with status ${res.status}`);
+                }
+                return res.json();
+            })
+            .catch((err) => {
+                console.error("failed to get background manifest", err);
+            });
+    }
+
+    async getBgImage(path) {
+        return fetch(this.url + ":" + this.port + path)
             .then((res) => {
                 if (!res.ok) {
                     throw new Error(`Server responded with status ${res.status}`);
@@ -221,10 +318,21 @@ class DataService {
 
 
     async getScheduleData() {
-        const url = this.url + ":" + this.port + "/" + this.status.deviceName + "/events";
+        // the server works out which events are today in the room's timezone
+        const url = this.api + "/rooms/" + encodeURIComponent(this.status.deviceName) + "/events?day=today";
         const res = await this.safeFetch(url, {}, "getting schedule data");
         if (!res) return;
         const data = await res.json();
+        this.syncClock(res);
+
+        // booking is turned off while the room's events come from a read-only fallback calendar
+        const fallback = res.headers.get("X-Calendar-Fallback") === "true";
+        const bookNow = (this.config["canCreateEvents"] ?? true) && !fallback;
+        if (bookNow !== this.status.getDisplayBookNow()) {
+            this.status.setDisplayBookNow(bookNow);
+            // @ts-ignore
+            if (window.currentComponent === "home") window.components.home.refreshNavButtons();
+        }
 
         if (!data || data.length === 0) {
             this.status.setEmptySchedule(true);
@@ -245,7 +353,7 @@ class DataService {
      * @param {ScheduledEvent} event
      */
     async submitNewEvent(event) {
-        const url = this.url + ":" + this.port + "/" + this.status.deviceName + "/events";
+        const url = this.api + "/rooms/" + encodeURIComponent(this.status.deviceName) + "/events";
         console.log("Submitting new event to", url);
 
         const body = new OutPutEvent({
@@ -268,13 +376,15 @@ class DataService {
     }
 
     /**
-     * @param {string} deviceId
+     * The server fills in this panel's device ID when deviceID is left empty.
+     * @param {string} [category]
+     * @param {string} [message]
      */
-    async sendHelpRequest(deviceId) {
-        const url = this.url + ":" + this.port + "/help";
+    async sendHelpRequest(category = "general", message = "") {
+        const url = this.api + "/panel/help" + this.panelQuery;
         console.log("Sending help request");
 
-        const body = new HelpRequest({ roomId: deviceId });
+        const body = new HelpRequest({ deviceID: "", category: category, message: message });
 
         const res = await this.safeFetch(
             url,
@@ -288,4 +398,26 @@ class DataService {
         if (!res) return null;
         return await res.json();
     }
+
+    /**
+     * @param {string} id
+     */
+    async getHelpRequest(id) {
+        const url = this.api + "/help/" + encodeURIComponent(id);
+        const res = await this.safeFetch(url, {}, "getting help request status");
+        if (!res) return null;
+        return await res.json();
+    }
+
+    /**
+     * @param {string} id
+     */
+    async cancelHelpRequest(id) {
+        const url = this.api + "/help/" + encodeURIComponent(id) + "/cancel";
+        console.log("Cancelling help request", id);
+
+        const res = await this.safeFetch(url, { method: "POST" }, "cancelling help request");
+        if (!res) return null;
+        return await res.json();
+    }
 }
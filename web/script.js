@@ -6,6 +6,8 @@ document.addEventListener('DOMContentLoaded', async () => {
         console.log("Data service created");
         console.log(window.dataService);
 
+        translatePage();
+
         currentComponent = 'home';
         await loadComponent(currentComponent);
         await loadBgImage();
@@ -54,6 +56,7 @@ async function loadComponent(name) {
     const response = await fetch(htmlPath);
     const html = await response.text();
     componentContainer.innerHTML = html;
+    translatePage(componentContainer);
 
     // load the js
     const oldScript = document.getElementById('component-script');
@@ -77,19 +80,34 @@ async function loadComponent(name) {
     document.body.appendChild(script);
 }
 
+let bgURL;
+let bgTimer;
+
 async function loadBgImage() {
+    clearTimeout(bgTimer);
+
     const html = document.querySelector('html');
-    const image = await window.dataService.getBgImage();
+    const manifest = await window.dataService.getBgManifest();
+    const image = manifest && await window.dataService.getBgImage(manifest.current);
     if (image) {
         const url = URL.createObjectURL(image);
         html.style.backgroundImage = `url(${url})`;
         html.style.backgroundSize = 'cover';
+
+        if (bgURL) URL.revokeObjectURL(bgURL);
+        bgURL = url;
     }
-    else {
+    else if (!bgURL) {
         console.warn("No background image found, using default.");
         html.style.backgroundImage = 'url(assets/bg.png)';
         html.style.backgroundSize = 'cover';
     }
+
+    // come back when the next background in the rotation should be showing
+    if (manifest?.next) {
+        const wait = Math.max(new Date(manifest.next) - window.dataService.now(), 1000);
+        bgTimer = setTimeout(loadBgImage, wait);
+    }
 }
 
 
@@ -102,21 +120,46 @@ function updateUI() {
 function updateDateTime() {
     const timeElement = document.querySelector('.time-text');
     const dateElement = document.querySelector('.date-text');
+    const dataService = window.dataService;
 
-    const now = new Date();
+    const now = dataService.now();
 
-    // Format time as h:mm AM/PM
-    let hours = now.getHours();
-    const minutes = now.getMinutes().toString().padStart(2, '0');
-    const ampm = hours >= 12 ? 'PM' : 'AM';
-    hours = hours % 12;
-    hours = hours ? hours : 12; // the hour '0' should be '12'
-
-    timeElement.textContent = `${hours}:${minutes}`;
+    // Format time as h:mm (or HH:mm) in the room's timezone, without AM/PM
+    const parts = new Intl.DateTimeFormat(dataService.locale.locale, {
+        timeZone: dataService.locale.timezone,
+        hour12: dataService.locale.clock === "12h",
+        hour: 'numeric',
+        minute: '2-digit'
+    }).formatToParts(now);
+    timeElement.textContent = parts.filter(p => p.type !== 'dayPeriod').map(p => p.value).join('').trim();
 
     // Format date like "Thursday, May 22"
     const options = { weekday: 'long', month: 'short', day: 'numeric' };
-    dateElement.textContent = now.toLocaleDateString('en-US', options);
+    dateElement.textContent = dataService.formatDate(now, options);
+}
+
+// translate a ui string, filling in {placeholders} from params
+function t(key, params = {}) {
+    let text = window.dataService?.strings?.[key] ?? key;
+    for (const [name, value] of Object.entries(params)) {
+        text = text.replaceAll(`{${name}}`, value);
+    }
+    return text;
+}
+
+// translate elements marked with data-i18n, data-i18n-html (markup from the catalog), or data-i18n-placeholder.
+// elements keep their text if there's no translation for them
+function translatePage(root = document) {
+    const strings = window.dataService?.strings ?? {};
+    root.querySelectorAll('[data-i18n]').forEach(el => {
+        if (strings[el.dataset.i18n]) el.textContent = strings[el.dataset.i18n];
+    });
+    root.querySelectorAll('[data-i18n-html]').forEach(el => {
+        if (strings[el.dataset.i18nHtml]) el.innerHTML = strings[el.dataset.i18nHtml];
+    });
+    root.querySelectorAll('[data-i18n-placeholder]').forEach(el => {
+        if (strings[el.dataset.i18nPlaceholder]) el.placeholder = strings[el.dataset.i18nPlaceholder];
+    });
 }
 
 function updateHeaderColor() {
@@ -141,7 +184,7 @@ function showHelp() {
 function showError() {
     const errorContainer = document.querySelector('.error-modal');
     const errorMessage = document.querySelector('.error-message');
-    errorMessage.textContent = window.schedulerError.message || "An unknown error occurred.";
+    errorMessage.textContent = window.schedulerError.message || t("unknownError");
     errorContainer.classList.remove('hidden');
 }
 
@@ -153,6 +196,8 @@ function closeHelp() {
         console.warn("Help container not found");
     }
 
+    clearInterval(helpStatusInterval);
+
     const getHelp = document.querySelector('.get-help');
 
     getHelp.classList.remove('hidden');
@@ -171,8 +216,7 @@ function closeError() {
 }
 
 async function requestHelp() {
-    const roomId = window.dataService.config._id;
-    console.log("Requesting help for room ID:", roomId);
+    console.log("Requesting help for room ID:", window.dataService.config._id);
 
     const getHelp = document.querySelector('.get-help');
     const helpConfirmation = document.querySelector('.help-confirmation');
@@ -180,22 +224,67 @@ async function requestHelp() {
     helpConfirmation.classList.remove('hidden');
     closeConfirmationButton = document.querySelector('.close-confirmation-button');
     closeConfirmationButton.classList.add('hidden');
+    const cancelRequestButton = document.querySelector('.cancel-request-button');
+    cancelRequestButton.classList.add('hidden');
 
     getHelp.classList.add('hidden');
 
     // Show spinner while waiting
-    helpMessage.innerHTML = `<span class="spinner"></span>Sending help request...`;
-
-    try {
-        const res = await window.dataService.sendHelpRequest(roomId);
-        console.log("Help request sent:", res);
-        closeConfirmationButton.classList.remove('hidden');
-        helpMessage.textContent = "Your help request has been received; a member of our support staff is on their way.";
-    } catch (err) {
-        closeConfirmationButton.classList.remove('hidden');
-        console.error("Help request failed:", err);
-        helpMessage.textContent = "Your help request failed to send; please try again or call AV Support at 801-422-7671.";
+    helpMessage.innerHTML = `<span class="spinner"></span>`;
+    helpMessage.appendChild(document.createTextNode(t("sendingHelp")));
+
+    const res = await window.dataService.sendHelpRequest();
+    console.log("Help request sent:", res);
+    closeConfirmationButton.classList.remove('hidden');
+
+    if (!res) {
+        helpMessage.textContent = t("helpFailed");
+        return;
+    }
+
+    helpRequestId = res.id;
+    cancelRequestButton.classList.remove('hidden');
+    showHelpStatus(res);
+
+    // watch for the support desk to acknowledge the request
+    clearInterval(helpStatusInterval);
+    helpStatusInterval = setInterval(async () => {
+        const status = await window.dataService.getHelpRequest(helpRequestId);
+        if (status) {
+            showHelpStatus(status);
+        }
+    }, 10 * 1000);
+}
+
+let helpRequestId = null;
+let helpStatusInterval = null;
+
+function showHelpStatus(request) {
+    const helpMessage = document.querySelector('.help-message');
+    const cancelRequestButton = document.querySelector('.cancel-request-button');
+
+    switch (request.status) {
+        case "acknowledged":
+            helpMessage.textContent = request.response || t("helpAcknowledged");
+            break;
+        case "cancelled":
+            clearInterval(helpStatusInterval);
+            cancelRequestButton.classList.add('hidden');
+            helpMessage.textContent = t("helpCancelled");
+            break;
+        default:
+            helpMessage.textContent = t("helpReceived");
+    }
+}
+
+async function cancelHelpRequest() {
+    if (!helpRequestId) {
+        return;
+    }
 
+    const res = await window.dataService.cancelHelpRequest(helpRequestId);
+    if (res) {
+        showHelpStatus(res);
     }
 }
 